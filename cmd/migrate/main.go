@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/alexandreffaria/hoby-loop/config"
+	"github.com/alexandreffaria/hoby-loop/internal/database"
+	"github.com/alexandreffaria/hoby-loop/internal/database/migrations"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+const usage = `Usage: migrate [config flags] <command>
+
+Commands:
+  up            Apply every pending migration
+  down [n]      Roll back the last n migrations (default 1)
+  status        List migrations and whether they are applied
+  new <name>    Create empty up/down files for a new migration
+`
+
+// migrationsDir is where `new` writes files, relative to the repository root
+const migrationsDir = "internal/database/migrations"
+
+func main() {
+	// Split config flags from the command and its arguments
+	args := os.Args[1:]
+	commandAt := len(args)
+	for i, arg := range args {
+		if arg == "up" || arg == "down" || arg == "status" || arg == "new" {
+			commandAt = i
+			break
+		}
+	}
+	if commandAt == len(args) {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	command, commandArgs := args[commandAt], args[commandAt+1:]
+
+	// `new` only touches the filesystem
+	if command == "new" {
+		if len(commandArgs) != 1 {
+			log.Fatal("Usage: migrate new <name>")
+		}
+		up, down, err := database.NewMigrationFiles(migrationsDir, commandArgs[0])
+		if err != nil {
+			log.Fatal("Failed to create migration: ", err)
+		}
+		fmt.Printf("✅ Created %s\n✅ Created %s\n", up, down)
+		return
+	}
+
+	if _, err := config.Load(args[:commandAt]); err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+
+	db, err := gorm.Open(postgres.Open(config.GetDSN()), &gorm.Config{})
+	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatal("Failed to access database pool: ", err)
+	}
+	defer sqlDB.Close()
+
+	migrator, err := database.NewMigrator(sqlDB, migrations.FS)
+	if err != nil {
+		log.Fatal("Failed to load migrations: ", err)
+	}
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("⬆️  Applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal("Migration failed: ", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("✅ Schema is up to date")
+		}
+
+	case "down":
+		steps := 1
+		if len(commandArgs) > 0 {
+			if steps, err = strconv.Atoi(commandArgs[0]); err != nil || steps < 1 {
+				log.Fatal("down expects a positive number of steps")
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Printf("⬇️  Reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal("Rollback failed: ", err)
+		}
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatal("Failed to read migration status: ", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Modified {
+				state += " (modified since applied!)"
+			}
+			fmt.Printf("%04d_%-40s %s\n", s.Version, s.Name, state)
+		}
+	}
+}
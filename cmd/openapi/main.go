@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/alexandreffaria/hoby-loop/internal/boleto"
+	"github.com/alexandreffaria/hoby-loop/internal/invoices"
+	"github.com/alexandreffaria/hoby-loop/internal/notify"
+	"github.com/alexandreffaria/hoby-loop/internal/payments"
+	"github.com/alexandreffaria/hoby-loop/internal/pix"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/internal/routes"
+	"github.com/alexandreffaria/hoby-loop/internal/webhooks"
+	"github.com/gin-gonic/gin"
+)
+
+const usage = `Usage: openapi [-check] [-version prefix] [-o file]
+
+Prints the OpenAPI document of one version of the API.
+
+  -check            Only compare the registered routes of every version with
+                    their documentation and exit with status 1 when they
+                    differ (use in CI)
+  -version prefix   Version to document, e.g. /v1 (default: the latest)
+  -o file           Write the document to file instead of stdout
+`
+
+func main() {
+	flags := flag.NewFlagSet("openapi", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	check := flags.Bool("check", false, "")
+	versions := routes.APIVersions()
+	version := flags.String("version", versions[len(versions)-1], "")
+	output := flags.String("o", "", "")
+	flags.Parse(os.Args[1:])
+
+	// The routes do not depend on the storage, so in-memory repositories are enough
+	gin.SetMode(gin.ReleaseMode)
+	repos := repository.NewMemoryRepositories()
+	notifier := notify.NewService(repos.Users, repos.Notifications, nil)
+	billing := payments.NewService(payments.NewFakeProvider(""), &pix.Merchant{}, &boleto.Issuer{}, repos)
+	r := routes.SetupRouter(repos, notifier, webhooks.NewSender(repos.Webhooks, 0), billing, invoices.NewService(repos))
+
+	if *check {
+		drift := routes.OpenAPIDrift(r)
+		for _, difference := range drift {
+			fmt.Printf("❌ %s\n", difference)
+		}
+		if len(drift) > 0 {
+			fmt.Println("Update the specs in internal/routes/openapi.go")
+			os.Exit(1)
+		}
+		fmt.Printf("✅ The routes of %v are documented\n", versions)
+		return
+	}
+
+	document, ok := routes.OpenAPI(r, *version)
+	if !ok {
+		log.Fatalf("Unknown API version %q, expected one of %v", *version, versions)
+	}
+	doc, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		log.Fatal("Failed to generate OpenAPI document: ", err)
+	}
+	doc = append(doc, '\n')
+
+	if *output == "" {
+		os.Stdout.Write(doc)
+		return
+	}
+	if err := os.WriteFile(*output, doc, 0o644); err != nil {
+		log.Fatal("Failed to write OpenAPI document: ", err)
+	}
+	fmt.Printf("✅ Wrote %s\n", *output)
+}
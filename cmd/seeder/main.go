@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -8,6 +9,9 @@ import (
 	"path/filepath"
 
 	"github.com/alexandreffaria/hoby-loop/config"
+	"github.com/alexandreffaria/hoby-loop/internal/auth"
+	"github.com/alexandreffaria/hoby-loop/internal/database"
+	"github.com/alexandreffaria/hoby-loop/internal/database/migrations"
 	"github.com/alexandreffaria/hoby-loop/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -40,11 +44,11 @@ type UserJSON struct {
 }
 
 type BasketJSON struct {
-	ID          uint    `json:"id"`
-	SellerID    uint    `json:"seller_id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
+	ID          uint         `json:"id"`
+	SellerID    uint         `json:"seller_id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Price       models.Money `json:"price"` // Decimal string in BRL, e.g. "140.58"
 }
 
 type SubscriptionJSON struct {
@@ -57,30 +61,27 @@ type SubscriptionJSON struct {
 
 func main() {
 	// 1. Connect to database using the configuration
+	if _, err := config.Load(os.Args[1:]); err != nil {
+		log.Fatal("Invalid configuration:", err)
+	}
 	dsn := config.GetDSN()
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
-	// Run Migrations with explicit field specification
+	// Run pending migrations
 	fmt.Println("🔄 Running database migrations...")
-	
-	// First run AutoMigrate to handle standard fields
-	if err := db.AutoMigrate(&models.User{}, &models.Basket{}, &models.Subscription{}, &models.Order{}); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatal("Failed to access database pool:", err)
 	}
-	
-	// Explicitly ensure admin fields are properly added
-	adminFieldsMigrations := []string{
-		"ALTER TABLE users ADD COLUMN IF NOT EXISTS is_active boolean DEFAULT true",
-		"ALTER TABLE users ADD COLUMN IF NOT EXISTS permissions text",
+	migrator, err := database.NewMigrator(sqlDB, migrations.FS)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
 	}
-	
-	for _, migration := range adminFieldsMigrations {
-		if err := db.Exec(migration).Error; err != nil {
-			log.Printf("⚠️ Migration warning: %v", err)
-		}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		log.Fatalf("Failed to run migrations: %v", err)
 	}
 	
 	fmt.Println("✅ Database schema updated successfully")
@@ -103,12 +104,16 @@ func main() {
 		
 		// Users
 		for _, u := range data.Users {
+			passwordHash, err := auth.HashPassword(u.Password)
+			if err != nil {
+				return err
+			}
 			user := models.User{
 				Model:         gorm.Model{ID: u.ID},
 				Name:          u.Name,
 				Email:         u.Email,
 				Role:          u.Role,
-				Password:      u.Password,
+				Password:      passwordHash,
 				CNPJ:          u.CNPJ,
 				CPF:           u.CPF,
 				IsActive:      u.IsActive,
@@ -202,10 +207,11 @@ func main() {
 			if result.Error == nil {
 				// Basket exists, update it
 				if err := tx.Model(&existingBasket).Updates(map[string]interface{}{
-					"name":        basket.Name,
-					"description": basket.Description,
-					"price":       basket.Price,
-					"user_id":     basket.UserID,
+					"name":           basket.Name,
+					"description":    basket.Description,
+					"price_amount":   basket.Price.Amount,
+					"price_currency": basket.Price.Currency,
+					"user_id":        basket.UserID,
 				}).Error; err != nil {
 					return err
 				}
@@ -233,7 +239,7 @@ func main() {
 				UserID:    s.UserID,
 				BasketID:  s.BasketID,
 				Frequency: s.Frequency,
-				Status:    s.Status,
+				Status:    models.SubscriptionStatus(s.Status),
 			}
 			// Check if the subscription exists by ID
 			var existingSub models.Subscription
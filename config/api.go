@@ -0,0 +1,28 @@
+package config
+
+import "time"
+
+// APIConfig holds the configuration of the API versions
+type APIConfig struct {
+	// LegacyRoutes keeps serving the unversioned routes, which mirror /v1,
+	// with Deprecation and Sunset headers
+	LegacyRoutes bool `config:"legacy_routes" env:"HOBY_API_LEGACY_ROUTES" usage:"Serve the deprecated unversioned routes next to /v1"`
+	// LegacyDeprecatedAt is the date (2006-01-02) sent in the Deprecation header
+	LegacyDeprecatedAt string `config:"legacy_deprecated_at" env:"HOBY_API_LEGACY_DEPRECATED_AT" usage:"Date the unversioned routes were deprecated (YYYY-MM-DD)"`
+	// LegacySunset is the date (2006-01-02) sent in the Sunset header, after
+	// which the unversioned routes may be removed. Empty omits the header.
+	LegacySunset string `config:"legacy_sunset" env:"HOBY_API_LEGACY_SUNSET" usage:"Date the unversioned routes stop working (YYYY-MM-DD), empty if not planned"`
+}
+
+// LegacyDates returns the deprecation and sunset dates of the unversioned
+// routes, zero when not set. Validate rejects malformed dates.
+func (c APIConfig) LegacyDates() (deprecatedAt, sunset time.Time) {
+	deprecatedAt, _ = time.Parse(time.DateOnly, c.LegacyDeprecatedAt)
+	sunset, _ = time.Parse(time.DateOnly, c.LegacySunset)
+	return deprecatedAt, sunset
+}
+
+// GetAPIConfig returns the API versioning configuration
+func GetAPIConfig() APIConfig {
+	return Get().API
+}
@@ -0,0 +1,19 @@
+package config
+
+import "time"
+
+// AuthConfig holds the configuration for session tokens
+type AuthConfig struct {
+	TokenSecret     string        `config:"token_secret" env:"HOBY_TOKEN_SECRET" usage:"Secret used to sign access tokens" secret:"true"`
+	TokenIssuer     string        `config:"token_issuer" env:"HOBY_TOKEN_ISSUER" usage:"Issuer claim of access tokens"`
+	AccessTokenTTL  time.Duration `config:"access_token_ttl" env:"HOBY_ACCESS_TOKEN_TTL" usage:"Lifetime of access tokens"`
+	RefreshTokenTTL time.Duration `config:"refresh_token_ttl" env:"HOBY_REFRESH_TOKEN_TTL" usage:"Lifetime of refresh tokens"`
+}
+
+// devTokenSecret is the default secret, rejected when running in production
+const devTokenSecret = "hoby-loop-dev-secret-change-me"
+
+// GetAuthConfig returns the session token configuration
+func GetAuthConfig() AuthConfig {
+	return Get().Auth
+}
@@ -0,0 +1,27 @@
+package config
+
+// BoletoConfig holds the bank account and agreement boletos are issued
+// under, for subscribers with a CNPJ
+type BoletoConfig struct {
+	// Agreement is the 7-digit convênio with the bank. Empty disables boletos.
+	Agreement           string `config:"agreement" env:"HOBY_BOLETO_AGREEMENT" usage:"7-digit bank agreement (convênio) boletos are issued under, empty disables boletos"`
+	BankCode            string `config:"bank_code" env:"HOBY_BOLETO_BANK_CODE" usage:"Bank issuing boletos (001 Banco do Brasil)"`
+	Wallet              string `config:"wallet" env:"HOBY_BOLETO_WALLET" usage:"2-digit wallet (carteira) of the agreement"`
+	Agency              string `config:"agency" env:"HOBY_BOLETO_AGENCY" usage:"Agency of the beneficiary account, printed on boletos"`
+	Account             string `config:"account" env:"HOBY_BOLETO_ACCOUNT" usage:"Beneficiary account, printed on boletos"`
+	BeneficiaryName     string `config:"beneficiary_name" env:"HOBY_BOLETO_BENEFICIARY_NAME" usage:"Company name printed as the beneficiary"`
+	BeneficiaryDocument string `config:"beneficiary_document" env:"HOBY_BOLETO_BENEFICIARY_DOCUMENT" usage:"CNPJ of the beneficiary"`
+	DueDays             int    `config:"due_days" env:"HOBY_BOLETO_DUE_DAYS" usage:"Days between issuing a boleto and its due date"`
+	WebhookSecret       string `config:"webhook_secret" env:"HOBY_BOLETO_WEBHOOK_SECRET" usage:"Secret verifying the boleto settlements sent by the bank" secret:"true"`
+}
+
+// Development defaults of the boleto agreement
+const (
+	devBoletoAgreement     = "1234567"
+	devBoletoWebhookSecret = "hoby-loop-dev-boleto-secret"
+)
+
+// GetBoletoConfig returns the boleto configuration
+func GetBoletoConfig() BoletoConfig {
+	return Get().Boleto
+}
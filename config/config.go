@@ -0,0 +1,705 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Config is the effective application configuration.
+//
+// Values are resolved in increasing order of precedence:
+// built-in defaults, the optional config file (YAML or TOML),
+// HOBY_* environment variables and finally command line flags.
+// Every field declares its file key with the `config` tag, its
+// environment variable with the `env` tag and is exposed as a flag
+// named after the key with dots replaced by dashes (server.port -> -server-port).
+type Config struct {
+	Env           string              `config:"env" env:"HOBY_ENV" usage:"Runtime environment (development|production)"`
+	Server        ServerConfig        `config:"server"`
+	API           APIConfig           `config:"api"`
+	Database      DBConfig            `config:"database"`
+	Auth          AuthConfig          `config:"auth"`
+	CORS          CORSConfig          `config:"cors"`
+	Log           LogConfig           `config:"log"`
+	Scheduler     SchedulerConfig     `config:"scheduler"`
+	Subscriptions SubscriptionConfig  `config:"subscriptions"`
+	Dunning       DunningConfig       `config:"dunning"`
+	Notifications NotificationsConfig `config:"notifications"`
+	Outbox        OutboxConfig        `config:"outbox"`
+	Webhooks      WebhooksConfig      `config:"webhooks"`
+	Idempotency   IdempotencyConfig   `config:"idempotency"`
+	Payments      PaymentsConfig      `config:"payments"`
+	Pix           PixConfig           `config:"pix"`
+	Boleto        BoletoConfig        `config:"boleto"`
+	Invoices      InvoicesConfig      `config:"invoices"`
+	Features      map[string]bool     `config:"features" env:"HOBY_FEATURES" usage:"Feature toggles as name=bool pairs separated by commas"`
+}
+
+// ServerConfig holds the HTTP server configuration
+type ServerConfig struct {
+	Port string `config:"port" env:"HOBY_SERVER_PORT" usage:"HTTP listen port"`
+}
+
+// CORSConfig holds the allowed cross-origin callers
+type CORSConfig struct {
+	AllowedOrigins []string `config:"allowed_origins" env:"HOBY_CORS_ALLOWED_ORIGINS" usage:"Comma separated list of allowed origins, * allows all"`
+}
+
+// LogConfig holds the logging configuration
+type LogConfig struct {
+	Level string `config:"level" env:"HOBY_LOG_LEVEL" usage:"Log level (debug|info|warn|error)"`
+}
+
+// Known feature toggles
+const (
+	// FeatureRegistration allows new sellers and consumers to sign up
+	FeatureRegistration = "registration"
+)
+
+// Defaults returns the configuration used when nothing else is provided
+func Defaults() Config {
+	return Config{
+		Env: "development",
+		Server: ServerConfig{
+			Port: "8080",
+		},
+		API: APIConfig{
+			LegacyRoutes:       true,
+			LegacyDeprecatedAt: "2026-10-17",
+			LegacySunset:       "2027-04-30",
+		},
+		Database: DBConfig{
+			Host:            "localhost",
+			User:            "hoby",
+			Password:        "password123",
+			DBName:          "hobyloop",
+			Port:            "5433",
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+		},
+		Auth: AuthConfig{
+			TokenSecret:     devTokenSecret,
+			TokenIssuer:     "hoby-loop",
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 30 * 24 * time.Hour,
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"*"},
+		},
+		Log: LogConfig{
+			Level: "info",
+		},
+		Scheduler: SchedulerConfig{
+			Enabled:    true,
+			Interval:   time.Minute,
+			BatchSize:  50,
+			MaxCatchUp: 4,
+		},
+		Dunning: DunningConfig{
+			RetrySchedule: []time.Duration{24 * time.Hour, 72 * time.Hour, 7 * 24 * time.Hour},
+			FinalAction:   DunningPause,
+		},
+		Notifications: NotificationsConfig{
+			DefaultChannels: []string{"email", "log"},
+			Timeout:         10 * time.Second,
+			DefaultLocale:   "pt-BR",
+			SMTP:            SMTPConfig{Port: "587", From: "Hoby Loop <no-reply@hobyloop.com.br>"},
+		},
+		Outbox: OutboxConfig{
+			Enabled:      true,
+			Workers:      4,
+			PollInterval: time.Second,
+			BatchSize:    10,
+			Lease:        5 * time.Minute,
+			MaxAttempts:  8,
+			BaseBackoff:  30 * time.Second,
+			MaxBackoff:   6 * time.Hour,
+		},
+		Webhooks: WebhooksConfig{
+			Timeout: 10 * time.Second,
+		},
+		Idempotency: IdempotencyConfig{
+			TTL: 24 * time.Hour,
+		},
+		Payments: PaymentsConfig{
+			Provider:      "fake",
+			WebhookSecret: devPaymentsWebhookSecret,
+		},
+		Pix: PixConfig{
+			Key:           devPixKey,
+			MerchantName:  "Hoby Loop",
+			MerchantCity:  "Sao Paulo",
+			WebhookSecret: devPixWebhookSecret,
+		},
+		Boleto: BoletoConfig{
+			Agreement:           devBoletoAgreement,
+			BankCode:            "001",
+			Wallet:              "17",
+			Agency:              "1234-5",
+			Account:             "12345-6",
+			BeneficiaryName:     "Hoby Loop Ltda",
+			BeneficiaryDocument: "11.222.333/0001-81",
+			DueDays:             5,
+			WebhookSecret:       devBoletoWebhookSecret,
+		},
+		Features: map[string]bool{
+			FeatureRegistration: true,
+		},
+	}
+}
+
+var (
+	current     *Config
+	currentOnce sync.Once
+	currentMu   sync.RWMutex
+)
+
+// Get returns the loaded configuration. If Load was never called,
+// the configuration is resolved from defaults, file and environment only.
+func Get() *Config {
+	currentMu.RLock()
+	cfg := current
+	currentMu.RUnlock()
+	if cfg != nil {
+		return cfg
+	}
+
+	currentOnce.Do(func() {
+		loaded, err := load(nil, false)
+		if err != nil {
+			panic("config: " + err.Error())
+		}
+		set(loaded)
+	})
+
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current
+}
+
+// set replaces the global configuration
+func set(cfg *Config) {
+	currentMu.Lock()
+	current = cfg
+	currentMu.Unlock()
+}
+
+// Load resolves the configuration from every source, validates it and makes it
+// the global configuration returned by Get. args are the command line arguments
+// without the program name. When -print-config is passed the redacted effective
+// configuration is written to stdout and the process exits.
+func Load(args []string) (*Config, error) {
+	cfg, err := load(args, true)
+	if err != nil {
+		return nil, err
+	}
+	set(cfg)
+	return cfg, nil
+}
+
+// load implements Load without touching the global configuration
+func load(args []string, parseFlags bool) (*Config, error) {
+	cfg := Defaults()
+
+	fs := flag.NewFlagSet("hoby-loop", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("HOBY_CONFIG"), "Path to a YAML or TOML config file")
+	printConfig := fs.Bool("print-config", false, "Print the effective configuration with secrets redacted and exit")
+
+	fields := collectFields(reflect.ValueOf(&cfg).Elem(), "")
+	flagValues := map[string]*string{}
+	for _, f := range fields {
+		flagValues[f.key] = fs.String(f.flagName(), "", f.usage)
+	}
+
+	if parseFlags {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+	}
+
+	// 1. Config file
+	if *configPath != "" {
+		values, err := readFile(*configPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range fields {
+			if raw, ok := values[f.key]; ok {
+				if err := f.set(raw); err != nil {
+					return nil, fmt.Errorf("%s: %s: %w", *configPath, f.key, err)
+				}
+			}
+		}
+	}
+
+	// 2. Environment variables
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		if raw, ok := os.LookupEnv(f.env); ok {
+			if err := f.set(raw); err != nil {
+				return nil, fmt.Errorf("%s: %w", f.env, err)
+			}
+		}
+	}
+
+	// 3. Command line flags, only those explicitly passed
+	var flagErr error
+	fs.Visit(func(fl *flag.Flag) {
+		for _, f := range fields {
+			if f.flagName() == fl.Name && flagErr == nil {
+				if err := f.set(*flagValues[f.key]); err != nil {
+					flagErr = fmt.Errorf("-%s: %w", fl.Name, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	if *printConfig {
+		if err := cfg.Dump(os.Stdout); err != nil {
+			return nil, err
+		}
+		os.Exit(0)
+	}
+
+	return &cfg, nil
+}
+
+// Validate checks that required values are present and consistent
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Env != "development" && c.Env != "production" {
+		errs = append(errs, fmt.Errorf("env must be development or production, got %q", c.Env))
+	}
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port <= 0 || port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port must be a valid TCP port, got %q", c.Server.Port))
+	}
+
+	if c.Database.Host == "" || c.Database.User == "" || c.Database.DBName == "" || c.Database.Port == "" {
+		errs = append(errs, errors.New("database.host, database.user, database.name and database.port are required"))
+	}
+	if c.Database.MaxOpenConns < 1 {
+		errs = append(errs, errors.New("database.max_open_conns must be at least 1"))
+	}
+	if c.Database.MaxIdleConns < 0 || c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		errs = append(errs, errors.New("database.max_idle_conns must be between 0 and database.max_open_conns"))
+	}
+
+	if c.Auth.TokenSecret == "" {
+		errs = append(errs, errors.New("auth.token_secret is required"))
+	}
+	if c.Auth.AccessTokenTTL <= 0 || c.Auth.RefreshTokenTTL <= 0 {
+		errs = append(errs, errors.New("auth token TTLs must be positive"))
+	}
+
+	for _, channel := range c.Notifications.DefaultChannels {
+		switch channel {
+		case "email", "webhook", "whatsapp", "sms", "log":
+		default:
+			errs = append(errs, fmt.Errorf("notifications.default_channels: unknown channel %q", channel))
+		}
+	}
+	switch c.Notifications.DefaultLocale {
+	case "pt-BR", "en":
+	default:
+		errs = append(errs, fmt.Errorf("notifications.default_locale must be pt-BR or en, got %q", c.Notifications.DefaultLocale))
+	}
+	if c.Notifications.Timeout <= 0 {
+		errs = append(errs, errors.New("notifications.timeout must be positive"))
+	}
+
+	if c.Subscriptions.TrialPeriod < 0 {
+		errs = append(errs, errors.New("subscriptions.trial_period must not be negative"))
+	}
+	if len(c.Dunning.RetrySchedule) == 0 {
+		errs = append(errs, errors.New("dunning.retry_schedule must list at least one delay"))
+	}
+	for i, d := range c.Dunning.RetrySchedule {
+		if d <= 0 || (i > 0 && d <= c.Dunning.RetrySchedule[i-1]) {
+			errs = append(errs, errors.New("dunning.retry_schedule delays must be positive and increasing"))
+			break
+		}
+	}
+	if c.Dunning.FinalAction != DunningPause && c.Dunning.FinalAction != DunningCancel {
+		errs = append(errs, fmt.Errorf("dunning.final_action must be pause or cancel, got %q", c.Dunning.FinalAction))
+	}
+	if c.Scheduler.Interval <= 0 || c.Scheduler.BatchSize < 1 || c.Scheduler.MaxCatchUp < 1 {
+		errs = append(errs, errors.New("scheduler.interval, scheduler.batch_size and scheduler.max_catch_up must be positive"))
+	}
+
+	if c.Outbox.Workers < 1 || c.Outbox.BatchSize < 1 || c.Outbox.MaxAttempts < 1 {
+		errs = append(errs, errors.New("outbox.workers, outbox.batch_size and outbox.max_attempts must be positive"))
+	}
+	if c.Outbox.PollInterval <= 0 || c.Outbox.Lease <= 0 || c.Outbox.BaseBackoff <= 0 || c.Outbox.MaxBackoff < c.Outbox.BaseBackoff {
+		errs = append(errs, errors.New("outbox.poll_interval, outbox.lease and outbox.base_backoff must be positive and outbox.max_backoff at least outbox.base_backoff"))
+	}
+
+	if c.Webhooks.Timeout <= 0 {
+		errs = append(errs, errors.New("webhooks.timeout must be positive"))
+	}
+	if c.Idempotency.TTL <= 0 {
+		errs = append(errs, errors.New("idempotency.ttl must be positive"))
+	}
+
+	switch c.Payments.Provider {
+	case "", "fake":
+	default:
+		errs = append(errs, fmt.Errorf("payments.provider must be fake or empty, got %q", c.Payments.Provider))
+	}
+	if c.Payments.Provider != "" && c.Payments.WebhookSecret == "" {
+		errs = append(errs, errors.New("payments.webhook_secret is required when payments.provider is set"))
+	}
+	if c.Pix.Key != "" {
+		if c.Pix.MerchantName == "" || len(c.Pix.MerchantName) > 25 || c.Pix.MerchantCity == "" || len(c.Pix.MerchantCity) > 15 {
+			errs = append(errs, errors.New("pix.merchant_name must have 1 to 25 characters and pix.merchant_city 1 to 15"))
+		}
+		if c.Pix.WebhookSecret == "" {
+			errs = append(errs, errors.New("pix.webhook_secret is required when pix.key is set"))
+		}
+	}
+	if c.Boleto.Agreement != "" {
+		if c.Boleto.BankCode != "001" {
+			errs = append(errs, fmt.Errorf("boleto.bank_code must be 001 (Banco do Brasil), got %q", c.Boleto.BankCode))
+		}
+		if !digits(c.Boleto.Agreement, 7) || !digits(c.Boleto.Wallet, 2) {
+			errs = append(errs, errors.New("boleto.agreement must have 7 digits and boleto.wallet 2"))
+		}
+		if c.Boleto.BeneficiaryName == "" || c.Boleto.BeneficiaryDocument == "" {
+			errs = append(errs, errors.New("boleto.beneficiary_name and boleto.beneficiary_document are required when boleto.agreement is set"))
+		}
+		if c.Boleto.DueDays < 1 {
+			errs = append(errs, errors.New("boleto.due_days must be at least 1"))
+		}
+		if c.Boleto.WebhookSecret == "" {
+			errs = append(errs, errors.New("boleto.webhook_secret is required when boleto.agreement is set"))
+		}
+	}
+
+	if rate := c.Invoices.ApproximateTaxRate; rate != "" {
+		if value, err := strconv.ParseFloat(rate, 64); err != nil || !(value >= 0 && value < 100) {
+			errs = append(errs, fmt.Errorf("invoices.approximate_tax_rate must be a percentage from 0 to 100, got %q", rate))
+		}
+	}
+
+	for _, date := range []struct{ key, value string }{
+		{"api.legacy_deprecated_at", c.API.LegacyDeprecatedAt},
+		{"api.legacy_sunset", c.API.LegacySunset},
+	} {
+		if _, err := time.Parse(time.DateOnly, date.value); date.value != "" && err != nil {
+			errs = append(errs, fmt.Errorf("%s must be a date (YYYY-MM-DD), got %q", date.key, date.value))
+		}
+	}
+	if deprecatedAt, sunset := c.API.LegacyDates(); !sunset.IsZero() && sunset.Before(deprecatedAt) {
+		errs = append(errs, errors.New("api.legacy_sunset must not be before api.legacy_deprecated_at"))
+	}
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level must be debug, info, warn or error, got %q", c.Log.Level))
+	}
+
+	// Development defaults must never reach production
+	if c.Env == "production" {
+		if c.Auth.TokenSecret == devTokenSecret || len(c.Auth.TokenSecret) < 32 {
+			errs = append(errs, errors.New("auth.token_secret must be set to at least 32 characters in production"))
+		}
+		if c.Database.Password == Defaults().Database.Password {
+			errs = append(errs, errors.New("database.password must be changed from the default in production"))
+		}
+		for _, origin := range c.CORS.AllowedOrigins {
+			if origin == "*" {
+				errs = append(errs, errors.New("cors.allowed_origins must not contain * in production"))
+			}
+		}
+		if c.Payments.Provider == "fake" {
+			errs = append(errs, errors.New("payments.provider must not be the fake provider in production"))
+		}
+		if c.Pix.Key == devPixKey || (c.Pix.Key != "" && c.Pix.WebhookSecret == devPixWebhookSecret) {
+			errs = append(errs, errors.New("pix.key and pix.webhook_secret must be changed from the defaults in production"))
+		}
+		if c.Boleto.Agreement == devBoletoAgreement || (c.Boleto.Agreement != "" && c.Boleto.WebhookSecret == devBoletoWebhookSecret) {
+			errs = append(errs, errors.New("boleto.agreement and boleto.webhook_secret must be changed from the defaults in production"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// FeatureEnabled reports whether the named feature toggle is on
+func (c *Config) FeatureEnabled(name string) bool {
+	return c.Features[name]
+}
+
+// Dump writes the configuration as YAML with secret values redacted
+func (c *Config) Dump(w io.Writer) error {
+	redacted := *c
+	fields := collectFields(reflect.ValueOf(&redacted).Elem(), "")
+	for _, f := range fields {
+		if f.secret && f.value.String() != "" {
+			f.value.SetString("******")
+		}
+	}
+
+	out := map[string]interface{}{}
+	for _, f := range fields {
+		out[f.key] = f.value.Interface()
+	}
+
+	keys := make([]string, 0, len(out))
+	for key := range out {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	ordered := yaml.MapSlice{}
+	for _, key := range keys {
+		value := out[key]
+		switch v := value.(type) {
+		case time.Duration:
+			value = v.String()
+		case []time.Duration:
+			list := make([]string, 0, len(v))
+			for _, d := range v {
+				list = append(list, d.String())
+			}
+			value = list
+		}
+		ordered = append(ordered, yaml.MapItem{Key: key, Value: value})
+	}
+
+	encoded, err := yaml.Marshal(ordered)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(encoded)
+	return err
+}
+
+// field is a single configurable leaf of Config
+type field struct {
+	key    string
+	env    string
+	usage  string
+	secret bool
+	value  reflect.Value
+}
+
+// flagName returns the command line flag name for the field
+func (f field) flagName() string {
+	return strings.ReplaceAll(strings.ReplaceAll(f.key, ".", "-"), "_", "-")
+}
+
+// set parses a raw value from a file, environment variable or flag into the field
+func (f field) set(raw interface{}) error {
+	switch f.value.Interface().(type) {
+	case map[string]bool:
+		features := map[string]bool{}
+		for name, enabled := range f.value.Interface().(map[string]bool) {
+			features[name] = enabled
+		}
+		switch v := raw.(type) {
+		case map[string]interface{}:
+			for name, enabled := range v {
+				b, err := strconv.ParseBool(fmt.Sprint(enabled))
+				if err != nil {
+					return err
+				}
+				features[name] = b
+			}
+		default:
+			for _, pair := range splitList(fmt.Sprint(v)) {
+				name, value, found := strings.Cut(pair, "=")
+				if !found {
+					value = "true"
+				}
+				b, err := strconv.ParseBool(value)
+				if err != nil {
+					return err
+				}
+				features[strings.TrimSpace(name)] = b
+			}
+		}
+		f.value.Set(reflect.ValueOf(features))
+		return nil
+	case []string:
+		switch v := raw.(type) {
+		case []interface{}:
+			list := make([]string, 0, len(v))
+			for _, item := range v {
+				list = append(list, fmt.Sprint(item))
+			}
+			f.value.Set(reflect.ValueOf(list))
+		default:
+			f.value.Set(reflect.ValueOf(splitList(fmt.Sprint(v))))
+		}
+		return nil
+	case []time.Duration:
+		var items []string
+		switch v := raw.(type) {
+		case []interface{}:
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+		default:
+			items = splitList(fmt.Sprint(v))
+		}
+		list := make([]time.Duration, 0, len(items))
+		for _, item := range items {
+			d, err := time.ParseDuration(item)
+			if err != nil {
+				return err
+			}
+			list = append(list, d)
+		}
+		f.value.Set(reflect.ValueOf(list))
+		return nil
+	case time.Duration:
+		d, err := time.ParseDuration(fmt.Sprint(raw))
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(d))
+		return nil
+	}
+
+	s := fmt.Sprint(raw)
+	switch f.value.Kind() {
+	case reflect.String:
+		f.value.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.value.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported config type %s", f.value.Type())
+	}
+	return nil
+}
+
+// collectFields walks the config struct and returns every configurable leaf
+func collectFields(v reflect.Value, prefix string) []field {
+	var fields []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		key := sf.Tag.Get("config")
+		if key == "" {
+			continue
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		fv := v.Field(i)
+		if sf.Type.Kind() == reflect.Struct && sf.Type != reflect.TypeOf(time.Duration(0)) {
+			fields = append(fields, collectFields(fv, key)...)
+			continue
+		}
+
+		fields = append(fields, field{
+			key:    key,
+			env:    sf.Tag.Get("env"),
+			usage:  sf.Tag.Get("usage"),
+			secret: sf.Tag.Get("secret") == "true",
+			value:  fv,
+		})
+	}
+	return fields
+}
+
+// readFile parses a YAML or TOML file into a flat map keyed by dotted paths
+func readFile(path string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	nested := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &nested)
+	case ".toml":
+		err = toml.Unmarshal(content, &nested)
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q, use .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing config file: %w", err)
+	}
+
+	flat := map[string]interface{}{}
+	flatten("", nested, flat)
+	return flat, nil
+}
+
+// flatten converts nested maps into dotted keys. The features table is kept
+// as a map since its keys are user-defined.
+func flatten(prefix string, in map[string]interface{}, out map[string]interface{}) {
+	for key, value := range in {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok && key != "features" {
+			flatten(key, nested, out)
+			continue
+		}
+		out[key] = value
+	}
+}
+
+// splitList splits a comma separated value, dropping empty items
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// digits reports whether s is made of exactly n ASCII digits
+func digits(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
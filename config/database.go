@@ -1,25 +1,25 @@
 package config
 
+import "time"
+
 // DBConfig holds the configuration for database connection
 type DBConfig struct {
-	Host     string
-	User     string
-	Password string
-	DBName   string
-	Port     string
-	SSLMode  string
+	Host     string `config:"host" env:"HOBY_DB_HOST" usage:"Database host"`
+	User     string `config:"user" env:"HOBY_DB_USER" usage:"Database user"`
+	Password string `config:"password" env:"HOBY_DB_PASSWORD" usage:"Database password" secret:"true"`
+	DBName   string `config:"name" env:"HOBY_DB_NAME" usage:"Database name"`
+	Port     string `config:"port" env:"HOBY_DB_PORT" usage:"Database port"`
+	SSLMode  string `config:"sslmode" env:"HOBY_DB_SSLMODE" usage:"Database SSL mode"`
+
+	// Connection pool settings
+	MaxOpenConns    int           `config:"max_open_conns" env:"HOBY_DB_MAX_OPEN_CONNS" usage:"Maximum open database connections"`
+	MaxIdleConns    int           `config:"max_idle_conns" env:"HOBY_DB_MAX_IDLE_CONNS" usage:"Maximum idle database connections"`
+	ConnMaxLifetime time.Duration `config:"conn_max_lifetime" env:"HOBY_DB_CONN_MAX_LIFETIME" usage:"Maximum lifetime of a database connection"`
 }
 
 // GetDBConfig returns the database configuration
 func GetDBConfig() DBConfig {
-	return DBConfig{
-		Host:     "localhost",
-		User:     "hoby",
-		Password: "password123",
-		DBName:   "hobyloop",
-		Port:     "5433",
-		SSLMode:  "disable",
-	}
+	return Get().Database
 }
 
 // GetDSN returns the database connection string
@@ -31,4 +31,4 @@ func GetDSN() string {
 		" dbname=" + config.DBName +
 		" port=" + config.Port +
 		" sslmode=" + config.SSLMode
-}
\ No newline at end of file
+}
@@ -0,0 +1,22 @@
+package config
+
+import "time"
+
+// Final actions applied to subscriptions still unpaid after the last retry
+const (
+	DunningPause  = "pause"
+	DunningCancel = "cancel"
+)
+
+// DunningConfig holds the retry schedule of failed recurring charges
+type DunningConfig struct {
+	// RetrySchedule lists the delays, counted from the first failure, at which
+	// the charge is retried. The subscription stays past due meanwhile.
+	RetrySchedule []time.Duration `config:"retry_schedule" env:"HOBY_DUNNING_RETRY_SCHEDULE" usage:"Comma separated delays after the first failed charge at which it is retried, e.g. 24h,72h,168h"`
+	FinalAction   string          `config:"final_action" env:"HOBY_DUNNING_FINAL_ACTION" usage:"What happens to subscriptions still unpaid after the last retry (pause|cancel)"`
+}
+
+// GetDunningConfig returns the dunning configuration
+func GetDunningConfig() DunningConfig {
+	return Get().Dunning
+}
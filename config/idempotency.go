@@ -0,0 +1,15 @@
+package config
+
+import "time"
+
+// IdempotencyConfig holds the configuration of Idempotency-Key handling
+type IdempotencyConfig struct {
+	// TTL is how long a key and its stored response are kept. Retries after
+	// that run the request again.
+	TTL time.Duration `config:"ttl" env:"HOBY_IDEMPOTENCY_TTL" usage:"How long Idempotency-Key responses are replayed to retries"`
+}
+
+// GetIdempotencyConfig returns the Idempotency-Key configuration
+func GetIdempotencyConfig() IdempotencyConfig {
+	return Get().Idempotency
+}
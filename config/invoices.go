@@ -0,0 +1,29 @@
+package config
+
+import (
+	"math"
+	"strconv"
+)
+
+// InvoicesConfig holds the configuration of the invoices issued for orders
+type InvoicesConfig struct {
+	// ApproximateTaxRate is the share of the price, in percent (e.g. 13.45),
+	// printed on invoices as the approximate taxes included in it, as Lei
+	// 12.741/2012 requires. Empty leaves the line out.
+	ApproximateTaxRate string `config:"approximate_tax_rate" env:"HOBY_INVOICES_APPROXIMATE_TAX_RATE" usage:"Percentage of the price shown on invoices as approximate taxes (Lei 12.741/2012), empty to omit"`
+}
+
+// TaxBasisPoints returns the approximate tax rate in hundredths of a
+// percent, 0 when not set. Validate rejects malformed rates.
+func (c InvoicesConfig) TaxBasisPoints() int64 {
+	rate, err := strconv.ParseFloat(c.ApproximateTaxRate, 64)
+	if err != nil {
+		return 0
+	}
+	return int64(math.Round(rate * 100))
+}
+
+// GetInvoicesConfig returns the invoices configuration
+func GetInvoicesConfig() InvoicesConfig {
+	return Get().Invoices
+}
@@ -0,0 +1,49 @@
+package config
+
+import "time"
+
+// NotificationsConfig holds the configuration of the notification channels.
+// A channel is only available when its settings are present.
+type NotificationsConfig struct {
+	// DefaultChannels are used for users without stored preferences
+	DefaultChannels []string      `config:"default_channels" env:"HOBY_NOTIFY_DEFAULT_CHANNELS" usage:"Comma separated channels used when a user has no preferences (email, webhook, whatsapp, sms, log)"`
+	Timeout         time.Duration `config:"timeout" env:"HOBY_NOTIFY_TIMEOUT" usage:"Time limit for sending one notification"`
+	// DefaultLocale is used for users without a locale of their own
+	DefaultLocale string `config:"default_locale" env:"HOBY_NOTIFY_DEFAULT_LOCALE" usage:"Language of notifications for users without a locale (pt-BR, en)"`
+
+	SMTP      SMTPConfig      `config:"smtp"`
+	Webhook   WebhookConfig   `config:"webhook"`
+	Messaging MessagingConfig `config:"messaging"`
+	Log       LogSinkConfig   `config:"log"`
+}
+
+// SMTPConfig configures the email channel
+type SMTPConfig struct {
+	Host     string `config:"host" env:"HOBY_SMTP_HOST" usage:"SMTP server host, empty disables email"`
+	Port     string `config:"port" env:"HOBY_SMTP_PORT" usage:"SMTP server port"`
+	Username string `config:"username" env:"HOBY_SMTP_USERNAME" usage:"SMTP username"`
+	Password string `config:"password" env:"HOBY_SMTP_PASSWORD" usage:"SMTP password" secret:"true"`
+	From     string `config:"from" env:"HOBY_SMTP_FROM" usage:"Sender address of notification emails"`
+}
+
+// WebhookConfig configures the generic webhook channel
+type WebhookConfig struct {
+	URL string `config:"url" env:"HOBY_NOTIFY_WEBHOOK_URL" usage:"URL receiving notifications as JSON, empty disables the channel"`
+}
+
+// MessagingConfig configures the WhatsApp and SMS provider
+type MessagingConfig struct {
+	ProviderURL string `config:"provider_url" env:"HOBY_MESSAGING_PROVIDER_URL" usage:"Messaging provider endpoint, empty disables WhatsApp and SMS"`
+	Token       string `config:"token" env:"HOBY_MESSAGING_TOKEN" usage:"Messaging provider API token" secret:"true"`
+	From        string `config:"from" env:"HOBY_MESSAGING_FROM" usage:"Sender number or ID registered with the provider"`
+}
+
+// LogSinkConfig configures the development log channel
+type LogSinkConfig struct {
+	Path string `config:"path" env:"HOBY_NOTIFY_LOG_PATH" usage:"File receiving log channel notifications, empty writes to stdout"`
+}
+
+// GetNotificationsConfig returns the notification channel configuration
+func GetNotificationsConfig() NotificationsConfig {
+	return Get().Notifications
+}
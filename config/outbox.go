@@ -0,0 +1,20 @@
+package config
+
+import "time"
+
+// OutboxConfig holds the configuration of the background job workers
+type OutboxConfig struct {
+	Enabled      bool          `config:"enabled" env:"HOBY_OUTBOX_ENABLED" usage:"Run background job workers on this instance"`
+	Workers      int           `config:"workers" env:"HOBY_OUTBOX_WORKERS" usage:"Number of concurrent job workers"`
+	PollInterval time.Duration `config:"poll_interval" env:"HOBY_OUTBOX_POLL_INTERVAL" usage:"How long an idle worker waits before looking for jobs again"`
+	BatchSize    int           `config:"batch_size" env:"HOBY_OUTBOX_BATCH_SIZE" usage:"Jobs claimed by a worker at once"`
+	Lease        time.Duration `config:"lease" env:"HOBY_OUTBOX_LEASE" usage:"How long a claimed job is reserved before another worker may take it over"`
+	MaxAttempts  int           `config:"max_attempts" env:"HOBY_OUTBOX_MAX_ATTEMPTS" usage:"Attempts before a failing job is moved to the dead-letter state"`
+	BaseBackoff  time.Duration `config:"base_backoff" env:"HOBY_OUTBOX_BASE_BACKOFF" usage:"Delay before the first retry, doubled on every further failure"`
+	MaxBackoff   time.Duration `config:"max_backoff" env:"HOBY_OUTBOX_MAX_BACKOFF" usage:"Longest delay between retries"`
+}
+
+// GetOutboxConfig returns the background job configuration
+func GetOutboxConfig() OutboxConfig {
+	return Get().Outbox
+}
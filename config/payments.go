@@ -0,0 +1,18 @@
+package config
+
+// PaymentsConfig holds the configuration of the payment provider charging
+// consumers for their orders
+type PaymentsConfig struct {
+	// Provider is the gateway orders are charged through. The fake provider
+	// runs locally, approving or declining by test card number.
+	Provider      string `config:"provider" env:"HOBY_PAYMENTS_PROVIDER" usage:"Payment provider (fake), empty disables payments"`
+	WebhookSecret string `config:"webhook_secret" env:"HOBY_PAYMENTS_WEBHOOK_SECRET" usage:"Secret verifying the payment provider's webhook calls" secret:"true"`
+}
+
+// devPaymentsWebhookSecret is the default webhook secret of the fake provider
+const devPaymentsWebhookSecret = "hoby-loop-dev-payments-secret"
+
+// GetPaymentsConfig returns the payment provider configuration
+func GetPaymentsConfig() PaymentsConfig {
+	return Get().Payments
+}
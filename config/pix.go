@@ -0,0 +1,22 @@
+package config
+
+// PixConfig holds the Pix account orders are paid into
+type PixConfig struct {
+	// Key is the Pix key of the receiving account: CNPJ, email, phone or
+	// random key. Empty disables Pix.
+	Key           string `config:"key" env:"HOBY_PIX_KEY" usage:"Pix key receiving order payments, empty disables Pix"`
+	MerchantName  string `config:"merchant_name" env:"HOBY_PIX_MERCHANT_NAME" usage:"Receiver name shown to payers, up to 25 characters"`
+	MerchantCity  string `config:"merchant_city" env:"HOBY_PIX_MERCHANT_CITY" usage:"Receiver city shown to payers, up to 15 characters"`
+	WebhookSecret string `config:"webhook_secret" env:"HOBY_PIX_WEBHOOK_SECRET" usage:"Secret verifying the Pix confirmations sent by the bank" secret:"true"`
+}
+
+// Development defaults of the Pix account
+const (
+	devPixKey           = "pix@hoby-loop.dev"
+	devPixWebhookSecret = "hoby-loop-dev-pix-secret"
+)
+
+// GetPixConfig returns the Pix configuration
+func GetPixConfig() PixConfig {
+	return Get().Pix
+}
@@ -0,0 +1,16 @@
+package config
+
+import "time"
+
+// SchedulerConfig holds the configuration of the recurring order scheduler
+type SchedulerConfig struct {
+	Enabled    bool          `config:"enabled" env:"HOBY_SCHEDULER_ENABLED" usage:"Generate recurring orders for active subscriptions"`
+	Interval   time.Duration `config:"interval" env:"HOBY_SCHEDULER_INTERVAL" usage:"How often due subscriptions are checked"`
+	BatchSize  int           `config:"batch_size" env:"HOBY_SCHEDULER_BATCH_SIZE" usage:"Subscriptions locked and processed per transaction"`
+	MaxCatchUp int           `config:"max_catch_up" env:"HOBY_SCHEDULER_MAX_CATCH_UP" usage:"Missed deliveries generated per subscription after downtime, older ones are skipped"`
+}
+
+// GetSchedulerConfig returns the recurring order scheduler configuration
+func GetSchedulerConfig() SchedulerConfig {
+	return Get().Scheduler
+}
@@ -0,0 +1,13 @@
+package config
+
+import "time"
+
+// SubscriptionConfig holds the configuration of the subscription lifecycle
+type SubscriptionConfig struct {
+	TrialPeriod time.Duration `config:"trial_period" env:"HOBY_SUBSCRIPTION_TRIAL_PERIOD" usage:"Trial length of new subscriptions, 0 disables trials"`
+}
+
+// GetSubscriptionConfig returns the subscription lifecycle configuration
+func GetSubscriptionConfig() SubscriptionConfig {
+	return Get().Subscriptions
+}
@@ -0,0 +1,14 @@
+package config
+
+import "time"
+
+// WebhooksConfig holds the configuration of outgoing seller webhooks.
+// Failed deliveries are retried by the outbox workers, see OutboxConfig.
+type WebhooksConfig struct {
+	Timeout time.Duration `config:"timeout" env:"HOBY_WEBHOOKS_TIMEOUT" usage:"Time limit for one webhook delivery"`
+}
+
+// GetWebhooksConfig returns the outgoing webhook configuration
+func GetWebhooksConfig() WebhooksConfig {
+	return Get().Webhooks
+}
@@ -15,7 +15,8 @@ export default function Input({
   placeholder = '',
   placeholderI18nKey = '',
   className = '',
-  required = false
+  required = false,
+  minLength
 }) {
   // Translate label and placeholder if i18n keys are provided
   const translatedLabel = labelI18nKey ? t(labelI18nKey) : label;
@@ -35,6 +36,7 @@ export default function Input({
         onChange={onChange}
         placeholder={translatedPlaceholder}
         required={required}
+        minLength={minLength}
         className="w-full bg-gray-900 p-3 rounded-xl text-sm outline-none border-2 border-gray-800 focus:border-transparent focus:bg-gradient-to-r focus:from-secondary focus:to-tertiary transition-all duration-300"
       />
     </div>
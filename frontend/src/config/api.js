@@ -7,13 +7,15 @@
  */
 
 // Base API URL
-export const API_BASE_URL = 'http://localhost:8080';
+export const API_BASE_URL = 'http://localhost:8080/v1';
 
 // API endpoints
 export const ENDPOINTS = {
   // Auth
   LOGIN: `${API_BASE_URL}/login`,
   REGISTER: `${API_BASE_URL}/register`,
+  REFRESH_TOKEN: `${API_BASE_URL}/token/refresh`,
+  LOGOUT: `${API_BASE_URL}/logout`,
   
   // Users
   UPDATE_USER: (userId) => `${API_BASE_URL}/users/${userId}`,
@@ -26,10 +28,13 @@ export const ENDPOINTS = {
   // Subscriptions
   CREATE_SUBSCRIPTION: `${API_BASE_URL}/subscriptions`,
   SELLER_SUBSCRIPTIONS: (sellerId) => `${API_BASE_URL}/sellers/${sellerId}/subscriptions`,
-  USER_SUBSCRIPTIONS: (userId) => `${API_BASE_URL}/users/${userId}/subscriptions`,
+  CONSUMER_SUBSCRIPTIONS: (consumerId) => `${API_BASE_URL}/consumers/${consumerId}/subscriptions`,
+  SUBSCRIPTION_ORDERS: (subscriptionId) => `${API_BASE_URL}/subscriptions/${subscriptionId}/orders`,
   
   // Orders
   CREATE_ORDER: `${API_BASE_URL}/orders`,
+  BASKET_ORDERS: (basketId) => `${API_BASE_URL}/baskets/${basketId}/orders`,
+  UPDATE_ORDER_STATUS: (orderId) => `${API_BASE_URL}/orders/${orderId}/status`,
   
   // Admin endpoints
   ADMIN_USERS: `${API_BASE_URL}/admin/users`,
@@ -65,6 +65,7 @@ export default {
     title: "Entrar",
     email: "Email",
     emailPlaceholder: "ex: ada-conceicao@cirino.com",
+    password: "Senha",
     accessAccount: "Acessar Conta",
     createAccount: "Crie sua conta",
     clickToTest: "Clique para testar:",
@@ -76,7 +77,7 @@ export default {
     clients: "👤 Clientes",
     client1: "Luiz Gustavo (Cliente 10)",
     client2: "Eduardo (Cliente 11)",
-    userNotFound: "Usuário não encontrado! Verifique os emails de teste abaixo."
+    invalidCredentials: "Email ou senha inválidos! Verifique as contas de teste abaixo."
   },
 
   alerts: {
@@ -92,6 +93,8 @@ export default {
   },
   
   registration: {
+    passwordHint: "Mínimo de 8 caracteres",
+    passwordTooLong: "A senha é longa demais.",
     seller: {
       title: "Crie sua conta",
       name: "Seu nome",
@@ -99,6 +102,7 @@ export default {
       cnpj: "CNPJ",
       phone: "Telefone",
       email: "E-mail",
+      password: "Senha",
       address: "Endereço",
       submit: "Cadastrar-se",
       success: "Conta criada com sucesso!",
@@ -108,6 +112,7 @@ export default {
       title: "Crie sua conta",
       name: "Seu nome",
       email: "E-mail",
+      password: "Senha",
       cpf: "CPF",
       phone: "Telefone",
       address: "Endereço",
@@ -147,6 +152,9 @@ export default {
     preparing: "Preparando",
     shipped: "Enviado",
     delivered: "Entregue",
+    failed_delivery: "Falha na Entrega",
+    returned: "Devolvido",
+    cancelled: "Cancelado",
     tracking: "Rastreio",
     previousOrders: "pedidos anteriores",
     orderId: "Pedido",
@@ -1,7 +1,8 @@
 import { useState, useEffect } from 'react'
-import axios from 'axios'
 import { useNavigate } from 'react-router-dom'
 import { ENDPOINTS } from '../config/api'
+import api, { logout as endSession } from '../utils/api'
+import { getUser, clearSession } from '../utils/auth'
 import { t } from '../i18n'
 
 export default function AdminDashboard() {
@@ -13,7 +14,7 @@ export default function AdminDashboard() {
   
   // Get logged in admin user
   const navigate = useNavigate()
-  const user = JSON.parse(localStorage.getItem('user'))
+  const user = getUser()
   
   // Parse permissions from JSON string
   const permissions = user?.permissions ? JSON.parse(user.permissions) : {}
@@ -28,18 +29,15 @@ export default function AdminDashboard() {
     // Check if admin is active
     if (!user.is_active) {
       alert(t('alerts.adminDeactivated'))
-      localStorage.removeItem('user')
+      clearSession()
       navigate('/')
       return
     }
 
     setIsLoading(true)
 
-    // Configure headers with authentication
-    const headers = { 'X-User-ID': user?.ID.toString() }
-
     // Fetch all users
-    axios.get(ENDPOINTS.ADMIN_USERS, { headers })
+    api.get(ENDPOINTS.ADMIN_USERS)
       .then(res => {
         setUsers(res.data.data || [])
         setIsLoading(false)
@@ -50,14 +48,14 @@ export default function AdminDashboard() {
       })
 
     // Fetch all subscriptions
-    axios.get(ENDPOINTS.ADMIN_SUBSCRIPTIONS, { headers })
+    api.get(ENDPOINTS.ADMIN_SUBSCRIPTIONS)
       .then(res => {
         setSubscriptions(res.data.data || [])
       })
       .catch(console.error)
 
     // Fetch all baskets
-    axios.get(ENDPOINTS.ADMIN_BASKETS, { headers })
+    api.get(ENDPOINTS.ADMIN_BASKETS)
       .then(res => {
         setBaskets(res.data.data || [])
       })
@@ -65,8 +63,8 @@ export default function AdminDashboard() {
   }, [])
 
   // Logout Helper
-  const logout = () => {
-    localStorage.removeItem('user')
+  const logout = async () => {
+    await endSession()
     navigate('/')
   }
 
@@ -207,10 +205,10 @@ export default function AdminDashboard() {
                 <div>
                   <h2 className="text-lg font-black uppercase text-main-text">{sub.user?.name || t('common.unknown')}</h2>
                   <p className="text-sm text-gray-400">{t('admin.basketInfo', { name: sub.basket?.name || t('common.unknown') })}</p>
-                  <p className="text-sm text-gray-400">Status: <span className={`font-bold ${sub.status === 'Active' ? 'text-green-400' : 'text-yellow-400'}`}>{sub.status}</span></p>
+                  <p className="text-sm text-gray-400">Status: <span className={`font-bold ${sub.status === 'active' ? 'text-green-400' : 'text-yellow-400'}`}>{sub.status}</span></p>
                 </div>
                 <div className="text-right">
-                  <span className="block text-lg font-bold text-main-text">R$ {sub.basket?.price || 0}</span>
+                  <span className="block text-lg font-bold text-main-text">{sub.basket?.price?.formatted || 'R$ 0,00'}</span>
                   <span className="text-xs text-gray-500 uppercase">{sub.frequency}</span>
                 </div>
               </div>
@@ -231,7 +229,7 @@ export default function AdminDashboard() {
                   <p className="text-sm text-gray-400">{basket.description}</p>
                 </div>
                 <div className="text-right">
-                  <span className="block text-lg font-bold text-main-text">R$ {basket.price}</span>
+                  <span className="block text-lg font-bold text-main-text">{basket.price?.formatted}</span>
                   <span className="text-xs text-gray-500">{t('admin.sellerId', { id: basket.seller_id || basket.UserID })}</span>
                 </div>
               </div>
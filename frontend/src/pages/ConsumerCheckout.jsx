@@ -1,6 +1,8 @@
 import { useState, useEffect } from 'react'
 import { useParams } from 'react-router-dom'
-import axios from 'axios'
+import { ENDPOINTS } from '../config/api'
+import api from '../utils/api'
+import { getUser } from '../utils/auth'
 import PageContainer from '../components/layout/PageContainer'
 import Button from '../components/ui/Button'
 import Input from '../components/ui/Input'
@@ -13,7 +15,7 @@ export default function ConsumerCheckout() {
 
   useEffect(() => {
     // Fetch basket details
-    axios.get(`http://localhost:8080/baskets/${id}`)
+    api.get(ENDPOINTS.GET_BASKET(id))
       .then(res => {
         setBasket(res.data.data)
         setLoading(false)
@@ -24,15 +26,14 @@ export default function ConsumerCheckout() {
   // Handle subscription
   const handleSubscribe = () => {
     // Get authenticated user from localStorage
-    const user = JSON.parse(localStorage.getItem('user'))
+    const user = getUser()
     
     if (!user || !user.ID) {
       alert(t('checkout.loginRequired'))
       return
     }
     
-    axios.post('http://localhost:8080/subscriptions', {
-      user_id: user.ID,
+    api.post(ENDPOINTS.CREATE_SUBSCRIPTION, {
       basket_id: parseInt(id),
       frequency: "monthly"
     })
@@ -56,7 +57,7 @@ export default function ConsumerCheckout() {
           <h1 className="text-2xl font-black uppercase text-main-text mb-6 bg-gradient-secondary-tertiary text-transparent bg-clip-text">
             {basket.name}
           </h1>
-          <p className="text-2xl font-bold mb-6">R$ {basket.price}</p>
+          <p className="text-2xl font-bold mb-6">{basket.price?.formatted}</p>
 
           {/* Dropdowns (Visual only for now) */}
           <div className="space-y-4">
@@ -1,6 +1,8 @@
 import { useState, useEffect } from 'react'
-import axios from 'axios'
 import { useNavigate } from 'react-router-dom'
+import { ENDPOINTS } from '../config/api'
+import api, { logout as endSession } from '../utils/api'
+import { getUser } from '../utils/auth'
 import { t } from '../i18n'
 
 export default function ConsumerDashboard() {
@@ -8,7 +10,7 @@ export default function ConsumerDashboard() {
   const [orders, setOrders] = useState({}) // Store orders by subscription ID
   
   const navigate = useNavigate()
-  const user = JSON.parse(localStorage.getItem('user'))
+  const user = getUser()
   
   useEffect(() => {
     if (!user || user.role !== 'consumer') {
@@ -17,14 +19,14 @@ export default function ConsumerDashboard() {
     }
     
     // Fetch consumer subscriptions
-    axios.get(`http://localhost:8080/consumers/${user.ID}/subscriptions`)
+    api.get(ENDPOINTS.CONSUMER_SUBSCRIPTIONS(user.ID))
       .then(res => {
         const subs = res.data.data || []
         setSubscriptions(subs)
         
         // Fetch orders for each subscription
         subs.forEach(sub => {
-          axios.get(`http://localhost:8080/subscriptions/${sub.ID}/orders`)
+          api.get(ENDPOINTS.SUBSCRIPTION_ORDERS(sub.ID))
             .then(orderRes => {
               setOrders(prev => ({
                 ...prev,
@@ -37,8 +39,8 @@ export default function ConsumerDashboard() {
       .catch(console.error)
   }, [])
   
-  const logout = () => {
-    localStorage.removeItem('user')
+  const logout = async () => {
+    await endSession()
     navigate('/')
   }
 
@@ -104,7 +106,7 @@ export default function ConsumerDashboard() {
                       </p>
                     </div>
                     <div className="text-right">
-                      <span className="block text-lg font-bold text-main-text">R$ {sub.basket.price}</span>
+                      <span className="block text-lg font-bold text-main-text">{sub.basket.price?.formatted}</span>
                       <span className="text-xs text-gray-500 uppercase">{sub.frequency}</span>
                     </div>
                   </div>
@@ -2,7 +2,7 @@ import { useState } from 'react';
 import { useNavigate, useSearchParams, Link } from 'react-router-dom';
 import axios from 'axios';
 import { ENDPOINTS } from '../config/api';
-import { setUser } from '../utils/auth';
+import { setSession } from '../utils/auth';
 import PageContainer from '../components/layout/PageContainer';
 import Button from '../components/ui/Button';
 import Input from '../components/ui/Input';
@@ -10,6 +10,7 @@ import { t } from '../i18n';
 
 export default function Login() {
   const [email, setEmail] = useState('');
+  const [password, setPassword] = useState('');
   const navigate = useNavigate();
   const [searchParams] = useSearchParams();
   const userType = searchParams.get('type') || 'subscriber';
@@ -18,11 +19,12 @@ export default function Login() {
     e.preventDefault();
     
     try {
-      const response = await axios.post(ENDPOINTS.LOGIN, { email });
-      const user = response.data.data;
+      const response = await axios.post(ENDPOINTS.LOGIN, { email, password });
+      const session = response.data.data;
+      const user = session.user;
       
-      // Save user to localStorage using our auth utility
-      setUser(user);
+      // Save the user and its tokens using our auth utility
+      setSession(session);
       
       // Navigate to the appropriate dashboard based on role
       if (user.role === 'admin') {
@@ -33,11 +35,15 @@ export default function Login() {
         navigate('/consumer');
       }
     } catch (error) {
-      alert(t("login.userNotFound"));
+      alert(t("login.invalidCredentials"));
     }
   };
 
-  const fillEmail = (val) => setEmail(val);
+  // Seeded test accounts share these passwords, see tools/data.json
+  const fillAccount = (val, pass = 'password123') => {
+    setEmail(val);
+    setPassword(pass);
+  };
 
   return (
     <PageContainer maxWidth="max-w-sm">
@@ -57,6 +63,15 @@ export default function Login() {
             required
           />
 
+          <Input
+            type="password"
+            labelI18nKey="login.password"
+            name="password"
+            value={password}
+            onChange={(e) => setPassword(e.target.value)}
+            required
+          />
+
           <Button
             type="submit"
             variant="primary"
@@ -82,27 +97,27 @@ export default function Login() {
           <div className="space-y-4">
             <div>
               <p className="font-bold text-tertiary mb-2 text-center">{t('login.administrator')}</p>
-              <button onClick={() => fillEmail('admin@hobyloop.com')} className="block w-full text-center hover:text-secondary transition-colors">
+              <button onClick={() => fillAccount('admin@hobyloop.com', 'admin123')} className="block w-full text-center hover:text-secondary transition-colors">
                 {t('login.adminUser')}
               </button>
             </div>
             
             <div>
               <p className="font-bold text-forth mb-2 text-center">{t('login.sellers')}</p>
-              <button onClick={() => fillEmail('ada-conceicao@cirino.com')} className="block w-full text-center hover:text-secondary transition-colors mb-1">
+              <button onClick={() => fillAccount('ada-conceicao@cirino.com')} className="block w-full text-center hover:text-secondary transition-colors mb-1">
                 {t('login.seller1')}
               </button>
-              <button onClick={() => fillEmail('lunaferreira@da.com')} className="block w-full text-center hover:text-secondary transition-colors">
+              <button onClick={() => fillAccount('lunaferreira@da.com')} className="block w-full text-center hover:text-secondary transition-colors">
                 {t('login.seller2')}
               </button>
             </div>
 
             <div>
               <p className="font-bold text-green-400 mb-2 text-center">{t('login.clients')}</p>
-              <button onClick={() => fillEmail('aliciacirino@example.com')} className="block w-full text-center hover:text-secondary transition-colors mb-1">
+              <button onClick={() => fillAccount('aliciacirino@example.com')} className="block w-full text-center hover:text-secondary transition-colors mb-1">
                 {t('login.client1')}
               </button>
-              <button onClick={() => fillEmail('enrico30@example.org')} className="block w-full text-center hover:text-secondary transition-colors">
+              <button onClick={() => fillAccount('enrico30@example.org')} className="block w-full text-center hover:text-secondary transition-colors">
                 {t('login.client2')}
               </button>
             </div>
@@ -1,6 +1,8 @@
 import { useState, useEffect } from 'react'
-import axios from 'axios'
 import { useNavigate } from 'react-router-dom'
+import { ENDPOINTS } from '../config/api'
+import api, { logout as endSession } from '../utils/api'
+import { getUser } from '../utils/auth'
 import { t } from '../i18n'
 import Button from '../components/ui/Button'
 
@@ -11,7 +13,7 @@ export default function SellerDashboard() {
   
   // NEW: Get logged in user
   const navigate = useNavigate()
-  const user = JSON.parse(localStorage.getItem('user'))
+  const user = getUser()
 
   useEffect(() => {
     // Security Check
@@ -21,12 +23,12 @@ export default function SellerDashboard() {
     }
 
     // 1. Fetch Clients (Using dynamic user.ID)
-    axios.get(`http://localhost:8080/sellers/${user.ID}/subscriptions`)
+    api.get(ENDPOINTS.SELLER_SUBSCRIPTIONS(user.ID))
       .then(res => setSubscriptions(res.data.data))
       .catch(console.error)
 
     // 2. Fetch Products
-    axios.get(`http://localhost:8080/sellers/${user.ID}/baskets`)
+    api.get(ENDPOINTS.SELLER_BASKETS(user.ID))
       .then(res => setBaskets(res.data.data))
       .catch(console.error)
   }, [])
@@ -38,8 +40,8 @@ export default function SellerDashboard() {
   }
 
   // Logout Helper
-  const logout = () => {
-    localStorage.removeItem('user')
+  const logout = async () => {
+    await endSession()
     navigate('/')
   }
 
@@ -95,7 +97,7 @@ export default function SellerDashboard() {
             >
               <div className="bg-background p-4 rounded-xl">
                 <h2 className="text-lg font-black uppercase text-main-text">{basket.name}</h2>
-                <p className="text-xl font-bold text-main-text mb-4">R$ {basket.price}</p>
+                <p className="text-xl font-bold text-main-text mb-4">{basket.price?.formatted}</p>
                 <Button
                   onClick={(e) => {
                     e.stopPropagation()
@@ -1,6 +1,8 @@
 import { useState, useEffect } from 'react'
 import { useParams, useNavigate } from 'react-router-dom'
-import axios from 'axios'
+import { ENDPOINTS } from '../config/api'
+import api from '../utils/api'
+import { getUser } from '../utils/auth'
 import { t } from '../i18n'
 import Button from '../components/ui/Button'
 
@@ -11,7 +13,7 @@ export default function SellerOrderManagement() {
   const [orders, setOrders] = useState([])
   const [filter, setFilter] = useState('all') // all, preparing, shipped, delivered
   
-  const user = JSON.parse(localStorage.getItem('user'))
+  const user = getUser()
 
   useEffect(() => {
     if (!user || user.role !== 'seller') {
@@ -20,7 +22,7 @@ export default function SellerOrderManagement() {
     }
 
     // Fetch basket details
-    axios.get(`http://localhost:8080/baskets/${basketId}`)
+    api.get(ENDPOINTS.GET_BASKET(basketId))
       .then(res => setBasket(res.data.data))
       .catch(console.error)
 
@@ -29,13 +31,13 @@ export default function SellerOrderManagement() {
   }, [basketId])
 
   const fetchOrders = () => {
-    axios.get(`http://localhost:8080/baskets/${basketId}/orders`)
+    api.get(ENDPOINTS.BASKET_ORDERS(basketId))
       .then(res => setOrders(res.data.data || []))
       .catch(console.error)
   }
 
   const updateOrderStatus = (orderId, newStatus) => {
-    axios.put(`http://localhost:8080/orders/${orderId}/status`, {
+    api.put(ENDPOINTS.UPDATE_ORDER_STATUS(orderId), {
       status: newStatus
     })
       .then(() => {
@@ -15,6 +15,7 @@ export default function SellerRegistration() {
     cnpj: '',
     phone: '',
     email: '',
+    password: '',
     address: ''
   });
   const [isSubmitting, setIsSubmitting] = useState(false);
@@ -31,13 +32,18 @@ export default function SellerRegistration() {
 
   const handleSubmit = async (e) => {
     e.preventDefault();
-    setIsSubmitting(true);
     setError('');
+    if (new TextEncoder().encode(formData.password).length > 72) {
+      setError(t('registration.passwordTooLong'));
+      return;
+    }
+    setIsSubmitting(true);
     
     try {
       const userData = {
         name: formData.name,
         email: formData.email,
+        password: formData.password,
         role: 'seller',
         cnpj: formData.cnpj,
         address_street: formData.address,
@@ -118,6 +124,17 @@ export default function SellerRegistration() {
             onChange={handleChange}
             required
           />
+
+          <Input
+            type="password"
+            label={t('registration.seller.password')}
+            name="password"
+            value={formData.password}
+            onChange={handleChange}
+            placeholder={t('registration.passwordHint')}
+            minLength={8}
+            required
+          />
           
           <Input
             type="text"
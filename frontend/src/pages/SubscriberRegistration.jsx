@@ -12,6 +12,7 @@ export default function SubscriberRegistration() {
   const [formData, setFormData] = useState({
     name: '',
     email: '',
+    password: '',
     cpf: '',
     phone: '',
     address: ''
@@ -30,13 +31,18 @@ export default function SubscriberRegistration() {
 
   const handleSubmit = async (e) => {
     e.preventDefault();
-    setIsSubmitting(true);
     setError('');
+    if (new TextEncoder().encode(formData.password).length > 72) {
+      setError(t('registration.passwordTooLong'));
+      return;
+    }
+    setIsSubmitting(true);
     
     try {
       const userData = {
         name: formData.name,
         email: formData.email,
+        password: formData.password,
         role: 'consumer',
         cpf: formData.cpf,
         address_street: formData.address,
@@ -90,6 +96,17 @@ export default function SubscriberRegistration() {
             onChange={handleChange}
             required
           />
+
+          <Input
+            type="password"
+            label={t('registration.subscriber.password')}
+            name="password"
+            value={formData.password}
+            onChange={handleChange}
+            placeholder={t('registration.passwordHint')}
+            minLength={8}
+            required
+          />
           
           <Input
             type="text"
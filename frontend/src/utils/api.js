@@ -0,0 +1,78 @@
+/**
+ * Shared API client
+ *
+ * Every request carries the access token of the session as an
+ * Authorization: Bearer header. When the API answers 401 the session is
+ * refreshed once with its refresh token and the request is retried; if the
+ * refresh fails too the session is dropped and the user sent to the login.
+ */
+import axios from 'axios';
+import { API_BASE_URL, ENDPOINTS } from '../config/api';
+import { getAccessToken, getRefreshToken, setSession, clearSession } from './auth';
+
+const api = axios.create({ baseURL: API_BASE_URL });
+
+api.interceptors.request.use((config) => {
+  const token = getAccessToken();
+  if (token) {
+    config.headers.Authorization = `Bearer ${token}`;
+  }
+  return config;
+});
+
+// Refresh tokens are rotated on every use and reusing one revokes every
+// session of the user, so concurrent 401s share a single refresh
+let refreshing = null;
+
+const refreshSession = () => {
+  if (!refreshing) {
+    const refreshToken = getRefreshToken();
+    refreshing = (refreshToken
+      ? axios.post(ENDPOINTS.REFRESH_TOKEN, { refresh_token: refreshToken })
+          .then((response) => setSession(response.data.data))
+      : Promise.reject(new Error('No refresh token'))
+    ).finally(() => {
+      refreshing = null;
+    });
+  }
+  return refreshing;
+};
+
+api.interceptors.response.use(
+  (response) => response,
+  async (error) => {
+    const request = error.config;
+    if (error.response?.status !== 401 || !request || request._retried) {
+      throw error;
+    }
+
+    request._retried = true;
+    try {
+      await refreshSession();
+    } catch {
+      clearSession();
+      window.location.assign('/login');
+      throw error;
+    }
+    return api(request);
+  }
+);
+
+/**
+ * Ends the session on the server, revoking its refresh token, then locally
+ */
+export const logout = async () => {
+  const refreshToken = getRefreshToken();
+  try {
+    if (getAccessToken()) {
+      // An expired session is simply dropped, without refreshing it first
+      await api.post(ENDPOINTS.LOGOUT, { refresh_token: refreshToken }, { _retried: true });
+    }
+  } catch (error) {
+    console.error('Logout error:', error);
+  } finally {
+    clearSession();
+  }
+};
+
+export default api;
@@ -13,9 +13,30 @@ export const getUser = () => {
   return userData ? JSON.parse(userData) : null;
 };
 
+// Store the session returned by login or token refresh: the user and its
+// access and refresh tokens
+export const setSession = (session) => {
+  setUser(session.user);
+  localStorage.setItem('accessToken', session.access_token);
+  localStorage.setItem('refreshToken', session.refresh_token);
+};
+
+// Get the access token sent as Authorization: Bearer
+export const getAccessToken = () => localStorage.getItem('accessToken');
+
+// Get the refresh token exchanged for a new session when the access token expires
+export const getRefreshToken = () => localStorage.getItem('refreshToken');
+
+// Remove the user and its tokens
+export const clearSession = () => {
+  localStorage.removeItem('user');
+  localStorage.removeItem('accessToken');
+  localStorage.removeItem('refreshToken');
+};
+
 // Check if user is authenticated
 export const isAuthenticated = () => {
-  return !!getUser();
+  return !!getUser() && !!getAccessToken();
 };
 
 // Check if user has a specific role
@@ -33,11 +54,6 @@ export const isConsumer = () => hasRole('consumer');
 // Check if user is an admin
 export const isAdmin = () => hasRole('admin');
 
-// Remove user data (logout)
-export const logout = () => {
-  localStorage.removeItem('user');
-};
-
 // Get user ID
 export const getUserId = () => {
   const user = getUser();
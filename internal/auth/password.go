@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordCost is the bcrypt cost used for newly hashed passwords.
+// Raising it causes existing hashes to be upgraded on the next successful login.
+const PasswordCost = 12
+
+// MaxPasswordBytes is the longest password bcrypt accepts, in bytes rather
+// than characters
+const MaxPasswordBytes = 72
+
+// ErrInvalidCredentials is returned when a password does not match its hash
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ErrPasswordTooLong is returned when a password exceeds MaxPasswordBytes
+var ErrPasswordTooLong = errors.New("password must be at most 72 bytes")
+
+// dummyHash is checked against when there is no user, so that failed logins
+// take as long whether or not the email is registered
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("hoby-loop-no-such-user"), PasswordCost)
+	if err != nil {
+		panic("auth: " + err.Error())
+	}
+	return hash
+})
+
+// HashPassword hashes a plaintext password with bcrypt
+func HashPassword(password string) (string, error) {
+	if len(password) > MaxPasswordBytes {
+		return "", ErrPasswordTooLong
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), PasswordCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword verifies a plaintext password against a stored hash.
+// Rows written before hashing was introduced still hold the plaintext value;
+// those are compared in constant time so they can be upgraded on login.
+func CheckPassword(stored, password string) error {
+	if stored == "" {
+		return ErrInvalidCredentials
+	}
+
+	if !isBcryptHash(stored) {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(password)) != 1 {
+			return ErrInvalidCredentials
+		}
+		return nil
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)); err != nil {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// CheckUnknownUser spends the time of CheckPassword on a login attempt for a
+// user that does not exist, and always fails
+func CheckUnknownUser(password string) error {
+	bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+	return ErrInvalidCredentials
+}
+
+// NeedsRehash reports whether a stored password should be re-hashed,
+// either because it is a legacy plaintext value or its cost is outdated
+func NeedsRehash(stored string) bool {
+	if !isBcryptHash(stored) {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(stored))
+	if err != nil {
+		return true
+	}
+	return cost != PasswordCost
+}
+
+// isBcryptHash reports whether the value looks like a bcrypt hash
+func isBcryptHash(value string) bool {
+	return strings.HasPrefix(value, "$2a$") ||
+		strings.HasPrefix(value, "$2b$") ||
+		strings.HasPrefix(value, "$2y$")
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Permission is a single capability that can be granted to an admin
+type Permission string
+
+// Admin permissions
+const (
+	PermissionUsersRead         Permission = "users:read"
+	PermissionUsersWrite        Permission = "users:write"
+	PermissionBasketsModerate   Permission = "baskets:moderate"
+	PermissionSubscriptionsRead Permission = "subscriptions:read"
+	PermissionOrdersRead        Permission = "orders:read"
+	PermissionOrdersWrite       Permission = "orders:write"
+	PermissionFinanceExport     Permission = "finance:export"
+	PermissionPermissionsManage Permission = "permissions:manage"
+	PermissionNotificationsRead Permission = "notifications:read"
+	PermissionJobsRead          Permission = "jobs:read"
+	PermissionJobsManage        Permission = "jobs:manage"
+	PermissionPaymentsRefund    Permission = "payments:refund"
+
+	// PermissionAll grants every permission, including ones added later
+	PermissionAll Permission = "*"
+)
+
+// AllPermissions lists every grantable permission
+var AllPermissions = []Permission{
+	PermissionUsersRead,
+	PermissionUsersWrite,
+	PermissionBasketsModerate,
+	PermissionSubscriptionsRead,
+	PermissionOrdersRead,
+	PermissionOrdersWrite,
+	PermissionFinanceExport,
+	PermissionPermissionsManage,
+	PermissionNotificationsRead,
+	PermissionJobsRead,
+	PermissionJobsManage,
+	PermissionPaymentsRefund,
+	PermissionAll,
+}
+
+// legacyPermissions maps the old boolean section flags to the permissions they implied
+var legacyPermissions = map[string][]Permission{
+	"users":         {PermissionUsersRead, PermissionUsersWrite},
+	"baskets":       {PermissionBasketsModerate},
+	"subscriptions": {PermissionSubscriptionsRead},
+	"orders":        {PermissionOrdersRead},
+	"reports":       {PermissionFinanceExport},
+}
+
+// IsValidPermission reports whether p is a known permission
+func IsValidPermission(p Permission) bool {
+	for _, known := range AllPermissions {
+		if known == p {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionSet is the set of permissions held by a user
+type PermissionSet map[Permission]bool
+
+// ParsePermissions decodes the User.Permissions column. It accepts the current
+// JSON array format (["users:read", ...]) and the legacy object format
+// ({"users": true, ...}). Unknown or malformed values grant nothing.
+func ParsePermissions(raw string) PermissionSet {
+	set := PermissionSet{}
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return set
+	}
+
+	var list []Permission
+	if err := json.Unmarshal([]byte(raw), &list); err == nil {
+		for _, p := range list {
+			if IsValidPermission(p) {
+				set[p] = true
+			}
+		}
+		return set
+	}
+
+	var legacy map[string]bool
+	if err := json.Unmarshal([]byte(raw), &legacy); err == nil {
+		for section, enabled := range legacy {
+			if !enabled {
+				continue
+			}
+			for _, p := range legacyPermissions[section] {
+				set[p] = true
+			}
+		}
+	}
+
+	return set
+}
+
+// Has reports whether the set grants the permission
+func (s PermissionSet) Has(p Permission) bool {
+	return s[PermissionAll] || s[p]
+}
+
+// List returns the permissions in a stable order
+func (s PermissionSet) List() []Permission {
+	list := make([]Permission, 0, len(s))
+	for p := range s {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+	return list
+}
+
+// Encode serializes the set into the JSON array stored in User.Permissions
+func (s PermissionSet) Encode() string {
+	encoded, _ := json.Marshal(s.List())
+	return string(encoded)
+}
@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+const (
+	testIssuer = "hoby-loop"
+	testSecret = "test-secret"
+)
+
+func TestAccessTokenRoundTrip(t *testing.T) {
+	token, issued, err := IssueAccessToken(42, "seller", testIssuer, testSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("IssueAccessToken: %v", err)
+	}
+	claims, err := ParseAccessToken(token, testIssuer, testSecret)
+	if err != nil {
+		t.Fatalf("ParseAccessToken: %v", err)
+	}
+	if claims != issued {
+		t.Errorf("ParseAccessToken = %+v, want %+v", claims, issued)
+	}
+	if claims.Subject != 42 || claims.Role != "seller" || claims.ID == "" {
+		t.Errorf("claims = %+v, want user 42, seller, with an ID", claims)
+	}
+	if got := claims.ExpiresAtTime().Sub(time.Unix(claims.IssuedAt, 0)); got != time.Hour {
+		t.Errorf("lifetime = %v, want 1h", got)
+	}
+
+	other, _, err := IssueAccessToken(42, "seller", testIssuer, testSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("IssueAccessToken: %v", err)
+	}
+	if other == token {
+		t.Errorf("two tokens issued for the same user are identical")
+	}
+}
+
+func TestParseAccessTokenRejects(t *testing.T) {
+	token, _, err := IssueAccessToken(42, "seller", testIssuer, testSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("IssueAccessToken: %v", err)
+	}
+	parts := strings.Split(token, ".")
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"jti":"x","sub":1,"role":"admin","iss":"hoby-loop","exp":9999999999}`))
+	expired, _, err := IssueAccessToken(42, "seller", testIssuer, testSecret, -time.Second)
+	if err != nil {
+		t.Fatalf("IssueAccessToken: %v", err)
+	}
+	noUser, _, err := IssueAccessToken(0, "seller", testIssuer, testSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("IssueAccessToken: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		issuer string
+		secret string
+	}{
+		{"empty", "", testIssuer, testSecret},
+		{"two parts", parts[0] + "." + parts[1], testIssuer, testSecret},
+		{"other secret", token, testIssuer, "other-secret"},
+		{"other issuer", token, "other", testSecret},
+		{"tampered payload", parts[0] + "." + forged + "." + parts[2], testIssuer, testSecret},
+		{"tampered signature", parts[0] + "." + parts[1] + "." + strings.ToUpper(parts[2]), testIssuer, testSecret},
+		{"other algorithm", base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + parts[1] + ".", testIssuer, testSecret},
+		{"expired", expired, testIssuer, testSecret},
+		{"no subject", noUser, testIssuer, testSecret},
+	}
+	for _, tt := range tests {
+		if _, err := ParseAccessToken(tt.token, tt.issuer, tt.secret); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: ParseAccessToken error = %v, want ErrInvalidToken", tt.name, err)
+		}
+	}
+}
+
+func TestGenerateRefreshToken(t *testing.T) {
+	token, hash, err := GenerateRefreshToken()
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+	if hash != HashRefreshToken(token) || hash == token || len(hash) != 64 {
+		t.Errorf("hash = %q, want the SHA-256 hex of the token", hash)
+	}
+	other, _, err := GenerateRefreshToken()
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+	if other == token {
+		t.Errorf("two refresh tokens are identical")
+	}
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	ctx := context.Background()
+	tokens := repository.NewMemoryRepositories().Tokens
+	now := time.Now()
+
+	// newRefreshToken stores a refresh token of user 1 and returns it with
+	// its stored record
+	newRefreshToken := func() (string, models.RefreshToken) {
+		token, hash, err := GenerateRefreshToken()
+		if err != nil {
+			t.Fatalf("GenerateRefreshToken: %v", err)
+		}
+		return token, models.RefreshToken{UserID: 1, TokenHash: hash, ExpiresAt: now.Add(time.Hour)}
+	}
+
+	first, stored := newRefreshToken()
+	if err := tokens.CreateRefreshToken(ctx, &stored); err != nil {
+		t.Fatalf("CreateRefreshToken: %v", err)
+	}
+	found, err := tokens.FindRefreshTokenByHash(ctx, HashRefreshToken(first))
+	if err != nil || found.ID != stored.ID || found.RevokedAt != nil {
+		t.Fatalf("FindRefreshTokenByHash = %+v, %v, want the active token", found, err)
+	}
+
+	second, next := newRefreshToken()
+	if err := tokens.RotateRefreshToken(ctx, found.ID, &next, now); err != nil {
+		t.Fatalf("RotateRefreshToken: %v", err)
+	}
+	rotated, err := tokens.FindRefreshTokenByHash(ctx, HashRefreshToken(first))
+	if err != nil || rotated.RevokedAt == nil {
+		t.Fatalf("rotated token = %+v, %v, want revoked", rotated, err)
+	}
+
+	// A concurrent refresh with the same token loses the race
+	_, racing := newRefreshToken()
+	if err := tokens.RotateRefreshToken(ctx, found.ID, &racing, now); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("second RotateRefreshToken error = %v, want ErrNotFound", err)
+	}
+
+	// Presenting the rotated token again is reuse: every session of the user
+	// is revoked, including the one it was rotated into
+	if err := tokens.RevokeAllRefreshTokens(ctx, rotated.UserID, now); err != nil {
+		t.Fatalf("RevokeAllRefreshTokens: %v", err)
+	}
+	current, err := tokens.FindRefreshTokenByHash(ctx, HashRefreshToken(second))
+	if err != nil || current.RevokedAt == nil {
+		t.Errorf("token rotated into = %+v, %v, want revoked after reuse", current, err)
+	}
+	if err := tokens.RotateRefreshToken(ctx, current.ID, &racing, now); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("RotateRefreshToken after reuse error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,235 @@
+// Package boleto issues and reads bank boletos ("boletos de cobrança") in
+// the FEBRABAN layout.
+//
+// A boleto is identified by a 44-digit barcode: bank code, currency, a mod 11
+// check digit, the due date as days since 1997-10-07, the amount and a
+// 25-digit free field laid out by the bank. Payers type the 47-digit linha
+// digitável instead, which rearranges the barcode into five fields, the first
+// three with their own mod 10 check digits.
+package boleto
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// ErrInvalidLine is returned for barcodes and lines that are malformed or
+// fail their check digits
+var ErrInvalidLine = errors.New("invalid boleto line")
+
+// Lengths of the two representations
+const (
+	BarcodeLength = 44
+	LineLength    = 47
+)
+
+// currencyReal is the currency code of boletos in reais
+const currencyReal = '9'
+
+// factorBase is the day before due date factor 1. Factors run from 1000 to
+// 9999 and restart at 1000 every 9000 days, the first time on 2025-02-22.
+var factorBase = time.Date(1997, 10, 7, 0, 0, 0, 0, time.UTC)
+
+// Fields are the contents of a boleto's barcode
+type Fields struct {
+	BankCode  string       // Three digits, e.g. 001 for Banco do Brasil
+	DueDate   time.Time    // Zero for boletos without due date
+	Amount    models.Money // Zero when the payer types the amount
+	FreeField string       // 25 digits laid out by the bank
+}
+
+// Barcode returns the 44 digits of the barcode of f
+func (f Fields) Barcode() (string, error) {
+	if len(f.BankCode) != 3 || !digitsOnly(f.BankCode) {
+		return "", fmt.Errorf("%w: bank code must have 3 digits", ErrInvalidLine)
+	}
+	if len(f.FreeField) != 25 || !digitsOnly(f.FreeField) {
+		return "", fmt.Errorf("%w: free field must have 25 digits", ErrInvalidLine)
+	}
+	if f.Amount.Amount < 0 || f.Amount.Amount > 99_999_999_99 || (!f.Amount.IsZero() && f.Amount.Currency != "BRL") {
+		return "", fmt.Errorf("%w: amount must be in reais and below 100 million", ErrInvalidLine)
+	}
+
+	factor := 0
+	if !f.DueDate.IsZero() {
+		var err error
+		if factor, err = DueFactor(f.DueDate); err != nil {
+			return "", err
+		}
+	}
+	partial := fmt.Sprintf("%s%c%04d%010d%s", f.BankCode, currencyReal, factor, f.Amount.Amount, f.FreeField)
+	return partial[:4] + strconv.Itoa(barcodeCheckDigit(partial)) + partial[4:], nil
+}
+
+// DueFactor returns the due date factor of date
+func DueFactor(date time.Time) (int, error) {
+	days := int(civilDate(date).Sub(factorBase).Hours() / 24)
+	if days < 1000 {
+		return 0, fmt.Errorf("%w: due date before 2000-07-03", ErrInvalidLine)
+	}
+	if days > 9999 {
+		days = (days-10000)%9000 + 1000
+	}
+	return days, nil
+}
+
+// dueDate returns the date of factor in the 9000-day cycle closest to ref
+func dueDate(factor int, ref time.Time) time.Time {
+	ref = civilDate(ref)
+	best := factorBase.AddDate(0, 0, factor)
+	for next := best; next.Before(ref); {
+		next = next.AddDate(0, 0, 9000)
+		if next.Sub(ref) < ref.Sub(best) {
+			best = next
+		}
+	}
+	return best
+}
+
+// DigitableLine returns the 47 digits of the linha digitável of a barcode
+func DigitableLine(barcode string) (string, error) {
+	if len(barcode) != BarcodeLength || !digitsOnly(barcode) {
+		return "", fmt.Errorf("%w: a barcode has %d digits", ErrInvalidLine, BarcodeLength)
+	}
+	field1 := barcode[0:4] + barcode[19:24]
+	field2 := barcode[24:34]
+	field3 := barcode[34:44]
+	return field1 + strconv.Itoa(mod10(field1)) +
+		field2 + strconv.Itoa(mod10(field2)) +
+		field3 + strconv.Itoa(mod10(field3)) +
+		barcode[4:5] + barcode[5:19], nil
+}
+
+// BarcodeFromLine returns the barcode of a linha digitável, checking the
+// digits of every field
+func BarcodeFromLine(line string) (string, error) {
+	if len(line) != LineLength || !digitsOnly(line) {
+		return "", fmt.Errorf("%w: a linha digitável has %d digits", ErrInvalidLine, LineLength)
+	}
+	for i, field := range []struct{ start, end int }{{0, 9}, {10, 20}, {21, 31}} {
+		if mod10(line[field.start:field.end]) != int(line[field.end]-'0') {
+			return "", fmt.Errorf("%w: check digit of field %d does not match", ErrInvalidLine, i+1)
+		}
+	}
+	barcode := line[0:4] + line[32:33] + line[33:47] + line[4:9] + line[10:20] + line[21:31]
+	if barcodeCheckDigit(barcode[:4]+barcode[5:]) != int(barcode[4]-'0') {
+		return "", fmt.Errorf("%w: general check digit does not match", ErrInvalidLine)
+	}
+	return barcode, nil
+}
+
+// Parse reads a linha digitável or barcode typed by a user, ignoring spaces,
+// dots and dashes. The due date is placed in the factor cycle closest to now.
+func Parse(input string, now time.Time) (Fields, string, error) {
+	digits := strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9':
+			return r
+		case r == ' ' || r == '.' || r == '-':
+			return -1
+		default:
+			return 'x'
+		}
+	}, input)
+	if !digitsOnly(digits) {
+		return Fields{}, "", fmt.Errorf("%w: only digits, spaces, dots and dashes are allowed", ErrInvalidLine)
+	}
+	if strings.HasPrefix(digits, "8") {
+		return Fields{}, "", fmt.Errorf("%w: utility and tax bills (starting with 8) are not bank boletos", ErrInvalidLine)
+	}
+
+	var barcode string
+	switch len(digits) {
+	case LineLength:
+		var err error
+		if barcode, err = BarcodeFromLine(digits); err != nil {
+			return Fields{}, "", err
+		}
+	case BarcodeLength:
+		if barcodeCheckDigit(digits[:4]+digits[5:]) != int(digits[4]-'0') {
+			return Fields{}, "", fmt.Errorf("%w: general check digit does not match", ErrInvalidLine)
+		}
+		barcode = digits
+	default:
+		return Fields{}, "", fmt.Errorf("%w: expected %d digits (linha digitável) or %d (barcode), got %d", ErrInvalidLine, LineLength, BarcodeLength, len(digits))
+	}
+	if barcode[3] != currencyReal {
+		return Fields{}, "", fmt.Errorf("%w: currency code %c is not reais", ErrInvalidLine, barcode[3])
+	}
+
+	fields := Fields{BankCode: barcode[0:3], FreeField: barcode[19:44]}
+	factor, _ := strconv.Atoi(barcode[5:9])
+	if factor != 0 {
+		fields.DueDate = dueDate(factor, now)
+	}
+	amount, _ := strconv.ParseInt(barcode[9:19], 10, 64)
+	if amount != 0 {
+		fields.Amount = models.BRL(amount)
+	}
+	return fields, barcode, nil
+}
+
+// FormatLine spaces a linha digitável the way it is printed:
+// AAABC.CCCCX DDDDD.DDDDDY EEEEE.EEEEEZ K UUUUVVVVVVVVVV
+func FormatLine(line string) string {
+	if len(line) != LineLength {
+		return line
+	}
+	return line[0:5] + "." + line[5:10] + " " + line[10:15] + "." + line[15:21] + " " +
+		line[21:26] + "." + line[26:32] + " " + line[32:33] + " " + line[33:47]
+}
+
+// barcodeCheckDigit returns the general check digit of the 43 barcode digits
+// other than itself: mod 11 with weights 2 to 9 from the right, where 0, 10
+// and 11 become 1
+func barcodeCheckDigit(digits string) int {
+	sum, weight := 0, 2
+	for i := len(digits) - 1; i >= 0; i-- {
+		sum += int(digits[i]-'0') * weight
+		if weight++; weight > 9 {
+			weight = 2
+		}
+	}
+	digit := 11 - sum%11
+	if digit == 0 || digit == 10 || digit == 11 {
+		return 1
+	}
+	return digit
+}
+
+// mod10 returns the check digit of a linha digitável field: digits are
+// multiplied by 2 and 1 alternately from the right, products above 9 are
+// replaced by the sum of their digits
+func mod10(digits string) int {
+	sum, weight := 0, 2
+	for i := len(digits) - 1; i >= 0; i-- {
+		product := int(digits[i]-'0') * weight
+		sum += product/10 + product%10
+		weight = 3 - weight
+	}
+	return (10 - sum%10) % 10
+}
+
+// civilDate returns the calendar date of t at midnight UTC
+func civilDate(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// digitsOnly reports whether s is a non-empty string of ASCII digits
+func digitsOnly(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
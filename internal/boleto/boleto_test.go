@@ -0,0 +1,232 @@
+package boleto
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// Banco do Brasil boleto of R$ 1,00 due 2007-12-31, as published by the bank
+const (
+	bbBarcode = "00193373700000001000500940144816060680935031"
+	bbLine    = "00190500954014481606906809350314337370000000100"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestMod10(t *testing.T) {
+	tests := []struct {
+		digits string
+		want   int
+	}{
+		{"001905009", 5},  // Field 1 of bbLine
+		{"4014481606", 9}, // Field 2
+		{"0680935031", 4}, // Field 3
+		{"0", 0},          // A sum ending in 0 gives 0, not 10
+		{"5", 9},          // 5×2 = 10 adds 1+0
+		{"18", 2},         // 8×2 = 16 adds 1+6, then 1×1
+		{"1111111111", 5},
+	}
+	for _, tt := range tests {
+		if got := mod10(tt.digits); got != tt.want {
+			t.Errorf("mod10(%s) = %d, want %d", tt.digits, got, tt.want)
+		}
+	}
+}
+
+func TestBarcodeCheckDigit(t *testing.T) {
+	tests := []struct {
+		digits string
+		want   int
+	}{
+		{bbBarcode[:4] + bbBarcode[5:], 3},
+		{"1", 9},          // 1×2 = 2, 11 - 2
+		{"0", 1},          // Remainder 0 gives 11, printed as 1
+		{"6", 1},          // 6×2 = 12, remainder 1 gives 10, printed as 1
+		{"5", 1},          // 5×2 = 10, remainder 10 gives 1
+		{"100000000", 9},  // Weights restart at 2 after 9: 1×2
+		{"1000000000", 8}, // 1×3
+	}
+	for _, tt := range tests {
+		if got := barcodeCheckDigit(tt.digits); got != tt.want {
+			t.Errorf("barcodeCheckDigit(%s) = %d, want %d", tt.digits, got, tt.want)
+		}
+	}
+}
+
+func TestDueFactor(t *testing.T) {
+	tests := []struct {
+		date time.Time
+		want int
+	}{
+		{date(2000, 7, 3), 1000},
+		{date(2007, 12, 31), 3737}, // bbBarcode
+		{date(2025, 2, 21), 9999},
+		{date(2025, 2, 22), 1000}, // First rollover
+		{date(2025, 2, 23), 1001},
+		{date(2049, 10, 13), 9999},
+		{date(2049, 10, 14), 1000}, // Second rollover
+		{time.Date(2025, 2, 22, 23, 59, 0, 0, time.FixedZone("BRT", -3*60*60)), 1000},
+	}
+	for _, tt := range tests {
+		got, err := DueFactor(tt.date)
+		if err != nil || got != tt.want {
+			t.Errorf("DueFactor(%s) = %d, %v, want %d", tt.date.Format(time.DateOnly), got, err, tt.want)
+		}
+	}
+
+	if _, err := DueFactor(date(2000, 7, 2)); !errors.Is(err, ErrInvalidLine) {
+		t.Errorf("DueFactor(2000-07-02) error = %v, want ErrInvalidLine", err)
+	}
+}
+
+func TestDueDateClosestCycle(t *testing.T) {
+	tests := []struct {
+		factor int
+		now    time.Time
+		want   time.Time
+	}{
+		{3737, date(2008, 1, 15), date(2007, 12, 31)},
+		{1000, date(2000, 6, 1), date(2000, 7, 3)},
+		{1000, date(2025, 1, 10), date(2025, 2, 22)}, // Closer than 2000-07-03
+		{9999, date(2025, 3, 1), date(2025, 2, 21)},  // Overdue, not 2049
+		{1005, date(2025, 3, 1), date(2025, 2, 27)},
+		{1000, date(2049, 10, 1), date(2049, 10, 14)},
+	}
+	for _, tt := range tests {
+		if got := dueDate(tt.factor, tt.now); !got.Equal(tt.want) {
+			t.Errorf("dueDate(%d, %s) = %s, want %s", tt.factor, tt.now.Format(time.DateOnly), got.Format(time.DateOnly), tt.want.Format(time.DateOnly))
+		}
+	}
+}
+
+func TestPublishedLine(t *testing.T) {
+	line, err := DigitableLine(bbBarcode)
+	if err != nil || line != bbLine {
+		t.Errorf("DigitableLine = %s, %v, want %s", line, err, bbLine)
+	}
+	barcode, err := BarcodeFromLine(bbLine)
+	if err != nil || barcode != bbBarcode {
+		t.Errorf("BarcodeFromLine = %s, %v, want %s", barcode, err, bbBarcode)
+	}
+
+	fields, barcode, err := Parse("00190.50095 40144.816069 06809.350314 3 37370000000100", date(2008, 1, 2))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := Fields{BankCode: "001", DueDate: date(2007, 12, 31), Amount: models.BRL(100), FreeField: "0500940144816060680935031"}
+	if barcode != bbBarcode || fields != want {
+		t.Errorf("Parse = %+v, %s, want %+v, %s", fields, barcode, want, bbBarcode)
+	}
+	if got := FormatLine(bbLine); got != "00190.50095 40144.816069 06809.350314 3 37370000000100" {
+		t.Errorf("FormatLine = %s", got)
+	}
+}
+
+func TestBarcodeRoundTrip(t *testing.T) {
+	now := date(2026, 10, 17)
+	tests := []Fields{
+		{BankCode: "001", DueDate: date(2026, 11, 5), Amount: models.BRL(12990), FreeField: "0000001234567000000000117"},
+		{BankCode: "237", DueDate: date(2025, 2, 22), Amount: models.BRL(1), FreeField: "3381260007827139500006330"},
+		{BankCode: "341", DueDate: date(2025, 2, 21), Amount: models.BRL(99_999_999_99), FreeField: "1091234567812345678901000"},
+		{BankCode: "104", FreeField: "9999999999999999999999999"}, // No due date, payer types the amount
+	}
+	for _, fields := range tests {
+		barcode, err := fields.Barcode()
+		if err != nil {
+			t.Fatalf("Barcode(%+v): %v", fields, err)
+		}
+		line, err := DigitableLine(barcode)
+		if err != nil {
+			t.Fatalf("DigitableLine(%s): %v", barcode, err)
+		}
+		back, err := BarcodeFromLine(line)
+		if err != nil || back != barcode {
+			t.Errorf("BarcodeFromLine(%s) = %s, %v, want %s", line, back, err, barcode)
+		}
+
+		for _, input := range []string{barcode, line, FormatLine(line)} {
+			parsed, _, err := Parse(input, now)
+			if err != nil {
+				t.Errorf("Parse(%s): %v", input, err)
+				continue
+			}
+			if !parsed.DueDate.Equal(fields.DueDate) || parsed.Amount != fields.Amount || parsed.BankCode != fields.BankCode || parsed.FreeField != fields.FreeField {
+				t.Errorf("Parse(%s) = %+v, want %+v", input, parsed, fields)
+			}
+		}
+	}
+}
+
+func TestBarcodeRejectsFields(t *testing.T) {
+	valid := Fields{BankCode: "001", DueDate: date(2026, 11, 5), Amount: models.BRL(100), FreeField: "0500940144816060680935031"}
+	tests := []struct {
+		name   string
+		change func(f *Fields)
+	}{
+		{"short bank code", func(f *Fields) { f.BankCode = "01" }},
+		{"letters in bank code", func(f *Fields) { f.BankCode = "0a1" }},
+		{"short free field", func(f *Fields) { f.FreeField = f.FreeField[1:] }},
+		{"negative amount", func(f *Fields) { f.Amount = models.BRL(-1) }},
+		{"amount too large", func(f *Fields) { f.Amount = models.BRL(100_000_000_00) }},
+		{"other currency", func(f *Fields) { f.Amount = models.Money{Amount: 100, Currency: "USD"} }},
+		{"due before factor 1000", func(f *Fields) { f.DueDate = date(2000, 7, 2) }},
+	}
+	for _, tt := range tests {
+		f := valid
+		tt.change(&f)
+		if _, err := f.Barcode(); !errors.Is(err, ErrInvalidLine) {
+			t.Errorf("%s: Barcode error = %v, want ErrInvalidLine", tt.name, err)
+		}
+	}
+}
+
+// Every single wrong digit of the published line is caught by the field or
+// general check digits
+func TestLineRejectsWrongDigit(t *testing.T) {
+	for i := 0; i < LineLength; i++ {
+		for d := byte('0'); d <= '9'; d++ {
+			if d == bbLine[i] {
+				continue
+			}
+			line := bbLine[:i] + string(d) + bbLine[i+1:]
+			if _, err := BarcodeFromLine(line); !errors.Is(err, ErrInvalidLine) {
+				t.Errorf("BarcodeFromLine(%s) with digit %d changed: error = %v, want ErrInvalidLine", line, i, err)
+			}
+		}
+	}
+
+	barcode := bbBarcode[:4] + "4" + bbBarcode[5:]
+	if _, _, err := Parse(barcode, date(2008, 1, 2)); !errors.Is(err, ErrInvalidLine) {
+		t.Errorf("Parse(%s) error = %v, want ErrInvalidLine", barcode, err)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"letters", "0019050095401448160690680935031433737000000010x"},
+		{"too short", bbLine[1:]},
+		{"too long", bbLine + "0"},
+		{"utility bill", "836200000005667800481000180975657313001589636081"},
+		{"other currency", otherCurrency()},
+	}
+	for _, tt := range tests {
+		if _, _, err := Parse(tt.input, date(2008, 1, 2)); !errors.Is(err, ErrInvalidLine) {
+			t.Errorf("%s: Parse error = %v, want ErrInvalidLine", tt.name, err)
+		}
+	}
+}
+
+// otherCurrency returns bbBarcode with currency code 0 and a matching check digit
+func otherCurrency() string {
+	partial := bbBarcode[:3] + "0" + bbBarcode[5:]
+	return partial[:4] + string(rune('0'+barcodeCheckDigit(partial))) + partial[4:]
+}
@@ -0,0 +1,97 @@
+package boleto
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/config"
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// BankBancoDoBrasil is the only bank whose free field layout is implemented
+const BankBancoDoBrasil = "001"
+
+// Issuer is the beneficiary of the boletos of the platform: the account at
+// the bank and the agreement ("convênio") boletos are registered under
+type Issuer struct {
+	BankCode            string
+	Agreement           string // Convênio, 7 digits
+	Wallet              string // Carteira, 2 digits
+	Agency              string // Agência with its check digit, printed only
+	Account             string // Conta with its check digit, printed only
+	BeneficiaryName     string
+	BeneficiaryDocument string // CNPJ
+	DueDays             int    // Days between issuing and the due date
+	WebhookSecret       string // Verifies the settlements sent by the bank
+}
+
+// NewIssuer creates the issuer of the configuration, or nil when boletos are
+// disabled
+func NewIssuer(cfg config.BoletoConfig) *Issuer {
+	if cfg.Agreement == "" {
+		return nil
+	}
+	return &Issuer{
+		BankCode:            cfg.BankCode,
+		Agreement:           cfg.Agreement,
+		Wallet:              cfg.Wallet,
+		Agency:              cfg.Agency,
+		Account:             cfg.Account,
+		BeneficiaryName:     cfg.BeneficiaryName,
+		BeneficiaryDocument: cfg.BeneficiaryDocument,
+		DueDays:             cfg.DueDays,
+		WebhookSecret:       cfg.WebhookSecret,
+	}
+}
+
+// Issued identifies a new boleto
+type Issued struct {
+	OurNumber     string // Nosso número, the boleto's ID at the bank
+	Barcode       string
+	DigitableLine string
+	DueDate       time.Time
+}
+
+// Banco do Brasil's free field for 7-digit agreements: six zeros, then the
+// 17-digit nosso número (agreement and sequence) and the wallet
+const freeFieldPrefix = "000000"
+
+// Issue builds the boleto number sequence of the agreement, charging amount
+// and due DueDays after now
+func (i *Issuer) Issue(sequence uint, amount models.Money, now time.Time) (Issued, error) {
+	if i.BankCode != BankBancoDoBrasil {
+		return Issued{}, fmt.Errorf("boletos of bank %s are not supported", i.BankCode)
+	}
+	if sequence > 9_999_999_999 {
+		return Issued{}, fmt.Errorf("boleto sequence %d does not fit the agreement's 10 digits", sequence)
+	}
+
+	ourNumber := fmt.Sprintf("%s%010d", i.Agreement, sequence)
+	due := civilDate(now).AddDate(0, 0, i.DueDays)
+	fields := Fields{
+		BankCode:  i.BankCode,
+		DueDate:   due,
+		Amount:    amount,
+		FreeField: freeFieldPrefix + ourNumber + i.Wallet,
+	}
+	barcode, err := fields.Barcode()
+	if err != nil {
+		return Issued{}, err
+	}
+	line, err := DigitableLine(barcode)
+	if err != nil {
+		return Issued{}, err
+	}
+	return Issued{OurNumber: ourNumber, Barcode: barcode, DigitableLine: line, DueDate: due}, nil
+}
+
+// OurNumber returns the nosso número in the free field of fields, and false
+// if the boleto was not issued under the agreement of i
+func (i *Issuer) OurNumber(fields Fields) (string, bool) {
+	free := fields.FreeField
+	if fields.BankCode != i.BankCode || len(free) != 25 || free[:6] != freeFieldPrefix ||
+		free[6:13] != i.Agreement || free[23:] != i.Wallet {
+		return "", false
+	}
+	return free[6:23], true
+}
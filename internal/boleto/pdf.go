@@ -0,0 +1,166 @@
+package boleto
+
+import (
+	"fmt"
+
+	"github.com/alexandreffaria/hoby-loop/internal/pdf"
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// Payer is who the boleto is issued to, as printed on it
+type Payer struct {
+	Name     string
+	Document string // CNPJ
+	Address  string
+}
+
+// itfPatterns are the Interleaved 2 of 5 widths of each digit, true for wide
+var itfPatterns = [10][5]bool{
+	{false, false, true, true, false},
+	{true, false, false, false, true},
+	{false, true, false, false, true},
+	{true, true, false, false, false},
+	{false, false, true, false, true},
+	{true, false, true, false, false},
+	{false, true, true, false, false},
+	{false, false, false, true, true},
+	{true, false, false, true, false},
+	{false, true, false, true, false},
+}
+
+// Barcode dimensions in points. The narrow bar is a third of the wide one
+// and the whole barcode is about 103 mm long, as FEBRABAN specifies.
+const (
+	itfNarrow = 0.72
+	itfWide   = 3 * itfNarrow
+	itfHeight = 37
+)
+
+// drawITF draws barcode in Interleaved 2 of 5 with its top left corner at
+// x, y: a start pattern, pairs of digits whose first digit sets the widths of
+// five bars and the second of the five spaces between them, and a stop
+// pattern
+func drawITF(page *pdf.Page, x, y float64, barcode string) {
+	bar := func(width float64) {
+		page.Rect(x, y, width, itfHeight)
+		x += width
+	}
+	space := func(width float64) { x += width }
+	width := func(wide bool) float64 {
+		if wide {
+			return itfWide
+		}
+		return itfNarrow
+	}
+
+	bar(itfNarrow)
+	space(itfNarrow)
+	bar(itfNarrow)
+	space(itfNarrow)
+	for i := 0; i+1 < len(barcode); i += 2 {
+		bars, spaces := itfPatterns[barcode[i]-'0'], itfPatterns[barcode[i+1]-'0']
+		for j := 0; j < 5; j++ {
+			bar(width(bars[j]))
+			space(width(spaces[j]))
+		}
+	}
+	bar(itfWide)
+	space(itfNarrow)
+	bar(itfNarrow)
+}
+
+// PDF renders b as a printable A4 page: the payer's receipt on top and the
+// bank slip ("ficha de compensação") with the barcode below
+func (i *Issuer) PDF(b *models.Boleto, payer Payer) ([]byte, error) {
+	doc := pdf.New("Boleto " + b.OurNumber)
+	page := doc.AddPage()
+
+	const left, right = 36.0, pdf.PageWidth - 36
+	bankHeader := func(y float64, title string) {
+		page.Text(left, y, pdf.HelveticaBold, 14, pdf.Left, "Banco do Brasil")
+		page.Line(left+125, y-14, left+125, y+4, 1)
+		page.Text(left+160, y, pdf.HelveticaBold, 14, pdf.Center, fmt.Sprintf("%s-9", b.BankCode))
+		page.Line(left+195, y-14, left+195, y+4, 1)
+		page.Text(right, y, pdf.HelveticaBold, 10, pdf.Right, title)
+		page.Line(left, y+6, right, y+6, 1.2)
+	}
+	// box prints a labelled value and the line below it
+	box := func(x, y, width float64, label, value string, align pdf.Align) {
+		page.Text(x+2, y+8, pdf.Helvetica, 6, pdf.Left, label)
+		valueX := x + 2
+		if align == pdf.Right {
+			valueX = x + width - 4
+		}
+		page.Text(valueX, y+19, pdf.Helvetica, 9, align, value)
+		page.Line(x, y+23, x+width, y+23, 0.5)
+	}
+	due := b.DueDate.Format("02/01/2006")
+	amount := b.Amount.Format()
+	beneficiary := fmt.Sprintf("%s - CNPJ %s", i.BeneficiaryName, i.BeneficiaryDocument)
+	agencyCode := fmt.Sprintf("%s / %s", i.Agency, i.Account)
+	issuedAt := b.CreatedAt.Format("02/01/2006")
+
+	// Payer's receipt
+	y := 60.0
+	bankHeader(y, "Recibo do Pagador")
+	y += 8
+	box(left, y, 380, "Beneficiário", beneficiary, pdf.Left)
+	box(left+380, y, right-left-380, "Vencimento", due, pdf.Right)
+	y += 24
+	box(left, y, 190, "Agência / Código do Beneficiário", agencyCode, pdf.Left)
+	box(left+190, y, 190, "Nosso Número", b.OurNumber, pdf.Left)
+	box(left+380, y, right-left-380, "Valor do Documento", amount, pdf.Right)
+	y += 24
+	box(left, y, right-left, "Pagador", fmt.Sprintf("%s - CNPJ %s", payer.Name, payer.Document), pdf.Left)
+	y += 36
+	page.Text(left, y, pdf.Courier, 10, pdf.Left, FormatLine(b.DigitableLine))
+	y += 24
+	page.Text(right, y, pdf.Helvetica, 6, pdf.Right, "Autenticação mecânica")
+
+	// Cut line
+	y += 30
+	for x := left; x < right; x += 8 {
+		page.Line(x, y, x+4, y, 0.5)
+	}
+
+	// Bank slip
+	y += 40
+	bankHeader(y, "")
+	page.Text(right, y, pdf.Courier, 8.5, pdf.Right, FormatLine(b.DigitableLine))
+	y += 8
+	box(left, y, 380, "Local de Pagamento", "Pagável em qualquer banco até o vencimento", pdf.Left)
+	box(left+380, y, right-left-380, "Vencimento", due, pdf.Right)
+	y += 24
+	box(left, y, 380, "Beneficiário", beneficiary, pdf.Left)
+	box(left+380, y, right-left-380, "Agência / Código do Beneficiário", agencyCode, pdf.Right)
+	y += 24
+	box(left, y, 95, "Data do Documento", issuedAt, pdf.Left)
+	box(left+95, y, 95, "Nº do Documento", fmt.Sprintf("%d", b.OrderID), pdf.Left)
+	box(left+190, y, 60, "Espécie Doc.", "DM", pdf.Left)
+	box(left+250, y, 40, "Aceite", "N", pdf.Left)
+	box(left+290, y, 90, "Data Processamento", issuedAt, pdf.Left)
+	box(left+380, y, right-left-380, "Nosso Número", b.OurNumber, pdf.Right)
+	y += 24
+	box(left, y, 95, "Uso do Banco", "", pdf.Left)
+	box(left+95, y, 95, "Carteira", i.Wallet, pdf.Left)
+	box(left+190, y, 60, "Espécie", "R$", pdf.Left)
+	box(left+250, y, 130, "Quantidade", "", pdf.Left)
+	box(left+380, y, right-left-380, "(=) Valor do Documento", amount, pdf.Right)
+	y += 24
+	page.Text(left+2, y+8, pdf.Helvetica, 6, pdf.Left, "Instruções (texto de responsabilidade do beneficiário)")
+	page.Text(left+2, y+20, pdf.Helvetica, 8, pdf.Left, fmt.Sprintf("Assinatura Hoby Loop, pedido %d. Não receber após o vencimento.", b.OrderID))
+	for _, label := range []string{"(-) Desconto / Abatimento", "(+) Mora / Multa", "(=) Valor Cobrado"} {
+		box(left+380, y, right-left-380, label, "", pdf.Right)
+		y += 24
+	}
+	page.Line(left, y-1, left+380, y-1, 0.5)
+	page.Line(left+380, y-96, left+380, y-1, 0.5)
+	box(left, y, right-left, "Pagador", fmt.Sprintf("%s - CNPJ %s", payer.Name, payer.Document), pdf.Left)
+	page.Text(left+2, y+32, pdf.Helvetica, 9, pdf.Left, payer.Address)
+	y += 40
+	page.Text(right, y, pdf.Helvetica, 6, pdf.Right, "Autenticação mecânica - Ficha de Compensação")
+	y += 8
+	drawITF(page, left, y, b.Barcode)
+
+	return doc.Bytes()
+}
@@ -0,0 +1,67 @@
+package boleto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// ErrInvalidSignature is returned for settlements not sent by the bank
+var ErrInvalidSignature = errors.New("invalid boleto notification signature")
+
+// ErrInvalidNotification is returned for settlements that cannot be read
+var ErrInvalidNotification = errors.New("invalid boleto notification")
+
+// SignatureHeader carries the hex HMAC-SHA256 of the body of notifications
+const SignatureHeader = "X-Boleto-Signature"
+
+// Settlement is a boleto paid at the bank ("liquidação")
+type Settlement struct {
+	OurNumber string `json:"our_number"` // Nosso número of the paid boleto
+	Amount    string `json:"amount"`     // Decimal amount in reais, e.g. "110.00"
+	PaidAt    string `json:"paid_at"`    // RFC 3339 time of the payment
+}
+
+// Notification is the body of a webhook call from the bank
+type Notification struct {
+	Settlements []Settlement `json:"settlements"`
+}
+
+// Money returns the amount paid
+func (s Settlement) Money() (models.Money, error) {
+	return models.ParseMoney(s.Amount, "BRL")
+}
+
+// Time returns when s was paid
+func (s Settlement) Time() (time.Time, error) {
+	return time.Parse(time.RFC3339, s.PaidAt)
+}
+
+// VerifyNotification checks the SignatureHeader of a webhook call and
+// decodes its settlements
+func (i *Issuer) VerifyNotification(header http.Header, body []byte) ([]Settlement, error) {
+	if !hmac.Equal([]byte(header.Get(SignatureHeader)), []byte(i.Sign(body))) {
+		return nil, ErrInvalidSignature
+	}
+
+	var notification Notification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidNotification, err)
+	}
+	return notification.Settlements, nil
+}
+
+// Sign returns the SignatureHeader value of body, to simulate settlements
+// during development
+func (i *Issuer) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(i.WebhookSecret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -1,47 +1,203 @@
 package controllers
 
 import (
-	"github.com/alexandreffaria/hoby-loop/internal/database"
+	"github.com/alexandreffaria/hoby-loop/internal/auth"
 	"github.com/alexandreffaria/hoby-loop/internal/middleware"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
 	"github.com/alexandreffaria/hoby-loop/models"
 	"github.com/gin-gonic/gin"
 )
 
-// GetAllUsers returns all users in the system (admin only)
+// AdminController handles platform-wide admin endpoints
+type AdminController struct {
+	Users         repository.UserRepository
+	Baskets       repository.BasketRepository
+	Subscriptions repository.SubscriptionRepository
+}
+
+// NewAdminController creates an AdminController
+func NewAdminController(users repository.UserRepository, baskets repository.BasketRepository, subscriptions repository.SubscriptionRepository) *AdminController {
+	return &AdminController{Users: users, Baskets: baskets, Subscriptions: subscriptions}
+}
+
+// userRoles are the roles users can be filtered by
+var userRoles = []string{"seller", "consumer", "admin"}
+
+// GetAllUsers returns a page of the users in the system (admin only),
+// filtered by role, is_active, city, state and created_from/created_to
 // Admin authentication is handled by middleware
-func GetAllUsers(c *gin.Context) {
-	// Get all users
-	var users []models.User
-	if err := database.DB.Find(&users).Error; err != nil {
-		middleware.ServerError(c, err.Error())
+func (ac *AdminController) GetAllUsers(c *gin.Context) {
+	q := newListQuery(c, "role", "is_active", "city", "state", "created_from", "created_to")
+	filter := repository.UserFilter{
+		Role:        queryOneOf(q, "role", userRoles),
+		Active:      q.bool("is_active"),
+		City:        q.text("city"),
+		State:       q.text("state"),
+		CreatedFrom: q.time("created_from", false),
+		CreatedTo:   q.time("created_to", true),
+	}
+	if !q.valid() {
 		return
 	}
 
-	middleware.Success(c, users)
+	page, err := ac.Users.List(c.Request.Context(), filter, q.page)
+	respondPage(c, q, page, err, "users")
 }
 
-// GetAllSubscriptions returns all subscriptions in the system (admin only)
+// GetAllSubscriptions returns a page of the subscriptions in the system
+// (admin only), filtered by status, frequency, seller_id, consumer_id,
+// basket_id, the consumer's city and state and created_from/created_to
 // Admin authentication is handled by middleware
-func GetAllSubscriptions(c *gin.Context) {
-	// Get all subscriptions with eager loading of related entities
-	var subscriptions []models.Subscription
-	if err := database.DB.Preload("User").Preload("Basket").Find(&subscriptions).Error; err != nil {
-		middleware.ServerError(c, err.Error())
+func (ac *AdminController) GetAllSubscriptions(c *gin.Context) {
+	q := newListQuery(c, "status", "frequency", "seller_id", "consumer_id", "basket_id", "city", "state", "created_from", "created_to")
+	filter := repository.SubscriptionFilter{
+		SellerID:    q.id("seller_id"),
+		ConsumerID:  q.id("consumer_id"),
+		BasketID:    q.id("basket_id"),
+		Status:      queryOneOf(q, "status", models.SubscriptionStatuses),
+		Frequency:   queryOneOf(q, "frequency", models.Frequencies),
+		City:        q.text("city"),
+		State:       q.text("state"),
+		CreatedFrom: q.time("created_from", false),
+		CreatedTo:   q.time("created_to", true),
+	}
+	if !q.valid() {
 		return
 	}
 
-	middleware.Success(c, subscriptions)
+	page, err := ac.Subscriptions.List(c.Request.Context(), filter, q.page)
+	respondPage(c, q, page, err, "subscriptions")
 }
 
-// GetAllBaskets returns all baskets in the system (admin only)
+// GetAllBaskets returns a page of the baskets in the system (admin only),
+// filtered by seller_id and created_from/created_to
 // Admin authentication is handled by middleware
-func GetAllBaskets(c *gin.Context) {
-	// Get all baskets
-	var baskets []models.Basket
-	if err := database.DB.Find(&baskets).Error; err != nil {
-		middleware.ServerError(c, err.Error())
+func (ac *AdminController) GetAllBaskets(c *gin.Context) {
+	q := newListQuery(c, "seller_id", "created_from", "created_to")
+	filter := repository.BasketFilter{
+		SellerID:    q.id("seller_id"),
+		CreatedFrom: q.time("created_from", false),
+		CreatedTo:   q.time("created_to", true),
+	}
+	if !q.valid() {
+		return
+	}
+
+	page, err := ac.Baskets.List(c.Request.Context(), filter, q.page)
+	respondPage(c, q, page, err, "baskets")
+}
+
+// PermissionsInput defines request structure for granting permissions
+type PermissionsInput struct {
+	Permissions []auth.Permission `json:"permissions" binding:"required,min=1"`
+}
+
+// GetPermissions lists every permission that can be granted (admin only)
+func (ac *AdminController) GetPermissions(c *gin.Context) {
+	middleware.Success(c, auth.AllPermissions)
+}
+
+// GrantPermissions adds permissions to an admin account
+func (ac *AdminController) GrantPermissions(c *gin.Context) {
+	var input PermissionsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		middleware.BadRequest(c, "Invalid permissions data", err.Error())
+		return
+	}
+
+	for _, permission := range input.Permissions {
+		if !auth.IsValidPermission(permission) {
+			middleware.BadRequest(c, "Unknown permission", string(permission))
+			return
+		}
+	}
+
+	ac.updatePermissions(c, func(set auth.PermissionSet) {
+		for _, permission := range input.Permissions {
+			set[permission] = true
+		}
+	})
+}
+
+// RevokePermission removes a single permission from an admin account
+func (ac *AdminController) RevokePermission(c *gin.Context) {
+	permission := auth.Permission(c.Param("permission"))
+	if !auth.IsValidPermission(permission) {
+		middleware.BadRequest(c, "Unknown permission", string(permission))
+		return
+	}
+
+	ac.updatePermissions(c, func(set auth.PermissionSet) {
+		delete(set, permission)
+	})
+}
+
+// updatePermissions loads the admin from the :id parameter, applies the change and saves it
+func (ac *AdminController) updatePermissions(c *gin.Context, change func(auth.PermissionSet)) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	user, err := ac.Users.FindByID(c.Request.Context(), id)
+	if err != nil {
+		middleware.NotFound(c, "User not found")
+		return
+	}
+
+	if user.Role != "admin" {
+		middleware.BadRequest(c, "Permissions can only be granted to admins", "")
+		return
+	}
+
+	set := auth.ParsePermissions(user.Permissions)
+	change(set)
+	user.Permissions = set.Encode()
+
+	if err := ac.Users.Save(c.Request.Context(), user); err != nil {
+		middleware.ServerError(c, "Failed to update permissions: "+err.Error())
+		return
+	}
+
+	middleware.Success(c, user)
+}
+
+// UpdateUserStatusInput defines request structure for enabling or disabling an account
+type UpdateUserStatusInput struct {
+	IsActive *bool `json:"is_active" binding:"required"`
+}
+
+// UpdateUserStatus enables or disables a user account
+func (ac *AdminController) UpdateUserStatus(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	var input UpdateUserStatusInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		middleware.BadRequest(c, "Invalid status data", err.Error())
+		return
+	}
+
+	user, err := ac.Users.FindByID(c.Request.Context(), id)
+	if err != nil {
+		middleware.NotFound(c, "User not found")
+		return
+	}
+
+	// Prevent admins from locking themselves out
+	current, _ := middleware.CurrentUser(c)
+	if current.ID == user.ID && !*input.IsActive {
+		middleware.BadRequest(c, "You cannot disable your own account", "")
+		return
+	}
+
+	user.IsActive = *input.IsActive
+	if err := ac.Users.Save(c.Request.Context(), user); err != nil {
+		middleware.ServerError(c, "Failed to update user status: "+err.Error())
 		return
 	}
 
-	middleware.Success(c, baskets)
+	middleware.Success(c, user)
 }
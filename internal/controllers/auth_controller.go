@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/config"
+	"github.com/alexandreffaria/hoby-loop/internal/auth"
+	"github.com/alexandreffaria/hoby-loop/internal/middleware"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+	"github.com/gin-gonic/gin"
+)
+
+// SessionResponse is returned by login and token refresh
+type SessionResponse struct {
+	User         models.User `json:"user"`
+	AccessToken  string      `json:"access_token"`
+	RefreshToken string      `json:"refresh_token"`
+	TokenType    string      `json:"token_type"`
+	ExpiresIn    int64       `json:"expires_in"`
+}
+
+// RefreshTokenInput defines request structure for refreshing or revoking a session
+type RefreshTokenInput struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutInput defines request structure for logging out. The refresh token is optional.
+type LogoutInput struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// AuthController handles session token refresh and logout
+type AuthController struct {
+	Users  repository.UserRepository
+	Tokens repository.TokenRepository
+}
+
+// NewAuthController creates an AuthController
+func NewAuthController(users repository.UserRepository, tokens repository.TokenRepository) *AuthController {
+	return &AuthController{Users: users, Tokens: tokens}
+}
+
+// newSession creates an access token and an unsaved refresh token for a user
+func newSession(user models.User) (SessionResponse, models.RefreshToken, error) {
+	cfg := config.GetAuthConfig()
+
+	accessToken, _, err := auth.IssueAccessToken(user.ID, user.Role, cfg.TokenIssuer, cfg.TokenSecret, cfg.AccessTokenTTL)
+	if err != nil {
+		return SessionResponse{}, models.RefreshToken{}, err
+	}
+
+	refreshToken, hash, err := auth.GenerateRefreshToken()
+	if err != nil {
+		return SessionResponse{}, models.RefreshToken{}, err
+	}
+
+	stored := models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(cfg.RefreshTokenTTL),
+	}
+
+	return SessionResponse{
+		User:         user,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(cfg.AccessTokenTTL.Seconds()),
+	}, stored, nil
+}
+
+// issueSession creates and stores a new access token and refresh token pair for a user
+func issueSession(ctx context.Context, tokens repository.TokenRepository, user models.User) (SessionResponse, error) {
+	session, stored, err := newSession(user)
+	if err != nil {
+		return SessionResponse{}, err
+	}
+	if err := tokens.CreateRefreshToken(ctx, &stored); err != nil {
+		return SessionResponse{}, err
+	}
+	return session, nil
+}
+
+// RefreshToken exchanges a valid refresh token for a new token pair.
+// The presented refresh token is rotated; reusing a rotated token revokes
+// every session of that user since it indicates the token was stolen.
+func (ac *AuthController) RefreshToken(c *gin.Context) {
+	var input RefreshTokenInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		middleware.BadRequest(c, "Refresh token is required", err.Error())
+		return
+	}
+
+	ctx := c.Request.Context()
+	stored, err := ac.Tokens.FindRefreshTokenByHash(ctx, auth.HashRefreshToken(input.RefreshToken))
+	if err != nil {
+		middleware.Unauthorized(c)
+		return
+	}
+
+	now := time.Now()
+	if stored.RevokedAt != nil {
+		ac.Tokens.RevokeAllRefreshTokens(ctx, stored.UserID, now)
+		middleware.Unauthorized(c)
+		return
+	}
+	if now.After(stored.ExpiresAt) {
+		middleware.Unauthorized(c)
+		return
+	}
+
+	user, err := ac.Users.FindByID(ctx, stored.UserID)
+	if err != nil || !user.IsActive {
+		middleware.Unauthorized(c)
+		return
+	}
+
+	session, next, err := newSession(*user)
+	if err != nil {
+		middleware.ServerError(c, "Failed to refresh session: "+err.Error())
+		return
+	}
+
+	err = ac.Tokens.RotateRefreshToken(ctx, stored.ID, &next, now)
+	if errors.Is(err, repository.ErrNotFound) {
+		// Someone else rotated this token first
+		middleware.Unauthorized(c)
+		return
+	}
+	if err != nil {
+		middleware.ServerError(c, "Failed to refresh session: "+err.Error())
+		return
+	}
+
+	middleware.Success(c, session)
+}
+
+// Logout revokes the current access token and, if provided, the refresh token
+func (ac *AuthController) Logout(c *gin.Context) {
+	var input LogoutInput
+	// The body is optional, a bare logout still revokes the access token
+	_ = c.ShouldBindJSON(&input)
+
+	claimsValue, _ := c.Get("claims")
+	claims, ok := claimsValue.(auth.Claims)
+	if !ok {
+		middleware.Unauthorized(c)
+		return
+	}
+
+	ctx := c.Request.Context()
+	now := time.Now()
+
+	revoked := models.RevokedToken{
+		TokenID:   claims.ID,
+		ExpiresAt: claims.ExpiresAtTime(),
+	}
+	if err := ac.Tokens.RevokeAccessToken(ctx, &revoked); err != nil {
+		middleware.ServerError(c, "Failed to logout: "+err.Error())
+		return
+	}
+
+	if input.RefreshToken != "" {
+		if err := ac.Tokens.RevokeRefreshToken(ctx, auth.HashRefreshToken(input.RefreshToken), claims.Subject, now); err != nil {
+			middleware.ServerError(c, "Failed to logout: "+err.Error())
+			return
+		}
+	}
+
+	// Entries past their expiry can no longer be used, so drop them
+	ac.Tokens.PurgeExpiredRevocations(ctx, now)
+
+	middleware.Success(c, MessageResponse{Message: "Logged out"})
+}
@@ -1,22 +1,37 @@
 package controllers
 
 import (
-	"github.com/alexandreffaria/hoby-loop/internal/database"
 	"github.com/alexandreffaria/hoby-loop/internal/middleware"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
 	"github.com/alexandreffaria/hoby-loop/models"
 	"github.com/gin-gonic/gin"
 )
 
 // CreateBasketInput defines request structure for creating a basket
 type CreateBasketInput struct {
-	Name        string  `json:"name" binding:"required"`
-	Description string  `json:"description" binding:"required"`
-	Price       float64 `json:"price" binding:"required,gt=0"`
-	SellerID    uint    `json:"seller_id" binding:"required"`
+	Name        string       `json:"name" binding:"required"`
+	Description string       `json:"description" binding:"required"`
+	Price       models.Money `json:"price"` // Centavos (12990), decimal string ("129.90") or {"amount", "currency"}
 }
 
-// CreateBasket handles the creation of a new basket
-func CreateBasket(c *gin.Context) {
+// BasketController handles basket endpoints
+type BasketController struct {
+	Baskets repository.BasketRepository
+}
+
+// NewBasketController creates a BasketController
+func NewBasketController(baskets repository.BasketRepository) *BasketController {
+	return &BasketController{Baskets: baskets}
+}
+
+// CreateBasket handles the creation of a new basket owned by the authenticated seller
+func (bc *BasketController) CreateBasket(c *gin.Context) {
+	seller, ok := middleware.CurrentUser(c)
+	if !ok {
+		middleware.Unauthorized(c)
+		return
+	}
+
 	var input CreateBasketInput
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -24,14 +39,19 @@ func CreateBasket(c *gin.Context) {
 		return
 	}
 
+	if !input.Price.IsPositive() {
+		middleware.BadRequest(c, "Invalid basket data", "price must be greater than zero")
+		return
+	}
+
 	basket := models.Basket{
 		Name:        input.Name,
 		Description: input.Description,
 		Price:       input.Price,
-		UserID:      input.SellerID,
+		UserID:      seller.ID,
 	}
 
-	if err := database.DB.Create(&basket).Error; err != nil {
+	if err := bc.Baskets.Create(c.Request.Context(), &basket); err != nil {
 		middleware.ServerError(c, "Failed to create basket: "+err.Error())
 		return
 	}
@@ -40,11 +60,14 @@ func CreateBasket(c *gin.Context) {
 }
 
 // GetBasket fetches a single basket by ID
-func GetBasket(c *gin.Context) {
-	id := c.Param("id")
-	var basket models.Basket
+func (bc *BasketController) GetBasket(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
 
-	if err := database.DB.First(&basket, id).Error; err != nil {
+	basket, err := bc.Baskets.FindByID(c.Request.Context(), id)
+	if err != nil {
 		middleware.NotFound(c, "Basket not found")
 		return
 	}
@@ -52,15 +75,24 @@ func GetBasket(c *gin.Context) {
 	middleware.Success(c, basket)
 }
 
-// GetSellerBaskets retrieves all baskets created by a seller
-func GetSellerBaskets(c *gin.Context) {
-	sellerID := c.Param("id")
-	var baskets []models.Basket
+// GetSellerBaskets retrieves a page of the baskets created by a seller,
+// filtered by created_from/created_to
+func (bc *BasketController) GetSellerBaskets(c *gin.Context) {
+	sellerID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
 
-	if err := database.DB.Where("user_id = ?", sellerID).Find(&baskets).Error; err != nil {
-		middleware.ServerError(c, "Failed to fetch baskets: "+err.Error())
+	q := newListQuery(c, "created_from", "created_to")
+	filter := repository.BasketFilter{
+		SellerID:    sellerID,
+		CreatedFrom: q.time("created_from", false),
+		CreatedTo:   q.time("created_to", true),
+	}
+	if !q.valid() {
 		return
 	}
 
-	middleware.Success(c, baskets)
-}
\ No newline at end of file
+	page, err := bc.Baskets.List(c.Request.Context(), filter, q.page)
+	respondPage(c, q, page, err, "baskets")
+}
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/alexandreffaria/hoby-loop/internal/boleto"
+	"github.com/alexandreffaria/hoby-loop/internal/middleware"
+	"github.com/alexandreffaria/hoby-loop/internal/payments"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+	"github.com/gin-gonic/gin"
+)
+
+// BoletoLineInput defines request structure for validating a boleto typed by
+// a user
+type BoletoLineInput struct {
+	Line string `json:"line" binding:"required,max=64"` // Linha digitável or barcode, dots and spaces allowed
+}
+
+// GetOrderBoleto returns the boleto paying an order, issuing one when the
+// order has none open. Once paid, the paid boleto is returned.
+func (pc *PaymentController) GetOrderBoleto(c *gin.Context) {
+	b, ok := pc.orderBoleto(c)
+	if !ok {
+		return
+	}
+	middleware.Success(c, b)
+}
+
+// GetOrderBoletoPDF returns the boleto paying an order as a printable PDF
+func (pc *PaymentController) GetOrderBoletoPDF(c *gin.Context) {
+	b, ok := pc.orderBoleto(c)
+	if !ok {
+		return
+	}
+
+	document, err := pc.Payments.BoletoPDF(b)
+	if err != nil {
+		middleware.ServerError(c, "Failed to render boleto: "+err.Error())
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"boleto-%s.pdf\"", b.OurNumber))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/pdf", document)
+}
+
+// orderBoleto issues or loads the boleto of the order in the path, writing
+// the error response when it cannot
+func (pc *PaymentController) orderBoleto(c *gin.Context) (*models.Boleto, bool) {
+	orderID, ok := paramID(c, "id")
+	if !ok {
+		return nil, false
+	}
+
+	b, err := pc.Payments.IssueBoleto(c.Request.Context(), orderID)
+	switch {
+	case errors.Is(err, payments.ErrDisabled):
+		middleware.Error(c, http.StatusServiceUnavailable, "Boletos are disabled", "")
+		return nil, false
+	case errors.Is(err, repository.ErrNotFound):
+		middleware.NotFound(c, "Order not found")
+		return nil, false
+	case errors.Is(err, payments.ErrCNPJRequired):
+		middleware.BadRequest(c, "Boletos require a CNPJ", err.Error())
+		return nil, false
+	case errors.Is(err, payments.ErrAlreadyPaid), errors.Is(err, payments.ErrNotCharged):
+		middleware.Conflict(c, "Order cannot be paid", err.Error())
+		return nil, false
+	case err != nil:
+		middleware.ServerError(c, "Failed to issue boleto: "+err.Error())
+		return nil, false
+	}
+	return b, true
+}
+
+// ValidateBoletoLine checks the check digits of a linha digitável or barcode
+// and decodes its bank, due date and amount
+func (pc *PaymentController) ValidateBoletoLine(c *gin.Context) {
+	var input BoletoLineInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		middleware.BadRequest(c, "Invalid input data", err.Error())
+		return
+	}
+
+	line, err := pc.Payments.ValidateLine(c.Request.Context(), input.Line)
+	switch {
+	case errors.Is(err, boleto.ErrInvalidLine):
+		middleware.BadRequest(c, "Invalid boleto line", err.Error())
+		return
+	case err != nil:
+		middleware.ServerError(c, "Failed to validate boleto: "+err.Error())
+		return
+	}
+
+	middleware.Success(c, line)
+}
+
+// ReceiveBoletoWebhook applies the boleto payments settled by the bank. The
+// call is authenticated by its signature.
+func (pc *PaymentController) ReceiveBoletoWebhook(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPaymentWebhookBody))
+	if err != nil {
+		middleware.BadRequest(c, "Failed to read webhook", err.Error())
+		return
+	}
+
+	results, err := pc.Payments.HandleBoletoNotification(c.Request.Context(), c.Request.Header, body)
+	switch {
+	case errors.Is(err, payments.ErrDisabled):
+		middleware.Error(c, http.StatusServiceUnavailable, "Boletos are disabled", "")
+		return
+	case errors.Is(err, boleto.ErrInvalidSignature):
+		middleware.Error(c, http.StatusUnauthorized, "Invalid webhook signature", "")
+		return
+	case errors.Is(err, boleto.ErrInvalidNotification):
+		middleware.BadRequest(c, "Invalid boleto notification", err.Error())
+		return
+	case err != nil:
+		middleware.ServerError(c, "Failed to reconcile boletos: "+err.Error())
+		return
+	}
+
+	middleware.Success(c, results)
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/alexandreffaria/hoby-loop/internal/invoices"
+	"github.com/alexandreffaria/hoby-loop/internal/middleware"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+	"github.com/gin-gonic/gin"
+)
+
+// InvoiceController serves the invoices of orders and their renderings
+type InvoiceController struct {
+	Invoices *invoices.Service
+}
+
+// NewInvoiceController creates an InvoiceController
+func NewInvoiceController(service *invoices.Service) *InvoiceController {
+	return &InvoiceController{Invoices: service}
+}
+
+// GetConsumerInvoices lists the invoices of a consumer, newest first
+func (ic *InvoiceController) GetConsumerInvoices(c *gin.Context) {
+	consumerID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+	q := newListQuery(c)
+	if !q.valid() {
+		return
+	}
+
+	page, err := ic.Invoices.Invoices.ListByConsumer(c.Request.Context(), consumerID, q.page)
+	for i := range page.Items {
+		if err == nil {
+			err = ic.Invoices.LoadStatus(c.Request.Context(), &page.Items[i])
+		}
+	}
+	respondPage(c, q, page, err, "invoices")
+}
+
+// GetOrderInvoice returns the invoice of an order, issuing it if the order
+// has none yet
+func (ic *InvoiceController) GetOrderInvoice(c *gin.Context) {
+	orderID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	invoice, err := ic.Invoices.Issue(c.Request.Context(), orderID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		middleware.NotFound(c, "Order not found")
+		return
+	case err != nil:
+		middleware.ServerError(c, "Failed to issue invoice: "+err.Error())
+		return
+	}
+	middleware.Success(c, invoice)
+}
+
+// GetInvoice returns an invoice with its lines
+func (ic *InvoiceController) GetInvoice(c *gin.Context) {
+	invoice, ok := ic.invoice(c)
+	if !ok {
+		return
+	}
+	middleware.Success(c, invoice)
+}
+
+// GetInvoicePDF returns an invoice as a printable PDF
+func (ic *InvoiceController) GetInvoicePDF(c *gin.Context) {
+	invoice, ok := ic.invoice(c)
+	if !ok {
+		return
+	}
+
+	document, err := invoices.PDF(invoice)
+	if err != nil {
+		middleware.ServerError(c, "Failed to render invoice: "+err.Error())
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"invoice-%d-%s.pdf\"", invoice.SellerID, invoice.Number))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/pdf", document)
+}
+
+// GetInvoiceHTML returns an invoice as a standalone HTML page
+func (ic *InvoiceController) GetInvoiceHTML(c *gin.Context) {
+	invoice, ok := ic.invoice(c)
+	if !ok {
+		return
+	}
+
+	page, err := invoices.HTML(invoice)
+	if err != nil {
+		middleware.ServerError(c, "Failed to render invoice: "+err.Error())
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+}
+
+// invoice loads the invoice in the path with its payment state, writing the
+// error response when it cannot
+func (ic *InvoiceController) invoice(c *gin.Context) (*models.Invoice, bool) {
+	invoiceID, ok := paramID(c, "id")
+	if !ok {
+		return nil, false
+	}
+
+	invoice, err := ic.Invoices.Invoices.FindByID(c.Request.Context(), invoiceID)
+	if err != nil {
+		middleware.NotFound(c, "Invoice not found")
+		return nil, false
+	}
+	if err := ic.Invoices.LoadStatus(c.Request.Context(), invoice); err != nil {
+		middleware.ServerError(c, "Failed to load invoice: "+err.Error())
+		return nil, false
+	}
+	return invoice, true
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/internal/middleware"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+	"github.com/gin-gonic/gin"
+)
+
+// JobController handles the admin endpoints of the background job outbox
+type JobController struct {
+	Jobs repository.JobRepository
+}
+
+// NewJobController creates a JobController
+func NewJobController(jobs repository.JobRepository) *JobController {
+	return &JobController{Jobs: jobs}
+}
+
+// GetJobs retrieves a page of outbox jobs, newest first, filtered by status
+// and kind
+func (jc *JobController) GetJobs(c *gin.Context) {
+	q := newListQuery(c, "status", "kind")
+	filter := repository.JobFilter{
+		Status: queryOneOf(q, "status", models.JobStatuses),
+		Kind:   q.text("kind"),
+	}
+	if !q.valid() {
+		return
+	}
+
+	page, err := jc.Jobs.List(c.Request.Context(), filter, q.page)
+	respondPage(c, q, page, err, "jobs")
+}
+
+// GetJob retrieves a single outbox job with its payload and last error
+func (jc *JobController) GetJob(c *gin.Context) {
+	jobID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	job, err := jc.Jobs.FindByID(c.Request.Context(), jobID)
+	if err != nil {
+		middleware.NotFound(c, "Job not found")
+		return
+	}
+
+	middleware.Success(c, job)
+}
+
+// ReplayJob queues a dead or done job to run again with a fresh set of attempts
+func (jc *JobController) ReplayJob(c *gin.Context) {
+	jobID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	job, err := jc.Jobs.Replay(c.Request.Context(), jobID, time.Now())
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		middleware.NotFound(c, "Job not found")
+		return
+	case errors.Is(err, repository.ErrConflict):
+		middleware.Conflict(c, "Job cannot be replayed", "only dead and done jobs can be replayed")
+		return
+	case err != nil:
+		middleware.ServerError(c, "Failed to replay job: "+err.Error())
+		return
+	}
+
+	middleware.Success(c, job)
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/internal/middleware"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// pageParams are accepted by every list endpoint
+var pageParams = []string{"limit", "cursor", "sort"}
+
+// listQuery parses the query string of a list endpoint: the page parameters
+// and the filters the endpoint accepts. The first invalid parameter is kept
+// and reported by valid.
+type listQuery struct {
+	c    *gin.Context
+	page repository.PageRequest
+	err  error
+}
+
+// newListQuery parses limit, cursor and sort (e.g. sort=-created_at,name)
+// and rejects parameters that are neither page parameters nor filters
+func newListQuery(c *gin.Context, filters ...string) *listQuery {
+	q := &listQuery{c: c, page: repository.PageRequest{Limit: repository.DefaultPageLimit, Cursor: c.Query("cursor")}}
+
+	allowed := append(slices.Clone(pageParams), filters...)
+	names := make([]string, 0, len(c.Request.URL.Query()))
+	for name := range c.Request.URL.Query() {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if !slices.Contains(allowed, name) {
+			q.fail(fmt.Errorf("unknown parameter %q, expected any of %v", name, allowed))
+		}
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			q.fail(fmt.Errorf("limit must be a number from 1 to %d", repository.MaxPageLimit))
+		}
+		q.page.Limit = min(limit, repository.MaxPageLimit)
+	}
+
+	if raw := c.Query("sort"); raw != "" {
+		for _, field := range strings.Split(raw, ",") {
+			field = strings.TrimSpace(field)
+			name, desc := strings.CutPrefix(field, "-")
+			if name == "" {
+				q.fail(fmt.Errorf("sort %q has an empty field", raw))
+				break
+			}
+			q.page.Sort = append(q.page.Sort, repository.SortField{Field: name, Desc: desc})
+		}
+	}
+	return q
+}
+
+// fail keeps the first invalid parameter
+func (q *listQuery) fail(err error) {
+	if q.err == nil {
+		q.err = err
+	}
+}
+
+// text returns a free text filter
+func (q *listQuery) text(name string) string {
+	return strings.TrimSpace(q.c.Query(name))
+}
+
+// id returns a numeric ID filter, 0 when absent
+func (q *listQuery) id(name string) uint {
+	raw := q.c.Query(name)
+	if raw == "" {
+		return 0
+	}
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil || id == 0 {
+		q.fail(fmt.Errorf("%s must be a positive number", name))
+	}
+	return uint(id)
+}
+
+// bool returns a true/false filter, nil when absent
+func (q *listQuery) bool(name string) *bool {
+	raw := q.c.Query(name)
+	if raw == "" {
+		return nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		q.fail(fmt.Errorf("%s must be true or false", name))
+	}
+	return &value
+}
+
+// time returns a date (2006-01-02) or RFC 3339 time filter, zero when absent.
+// An end bound given as a date includes that whole day.
+func (q *listQuery) time(name string, end bool) time.Time {
+	raw := q.c.Query(name)
+	if raw == "" {
+		return time.Time{}
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t
+	}
+	t, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		q.fail(fmt.Errorf("%s must be a date (2006-01-02) or an RFC 3339 time", name))
+		return time.Time{}
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+// valid responds with 400 and returns false if a parameter was invalid
+func (q *listQuery) valid() bool {
+	if q.err != nil {
+		middleware.BadRequest(q.c, "Invalid query parameters", q.err.Error())
+		return false
+	}
+	return true
+}
+
+// queryOneOf returns a filter that must be one of allowed, empty when absent
+func queryOneOf[S ~string](q *listQuery, name string, allowed []S) S {
+	value := S(q.text(name))
+	if value != "" && !slices.Contains(allowed, value) {
+		q.fail(fmt.Errorf("unknown %s %q, expected one of %v", name, value, allowed))
+	}
+	return value
+}
+
+// respondPage sends one page of a list with its meta block, or the error of
+// the list query. Unknown sort fields and bad cursors are client errors.
+func respondPage[T any](c *gin.Context, q *listQuery, page repository.Page[T], err error, what string) {
+	if errors.Is(err, repository.ErrInvalidPage) {
+		middleware.BadRequest(c, "Invalid query parameters", err.Error())
+		return
+	}
+	if err != nil {
+		middleware.ServerError(c, "Failed to fetch "+what+": "+err.Error())
+		return
+	}
+
+	middleware.SuccessPage(c, page.Items, middleware.Meta{
+		Limit:      q.page.Limit,
+		Count:      len(page.Items),
+		Total:      page.Total,
+		NextCursor: page.NextCursor,
+		HasMore:    page.NextCursor != "",
+	})
+}
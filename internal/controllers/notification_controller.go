@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/alexandreffaria/hoby-loop/internal/middleware"
+	"github.com/alexandreffaria/hoby-loop/internal/notify"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+	"github.com/gin-gonic/gin"
+)
+
+// deliveryStatuses are the values of the status filter of the delivery lists
+var deliveryStatuses = []string{models.DeliverySent, models.DeliveryFailed, models.DeliverySkipped}
+
+// NotificationPreferencesInput maps channels to whether they are enabled,
+// e.g. {"channels": {"email": true, "whatsapp": false}}. Channels left out
+// keep their current setting.
+type NotificationPreferencesInput struct {
+	Channels map[string]bool `json:"channels" binding:"required"`
+}
+
+// ChannelPreference is a channel as shown to the user
+type ChannelPreference struct {
+	Channel   string `json:"channel"`
+	Enabled   bool   `json:"enabled"`
+	Available bool   `json:"available"` // Whether the server has this channel configured
+}
+
+// TemplatesResponse lists the events with notification templates and the supported locales
+type TemplatesResponse struct {
+	Events        []string `json:"events"`
+	Locales       []string `json:"locales"`
+	DefaultLocale string   `json:"default_locale"`
+}
+
+// TemplatePreviewResponse is a template rendered with the sample data of its event
+type TemplatePreviewResponse struct {
+	Locale  string         `json:"locale"`
+	Data    interface{}    `json:"data"`
+	Message notify.Message `json:"message"`
+}
+
+// NotificationController handles notification preference and delivery endpoints
+type NotificationController struct {
+	Notifications repository.NotificationRepository
+	Notifier      *notify.Service
+}
+
+// NewNotificationController creates a NotificationController
+func NewNotificationController(notifications repository.NotificationRepository, notifier *notify.Service) *NotificationController {
+	return &NotificationController{Notifications: notifications, Notifier: notifier}
+}
+
+// GetPreferences lists every channel and whether the user receives notifications on it
+func (nc *NotificationController) GetPreferences(c *gin.Context) {
+	userID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	preferences, err := nc.preferences(c, userID)
+	if err != nil {
+		middleware.ServerError(c, "Failed to fetch notification preferences: "+err.Error())
+		return
+	}
+
+	middleware.Success(c, preferences)
+}
+
+// UpdatePreferences enables or disables channels for the user
+func (nc *NotificationController) UpdatePreferences(c *gin.Context) {
+	userID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	var input NotificationPreferencesInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		middleware.BadRequest(c, "Invalid notification preferences", err.Error())
+		return
+	}
+	for channel := range input.Channels {
+		if !slices.Contains(models.NotificationChannels, channel) {
+			middleware.BadRequest(c, "Invalid notification preferences", "unknown channel "+strconv.Quote(channel))
+			return
+		}
+	}
+
+	current, err := nc.preferences(c, userID)
+	if err != nil {
+		middleware.ServerError(c, "Failed to fetch notification preferences: "+err.Error())
+		return
+	}
+
+	updated := make([]models.NotificationPreference, 0, len(current))
+	for _, preference := range current {
+		enabled := preference.Enabled
+		if value, ok := input.Channels[preference.Channel]; ok {
+			enabled = value
+		}
+		updated = append(updated, models.NotificationPreference{Channel: preference.Channel, Enabled: enabled})
+	}
+
+	if err := nc.Notifications.SavePreferences(c.Request.Context(), userID, updated); err != nil {
+		middleware.ServerError(c, "Failed to save notification preferences: "+err.Error())
+		return
+	}
+
+	nc.GetPreferences(c)
+}
+
+// GetUserNotifications retrieves a page of the notifications sent to a
+// user, newest first, filtered by status
+func (nc *NotificationController) GetUserNotifications(c *gin.Context) {
+	userID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	q := newListQuery(c, "status")
+	filter := repository.DeliveryFilter{
+		UserID: userID,
+		Status: queryOneOf(q, "status", deliveryStatuses),
+	}
+	if !q.valid() {
+		return
+	}
+
+	page, err := nc.Notifications.ListDeliveries(c.Request.Context(), filter, q.page)
+	respondPage(c, q, page, err, "notifications")
+}
+
+// GetAllNotifications retrieves a page of delivery results across users for
+// admins, newest first, filtered by status and user_id
+func (nc *NotificationController) GetAllNotifications(c *gin.Context) {
+	q := newListQuery(c, "status", "user_id")
+	filter := repository.DeliveryFilter{
+		UserID: q.id("user_id"),
+		Status: queryOneOf(q, "status", deliveryStatuses),
+	}
+	if !q.valid() {
+		return
+	}
+
+	page, err := nc.Notifications.ListDeliveries(c.Request.Context(), filter, q.page)
+	respondPage(c, q, page, err, "notifications")
+}
+
+// GetTemplates lists the events with notification templates and the supported locales
+func (nc *NotificationController) GetTemplates(c *gin.Context) {
+	middleware.Success(c, TemplatesResponse{
+		Events:        nc.Notifier.Templates.Events(),
+		Locales:       models.Locales,
+		DefaultLocale: nc.Notifier.Templates.DefaultLocale,
+	})
+}
+
+// PreviewTemplate renders the templates of an event with sample data,
+// in the locale given by ?locale= or the default locale
+func (nc *NotificationController) PreviewTemplate(c *gin.Context) {
+	event := c.Param("event")
+	data, ok := notify.SampleData(event)
+	if !ok {
+		middleware.NotFound(c, "Template not found")
+		return
+	}
+
+	locale := nc.Notifier.Templates.DefaultLocale
+	if raw := c.Query("locale"); raw != "" {
+		if locale, ok = notify.NormalizeLocale(raw); !ok {
+			middleware.BadRequest(c, "Invalid locale", fmt.Sprintf("expected one of %v", models.Locales))
+			return
+		}
+	}
+
+	msg, err := nc.Notifier.Templates.Render(event, locale, data)
+	if err != nil {
+		middleware.ServerError(c, "Failed to render template: "+err.Error())
+		return
+	}
+
+	middleware.Success(c, TemplatePreviewResponse{
+		Locale:  locale,
+		Data:    data,
+		Message: msg,
+	})
+}
+
+// preferences returns the effective setting of every channel for a user
+func (nc *NotificationController) preferences(c *gin.Context, userID uint) ([]ChannelPreference, error) {
+	enabled, err := nc.Notifier.Channels(c.Request.Context(), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	preferences := make([]ChannelPreference, 0, len(models.NotificationChannels))
+	for _, channel := range models.NotificationChannels {
+		preferences = append(preferences, ChannelPreference{
+			Channel:   channel,
+			Enabled:   slices.Contains(enabled, channel),
+			Available: nc.Notifier.Available(channel),
+		})
+	}
+	return preferences, nil
+}
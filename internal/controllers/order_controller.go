@@ -1,23 +1,45 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
-	"github.com/alexandreffaria/hoby-loop/internal/database"
+	"github.com/alexandreffaria/hoby-loop/internal/auth"
+	"github.com/alexandreffaria/hoby-loop/internal/invoices"
 	"github.com/alexandreffaria/hoby-loop/internal/middleware"
+	"github.com/alexandreffaria/hoby-loop/internal/outbox"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/internal/webhooks"
 	"github.com/alexandreffaria/hoby-loop/models"
 	"github.com/gin-gonic/gin"
 )
 
-// CreateOrderInput defines request structure for creating an order
+// CreateOrderInput defines request structure for creating an order.
+// New orders always start as preparing.
 type CreateOrderInput struct {
-	SubscriptionID uint   `json:"subscription_id" binding:"required"`
-	Status         string `json:"status" binding:"required,oneof=Processing Shipped Delivered Cancelled"`
+	SubscriptionID uint `json:"subscription_id" binding:"required"`
+}
+
+// CreateOrderResponse is returned when an order is created
+type CreateOrderResponse struct {
+	Order   models.Order `json:"order"`
+	Message string       `json:"message"`
+}
+
+// OrderController handles order endpoints
+type OrderController struct {
+	Orders        repository.OrderRepository
+	Subscriptions repository.SubscriptionRepository
+}
+
+// NewOrderController creates an OrderController
+func NewOrderController(orders repository.OrderRepository, subscriptions repository.SubscriptionRepository) *OrderController {
+	return &OrderController{Orders: orders, Subscriptions: subscriptions}
 }
 
 // CreateOrder handles the creation of a new delivery order
-func CreateOrder(c *gin.Context) {
+func (oc *OrderController) CreateOrder(c *gin.Context) {
 	var input CreateOrderInput
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -26,140 +48,230 @@ func CreateOrder(c *gin.Context) {
 	}
 
 	// Verify subscription exists
-	var subscription models.Subscription
-	if err := database.DB.First(&subscription, input.SubscriptionID).Error; err != nil {
+	subscription, err := oc.Subscriptions.FindByID(c.Request.Context(), input.SubscriptionID)
+	if err != nil {
 		middleware.NotFound(c, "Subscription not found")
 		return
 	}
 
+	// Only the seller of the subscribed basket may create orders for it
+	user, _ := middleware.CurrentUser(c)
+	if !middleware.HasPermission(user, auth.PermissionOrdersWrite) && subscription.Basket.UserID != user.ID {
+		middleware.Forbidden(c, "You do not have access to this subscription")
+		return
+	}
+
 	order := models.Order{
 		SubscriptionID: input.SubscriptionID,
-		Status:         input.Status,
+		Status:         models.OrderPreparing,
+	}
+	event := models.OrderEvent{
+		ActorID:  user.ID,
+		Type:     models.OrderEventCreated,
+		ToStatus: order.Status,
 	}
 
-	if err := database.DB.Create(&order).Error; err != nil {
+	// The notification and webhooks are queued in the same transaction as the order
+	if err := oc.Orders.Create(c.Request.Context(), &order, &event, OrderCreatedJobs); err != nil {
 		middleware.ServerError(c, "Could not create order: "+err.Error())
 		return
 	}
 
-	// Send notification in the background
-	go sendOrderNotification(input.SubscriptionID, input.Status)
-
-	middleware.Success(c, map[string]interface{}{
-		"order":   order,
-		"message": "Order created and notification queued",
+	middleware.Success(c, CreateOrderResponse{
+		Order:   order,
+		Message: "Order created and notification queued",
 	})
 }
 
-// sendOrderNotification sends a notification about order status
-func sendOrderNotification(subscriptionID uint, status string) {
-	var sub models.Subscription
-	
-	// Fetch subscription with related data
-	database.DB.Preload("User").Preload("Basket").First(&sub, subscriptionID)
-	
-	// In a real app, this would send an email or push notification
-	fmt.Printf("\n--- 🔔 NOTIFICATION SENT ---\n")
-	fmt.Printf("To: %s <%s>\n", sub.User.Name, sub.User.Email)
-	fmt.Printf("Message: Your '%s' is now %s!\n", sub.Basket.Name, status)
-	fmt.Printf("----------------------------\n")
-}
+// OrderCreatedJobs queues the subscriber notification, the order.created
+// webhook and the invoice of a new order. Orders created by sellers and by
+// the recurring order scheduler share it.
+var OrderCreatedJobs = repository.JoinJobs(outbox.OrderNotificationJobs, webhooks.OrderCreatedJobs, invoices.IssueJobs)
 
-// GetSubscriptionOrders retrieves all orders for a specific subscription
-func GetSubscriptionOrders(c *gin.Context) {
-	subscriptionID := c.Param("id")
-	var orders []models.Order
+// GetSubscriptionOrders retrieves a page of the orders of a subscription,
+// newest first, filtered by status, created_from/created_to and
+// scheduled_from/scheduled_to
+func (oc *OrderController) GetSubscriptionOrders(c *gin.Context) {
+	subscriptionID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
 
-	if err := database.DB.Where("subscription_id = ?", subscriptionID).
-		Order("created_at DESC").
-		Find(&orders).Error; err != nil {
-		middleware.ServerError(c, "Failed to fetch orders: "+err.Error())
+	q := newListQuery(c, "status", "created_from", "created_to", "scheduled_from", "scheduled_to")
+	filter := repository.OrderFilter{
+		SubscriptionID: subscriptionID,
+		Status:         queryOneOf(q, "status", models.OrderStatuses),
+		CreatedFrom:    q.time("created_from", false),
+		CreatedTo:      q.time("created_to", true),
+		ScheduledFrom:  q.time("scheduled_from", false),
+		ScheduledTo:    q.time("scheduled_to", true),
+	}
+	if !q.valid() {
 		return
 	}
 
-	middleware.Success(c, orders)
+	page, err := oc.Orders.List(c.Request.Context(), filter, q.page)
+	respondPage(c, q, page, err, "orders")
 }
 
-// GetBasketOrders retrieves all orders for baskets owned by a seller
-func GetBasketOrders(c *gin.Context) {
-	basketID := c.Param("id")
-	var orders []models.Order
+// GetBasketOrders retrieves a page of the orders of a basket, newest first,
+// filtered by status, subscription_id, created_from/created_to and
+// scheduled_from/scheduled_to
+func (oc *OrderController) GetBasketOrders(c *gin.Context) {
+	basketID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
 
-	// Get all subscriptions for this basket, then get their orders
-	if err := database.DB.Joins("JOIN subscriptions ON subscriptions.id = orders.subscription_id").
-		Where("subscriptions.basket_id = ?", basketID).
-		Preload("Subscription").
-		Preload("Subscription.User").
-		Preload("Subscription.Basket").
-		Order("orders.created_at DESC").
-		Find(&orders).Error; err != nil {
-		middleware.ServerError(c, "Failed to fetch orders: "+err.Error())
+	q := newListQuery(c, "status", "subscription_id", "created_from", "created_to", "scheduled_from", "scheduled_to")
+	filter := repository.OrderFilter{
+		BasketID:       basketID,
+		SubscriptionID: q.id("subscription_id"),
+		Status:         queryOneOf(q, "status", models.OrderStatuses),
+		CreatedFrom:    q.time("created_from", false),
+		CreatedTo:      q.time("created_to", true),
+		ScheduledFrom:  q.time("scheduled_from", false),
+		ScheduledTo:    q.time("scheduled_to", true),
+	}
+	if !q.valid() {
 		return
 	}
 
-	middleware.Success(c, orders)
+	page, err := oc.Orders.List(c.Request.Context(), filter, q.page)
+	respondPage(c, q, page, err, "orders")
 }
 
 // UpdateOrderStatusInput defines request structure for updating order status
 type UpdateOrderStatusInput struct {
-	Status       string `json:"status" binding:"required,oneof=preparing shipped delivered"`
-	TrackingCode string `json:"tracking_code,omitempty"`
+	Status       models.OrderStatus `json:"status" binding:"required"`
+	TrackingCode string             `json:"tracking_code,omitempty"`
+	Note         string             `json:"note,omitempty" binding:"max=500"` // Recorded on the order timeline
 }
 
-// UpdateOrderStatus updates the status of an order
-func UpdateOrderStatus(c *gin.Context) {
-	orderID := c.Param("id")
+// UpdateOrderStatus updates the status of an order and records the change on its timeline
+func (oc *OrderController) UpdateOrderStatus(c *gin.Context) {
+	actor, ok := middleware.CurrentUser(c)
+	if !ok {
+		middleware.Unauthorized(c)
+		return
+	}
+
+	orderID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
 	var input UpdateOrderStatusInput
 
 	if err := c.ShouldBindJSON(&input); err != nil {
 		middleware.BadRequest(c, "Invalid status data", err.Error())
 		return
 	}
+	if !input.Status.IsValid() {
+		middleware.BadRequest(c, "Invalid status data", fmt.Sprintf("unknown status %q, expected one of %v", input.Status, models.OrderStatuses))
+		return
+	}
 
-	var order models.Order
-	if err := database.DB.First(&order, orderID).Error; err != nil {
+	order, err := oc.Orders.FindByID(c.Request.Context(), orderID)
+	if err != nil {
 		middleware.NotFound(c, "Order not found")
 		return
 	}
 
-	// Update status
-	order.Status = input.Status
-	if input.TrackingCode != "" {
+	from := order.Status
+	now := time.Now()
+	var events []models.OrderEvent
+
+	// Only transitions allowed by the order state machine are accepted.
+	// Repeating the current status just updates the tracking code.
+	if input.Status != from {
+		if err := order.TransitionTo(input.Status, now); err != nil {
+			if errors.Is(err, models.ErrInvalidOrderTransition) {
+				middleware.Conflict(c, "Order status cannot be changed", err.Error())
+				return
+			}
+			middleware.ServerError(c, "Failed to update order: "+err.Error())
+			return
+		}
+		events = append(events, models.OrderEvent{
+			Type:       models.OrderEventStatusChanged,
+			FromStatus: from,
+			ToStatus:   order.Status,
+		})
+	}
+	if input.TrackingCode != "" && input.TrackingCode != order.TrackingCode {
 		order.TrackingCode = input.TrackingCode
+		if len(events) == 0 {
+			events = append(events, models.OrderEvent{Type: models.OrderEventTrackingUpdated})
+		}
 	}
-
-	// Set timestamps based on status
-	now := time.Now()
-	if input.Status == "shipped" && order.ShippedAt == nil {
-		order.ShippedAt = &now
+	if len(events) == 0 && input.Note != "" {
+		events = append(events, models.OrderEvent{Type: models.OrderEventNote})
+	}
+	for i := range events {
+		events[i].ActorID = actor.ID
+		events[i].TrackingCode = order.TrackingCode
+		events[i].Note = input.Note
+		events[i].CreatedAt = now
 	}
-	if input.Status == "delivered" && order.DeliveredAt == nil {
-		order.DeliveredAt = &now
+
+	// Notify the consumer and the seller's webhooks about status changes
+	// once the change is committed
+	var jobs []models.OutboxJob
+	if order.Status != from {
+		notification, err := outbox.NewOrderNotification(*order)
+		if err != nil {
+			middleware.ServerError(c, "Failed to update order: "+err.Error())
+			return
+		}
+		webhook, err := webhooks.OrderJob(webhooks.EventOrderStatusChanged, *order, from)
+		if err != nil {
+			middleware.ServerError(c, "Failed to update order: "+err.Error())
+			return
+		}
+		jobs = append(jobs, notification, webhook)
 	}
 
-	if err := database.DB.Save(&order).Error; err != nil {
+	if err := oc.Orders.Transition(c.Request.Context(), order, from, events, jobs); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			middleware.Conflict(c, "Order was changed by another request", "reload the order and try again")
+			return
+		}
 		middleware.ServerError(c, "Failed to update order: "+err.Error())
 		return
 	}
 
-	// Send notification
-	go sendOrderNotification(order.SubscriptionID, input.Status)
-
 	middleware.Success(c, order)
 }
 
 // GetOrder retrieves a single order by ID
-func GetOrder(c *gin.Context) {
-	orderID := c.Param("id")
-	var order models.Order
-
-	if err := database.DB.Preload("Subscription").
-		Preload("Subscription.User").
-		Preload("Subscription.Basket").
-		First(&order, orderID).Error; err != nil {
+func (oc *OrderController) GetOrder(c *gin.Context) {
+	orderID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	order, err := oc.Orders.FindByID(c.Request.Context(), orderID)
+	if err != nil {
 		middleware.NotFound(c, "Order not found")
 		return
 	}
 
 	middleware.Success(c, order)
-}
\ No newline at end of file
+}
+
+// GetOrderTimeline lists every recorded change of an order, oldest first
+func (oc *OrderController) GetOrderTimeline(c *gin.Context) {
+	orderID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	events, err := oc.Orders.ListEvents(c.Request.Context(), orderID)
+	if err != nil {
+		middleware.ServerError(c, "Failed to fetch order timeline: "+err.Error())
+		return
+	}
+
+	middleware.Success(c, events)
+}
@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/alexandreffaria/hoby-loop/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// MessageResponse is returned by endpoints that have nothing else to report
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// paramID parses a numeric route parameter, responding with 400 when it is invalid
+func paramID(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil {
+		middleware.BadRequest(c, "Invalid ID", err.Error())
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,272 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/alexandreffaria/hoby-loop/internal/middleware"
+	"github.com/alexandreffaria/hoby-loop/internal/payments"
+	"github.com/alexandreffaria/hoby-loop/internal/pix"
+	"github.com/alexandreffaria/hoby-loop/internal/qrcode"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+	"github.com/gin-gonic/gin"
+)
+
+// maxPaymentWebhookBody caps the body read from payment provider calls
+const maxPaymentWebhookBody = 1 << 20
+
+// pixQRScale is the size in pixels of the modules of Pix QR codes
+const pixQRScale = 8
+
+// CardInput holds the card details passed to the payment provider. They are
+// not stored, only the provider's token and the last four digits.
+type CardInput struct {
+	Number     string `json:"number" binding:"required"`
+	HolderName string `json:"holder_name" binding:"required,max=100"`
+	ExpMonth   int    `json:"exp_month" binding:"required,min=1,max=12"`
+	ExpYear    int    `json:"exp_year" binding:"required"`
+	CVC        string `json:"cvc" binding:"required"`
+}
+
+// PaymentMethodInput defines request structure for setting the payment
+// method of a subscription. Card is required for the card method.
+type PaymentMethodInput struct {
+	Method string     `json:"method" binding:"required,oneof=card pix boleto"`
+	Card   *CardInput `json:"card"`
+}
+
+// PixChargeResponse is the Pix payment of an order with its "copia e cola" code
+type PixChargeResponse struct {
+	Payment    *models.Payment `json:"payment"`
+	CopiaECola string          `json:"copia_e_cola"`
+}
+
+// PaymentController handles payment methods, charges and provider webhooks
+type PaymentController struct {
+	Payments      *payments.Service
+	Subscriptions repository.SubscriptionRepository
+}
+
+// NewPaymentController creates a PaymentController
+func NewPaymentController(service *payments.Service, subscriptions repository.SubscriptionRepository) *PaymentController {
+	return &PaymentController{Payments: service, Subscriptions: subscriptions}
+}
+
+// UpdatePaymentMethod sets the card charged for the orders of a subscription
+func (pc *PaymentController) UpdatePaymentMethod(c *gin.Context) {
+	subscriptionID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	var input PaymentMethodInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		middleware.BadRequest(c, "Invalid payment method", err.Error())
+		return
+	}
+	if input.Method == models.PaymentMethodCard && input.Card == nil {
+		middleware.BadRequest(c, "Invalid payment method", "card is required for the card method")
+		return
+	}
+
+	subscription, err := pc.Subscriptions.FindByID(c.Request.Context(), subscriptionID)
+	if err != nil {
+		middleware.NotFound(c, "Subscription not found")
+		return
+	}
+
+	switch input.Method {
+	case models.PaymentMethodPix:
+		err = pc.Payments.UsePix(c.Request.Context(), subscription)
+	case models.PaymentMethodBoleto:
+		err = pc.Payments.UseBoleto(c.Request.Context(), subscription)
+	default:
+		card := payments.Card{
+			Number:     input.Card.Number,
+			HolderName: input.Card.HolderName,
+			ExpMonth:   input.Card.ExpMonth,
+			ExpYear:    input.Card.ExpYear,
+			CVC:        input.Card.CVC,
+		}
+		err = pc.Payments.SaveCard(c.Request.Context(), subscription, card)
+	}
+	switch {
+	case errors.Is(err, payments.ErrDisabled):
+		middleware.Error(c, http.StatusServiceUnavailable, "Payments are disabled", "")
+		return
+	case errors.Is(err, payments.ErrInvalidCard):
+		middleware.BadRequest(c, "Invalid card", err.Error())
+		return
+	case errors.Is(err, payments.ErrCNPJRequired):
+		middleware.BadRequest(c, "Boletos require a CNPJ", err.Error())
+		return
+	case err != nil:
+		middleware.ServerError(c, "Failed to save payment method: "+err.Error())
+		return
+	}
+
+	middleware.Success(c, subscription)
+}
+
+// GetOrderPayments lists the charge attempts of an order, oldest first
+func (pc *PaymentController) GetOrderPayments(c *gin.Context) {
+	orderID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	list, err := pc.Payments.Payments.ListByOrder(c.Request.Context(), orderID)
+	if err != nil {
+		middleware.ServerError(c, "Failed to fetch payments: "+err.Error())
+		return
+	}
+
+	middleware.Success(c, list)
+}
+
+// GetDunning returns a page of the subscriptions in dunning, past due after
+// a failed charge, with their retry progress (admin only), filtered by
+// seller_id, consumer_id and basket_id
+// Admin authentication is handled by middleware
+func (pc *PaymentController) GetDunning(c *gin.Context) {
+	q := newListQuery(c, "seller_id", "consumer_id", "basket_id")
+	filter := repository.SubscriptionFilter{
+		SellerID:   q.id("seller_id"),
+		ConsumerID: q.id("consumer_id"),
+		BasketID:   q.id("basket_id"),
+	}
+	if !q.valid() {
+		return
+	}
+
+	page, err := pc.Payments.DunningReport(c.Request.Context(), filter, q.page)
+	respondPage(c, q, page, err, "subscriptions in dunning")
+}
+
+// RefundPayment gives the full amount of a succeeded payment back to the subscriber
+func (pc *PaymentController) RefundPayment(c *gin.Context) {
+	paymentID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	payment, err := pc.Payments.Payments.FindByID(c.Request.Context(), paymentID)
+	if err != nil {
+		middleware.NotFound(c, "Payment not found")
+		return
+	}
+
+	err = pc.Payments.Refund(c.Request.Context(), payment)
+	switch {
+	case errors.Is(err, payments.ErrDisabled):
+		middleware.Error(c, http.StatusServiceUnavailable, "Payments are disabled", "")
+		return
+	case errors.Is(err, payments.ErrNotRefundable):
+		middleware.Conflict(c, "Payment cannot be refunded", err.Error())
+		return
+	case err != nil:
+		middleware.ServerError(c, "Failed to refund payment: "+err.Error())
+		return
+	}
+
+	middleware.Success(c, payment)
+}
+
+// ReceiveWebhook applies a charge update sent by the payment provider. The
+// call is authenticated by the provider's signature instead of a token.
+func (pc *PaymentController) ReceiveWebhook(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPaymentWebhookBody))
+	if err != nil {
+		middleware.BadRequest(c, "Failed to read webhook", err.Error())
+		return
+	}
+
+	_, err = pc.Payments.HandleWebhook(c.Request.Context(), c.Request.Header, body)
+	switch {
+	case errors.Is(err, payments.ErrDisabled):
+		middleware.Error(c, http.StatusServiceUnavailable, "Payments are disabled", "")
+		return
+	case errors.Is(err, payments.ErrInvalidSignature):
+		middleware.Error(c, http.StatusUnauthorized, "Invalid webhook signature", "")
+		return
+	case err != nil:
+		middleware.ServerError(c, "Failed to process webhook: "+err.Error())
+		return
+	}
+
+	middleware.Success(c, MessageResponse{Message: "Event processed"})
+}
+
+// GetOrderPix returns the Pix QR code paying an order as a PNG image, or its
+// payment and "copia e cola" code when the client accepts only JSON. The same
+// pending payment is returned until it is paid.
+func (pc *PaymentController) GetOrderPix(c *gin.Context) {
+	orderID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	payment, code, err := pc.Payments.PixCharge(c.Request.Context(), orderID)
+	switch {
+	case errors.Is(err, payments.ErrDisabled):
+		middleware.Error(c, http.StatusServiceUnavailable, "Pix is disabled", "")
+		return
+	case errors.Is(err, repository.ErrNotFound):
+		middleware.NotFound(c, "Order not found")
+		return
+	case errors.Is(err, payments.ErrAlreadyPaid), errors.Is(err, payments.ErrNotCharged):
+		middleware.Conflict(c, "Order cannot be paid", err.Error())
+		return
+	case err != nil:
+		middleware.ServerError(c, "Failed to create Pix charge: "+err.Error())
+		return
+	}
+
+	if c.NegotiateFormat("image/png", gin.MIMEJSON) == gin.MIMEJSON {
+		middleware.Success(c, PixChargeResponse{Payment: payment, CopiaECola: code})
+		return
+	}
+
+	qr, err := qrcode.Encode([]byte(code))
+	if err != nil {
+		middleware.ServerError(c, "Failed to encode Pix QR code: "+err.Error())
+		return
+	}
+	image, err := qr.PNG(pixQRScale)
+	if err != nil {
+		middleware.ServerError(c, "Failed to render Pix QR code: "+err.Error())
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "image/png", image)
+}
+
+// ReceivePixWebhook reconciles the Pix transfers confirmed by the bank with
+// pending payments. The call is authenticated by its signature.
+func (pc *PaymentController) ReceivePixWebhook(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPaymentWebhookBody))
+	if err != nil {
+		middleware.BadRequest(c, "Failed to read webhook", err.Error())
+		return
+	}
+
+	results, err := pc.Payments.HandlePixNotification(c.Request.Context(), c.Request.Header, body)
+	switch {
+	case errors.Is(err, payments.ErrDisabled):
+		middleware.Error(c, http.StatusServiceUnavailable, "Pix is disabled", "")
+		return
+	case errors.Is(err, pix.ErrInvalidSignature):
+		middleware.Error(c, http.StatusUnauthorized, "Invalid webhook signature", "")
+		return
+	case errors.Is(err, pix.ErrInvalidNotification):
+		middleware.BadRequest(c, "Invalid Pix notification", err.Error())
+		return
+	case err != nil:
+		middleware.ServerError(c, "Failed to reconcile Pix: "+err.Error())
+		return
+	}
+
+	middleware.Success(c, results)
+}
@@ -1,21 +1,58 @@
 package controllers
 
 import (
-	"github.com/alexandreffaria/hoby-loop/internal/database"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/config"
+	"github.com/alexandreffaria/hoby-loop/internal/lifecycle"
 	"github.com/alexandreffaria/hoby-loop/internal/middleware"
+	"github.com/alexandreffaria/hoby-loop/internal/payments"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/internal/webhooks"
 	"github.com/alexandreffaria/hoby-loop/models"
 	"github.com/gin-gonic/gin"
 )
 
 // CreateSubscriptionInput defines request structure for creating a subscription
 type CreateSubscriptionInput struct {
-	UserID    uint   `json:"user_id" binding:"required"`
 	BasketID  uint   `json:"basket_id" binding:"required"`
 	Frequency string `json:"frequency" binding:"required,oneof=weekly biweekly monthly"`
 }
 
-// CreateSubscription handles the creation of a new subscription
-func CreateSubscription(c *gin.Context) {
+// PauseSubscriptionInput defines request structure for pausing a subscription.
+// Until accepts a date (2026-12-01) or an RFC 3339 time; without it the
+// subscription stays paused until resumed.
+type PauseSubscriptionInput struct {
+	Until  string `json:"until"`
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// CancelSubscriptionInput defines request structure for cancelling a subscription
+type CancelSubscriptionInput struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// SubscriptionController handles subscription endpoints
+type SubscriptionController struct {
+	Subscriptions repository.SubscriptionRepository
+	Baskets       repository.BasketRepository
+}
+
+// NewSubscriptionController creates a SubscriptionController
+func NewSubscriptionController(subscriptions repository.SubscriptionRepository, baskets repository.BasketRepository) *SubscriptionController {
+	return &SubscriptionController{Subscriptions: subscriptions, Baskets: baskets}
+}
+
+// CreateSubscription subscribes the authenticated consumer to a basket
+func (sc *SubscriptionController) CreateSubscription(c *gin.Context) {
+	consumer, ok := middleware.CurrentUser(c)
+	if !ok {
+		middleware.Unauthorized(c)
+		return
+	}
+
 	var input CreateSubscriptionInput
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -23,14 +60,32 @@ func CreateSubscription(c *gin.Context) {
 		return
 	}
 
+	// Verify basket exists
+	basket, err := sc.Baskets.FindByID(c.Request.Context(), input.BasketID)
+	if err != nil {
+		middleware.NotFound(c, "Basket not found")
+		return
+	}
+
 	subscription := models.Subscription{
-		UserID:    input.UserID,
+		UserID:    consumer.ID,
+		User:      consumer,
 		BasketID:  input.BasketID,
+		Basket:    *basket,
 		Frequency: input.Frequency,
-		Status:    "Active",
 	}
+	event := lifecycle.Start(&subscription, consumer.ID, config.GetSubscriptionConfig().TrialPeriod, time.Now())
 
-	if err := database.DB.Create(&subscription).Error; err != nil {
+	// The seller's webhooks learn about the subscription once it is stored
+	created := func(subscription models.Subscription) ([]models.OutboxJob, error) {
+		job, err := webhooks.SubscriptionJob(webhooks.EventSubscriptionCreated, subscription)
+		if err != nil {
+			return nil, err
+		}
+		return []models.OutboxJob{job}, nil
+	}
+
+	if err := sc.Subscriptions.Create(c.Request.Context(), &subscription, &event, created); err != nil {
 		middleware.ServerError(c, "Failed to create subscription: "+err.Error())
 		return
 	}
@@ -38,33 +93,199 @@ func CreateSubscription(c *gin.Context) {
 	middleware.Success(c, subscription)
 }
 
-// GetSellerSubscriptions retrieves all active subscriptions for a seller
-func GetSellerSubscriptions(c *gin.Context) {
-	sellerID := c.Param("id")
+// GetSellerSubscriptions retrieves a page of the subscriptions to a seller's
+// baskets, filtered by status, frequency, basket_id, the consumer's city and
+// state and created_from/created_to
+func (sc *SubscriptionController) GetSellerSubscriptions(c *gin.Context) {
+	sellerID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	q := newListQuery(c, "status", "frequency", "basket_id", "city", "state", "created_from", "created_to")
+	filter := repository.SubscriptionFilter{
+		SellerID:    sellerID,
+		BasketID:    q.id("basket_id"),
+		Status:      queryOneOf(q, "status", models.SubscriptionStatuses),
+		Frequency:   queryOneOf(q, "frequency", models.Frequencies),
+		City:        q.text("city"),
+		State:       q.text("state"),
+		CreatedFrom: q.time("created_from", false),
+		CreatedTo:   q.time("created_to", true),
+	}
+	if !q.valid() {
+		return
+	}
+
+	page, err := sc.Subscriptions.List(c.Request.Context(), filter, q.page)
+	respondPage(c, q, page, err, "subscriptions")
+}
+
+// GetConsumerSubscriptions retrieves a page of a consumer's subscriptions,
+// filtered by status, frequency, basket_id and created_from/created_to
+func (sc *SubscriptionController) GetConsumerSubscriptions(c *gin.Context) {
+	userID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
 
-	var subscriptions []models.Subscription
+	q := newListQuery(c, "status", "frequency", "basket_id", "created_from", "created_to")
+	filter := repository.SubscriptionFilter{
+		ConsumerID:  userID,
+		BasketID:    q.id("basket_id"),
+		Status:      queryOneOf(q, "status", models.SubscriptionStatuses),
+		Frequency:   queryOneOf(q, "frequency", models.Frequencies),
+		CreatedFrom: q.time("created_from", false),
+		CreatedTo:   q.time("created_to", true),
+	}
+	if !q.valid() {
+		return
+	}
 
-	if err := database.DB.Joins("JOIN baskets ON baskets.id = subscriptions.basket_id").
-		Where("baskets.user_id = ?", sellerID).
-		Preload("User").
-		Preload("Basket").
-		Find(&subscriptions).Error; err != nil {
-		middleware.ServerError(c, "Failed to fetch subscriptions: "+err.Error())
+	page, err := sc.Subscriptions.List(c.Request.Context(), filter, q.page)
+	respondPage(c, q, page, err, "subscriptions")
+}
+
+// PauseSubscription pauses deliveries, optionally until a given date
+func (sc *SubscriptionController) PauseSubscription(c *gin.Context) {
+	var input PauseSubscriptionInput
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		middleware.BadRequest(c, "Invalid pause request", err.Error())
 		return
 	}
 
-	middleware.Success(c, subscriptions)
+	var until *time.Time
+	if input.Until != "" {
+		parsed, err := parseDateOrTime(input.Until)
+		if err != nil {
+			middleware.BadRequest(c, "Invalid pause request", "until must be a date (YYYY-MM-DD) or an RFC 3339 time")
+			return
+		}
+		until = &parsed
+	}
+
+	sc.changeLifecycle(c, func(subscription *models.Subscription, actor models.User, now time.Time) (models.SubscriptionEvent, error) {
+		return lifecycle.Pause(subscription, actor.ID, until, input.Reason, now)
+	})
 }
 
-// GetConsumerSubscriptions retrieves all subscriptions for a specific consumer
-func GetConsumerSubscriptions(c *gin.Context) {
-	userID := c.Param("id")
-	var subscriptions []models.Subscription
+// ResumeSubscription restarts deliveries of a paused subscription
+func (sc *SubscriptionController) ResumeSubscription(c *gin.Context) {
+	sc.changeLifecycle(c, func(subscription *models.Subscription, actor models.User, now time.Time) (models.SubscriptionEvent, error) {
+		return lifecycle.Resume(subscription, actor.ID, now)
+	})
+}
 
-	if err := database.DB.Preload("Basket").Where("user_id = ?", userID).Find(&subscriptions).Error; err != nil {
-		middleware.ServerError(c, "Failed to fetch subscriptions: "+err.Error())
+// CancelSubscription cancels a subscription with a reason
+func (sc *SubscriptionController) CancelSubscription(c *gin.Context) {
+	var input CancelSubscriptionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		middleware.BadRequest(c, "Invalid cancellation request", err.Error())
 		return
 	}
 
-	middleware.Success(c, subscriptions)
-}
\ No newline at end of file
+	sc.changeLifecycle(c, func(subscription *models.Subscription, actor models.User, now time.Time) (models.SubscriptionEvent, error) {
+		return lifecycle.Cancel(subscription, actor.ID, input.Reason, now)
+	})
+}
+
+// SkipNextDelivery skips the upcoming delivery of a subscription
+func (sc *SubscriptionController) SkipNextDelivery(c *gin.Context) {
+	sc.changeLifecycle(c, func(subscription *models.Subscription, actor models.User, now time.Time) (models.SubscriptionEvent, error) {
+		return lifecycle.SkipNext(subscription, actor.ID, now)
+	})
+}
+
+// GetSubscriptionHistory retrieves a page of the lifecycle changes of a
+// subscription, oldest first
+func (sc *SubscriptionController) GetSubscriptionHistory(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	q := newListQuery(c)
+	if !q.valid() {
+		return
+	}
+
+	page, err := sc.Subscriptions.ListEvents(c.Request.Context(), id, q.page)
+	respondPage(c, q, page, err, "subscription history")
+}
+
+// lifecycleChange applies a state machine operation to a subscription
+type lifecycleChange func(subscription *models.Subscription, actor models.User, now time.Time) (models.SubscriptionEvent, error)
+
+// changeLifecycle loads the subscription, applies change and stores the
+// result with its history event. Illegal transitions are answered with 409.
+func (sc *SubscriptionController) changeLifecycle(c *gin.Context, change lifecycleChange) {
+	actor, ok := middleware.CurrentUser(c)
+	if !ok {
+		middleware.Unauthorized(c)
+		return
+	}
+
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	subscription, err := sc.Subscriptions.FindByID(ctx, id)
+	if err != nil {
+		middleware.NotFound(c, "Subscription not found")
+		return
+	}
+
+	from := subscription.Status
+	event, err := change(subscription, actor, time.Now())
+	switch {
+	case errors.Is(err, lifecycle.ErrInvalidTransition):
+		middleware.Conflict(c, "Subscription cannot be changed", err.Error())
+		return
+	case errors.Is(err, lifecycle.ErrInvalidPauseDate):
+		middleware.BadRequest(c, "Invalid pause request", err.Error())
+		return
+	case err != nil:
+		middleware.ServerError(c, "Failed to update subscription: "+err.Error())
+		return
+	}
+
+	var jobs []models.OutboxJob
+	if subscription.Status == models.SubscriptionCancelled && from != models.SubscriptionCancelled {
+		job, err := webhooks.SubscriptionJob(webhooks.EventSubscriptionCancelled, *subscription)
+		if err != nil {
+			middleware.ServerError(c, "Failed to update subscription: "+err.Error())
+			return
+		}
+		jobs = append(jobs, job)
+	}
+	if subscription.Status == models.SubscriptionPastDue && from == models.SubscriptionPaused {
+		// Resumed with a failed charge
+		retry, err := payments.DunningRetryJobs(*subscription)
+		if err != nil {
+			middleware.ServerError(c, "Failed to update subscription: "+err.Error())
+			return
+		}
+		jobs = append(jobs, retry...)
+	}
+
+	if err := sc.Subscriptions.Transition(ctx, subscription, from, &event, jobs); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			middleware.Conflict(c, "Subscription was changed by another request", "reload the subscription and try again")
+			return
+		}
+		middleware.ServerError(c, "Failed to update subscription: "+err.Error())
+		return
+	}
+
+	middleware.Success(c, subscription)
+}
+
+// parseDateOrTime parses a YYYY-MM-DD date (midnight UTC) or an RFC 3339 time
+func parseDateOrTime(value string) (time.Time, error) {
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
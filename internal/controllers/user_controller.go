@@ -1,40 +1,108 @@
 package controllers
 
 import (
-	"github.com/alexandreffaria/hoby-loop/internal/database"
+	"fmt"
+	"log"
+
+	"github.com/alexandreffaria/hoby-loop/config"
+	"github.com/alexandreffaria/hoby-loop/internal/auth"
 	"github.com/alexandreffaria/hoby-loop/internal/middleware"
+	"github.com/alexandreffaria/hoby-loop/internal/notify"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
 	"github.com/alexandreffaria/hoby-loop/internal/validators"
 	"github.com/alexandreffaria/hoby-loop/models"
 	"github.com/gin-gonic/gin"
 )
 
-// Login authenticates a user by email
-func Login(c *gin.Context) {
-	var input struct {
-		Email string `json:"email" binding:"required,email"`
-	}
+// LoginInput defines request structure for logging in
+type LoginInput struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+// RegisterUserInput defines request structure for registering a seller or consumer
+type RegisterUserInput struct {
+	Email         string `json:"email" binding:"required,email"`
+	Password      string `json:"password" binding:"required,min=8"` // At most auth.MaxPasswordBytes bytes
+	Name          string `json:"name" binding:"required"`
+	Role          string `json:"role" binding:"required,oneof=seller consumer"`
+	CNPJ          string `json:"cnpj"`
+	CPF           string `json:"cpf"`
+	AddressStreet string `json:"address_street" binding:"required"`
+	AddressCity   string `json:"address_city"`
+	AddressState  string `json:"address_state"`
+	AddressZip    string `json:"address_zip"`
+	AddressNumber string `json:"address_number"`
+	Locale        string `json:"locale"` // Notification language, pt-BR or en
+}
+
+// UserController handles authentication, registration and profile updates
+type UserController struct {
+	Users  repository.UserRepository
+	Tokens repository.TokenRepository
+}
+
+// NewUserController creates a UserController
+func NewUserController(users repository.UserRepository, tokens repository.TokenRepository) *UserController {
+	return &UserController{Users: users, Tokens: tokens}
+}
+
+// Login authenticates a user by email and password
+func (uc *UserController) Login(c *gin.Context) {
+	var input LoginInput
 
 	if err := c.ShouldBindJSON(&input); err != nil {
-		middleware.BadRequest(c, "Valid email is required", err.Error())
+		middleware.BadRequest(c, "Valid email and password are required", err.Error())
 		return
 	}
 
-	var user models.User
-	if err := database.DB.Where("email = ?", input.Email).First(&user).Error; err != nil {
+	user, err := uc.Users.FindByEmail(c.Request.Context(), input.Email)
+	if err != nil {
+		// Take as long as a wrong password so unknown emails cannot be told apart
+		auth.CheckUnknownUser(input.Password)
 		middleware.Unauthorized(c)
 		return
 	}
 
-	middleware.Success(c, user)
+	if err := auth.CheckPassword(user.Password, input.Password); err != nil {
+		middleware.Unauthorized(c)
+		return
+	}
+
+	if !user.IsActive {
+		middleware.Forbidden(c, "Account is disabled")
+		return
+	}
+
+	// Upgrade legacy or outdated hashes now that we know the plaintext
+	if auth.NeedsRehash(user.Password) {
+		if hash, err := auth.HashPassword(input.Password); err == nil {
+			user.Password = hash
+			if err := uc.Users.UpdatePassword(c.Request.Context(), user.ID, hash); err != nil {
+				log.Printf("⚠️ Failed to rehash password for user %d: %v", user.ID, err)
+			}
+		}
+	}
+
+	session, err := issueSession(c.Request.Context(), uc.Tokens, *user)
+	if err != nil {
+		middleware.ServerError(c, "Failed to create session: "+err.Error())
+		return
+	}
+
+	middleware.Success(c, session)
 }
 
 // UpdateUser handles user profile updates
-func UpdateUser(c *gin.Context) {
-	id := c.Param("id")
-	var user models.User
+func (uc *UserController) UpdateUser(c *gin.Context) {
+	id, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
 
 	// Find the user
-	if err := database.DB.First(&user, id).Error; err != nil {
+	user, err := uc.Users.FindByID(c.Request.Context(), id)
+	if err != nil {
 		middleware.NotFound(c, "User not found")
 		return
 	}
@@ -56,7 +124,8 @@ func UpdateUser(c *gin.Context) {
 		input.CPF = validators.FormatCPF(input.CPF)
 	}
 
-	if user.Role == "seller" && input.CNPJ != "" {
+	// Consumers may have a CNPJ too, to pay with boletos
+	if input.CNPJ != "" {
 		if !validators.ValidateCNPJ(input.CNPJ) {
 			middleware.BadRequest(c, "Invalid CNPJ format", "CNPJ validation failed")
 			return
@@ -65,20 +134,37 @@ func UpdateUser(c *gin.Context) {
 		input.CNPJ = validators.FormatCNPJ(input.CNPJ)
 	}
 
-	// Update fields
-	updates := models.User{
-		Name:          input.Name,
-		Email:         input.Email,
-		CNPJ:          input.CNPJ,
-		CPF:           input.CPF,
-		AddressStreet: input.AddressStreet,
-		AddressNumber: input.AddressNumber,
-		AddressCity:   input.AddressCity,
-		AddressState:  input.AddressState,
-		AddressZip:    input.AddressZip,
+	if input.Phone != "" {
+		if !validators.ValidatePhone(input.Phone) {
+			middleware.BadRequest(c, "Invalid phone number", "use an international number such as +5511987654321")
+			return
+		}
+		input.Phone = validators.FormatPhone(input.Phone)
 	}
 
-	if err := database.DB.Model(&user).Updates(updates).Error; err != nil {
+	if input.Locale != "" {
+		locale, ok := notify.NormalizeLocale(input.Locale)
+		if !ok {
+			middleware.BadRequest(c, "Invalid locale", fmt.Sprintf("expected one of %v", models.Locales))
+			return
+		}
+		input.Locale = locale
+	}
+
+	// Update only the fields that were provided
+	setIfNotEmpty(&user.Name, input.Name)
+	setIfNotEmpty(&user.Email, input.Email)
+	setIfNotEmpty(&user.CNPJ, input.CNPJ)
+	setIfNotEmpty(&user.CPF, input.CPF)
+	setIfNotEmpty(&user.Phone, input.Phone)
+	setIfNotEmpty(&user.Locale, input.Locale)
+	setIfNotEmpty(&user.AddressStreet, input.AddressStreet)
+	setIfNotEmpty(&user.AddressNumber, input.AddressNumber)
+	setIfNotEmpty(&user.AddressCity, input.AddressCity)
+	setIfNotEmpty(&user.AddressState, input.AddressState)
+	setIfNotEmpty(&user.AddressZip, input.AddressZip)
+
+	if err := uc.Users.Save(c.Request.Context(), user); err != nil {
 		middleware.ServerError(c, err.Error())
 		return
 	}
@@ -87,24 +173,22 @@ func UpdateUser(c *gin.Context) {
 }
 
 // RegisterUser handles user registration (both seller and consumer)
-func RegisterUser(c *gin.Context) {
-	var input struct {
-		Email         string `json:"email" binding:"required,email"`
-		Name          string `json:"name" binding:"required"`
-		Role          string `json:"role" binding:"required,oneof=seller consumer"`
-		CNPJ          string `json:"cnpj"`
-		CPF           string `json:"cpf"`
-		AddressStreet string `json:"address_street" binding:"required"`
-		AddressCity   string `json:"address_city"`
-		AddressState  string `json:"address_state"`
-		AddressZip    string `json:"address_zip"`
-		AddressNumber string `json:"address_number"`
+func (uc *UserController) RegisterUser(c *gin.Context) {
+	if !config.Get().FeatureEnabled(config.FeatureRegistration) {
+		middleware.Forbidden(c, "Registration is currently disabled")
+		return
 	}
 
+	var input RegisterUserInput
+
 	if err := c.ShouldBindJSON(&input); err != nil {
 		middleware.BadRequest(c, "Invalid input data", err.Error())
 		return
 	}
+	if len(input.Password) > auth.MaxPasswordBytes {
+		middleware.BadRequest(c, "Invalid input data", auth.ErrPasswordTooLong.Error())
+		return
+	}
 
 	// Validate role-specific required fields
 	if input.Role == "seller" && input.CNPJ == "" {
@@ -127,7 +211,8 @@ func RegisterUser(c *gin.Context) {
 		input.CPF = validators.FormatCPF(input.CPF)
 	}
 
-	if input.Role == "seller" && input.CNPJ != "" {
+	// Consumers may have a CNPJ too, to pay with boletos
+	if input.CNPJ != "" {
 		if !validators.ValidateCNPJ(input.CNPJ) {
 			middleware.BadRequest(c, "Invalid CNPJ format", "CNPJ validation failed")
 			return
@@ -136,18 +221,35 @@ func RegisterUser(c *gin.Context) {
 		input.CNPJ = validators.FormatCNPJ(input.CNPJ)
 	}
 
+	if input.Locale != "" {
+		locale, ok := notify.NormalizeLocale(input.Locale)
+		if !ok {
+			middleware.BadRequest(c, "Invalid locale", fmt.Sprintf("expected one of %v", models.Locales))
+			return
+		}
+		input.Locale = locale
+	}
+
 	// Check if email already exists
-	var existingUser models.User
-	if err := database.DB.Where("email = ?", input.Email).First(&existingUser).Error; err == nil {
+	if _, err := uc.Users.FindByEmail(c.Request.Context(), input.Email); err == nil {
 		middleware.BadRequest(c, "Email already in use", "")
 		return
 	}
 
+	// Hash the password before storing it
+	hash, err := auth.HashPassword(input.Password)
+	if err != nil {
+		middleware.ServerError(c, "Failed to hash password: "+err.Error())
+		return
+	}
+
 	// Create new user
 	user := models.User{
 		Email:         input.Email,
+		Password:      hash,
 		Name:          input.Name,
 		Role:          input.Role,
+		IsActive:      true,
 		CNPJ:          input.CNPJ,
 		CPF:           input.CPF,
 		AddressStreet: input.AddressStreet,
@@ -155,12 +257,20 @@ func RegisterUser(c *gin.Context) {
 		AddressState:  input.AddressState,
 		AddressZip:    input.AddressZip,
 		AddressNumber: input.AddressNumber,
+		Locale:        input.Locale,
 	}
 
-	if err := database.DB.Create(&user).Error; err != nil {
+	if err := uc.Users.Create(c.Request.Context(), &user); err != nil {
 		middleware.ServerError(c, err.Error())
 		return
 	}
 
 	middleware.Success(c, user)
 }
+
+// setIfNotEmpty overwrites dst only when value was provided
+func setIfNotEmpty(dst *string, value string) {
+	if value != "" {
+		*dst = value
+	}
+}
@@ -0,0 +1,275 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/config"
+	"github.com/alexandreffaria/hoby-loop/internal/middleware"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/internal/webhooks"
+	"github.com/alexandreffaria/hoby-loop/models"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateWebhookInput defines request structure for registering a webhook endpoint
+type CreateWebhookInput struct {
+	URL         string   `json:"url" binding:"required,url"`
+	Events      []string `json:"events" binding:"required,min=1"`
+	Description string   `json:"description" binding:"max=200"`
+}
+
+// UpdateWebhookInput defines request structure for changing a webhook endpoint.
+// Omitted fields keep their current value.
+type UpdateWebhookInput struct {
+	URL         string   `json:"url" binding:"omitempty,url"`
+	Events      []string `json:"events"`
+	Description *string  `json:"description" binding:"omitempty,max=200"`
+	Active      *bool    `json:"active"`
+}
+
+// CreateWebhookResponse is returned when an endpoint is registered, with the
+// signing secret that is not shown again
+type CreateWebhookResponse struct {
+	Webhook models.WebhookEndpoint `json:"webhook"`
+	Secret  string                 `json:"secret"`
+	Message string                 `json:"message"`
+}
+
+// WebhookTestResponse is the outcome of a test event. The response body of
+// the endpoint is not returned.
+type WebhookTestResponse struct {
+	Success    bool  `json:"success"`
+	StatusCode int   `json:"status_code,omitempty"`
+	DurationMS int64 `json:"duration_ms"`
+}
+
+// WebhookController handles the webhook endpoints of sellers
+type WebhookController struct {
+	Webhooks repository.WebhookRepository
+	Sender   *webhooks.Sender
+}
+
+// NewWebhookController creates a WebhookController
+func NewWebhookController(repo repository.WebhookRepository, sender *webhooks.Sender) *WebhookController {
+	return &WebhookController{Webhooks: repo, Sender: sender}
+}
+
+// CreateWebhook registers an endpoint for the seller. The signing secret is
+// only returned in this response.
+func (wc *WebhookController) CreateWebhook(c *gin.Context) {
+	sellerID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	var input CreateWebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		middleware.BadRequest(c, "Invalid webhook data", err.Error())
+		return
+	}
+	if err := validateWebhook(input.URL, input.Events); err != nil {
+		middleware.BadRequest(c, "Invalid webhook data", err.Error())
+		return
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		middleware.ServerError(c, "Failed to generate webhook secret: "+err.Error())
+		return
+	}
+
+	endpoint := models.WebhookEndpoint{
+		UserID:      sellerID,
+		URL:         input.URL,
+		Description: input.Description,
+		Events:      slices.Compact(slices.Sorted(slices.Values(input.Events))),
+		Secret:      secret,
+		Active:      true,
+	}
+	if err := wc.Webhooks.CreateEndpoint(c.Request.Context(), &endpoint); err != nil {
+		middleware.ServerError(c, "Failed to create webhook: "+err.Error())
+		return
+	}
+
+	middleware.Success(c, CreateWebhookResponse{
+		Webhook: endpoint,
+		Secret:  secret,
+		Message: "Store the secret now, it is not shown again",
+	})
+}
+
+// GetWebhooks retrieves a page of the endpoints of a seller
+func (wc *WebhookController) GetWebhooks(c *gin.Context) {
+	sellerID, ok := paramID(c, "id")
+	if !ok {
+		return
+	}
+
+	q := newListQuery(c)
+	if !q.valid() {
+		return
+	}
+
+	page, err := wc.Webhooks.List(c.Request.Context(), sellerID, q.page)
+	respondPage(c, q, page, err, "webhooks")
+}
+
+// UpdateWebhook changes the URL, events, description or active flag of an endpoint
+func (wc *WebhookController) UpdateWebhook(c *gin.Context) {
+	endpoint, ok := wc.endpoint(c)
+	if !ok {
+		return
+	}
+
+	var input UpdateWebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		middleware.BadRequest(c, "Invalid webhook data", err.Error())
+		return
+	}
+
+	setIfNotEmpty(&endpoint.URL, input.URL)
+	if input.Events != nil {
+		endpoint.Events = slices.Compact(slices.Sorted(slices.Values(input.Events)))
+	}
+	if input.Description != nil {
+		endpoint.Description = *input.Description
+	}
+	if input.Active != nil {
+		endpoint.Active = *input.Active
+	}
+	if err := validateWebhook(endpoint.URL, endpoint.Events); err != nil {
+		middleware.BadRequest(c, "Invalid webhook data", err.Error())
+		return
+	}
+
+	if err := wc.Webhooks.SaveEndpoint(c.Request.Context(), endpoint); err != nil {
+		middleware.ServerError(c, "Failed to update webhook: "+err.Error())
+		return
+	}
+
+	middleware.Success(c, endpoint)
+}
+
+// DeleteWebhook removes an endpoint and its delivery log. Queued deliveries
+// to it are dropped.
+func (wc *WebhookController) DeleteWebhook(c *gin.Context) {
+	endpoint, ok := wc.endpoint(c)
+	if !ok {
+		return
+	}
+
+	if err := wc.Webhooks.DeleteEndpoint(c.Request.Context(), endpoint.ID); err != nil {
+		middleware.ServerError(c, "Failed to delete webhook: "+err.Error())
+		return
+	}
+
+	middleware.Success(c, MessageResponse{Message: "Webhook deleted"})
+}
+
+// GetWebhookDeliveries retrieves a page of the delivery attempts of an
+// endpoint, newest first
+func (wc *WebhookController) GetWebhookDeliveries(c *gin.Context) {
+	endpoint, ok := wc.endpoint(c)
+	if !ok {
+		return
+	}
+
+	q := newListQuery(c)
+	if !q.valid() {
+		return
+	}
+
+	page, err := wc.Webhooks.ListDeliveries(c.Request.Context(), endpoint.ID, q.page)
+	respondPage(c, q, page, err, "webhook deliveries")
+}
+
+// SendTestEvent posts a webhook.test event to the endpoint right away and
+// returns how it responded, so sellers can check their integration
+func (wc *WebhookController) SendTestEvent(c *gin.Context) {
+	endpoint, ok := wc.endpoint(c)
+	if !ok {
+		return
+	}
+
+	event, err := webhooks.NewEvent(webhooks.EventTest, map[string]interface{}{
+		"webhook_id": endpoint.ID,
+		"message":    "This is a test event from Hoby Loop",
+		"sent_at":    time.Now().UTC(),
+	})
+	if err != nil {
+		middleware.ServerError(c, "Failed to create test event: "+err.Error())
+		return
+	}
+
+	// Failures are part of the result, not an error of this request
+	delivery, _ := wc.Sender.Deliver(c.Request.Context(), *endpoint, event, 1)
+	middleware.Success(c, WebhookTestResponse{
+		Success:    delivery.Success,
+		StatusCode: delivery.StatusCode,
+		DurationMS: delivery.DurationMS,
+	})
+}
+
+// endpoint loads the :webhook_id endpoint and checks it belongs to the :id seller
+func (wc *WebhookController) endpoint(c *gin.Context) (*models.WebhookEndpoint, bool) {
+	sellerID, ok := paramID(c, "id")
+	if !ok {
+		return nil, false
+	}
+	endpointID, ok := paramID(c, "webhook_id")
+	if !ok {
+		return nil, false
+	}
+
+	endpoint, err := wc.Webhooks.FindEndpoint(c.Request.Context(), endpointID)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && endpoint.UserID != sellerID) {
+		middleware.NotFound(c, "Webhook not found")
+		return nil, false
+	}
+	if err != nil {
+		middleware.ServerError(c, "Failed to fetch webhook: "+err.Error())
+		return nil, false
+	}
+	return endpoint, true
+}
+
+// validateWebhook checks the URL is an absolute HTTPS URL of a public host,
+// or HTTP in development, and every event is known. Hosts resolving to
+// private addresses are refused again by the sender when connecting.
+func validateWebhook(rawURL string, events []string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return fmt.Errorf("url must be an absolute https URL")
+	}
+	if parsed.Scheme != "https" && (parsed.Scheme != "http" || config.Get().Env != "development") {
+		return fmt.Errorf("url must be an absolute https URL")
+	}
+	host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+	if ip, err := netip.ParseAddr(host); err == nil && !webhooks.PublicAddress(ip) {
+		return fmt.Errorf("url must not point to a private network address")
+	}
+	// Single label names and reserved suffixes only resolve on internal networks
+	if !strings.Contains(host, ".") && !strings.Contains(host, ":") {
+		return fmt.Errorf("url must point to a public host name")
+	}
+	for _, suffix := range []string{".localhost", ".local", ".internal", ".lan", ".home.arpa"} {
+		if strings.HasSuffix(host, suffix) {
+			return fmt.Errorf("url must point to a public host name")
+		}
+	}
+	if len(events) == 0 {
+		return fmt.Errorf("at least one event is required, expected any of %v", webhooks.Events)
+	}
+	for _, event := range events {
+		if !slices.Contains(webhooks.Events, event) {
+			return fmt.Errorf("unknown event %q, expected any of %v", event, webhooks.Events)
+		}
+	}
+	return nil
+}
@@ -1,53 +1,68 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	"log"
 
 	"github.com/alexandreffaria/hoby-loop/config"
-	"github.com/alexandreffaria/hoby-loop/models"
+	"github.com/alexandreffaria/hoby-loop/internal/database/migrations"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 // DB is the global database instance
 var DB *gorm.DB
 
-// Initialize connects to the database and performs migrations
+// Initialize connects to the database and verifies the schema version
 func Initialize() {
 	var err error
 
 	// Get database connection string from config
 	dsn := config.GetDSN()
+	cfg := config.Get()
 	
 	// Connect to the database
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger:         logger.Default.LogMode(gormLogLevel(cfg.Log.Level)),
+		TranslateError: true,
+	})
 	if err != nil {
 		log.Fatal("Failed to connect to database: ", err)
 	}
 
-	// Migrate the schema
-	fmt.Println("🔄 Running database migrations...")
-	
-	// First run AutoMigrate for standard fields
-	err = DB.AutoMigrate(&models.User{}, &models.Basket{}, &models.Subscription{}, &models.Order{})
+	// Configure the connection pool
+	sqlDB, err := DB.DB()
 	if err != nil {
-		log.Fatal("Migration failed: ", err)
+		log.Fatal("Failed to access database pool: ", err)
 	}
-	
-	// Explicitly ensure admin fields exist
-	adminFieldsMigrations := []string{
-		"ALTER TABLE users ADD COLUMN IF NOT EXISTS is_active boolean DEFAULT true",
-		"ALTER TABLE users ADD COLUMN IF NOT EXISTS permissions text",
+	sqlDB.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
+
+	// Refuse to start against a schema this build was not written for
+	migrator, err := NewMigrator(sqlDB, migrations.FS)
+	if err != nil {
+		log.Fatal("Failed to load migrations: ", err)
 	}
-	
-	for _, migration := range adminFieldsMigrations {
-		if err := DB.Exec(migration).Error; err != nil {
-			log.Printf("⚠️ Admin field migration warning: %v", err)
-		}
+	if err := migrator.Verify(context.Background()); err != nil {
+		log.Fatal("Run `go run ./cmd/migrate up` before starting the server: ", err)
 	}
 
-	fmt.Println("🚀 Database connected and migrated successfully!")
+	fmt.Println("🚀 Database connected, schema is up to date!")
+}
+
+// gormLogLevel maps the configured log level to the GORM logger level
+func gormLogLevel(level string) logger.LogLevel {
+	switch level {
+	case "debug":
+		return logger.Info
+	case "error":
+		return logger.Error
+	default:
+		return logger.Warn
+	}
 }
 
 // GetDB returns the database instance
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS revoked_tokens;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS subscriptions;
+DROP TABLE IF EXISTS baskets;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,103 @@
+-- Baseline schema, equivalent to what GORM AutoMigrate used to create.
+-- Statements are idempotent so databases created before versioned
+-- migrations existed can be brought under version control.
+
+CREATE TABLE IF NOT EXISTS users (
+    id             bigserial PRIMARY KEY,
+    created_at     timestamptz,
+    updated_at     timestamptz,
+    deleted_at     timestamptz,
+    email          text,
+    password       text,
+    role           text,
+    name           text,
+    cnpj           text,
+    cpf            text,
+    is_active      boolean DEFAULT true,
+    permissions    text,
+    address_street text,
+    address_number text,
+    address_city   text,
+    address_state  text,
+    address_zip    text
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_active boolean DEFAULT true;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS permissions text;
+
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+CREATE INDEX IF NOT EXISTS idx_users_role ON users (role);
+-- Sellers have no CPF and consumers no CNPJ, so empty values must not collide.
+-- AutoMigrate created plain unique constraints which rejected a second empty value.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS uni_users_cnpj;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS uni_users_cpf;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_cnpj_unique ON users (cnpj) WHERE cnpj <> '';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_cpf_unique ON users (cpf) WHERE cpf <> '';
+
+CREATE TABLE IF NOT EXISTS baskets (
+    id          bigserial PRIMARY KEY,
+    created_at  timestamptz,
+    updated_at  timestamptz,
+    deleted_at  timestamptz,
+    name        text,
+    description text,
+    price       numeric,
+    user_id     bigint
+);
+
+CREATE INDEX IF NOT EXISTS idx_baskets_deleted_at ON baskets (deleted_at);
+
+CREATE TABLE IF NOT EXISTS subscriptions (
+    id         bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id    bigint REFERENCES users (id),
+    basket_id  bigint REFERENCES baskets (id),
+    frequency  text,
+    status     text
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_deleted_at ON subscriptions (deleted_at);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id              bigserial PRIMARY KEY,
+    created_at      timestamptz,
+    updated_at      timestamptz,
+    deleted_at      timestamptz,
+    subscription_id bigint REFERENCES subscriptions (id),
+    status          text DEFAULT 'preparing',
+    tracking_code   text,
+    shipped_at      timestamptz,
+    delivered_at    timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_orders_deleted_at ON orders (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_orders_subscription_id ON orders (subscription_id);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id          bigserial PRIMARY KEY,
+    created_at  timestamptz,
+    updated_at  timestamptz,
+    deleted_at  timestamptz,
+    user_id     bigint,
+    token_hash  text,
+    expires_at  timestamptz,
+    revoked_at  timestamptz,
+    replaced_by bigint
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_deleted_at ON refresh_tokens (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    id         bigserial PRIMARY KEY,
+    token_id   text,
+    expires_at timestamptz,
+    created_at timestamptz
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_revoked_tokens_token_id ON revoked_tokens (token_id);
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);
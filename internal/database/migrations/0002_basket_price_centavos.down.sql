@@ -0,0 +1,6 @@
+ALTER TABLE baskets ADD COLUMN price numeric;
+
+UPDATE baskets SET price = price_amount / 100.0;
+
+ALTER TABLE baskets DROP COLUMN price_currency;
+ALTER TABLE baskets DROP COLUMN price_amount;
@@ -0,0 +1,15 @@
+-- Store basket prices as integer centavos with an explicit currency instead of
+-- a floating point amount. Existing prices are rounded half to even.
+ALTER TABLE baskets ADD COLUMN price_amount bigint NOT NULL DEFAULT 0;
+ALTER TABLE baskets ADD COLUMN price_currency varchar(3) NOT NULL DEFAULT 'BRL';
+
+UPDATE baskets
+SET price_amount = CASE
+        -- Exact ties (x.xx5) go to the even centavo
+        WHEN price * 100 - trunc(price * 100) = 0.5 AND mod(trunc(price * 100), 2) = 0 THEN trunc(price * 100)
+        WHEN price * 100 - trunc(price * 100) = -0.5 AND mod(trunc(price * 100), 2) = 0 THEN trunc(price * 100)
+        ELSE round(price * 100)
+    END
+WHERE price IS NOT NULL;
+
+ALTER TABLE baskets DROP COLUMN price;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_orders_subscription_scheduled_for;
+ALTER TABLE orders DROP COLUMN scheduled_for;
+
+DROP INDEX IF EXISTS idx_subscriptions_next_delivery_at;
+ALTER TABLE subscriptions DROP COLUMN next_delivery_at;
@@ -0,0 +1,25 @@
+-- Recurring order scheduling: each subscription tracks its next delivery and
+-- generated orders record the delivery they belong to. The unique index makes
+-- generation idempotent even if two instances race for the same delivery.
+ALTER TABLE subscriptions ADD COLUMN next_delivery_at timestamptz;
+CREATE INDEX idx_subscriptions_next_delivery_at ON subscriptions (next_delivery_at);
+
+ALTER TABLE orders ADD COLUMN scheduled_for timestamptz;
+CREATE UNIQUE INDEX idx_orders_subscription_scheduled_for ON orders (subscription_id, scheduled_for);
+
+-- Existing active subscriptions continue from their next regular delivery
+-- after now, anchored on the subscription date, instead of catching up on
+-- every delivery since they were created.
+UPDATE subscriptions
+SET next_delivery_at = created_at + interval '7 days' * (floor(extract(epoch FROM now() - created_at) / 604800) + 1)
+WHERE status = 'Active' AND frequency = 'weekly' AND deleted_at IS NULL;
+
+UPDATE subscriptions
+SET next_delivery_at = created_at + interval '14 days' * (floor(extract(epoch FROM now() - created_at) / 1209600) + 1)
+WHERE status = 'Active' AND frequency = 'biweekly' AND deleted_at IS NULL;
+
+UPDATE subscriptions
+SET next_delivery_at = created_at + interval '1 month' * (
+        date_part('year', age(now(), created_at)) * 12 + date_part('month', age(now(), created_at)) + 1
+    )
+WHERE status = 'Active' AND frequency = 'monthly' AND deleted_at IS NULL;
@@ -0,0 +1,14 @@
+DROP TABLE IF EXISTS subscription_events;
+
+ALTER TABLE subscriptions DROP COLUMN cancellation_reason;
+ALTER TABLE subscriptions DROP COLUMN cancelled_at;
+ALTER TABLE subscriptions DROP COLUMN paused_until;
+ALTER TABLE subscriptions DROP COLUMN trial_ends_at;
+
+DROP INDEX IF EXISTS idx_subscriptions_status;
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS chk_subscriptions_status;
+ALTER TABLE subscriptions ALTER COLUMN status DROP NOT NULL;
+
+-- Statuses that did not exist before are folded into the closest old one
+UPDATE subscriptions SET status = 'active' WHERE status IN ('trialing', 'past_due');
+UPDATE subscriptions SET status = initcap(status);
@@ -0,0 +1,53 @@
+-- Subscription lifecycle: canonical lowercase states, pause/trial/cancellation
+-- details and a history of every change.
+UPDATE subscriptions SET status = lower(status);
+UPDATE subscriptions SET status = 'active'    WHERE status IN ('', 'ativo');
+UPDATE subscriptions SET status = 'paused'    WHERE status = 'pausado';
+UPDATE subscriptions SET status = 'cancelled' WHERE status IN ('cancelado', 'canceled');
+-- Anything else, including NULL, gets the default state like '' did, so
+-- the constraints below cannot fail on legacy rows
+UPDATE subscriptions SET status = 'active'
+WHERE status IS NULL OR status NOT IN ('trialing', 'active', 'paused', 'past_due', 'cancelled');
+ALTER TABLE subscriptions ALTER COLUMN status SET NOT NULL;
+ALTER TABLE subscriptions ADD CONSTRAINT chk_subscriptions_status
+    CHECK (status IN ('trialing', 'active', 'paused', 'past_due', 'cancelled'));
+CREATE INDEX idx_subscriptions_status ON subscriptions (status);
+
+ALTER TABLE subscriptions ADD COLUMN trial_ends_at timestamptz;
+ALTER TABLE subscriptions ADD COLUMN paused_until timestamptz;
+ALTER TABLE subscriptions ADD COLUMN cancelled_at timestamptz;
+ALTER TABLE subscriptions ADD COLUMN cancellation_reason text NOT NULL DEFAULT '';
+
+-- Subscriptions that no longer deliver have no next delivery
+UPDATE subscriptions SET next_delivery_at = NULL WHERE status IN ('paused', 'cancelled');
+
+-- Active subscriptions that were not recognised as 'Active' by 0003 get
+-- their next regular delivery after now
+UPDATE subscriptions
+SET next_delivery_at = created_at + interval '7 days' * (floor(extract(epoch FROM now() - created_at) / 604800) + 1)
+WHERE status = 'active' AND next_delivery_at IS NULL AND frequency = 'weekly';
+
+UPDATE subscriptions
+SET next_delivery_at = created_at + interval '14 days' * (floor(extract(epoch FROM now() - created_at) / 1209600) + 1)
+WHERE status = 'active' AND next_delivery_at IS NULL AND frequency = 'biweekly';
+
+UPDATE subscriptions
+SET next_delivery_at = created_at + interval '1 month' * (
+        date_part('year', age(now(), created_at)) * 12 + date_part('month', age(now(), created_at)) + 1
+    )
+WHERE status = 'active' AND next_delivery_at IS NULL AND frequency = 'monthly';
+
+CREATE TABLE subscription_events (
+    id              bigserial PRIMARY KEY,
+    subscription_id bigint NOT NULL REFERENCES subscriptions (id),
+    actor_id        bigint NOT NULL DEFAULT 0,
+    action          text NOT NULL,
+    from_status     text NOT NULL DEFAULT '',
+    to_status       text NOT NULL DEFAULT '',
+    reason          text NOT NULL DEFAULT '',
+    effective_until timestamptz,
+    skipped_for     timestamptz,
+    created_at      timestamptz NOT NULL
+);
+
+CREATE INDEX idx_subscription_events_subscription_id ON subscription_events (subscription_id);
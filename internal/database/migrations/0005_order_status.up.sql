@@ -0,0 +1,14 @@
+-- Orders were created with capitalised statuses (Processing, Shipped, ...)
+-- and updated with lowercase ones. Fold everything into the canonical set.
+UPDATE orders SET status = lower(status);
+UPDATE orders SET status = 'preparing' WHERE status IN ('', 'processing', 'pending');
+UPDATE orders SET status = 'cancelled' WHERE status = 'canceled';
+-- Anything else, including NULL, gets the default state like '' did, so
+-- the constraints below cannot fail on legacy rows
+UPDATE orders SET status = 'preparing'
+WHERE status IS NULL OR status NOT IN ('preparing', 'shipped', 'delivered', 'failed_delivery', 'returned', 'cancelled');
+
+ALTER TABLE orders ALTER COLUMN status SET DEFAULT 'preparing';
+ALTER TABLE orders ALTER COLUMN status SET NOT NULL;
+ALTER TABLE orders ADD CONSTRAINT chk_orders_status
+    CHECK (status IN ('preparing', 'shipped', 'delivered', 'failed_delivery', 'returned', 'cancelled'));
@@ -0,0 +1,27 @@
+-- Timeline of every change made to an order
+CREATE TABLE order_events (
+    id            bigserial PRIMARY KEY,
+    order_id      bigint NOT NULL REFERENCES orders (id),
+    actor_id      bigint NOT NULL DEFAULT 0,
+    type          text NOT NULL,
+    from_status   text NOT NULL DEFAULT '',
+    to_status     text NOT NULL DEFAULT '',
+    tracking_code text NOT NULL DEFAULT '',
+    note          text NOT NULL DEFAULT '',
+    created_at    timestamptz NOT NULL
+);
+
+CREATE INDEX idx_order_events_order_id ON order_events (order_id);
+
+-- Reconstruct what is known about existing orders. The actor is unknown (0).
+INSERT INTO order_events (order_id, type, to_status, note, created_at)
+SELECT id, 'created', 'preparing', 'Backfilled', created_at
+FROM orders WHERE created_at IS NOT NULL;
+
+INSERT INTO order_events (order_id, type, from_status, to_status, tracking_code, note, created_at)
+SELECT id, 'status_changed', 'preparing', 'shipped', coalesce(tracking_code, ''), 'Backfilled', shipped_at
+FROM orders WHERE shipped_at IS NOT NULL;
+
+INSERT INTO order_events (order_id, type, from_status, to_status, tracking_code, note, created_at)
+SELECT id, 'status_changed', 'shipped', 'delivered', coalesce(tracking_code, ''), 'Backfilled', delivered_at
+FROM orders WHERE delivered_at IS NOT NULL;
@@ -0,0 +1,29 @@
+-- Phone number used by the WhatsApp and SMS channels, in E.164 format
+ALTER TABLE users ADD COLUMN phone text NOT NULL DEFAULT '';
+
+-- Channels each user enabled or disabled. Users without rows receive
+-- notifications on the configured default channels.
+CREATE TABLE notification_preferences (
+    id         bigserial PRIMARY KEY,
+    user_id    bigint NOT NULL REFERENCES users (id),
+    channel    text NOT NULL,
+    enabled    boolean NOT NULL DEFAULT true,
+    updated_at timestamptz
+);
+
+CREATE UNIQUE INDEX idx_notification_preferences_user_channel ON notification_preferences (user_id, channel);
+
+-- Result of every notification attempt, so failed deliveries are visible
+CREATE TABLE notification_deliveries (
+    id         bigserial PRIMARY KEY,
+    user_id    bigint NOT NULL,
+    channel    text NOT NULL,
+    event      text NOT NULL,
+    subject    text NOT NULL DEFAULT '',
+    status     text NOT NULL,
+    error      text NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL
+);
+
+CREATE INDEX idx_notification_deliveries_user_id ON notification_deliveries (user_id);
+CREATE INDEX idx_notification_deliveries_status ON notification_deliveries (status);
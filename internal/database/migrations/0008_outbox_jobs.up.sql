@@ -0,0 +1,19 @@
+-- Background jobs written in the same transaction as the change that caused
+-- them, claimed by workers with FOR UPDATE SKIP LOCKED
+CREATE TABLE outbox_jobs (
+    id           bigserial PRIMARY KEY,
+    kind         text NOT NULL,
+    payload      jsonb NOT NULL DEFAULT 'null',
+    status       text NOT NULL DEFAULT 'pending',
+    attempts     integer NOT NULL DEFAULT 0,
+    run_at       timestamptz NOT NULL,
+    locked_until timestamptz,
+    last_error   text NOT NULL DEFAULT '',
+    completed_at timestamptz,
+    created_at   timestamptz NOT NULL,
+    updated_at   timestamptz NOT NULL,
+    CONSTRAINT chk_outbox_jobs_status CHECK (status IN ('pending', 'running', 'done', 'dead'))
+);
+
+CREATE INDEX idx_outbox_jobs_kind ON outbox_jobs (kind);
+CREATE INDEX idx_outbox_jobs_status_run_at ON outbox_jobs (status, run_at);
@@ -0,0 +1,2 @@
+-- Language of notifications (pt-BR or en), empty uses notifications.default_locale
+ALTER TABLE users ADD COLUMN locale text NOT NULL DEFAULT '';
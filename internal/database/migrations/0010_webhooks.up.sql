@@ -0,0 +1,33 @@
+-- Endpoints sellers registered to receive events about their subscriptions
+-- and orders. Events is a JSON array of event types.
+CREATE TABLE webhook_endpoints (
+    id          bigserial PRIMARY KEY,
+    user_id     bigint NOT NULL REFERENCES users (id),
+    url         text NOT NULL,
+    description text NOT NULL DEFAULT '',
+    events      text NOT NULL DEFAULT '[]',
+    secret      text NOT NULL,
+    active      boolean NOT NULL DEFAULT true,
+    created_at  timestamptz,
+    updated_at  timestamptz
+);
+
+CREATE INDEX idx_webhook_endpoints_user_id ON webhook_endpoints (user_id);
+
+-- Result of every delivery attempt, shown to sellers for debugging
+CREATE TABLE webhook_deliveries (
+    id            bigserial PRIMARY KEY,
+    endpoint_id   bigint NOT NULL REFERENCES webhook_endpoints (id) ON DELETE CASCADE,
+    event_id      text NOT NULL,
+    event         text NOT NULL,
+    attempt       integer NOT NULL DEFAULT 1,
+    status_code   integer NOT NULL DEFAULT 0,
+    success       boolean NOT NULL DEFAULT false,
+    error         text NOT NULL DEFAULT '',
+    response_body text NOT NULL DEFAULT '',
+    duration_ms   bigint NOT NULL DEFAULT 0,
+    created_at    timestamptz NOT NULL
+);
+
+CREATE INDEX idx_webhook_deliveries_endpoint_id ON webhook_deliveries (endpoint_id);
+CREATE INDEX idx_webhook_deliveries_event_id ON webhook_deliveries (event_id);
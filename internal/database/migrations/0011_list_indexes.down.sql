@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_orders_created_at;
+DROP INDEX IF EXISTS idx_subscriptions_created_at;
+DROP INDEX IF EXISTS idx_subscriptions_user_id;
+DROP INDEX IF EXISTS idx_subscriptions_basket_id;
+DROP INDEX IF EXISTS idx_baskets_created_at;
+DROP INDEX IF EXISTS idx_baskets_user_id;
+DROP INDEX IF EXISTS idx_users_created_at;
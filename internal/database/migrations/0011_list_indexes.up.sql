@@ -0,0 +1,9 @@
+-- Indexes for the filters and default orders of the paginated list endpoints.
+-- The id is included so cursors continue from an index position.
+CREATE INDEX idx_users_created_at ON users (created_at, id);
+CREATE INDEX idx_baskets_user_id ON baskets (user_id);
+CREATE INDEX idx_baskets_created_at ON baskets (created_at, id);
+CREATE INDEX idx_subscriptions_basket_id ON subscriptions (basket_id);
+CREATE INDEX idx_subscriptions_user_id ON subscriptions (user_id);
+CREATE INDEX idx_subscriptions_created_at ON subscriptions (created_at, id);
+CREATE INDEX idx_orders_created_at ON orders (created_at, id);
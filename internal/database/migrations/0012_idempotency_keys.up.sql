@@ -0,0 +1,18 @@
+-- Responses to requests sent with an Idempotency-Key, replayed to retries.
+-- A status_code of 0 marks a request that is still being processed.
+CREATE TABLE idempotency_keys (
+    id           bigserial PRIMARY KEY,
+    user_id      bigint NOT NULL,
+    key          text NOT NULL,
+    method       text NOT NULL,
+    path         text NOT NULL,
+    fingerprint  text NOT NULL,
+    status_code  integer NOT NULL DEFAULT 0,
+    content_type text NOT NULL DEFAULT '',
+    response     bytea,
+    expires_at   timestamptz NOT NULL,
+    created_at   timestamptz NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_idempotency_keys_user_key ON idempotency_keys (user_id, key);
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
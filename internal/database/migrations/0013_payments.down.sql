@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS payments;
+
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS card_last4;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS card_brand;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS payment_token;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS payment_method;
+
+ALTER TABLE users DROP COLUMN IF EXISTS payment_customer_id;
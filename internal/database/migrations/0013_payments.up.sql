@@ -0,0 +1,33 @@
+-- Customers and payment methods are stored at the payment provider; only
+-- their IDs and what is needed to show the card to its owner are kept here
+ALTER TABLE users ADD COLUMN payment_customer_id text NOT NULL DEFAULT '';
+
+ALTER TABLE subscriptions ADD COLUMN payment_method text NOT NULL DEFAULT '';
+ALTER TABLE subscriptions ADD COLUMN payment_token text NOT NULL DEFAULT '';
+ALTER TABLE subscriptions ADD COLUMN card_brand text NOT NULL DEFAULT '';
+ALTER TABLE subscriptions ADD COLUMN card_last4 text NOT NULL DEFAULT '';
+
+-- Every attempt to charge an order, with the provider's answer
+CREATE TABLE payments (
+    id              bigserial PRIMARY KEY,
+    order_id        bigint NOT NULL REFERENCES orders (id),
+    subscription_id bigint NOT NULL REFERENCES subscriptions (id),
+    provider        text NOT NULL,
+    method          text NOT NULL DEFAULT '',
+    charge_id       text NOT NULL DEFAULT '',
+    amount_amount   bigint NOT NULL,
+    amount_currency varchar(3) NOT NULL DEFAULT 'BRL',
+    status          text NOT NULL,
+    attempt         integer NOT NULL DEFAULT 1,
+    failure_code    text NOT NULL DEFAULT '',
+    failure_message text NOT NULL DEFAULT '',
+    paid_at         timestamptz,
+    refunded_at     timestamptz,
+    created_at      timestamptz NOT NULL,
+    updated_at      timestamptz NOT NULL
+);
+
+CREATE INDEX idx_payments_order_id ON payments (order_id);
+CREATE INDEX idx_payments_subscription_id ON payments (subscription_id);
+CREATE INDEX idx_payments_status ON payments (status);
+CREATE INDEX idx_payments_provider_charge_id ON payments (provider, charge_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_payments_end_to_end_id;
+
+ALTER TABLE payments DROP COLUMN IF EXISTS end_to_end_id;
@@ -0,0 +1,5 @@
+-- Pix payments are matched by txid (stored in charge_id) and keep the ID of
+-- the transfer that paid them
+ALTER TABLE payments ADD COLUMN end_to_end_id text NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX idx_payments_end_to_end_id ON payments (end_to_end_id) WHERE end_to_end_id <> '';
@@ -0,0 +1,28 @@
+-- Boletos issued for orders of subscribers with a CNPJ. Each belongs to the
+-- payment attempt it settles.
+CREATE TABLE boletos (
+    id                   bigserial PRIMARY KEY,
+    order_id             bigint NOT NULL REFERENCES orders (id),
+    payment_id           bigint NOT NULL REFERENCES payments (id),
+    bank_code            varchar(3) NOT NULL,
+    our_number           text NOT NULL,
+    barcode              varchar(44) NOT NULL,
+    digitable_line       varchar(47) NOT NULL,
+    amount_amount        bigint NOT NULL,
+    amount_currency      varchar(3) NOT NULL DEFAULT 'BRL',
+    due_date             date NOT NULL,
+    payer_name           text NOT NULL DEFAULT '',
+    payer_document       text NOT NULL DEFAULT '',
+    payer_address        text NOT NULL DEFAULT '',
+    status               text NOT NULL,
+    paid_at              timestamptz,
+    paid_amount_amount   bigint NOT NULL DEFAULT 0,
+    paid_amount_currency varchar(3) NOT NULL DEFAULT '',
+    created_at           timestamptz NOT NULL,
+    updated_at           timestamptz NOT NULL
+);
+
+CREATE INDEX idx_boletos_order_id ON boletos (order_id);
+CREATE INDEX idx_boletos_payment_id ON boletos (payment_id);
+CREATE INDEX idx_boletos_status ON boletos (status);
+CREATE UNIQUE INDEX idx_boletos_our_number ON boletos (our_number);
@@ -0,0 +1,4 @@
+ALTER TABLE subscriptions DROP COLUMN dunning_order_id;
+ALTER TABLE subscriptions DROP COLUMN next_retry_at;
+ALTER TABLE subscriptions DROP COLUMN dunning_attempts;
+ALTER TABLE subscriptions DROP COLUMN past_due_at;
@@ -0,0 +1,6 @@
+-- Dunning of failed recurring charges: a subscription whose charge fails
+-- becomes past_due and the charge is retried on the configured schedule.
+ALTER TABLE subscriptions ADD COLUMN past_due_at timestamptz;
+ALTER TABLE subscriptions ADD COLUMN dunning_attempts integer NOT NULL DEFAULT 0;
+ALTER TABLE subscriptions ADD COLUMN next_retry_at timestamptz;
+ALTER TABLE subscriptions ADD COLUMN dunning_order_id bigint NOT NULL DEFAULT 0;
@@ -0,0 +1,57 @@
+-- Invoices of orders, one per delivered subscription cycle, numbered
+-- sequentially per seller. invoice_sequences holds each seller's last number
+-- so numbers are allocated without gaps inside the issuing transaction.
+CREATE TABLE invoice_sequences (
+    seller_id   bigint PRIMARY KEY REFERENCES users (id),
+    last_number integer NOT NULL
+);
+
+CREATE TABLE invoices (
+    id                bigserial PRIMARY KEY,
+    seller_id         bigint NOT NULL REFERENCES users (id),
+    sequence          integer NOT NULL,
+    number            text NOT NULL,
+    consumer_id       bigint NOT NULL REFERENCES users (id),
+    subscription_id   bigint NOT NULL REFERENCES subscriptions (id),
+    order_id          bigint NOT NULL REFERENCES orders (id),
+    period_start      timestamptz NOT NULL,
+    period_end        timestamptz NOT NULL,
+    issued_at         timestamptz NOT NULL,
+    locale            text NOT NULL DEFAULT '',
+    subtotal_amount   bigint NOT NULL,
+    subtotal_currency varchar(3) NOT NULL DEFAULT 'BRL',
+    discount_amount   bigint NOT NULL DEFAULT 0,
+    discount_currency varchar(3) NOT NULL DEFAULT 'BRL',
+    tax_amount        bigint NOT NULL DEFAULT 0,
+    tax_currency      varchar(3) NOT NULL DEFAULT 'BRL',
+    total_amount      bigint NOT NULL,
+    total_currency    varchar(3) NOT NULL DEFAULT 'BRL',
+    seller_name       text NOT NULL DEFAULT '',
+    seller_document   text NOT NULL DEFAULT '',
+    seller_address    text NOT NULL DEFAULT '',
+    consumer_name     text NOT NULL DEFAULT '',
+    consumer_document text NOT NULL DEFAULT '',
+    consumer_email    text NOT NULL DEFAULT '',
+    consumer_address  text NOT NULL DEFAULT '',
+    created_at        timestamptz NOT NULL,
+    updated_at        timestamptz NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_invoices_seller_sequence ON invoices (seller_id, sequence);
+CREATE UNIQUE INDEX idx_invoices_order_id ON invoices (order_id);
+CREATE INDEX idx_invoices_consumer_id ON invoices (consumer_id);
+CREATE INDEX idx_invoices_subscription_id ON invoices (subscription_id);
+
+CREATE TABLE invoice_items (
+    id                  bigserial PRIMARY KEY,
+    invoice_id          bigint NOT NULL REFERENCES invoices (id) ON DELETE CASCADE,
+    kind                text NOT NULL,
+    description         text NOT NULL DEFAULT '',
+    quantity            integer NOT NULL DEFAULT 1,
+    unit_price_amount   bigint NOT NULL,
+    unit_price_currency varchar(3) NOT NULL DEFAULT 'BRL',
+    amount_amount       bigint NOT NULL,
+    amount_currency     varchar(3) NOT NULL DEFAULT 'BRL'
+);
+
+CREATE INDEX idx_invoice_items_invoice_id ON invoice_items (invoice_id);
@@ -0,0 +1,14 @@
+// Package migrations holds the versioned SQL migrations of the database schema.
+//
+// Files are named NNNN_description.up.sql and NNNN_description.down.sql and are
+// applied in version order by database.Migrator. Create new ones with
+// `go run ./cmd/migrate new <description>`; never edit a migration once it has
+// been applied, the checksum stored in schema_migrations will no longer match.
+package migrations
+
+import "embed"
+
+// FS contains every migration file
+//
+//go:embed *.sql
+var FS embed.FS
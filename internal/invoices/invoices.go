@@ -0,0 +1,192 @@
+// Package invoices issues the invoice of each order, the billing statement of
+// one subscription cycle, and renders it as PDF or HTML.
+package invoices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/config"
+	"github.com/alexandreffaria/hoby-loop/internal/lifecycle"
+	"github.com/alexandreffaria/hoby-loop/internal/notify"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// Service issues invoices for orders and reports whether they were paid
+type Service struct {
+	Invoices       repository.InvoiceRepository
+	Orders         repository.OrderRepository
+	Payments       repository.PaymentRepository
+	Users          repository.UserRepository
+	TaxBasisPoints int64  // Approximate taxes included in prices, 0 leaves them out
+	DefaultLocale  string // Language of invoices of consumers without a supported locale
+	Now            func() time.Time
+}
+
+// NewService creates a Service storing invoices in repos
+func NewService(repos repository.Repositories) *Service {
+	return &Service{
+		Invoices:       repos.Invoices,
+		Orders:         repos.Orders,
+		Payments:       repos.Payments,
+		Users:          repos.Users,
+		TaxBasisPoints: config.GetInvoicesConfig().TaxBasisPoints(),
+		DefaultLocale:  config.GetNotificationsConfig().DefaultLocale,
+		Now:            time.Now,
+	}
+}
+
+// Issue returns the invoice of an order, issuing it with the seller's next
+// number if the order has none yet. The invoice bills what the order is
+// charged: the basket price, or nothing for deliveries within the trial.
+func (s *Service) Issue(ctx context.Context, orderID uint) (*models.Invoice, error) {
+	invoice, err := s.Invoices.FindByOrder(ctx, orderID)
+	if err == nil {
+		return invoice, s.LoadStatus(ctx, invoice)
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	order, err := s.Orders.FindByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	seller, err := s.Users.FindByID(ctx, order.Subscription.Basket.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("seller of order %d: %w", orderID, err)
+	}
+	invoice, err = s.build(order, seller)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Invoices.Create(ctx, invoice)
+	if errors.Is(err, repository.ErrConflict) {
+		// Issued concurrently
+		invoice, err = s.Invoices.FindByOrder(ctx, orderID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return invoice, s.LoadStatus(ctx, invoice)
+}
+
+// build prepares the invoice of order with its lines, leaving the numbering
+// to the repository
+func (s *Service) build(order *models.Order, seller *models.User) (*models.Invoice, error) {
+	subscription := order.Subscription
+	consumer := subscription.User
+	basket := subscription.Basket
+
+	locale, ok := notify.NormalizeLocale(consumer.Locale)
+	if !ok {
+		locale = s.DefaultLocale
+	}
+	periodStart := order.CreatedAt
+	if order.ScheduledFor != nil {
+		periodStart = *order.ScheduledFor
+	}
+	periodEnd, err := lifecycle.NextDelivery(subscription.Frequency, subscription.CreatedAt, periodStart)
+	if err != nil {
+		return nil, err
+	}
+
+	price := basket.Price
+	zero := models.NewMoney(0, price.Currency)
+	items := []models.InvoiceItem{
+		{
+			Kind:        models.InvoiceItemBasket,
+			Description: fmt.Sprintf("%s (%s)", basket.Name, label(locale, "frequency."+subscription.Frequency)),
+			Quantity:    1,
+			UnitPrice:   price,
+			Amount:      price,
+		},
+		// Deliveries are included in the basket price
+		{Kind: models.InvoiceItemShipping, Description: label(locale, "item.shipping"), Quantity: 1, UnitPrice: zero, Amount: zero},
+	}
+	subtotal := price
+	discount := zero
+	if subscription.TrialEndsAt != nil && subscription.TrialEndsAt.After(order.CreatedAt) {
+		discount = subtotal
+		items = append(items, models.InvoiceItem{
+			Kind:        models.InvoiceItemDiscount,
+			Description: label(locale, "item.trial"),
+			Quantity:    1,
+			UnitPrice:   discount.Negate(),
+			Amount:      discount.Negate(),
+		})
+	}
+	total, err := subtotal.Sub(discount)
+	if err != nil {
+		return nil, err
+	}
+
+	tax := zero
+	if s.TaxBasisPoints > 0 && total.IsPositive() {
+		if tax, err = total.Scale(s.TaxBasisPoints, 10000); err != nil {
+			return nil, err
+		}
+		items = append(items, models.InvoiceItem{
+			Kind:        models.InvoiceItemTax,
+			Description: fmt.Sprintf(label(locale, "item.tax"), formatRate(locale, s.TaxBasisPoints)),
+			Quantity:    1,
+			UnitPrice:   tax,
+			Amount:      tax,
+		})
+	}
+
+	consumerDocument := consumer.CNPJ
+	if consumerDocument == "" {
+		consumerDocument = consumer.CPF
+	}
+	return &models.Invoice{
+		SellerID:         seller.ID,
+		ConsumerID:       consumer.ID,
+		SubscriptionID:   subscription.ID,
+		OrderID:          order.ID,
+		PeriodStart:      periodStart,
+		PeriodEnd:        periodEnd,
+		IssuedAt:         s.Now(),
+		Locale:           locale,
+		Subtotal:         subtotal,
+		Discount:         discount,
+		Tax:              tax,
+		Total:            total,
+		SellerName:       seller.Name,
+		SellerDocument:   seller.CNPJ,
+		SellerAddress:    seller.PostalAddress(),
+		ConsumerName:     consumer.Name,
+		ConsumerDocument: consumerDocument,
+		ConsumerEmail:    consumer.Email,
+		ConsumerAddress:  consumer.PostalAddress(),
+		Items:            items,
+	}, nil
+}
+
+// LoadStatus fills in whether the invoice was paid from the payments of its
+// order
+func (s *Service) LoadStatus(ctx context.Context, invoice *models.Invoice) error {
+	invoice.Status, invoice.PaidAt = models.InvoiceOpen, nil
+	if !invoice.Total.IsPositive() {
+		invoice.Status = models.InvoiceNoCharge
+		return nil
+	}
+
+	payments, err := s.Payments.ListByOrder(ctx, invoice.OrderID)
+	if err != nil {
+		return err
+	}
+	for _, payment := range payments {
+		switch {
+		case payment.Status == models.PaymentSucceeded:
+			invoice.Status, invoice.PaidAt = models.InvoicePaid, payment.PaidAt
+		case payment.Status == models.PaymentRefunded && invoice.Status != models.InvoicePaid:
+			invoice.Status, invoice.PaidAt = models.InvoiceRefunded, payment.PaidAt
+		}
+	}
+	return nil
+}
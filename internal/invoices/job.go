@@ -0,0 +1,46 @@
+package invoices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/alexandreffaria/hoby-loop/internal/outbox"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// KindIssue issues the invoice of an order
+const KindIssue = "invoice.issue"
+
+// issuePayload is the payload of KindIssue jobs
+type issuePayload struct {
+	OrderID uint `json:"order_id"`
+}
+
+// IssueJobs builds the job issuing the invoice of a new order, for use as a
+// repository.JobBuilder
+func IssueJobs(order models.Order) ([]models.OutboxJob, error) {
+	job, err := outbox.NewJob(KindIssue, issuePayload{OrderID: order.ID})
+	if err != nil {
+		return nil, err
+	}
+	return []models.OutboxJob{job}, nil
+}
+
+// IssueHandler runs KindIssue jobs. Issuing is idempotent, so retries never
+// number an order twice.
+func IssueHandler(service *Service) outbox.Handler {
+	return func(ctx context.Context, job *models.OutboxJob) error {
+		var payload issuePayload
+		if err := outbox.Decode(job, &payload); err != nil {
+			return err
+		}
+
+		_, err := service.Issue(ctx, payload.OrderID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return outbox.Permanent(fmt.Errorf("order %d not found", payload.OrderID))
+		}
+		return err
+	}
+}
@@ -0,0 +1,303 @@
+package invoices
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/alexandreffaria/hoby-loop/internal/notify"
+	"github.com/alexandreffaria/hoby-loop/internal/pdf"
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// labels holds the text printed on invoices, keyed by locale
+var labels = map[string]map[string]string{
+	models.LocalePortuguese: {
+		"title":       "Fatura",
+		"number":      "Nº",
+		"issued_at":   "Emissão",
+		"period":      "Período",
+		"order":       "Pedido",
+		"seller":      "Vendedor",
+		"consumer":    "Cliente",
+		"description": "Descrição",
+		"quantity":    "Qtd.",
+		"unit_price":  "Valor unitário",
+		"amount":      "Valor",
+		"subtotal":    "Subtotal",
+		"discount":    "Descontos",
+		"total":       "Total",
+
+		"frequency.weekly":   "semanal",
+		"frequency.biweekly": "quinzenal",
+		"frequency.monthly":  "mensal",
+
+		"item.shipping": "Entrega (incluída no preço)",
+		"item.trial":    "Desconto do período de teste",
+		"item.tax":      "Tributos aproximados incluídos (%s%%) - Lei 12.741/2012",
+
+		"status.open":      "Aguardando pagamento",
+		"status.paid":      "Pago em %s",
+		"status.refunded":  "Pagamento estornado",
+		"status.no_charge": "Sem cobrança",
+	},
+	models.LocaleEnglish: {
+		"title":       "Invoice",
+		"number":      "No.",
+		"issued_at":   "Issued",
+		"period":      "Period",
+		"order":       "Order",
+		"seller":      "Seller",
+		"consumer":    "Customer",
+		"description": "Description",
+		"quantity":    "Qty",
+		"unit_price":  "Unit price",
+		"amount":      "Amount",
+		"subtotal":    "Subtotal",
+		"discount":    "Discounts",
+		"total":       "Total",
+
+		"frequency.weekly":   "weekly",
+		"frequency.biweekly": "every two weeks",
+		"frequency.monthly":  "monthly",
+
+		"item.shipping": "Delivery (included in the price)",
+		"item.trial":    "Trial period discount",
+		"item.tax":      "Approximate taxes included (%s%%)",
+
+		"status.open":      "Awaiting payment",
+		"status.paid":      "Paid on %s",
+		"status.refunded":  "Payment refunded",
+		"status.no_charge": "Nothing to pay",
+	},
+}
+
+// label returns the text of key in locale, in Portuguese if locale is not
+// supported
+func label(locale, key string) string {
+	catalog, ok := labels[locale]
+	if !ok {
+		catalog = labels[models.LocalePortuguese]
+	}
+	if text, ok := catalog[key]; ok {
+		return text
+	}
+	return key
+}
+
+// formatRate renders a rate in hundredths of a percent as a percentage,
+// e.g. 1345 as 13,45 in Portuguese
+func formatRate(locale string, basisPoints int64) string {
+	rate := fmt.Sprintf("%d.%02d", basisPoints/100, basisPoints%100)
+	rate = strings.TrimSuffix(strings.TrimRight(rate, "0"), ".")
+	if locale == models.LocalePortuguese {
+		rate = strings.Replace(rate, ".", ",", 1)
+	}
+	return rate
+}
+
+// documentName returns whether a Brazilian document is a CNPJ or a CPF
+func documentName(document string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, document)
+	if len(digits) == 14 {
+		return "CNPJ"
+	}
+	return "CPF"
+}
+
+// party is the seller or consumer as printed
+type party struct {
+	Name    string
+	Details []string // Document, email and address lines, when known
+}
+
+// line is an invoice item as printed
+type line struct {
+	Description string
+	Quantity    int
+	UnitPrice   string
+	Amount      string
+}
+
+// view is an invoice with every text already translated and formatted,
+// shared by the PDF and HTML renderings
+type view struct {
+	Lang     string
+	Title    string
+	Labels   map[string]string
+	IssuedAt string
+	Period   string
+	OrderID  uint
+	Seller   party
+	Consumer party
+	Lines    []line
+	Subtotal string
+	Discount string // Empty without discounts
+	Total    string
+	TaxNote  string // Empty without approximate taxes
+	Status   string
+}
+
+// newView prepares invoice for rendering in its locale
+func newView(invoice *models.Invoice) view {
+	locale := invoice.Locale
+	if _, ok := labels[locale]; !ok {
+		locale = models.LocalePortuguese
+	}
+	v := view{
+		Lang:     locale,
+		Title:    fmt.Sprintf("%s %s %s", label(locale, "title"), label(locale, "number"), invoice.Number),
+		Labels:   labels[locale],
+		IssuedAt: notify.FormatDate(locale, invoice.IssuedAt),
+		Period:   notify.FormatDate(locale, invoice.PeriodStart) + " - " + notify.FormatDate(locale, invoice.PeriodEnd),
+		OrderID:  invoice.OrderID,
+		Seller:   party{Name: invoice.SellerName},
+		Consumer: party{Name: invoice.ConsumerName},
+		Subtotal: invoice.Subtotal.Format(),
+		Total:    invoice.Total.Format(),
+	}
+	if invoice.SellerDocument != "" {
+		v.Seller.Details = append(v.Seller.Details, "CNPJ "+invoice.SellerDocument)
+	}
+	if invoice.SellerAddress != "" {
+		v.Seller.Details = append(v.Seller.Details, invoice.SellerAddress)
+	}
+	if invoice.ConsumerDocument != "" {
+		v.Consumer.Details = append(v.Consumer.Details, documentName(invoice.ConsumerDocument)+" "+invoice.ConsumerDocument)
+	}
+	v.Consumer.Details = append(v.Consumer.Details, invoice.ConsumerEmail)
+	if invoice.ConsumerAddress != "" {
+		v.Consumer.Details = append(v.Consumer.Details, invoice.ConsumerAddress)
+	}
+	if !invoice.Discount.IsZero() {
+		v.Discount = invoice.Discount.Negate().Format()
+	}
+
+	for _, item := range invoice.Items {
+		if item.Kind == models.InvoiceItemTax {
+			// Already included in the other lines
+			v.TaxNote = item.Description + ": " + item.Amount.Format()
+			continue
+		}
+		v.Lines = append(v.Lines, line{
+			Description: item.Description,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice.Format(),
+			Amount:      item.Amount.Format(),
+		})
+	}
+
+	switch invoice.Status {
+	case models.InvoicePaid:
+		paidAt := ""
+		if invoice.PaidAt != nil {
+			paidAt = notify.FormatDate(locale, *invoice.PaidAt)
+		}
+		v.Status = strings.TrimSpace(fmt.Sprintf(label(locale, "status.paid"), paidAt))
+	case "":
+	default:
+		v.Status = label(locale, "status."+string(invoice.Status))
+	}
+	return v
+}
+
+//go:embed templates
+var templateFS embed.FS
+
+// htmlTemplate renders a view as a standalone HTML page
+var htmlTemplate = template.Must(template.ParseFS(templateFS, "templates/invoice.html.tmpl"))
+
+// HTML renders invoice as a standalone page, in its locale
+func HTML(invoice *models.Invoice) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, newView(invoice)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// PDF renders invoice as a printable A4 page, in its locale
+func PDF(invoice *models.Invoice) ([]byte, error) {
+	v := newView(invoice)
+	doc := pdf.New(v.Title)
+	page := doc.AddPage()
+
+	const left, right = 48.0, pdf.PageWidth - 48
+	const quantityX, unitPriceX = right - 190, right - 95
+
+	// Header: the invoice on the left, the seller on the right
+	y := 72.0
+	page.Text(left, y, pdf.HelveticaBold, 18, pdf.Left, v.Title)
+	page.Text(right, y, pdf.HelveticaBold, 11, pdf.Right, v.Seller.Name)
+	sellerY := y
+	for _, detail := range v.Seller.Details {
+		sellerY += 13
+		page.Text(right, sellerY, pdf.Helvetica, 8.5, pdf.Right, detail)
+	}
+	y += 20
+	page.Text(left, y, pdf.Helvetica, 9, pdf.Left, fmt.Sprintf("%s: %s", v.Labels["issued_at"], v.IssuedAt))
+	y += 13
+	page.Text(left, y, pdf.Helvetica, 9, pdf.Left, fmt.Sprintf("%s: %s", v.Labels["period"], v.Period))
+	y += 13
+	page.Text(left, y, pdf.Helvetica, 9, pdf.Left, fmt.Sprintf("%s: %d", v.Labels["order"], v.OrderID))
+	y = max(y, sellerY) + 16
+	page.Line(left, y, right, y, 1)
+
+	// Consumer
+	y += 20
+	page.Text(left, y, pdf.Helvetica, 7, pdf.Left, strings.ToUpper(v.Labels["consumer"]))
+	y += 14
+	page.Text(left, y, pdf.HelveticaBold, 10, pdf.Left, v.Consumer.Name)
+	for _, detail := range v.Consumer.Details {
+		y += 13
+		page.Text(left, y, pdf.Helvetica, 9, pdf.Left, detail)
+	}
+
+	// Lines
+	y += 32
+	page.Text(left, y, pdf.HelveticaBold, 8, pdf.Left, v.Labels["description"])
+	page.Text(quantityX, y, pdf.HelveticaBold, 8, pdf.Right, v.Labels["quantity"])
+	page.Text(unitPriceX, y, pdf.HelveticaBold, 8, pdf.Right, v.Labels["unit_price"])
+	page.Text(right, y, pdf.HelveticaBold, 8, pdf.Right, v.Labels["amount"])
+	y += 6
+	page.Line(left, y, right, y, 0.5)
+	for _, l := range v.Lines {
+		y += 16
+		page.Text(left, y, pdf.Helvetica, 9, pdf.Left, l.Description)
+		page.Text(quantityX, y, pdf.Helvetica, 9, pdf.Right, fmt.Sprint(l.Quantity))
+		page.Text(unitPriceX, y, pdf.Helvetica, 9, pdf.Right, l.UnitPrice)
+		page.Text(right, y, pdf.Helvetica, 9, pdf.Right, l.Amount)
+	}
+	y += 8
+	page.Line(left, y, right, y, 0.5)
+
+	// Totals
+	total := func(label, amount string, font pdf.Font) {
+		y += 16
+		page.Text(unitPriceX, y, font, 9, pdf.Right, label)
+		page.Text(right, y, font, 9, pdf.Right, amount)
+	}
+	total(v.Labels["subtotal"], v.Subtotal, pdf.Helvetica)
+	if v.Discount != "" {
+		total(v.Labels["discount"], v.Discount, pdf.Helvetica)
+	}
+	total(v.Labels["total"], v.Total, pdf.HelveticaBold)
+	if v.TaxNote != "" {
+		y += 20
+		page.Text(left, y, pdf.Helvetica, 7.5, pdf.Left, v.TaxNote)
+	}
+	if v.Status != "" {
+		y += 24
+		page.Text(left, y, pdf.HelveticaBold, 10, pdf.Left, v.Status)
+	}
+
+	page.Text(pdf.PageWidth/2, pdf.PageHeight-36, pdf.Helvetica, 7, pdf.Center, "Hoby Loop")
+	return doc.Bytes()
+}
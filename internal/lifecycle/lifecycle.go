@@ -0,0 +1,249 @@
+// Package lifecycle implements the subscription state machine. Each
+// operation validates the transition against models.SubscriptionStatus,
+// updates the subscription in place and returns the event to record in its
+// history. Persisting both is left to the caller.
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// SystemActor is the actor ID recorded for changes made by the scheduler
+const SystemActor uint = 0
+
+// ErrInvalidTransition is returned when the subscription cannot move to the requested state
+var ErrInvalidTransition = errors.New("invalid subscription transition")
+
+// ErrInvalidPauseDate is returned when a pause would end in the past
+var ErrInvalidPauseDate = errors.New("pause end date must be in the future")
+
+// Start initialises a new subscription. It starts trialing when trialPeriod
+// is positive, and the first delivery is due right away.
+func Start(subscription *models.Subscription, actorID uint, trialPeriod time.Duration, now time.Time) models.SubscriptionEvent {
+	subscription.CreatedAt = now
+	subscription.NextDeliveryAt = &now
+	subscription.Status = models.SubscriptionActive
+	if trialPeriod > 0 {
+		trialEndsAt := now.Add(trialPeriod)
+		subscription.Status = models.SubscriptionTrialing
+		subscription.TrialEndsAt = &trialEndsAt
+	}
+
+	return models.SubscriptionEvent{
+		SubscriptionID: subscription.ID,
+		ActorID:        actorID,
+		Action:         models.SubscriptionEventCreated,
+		ToStatus:       subscription.Status,
+		CreatedAt:      now,
+	}
+}
+
+// Pause stops deliveries until resumed, or until the given date when set.
+// The failed charge of a past due subscription is kept, and its retries
+// suspended, until it resumes.
+func Pause(subscription *models.Subscription, actorID uint, until *time.Time, reason string, now time.Time) (models.SubscriptionEvent, error) {
+	if until != nil && !until.After(now) {
+		return models.SubscriptionEvent{}, ErrInvalidPauseDate
+	}
+
+	event, err := transition(subscription, models.SubscriptionPaused, models.SubscriptionEventPaused, actorID, now)
+	if err != nil {
+		return event, err
+	}
+	subscription.PausedUntil = until
+	subscription.NextDeliveryAt = nil
+	subscription.NextRetryAt = nil
+	event.Reason = reason
+	event.EffectiveUntil = until
+	return event, nil
+}
+
+// Resume restarts deliveries of a paused subscription from its next regular
+// delivery date. Subscriptions still within their trial go back to trialing.
+// Subscriptions paused with a failed charge go back to past_due instead,
+// with the charge retried right away.
+func Resume(subscription *models.Subscription, actorID uint, now time.Time) (models.SubscriptionEvent, error) {
+	if subscription.Status != models.SubscriptionPaused {
+		return models.SubscriptionEvent{}, invalid(subscription.Status, models.SubscriptionActive)
+	}
+
+	if subscription.DunningOrderID != 0 {
+		event, err := transition(subscription, models.SubscriptionPastDue, models.SubscriptionEventResumed, actorID, now)
+		if err != nil {
+			return event, err
+		}
+		subscription.PausedUntil = nil
+		subscription.NextRetryAt = &now
+		return event, nil
+	}
+
+	next, err := NextDelivery(subscription.Frequency, subscription.CreatedAt, now)
+	if err != nil {
+		return models.SubscriptionEvent{}, err
+	}
+
+	target := models.SubscriptionActive
+	if subscription.TrialEndsAt != nil && subscription.TrialEndsAt.After(now) {
+		target = models.SubscriptionTrialing
+	}
+
+	event, err := transition(subscription, target, models.SubscriptionEventResumed, actorID, now)
+	if err != nil {
+		return event, err
+	}
+	subscription.PausedUntil = nil
+	subscription.NextDeliveryAt = &next
+	return event, nil
+}
+
+// Cancel ends the subscription for good
+func Cancel(subscription *models.Subscription, actorID uint, reason string, now time.Time) (models.SubscriptionEvent, error) {
+	event, err := transition(subscription, models.SubscriptionCancelled, models.SubscriptionEventCancelled, actorID, now)
+	if err != nil {
+		return event, err
+	}
+	subscription.CancelledAt = &now
+	subscription.CancellationReason = reason
+	subscription.NextDeliveryAt = nil
+	subscription.PausedUntil = nil
+	clearDunning(subscription)
+	event.Reason = reason
+	return event, nil
+}
+
+// SkipNext moves the next delivery to the following regular date
+func SkipNext(subscription *models.Subscription, actorID uint, now time.Time) (models.SubscriptionEvent, error) {
+	if !subscription.Status.ReceivesDeliveries() || subscription.NextDeliveryAt == nil {
+		return models.SubscriptionEvent{}, fmt.Errorf("%w: a %s subscription has no delivery to skip", ErrInvalidTransition, subscription.Status)
+	}
+
+	skipped := *subscription.NextDeliveryAt
+	next, err := NextDelivery(subscription.Frequency, subscription.CreatedAt, skipped)
+	if err != nil {
+		return models.SubscriptionEvent{}, err
+	}
+	subscription.NextDeliveryAt = &next
+
+	return models.SubscriptionEvent{
+		SubscriptionID: subscription.ID,
+		ActorID:        actorID,
+		Action:         models.SubscriptionEventSkipped,
+		FromStatus:     subscription.Status,
+		ToStatus:       subscription.Status,
+		SkippedFor:     &skipped,
+		CreatedAt:      now,
+	}, nil
+}
+
+// EndTrial moves a trialing subscription whose trial is over to active
+func EndTrial(subscription *models.Subscription, now time.Time) (models.SubscriptionEvent, error) {
+	if subscription.Status != models.SubscriptionTrialing {
+		return models.SubscriptionEvent{}, invalid(subscription.Status, models.SubscriptionActive)
+	}
+	return transition(subscription, models.SubscriptionActive, models.SubscriptionEventTrialEnded, SystemActor, now)
+}
+
+// MarkPastDue puts deliveries on hold after the charge of orderID failed.
+// nextRetryAt is when the charge is retried, nil when it will not be.
+func MarkPastDue(subscription *models.Subscription, orderID uint, nextRetryAt *time.Time, reason string, now time.Time) (models.SubscriptionEvent, error) {
+	event, err := transition(subscription, models.SubscriptionPastDue, models.SubscriptionEventPastDue, SystemActor, now)
+	if err != nil {
+		return event, err
+	}
+	subscription.NextDeliveryAt = nil
+	subscription.PastDueAt = &now
+	subscription.DunningAttempts = 1
+	subscription.DunningOrderID = orderID
+	subscription.NextRetryAt = nextRetryAt
+	event.Reason = reason
+	return event, nil
+}
+
+// RetryFailed records another failed charge of a past due subscription.
+// nextRetryAt is when the charge is retried again, nil when it will not be.
+func RetryFailed(subscription *models.Subscription, nextRetryAt *time.Time, reason string, now time.Time) (models.SubscriptionEvent, error) {
+	if subscription.Status != models.SubscriptionPastDue {
+		return models.SubscriptionEvent{}, fmt.Errorf("%w: a %s subscription has no failed charge to retry", ErrInvalidTransition, subscription.Status)
+	}
+	subscription.DunningAttempts++
+	subscription.NextRetryAt = nextRetryAt
+
+	return models.SubscriptionEvent{
+		SubscriptionID: subscription.ID,
+		ActorID:        SystemActor,
+		Action:         models.SubscriptionEventRetryFailed,
+		FromStatus:     subscription.Status,
+		ToStatus:       subscription.Status,
+		Reason:         reason,
+		CreatedAt:      now,
+	}, nil
+}
+
+// Recover reactivates a past due subscription once its failed charge is paid.
+// Deliveries restart from the next regular delivery date. A subscription
+// paused meanwhile stays paused, without the failed charge.
+func Recover(subscription *models.Subscription, now time.Time) (models.SubscriptionEvent, error) {
+	if subscription.Status == models.SubscriptionPaused && subscription.DunningOrderID != 0 {
+		clearDunning(subscription)
+		return models.SubscriptionEvent{
+			SubscriptionID: subscription.ID,
+			ActorID:        SystemActor,
+			Action:         models.SubscriptionEventRecovered,
+			FromStatus:     subscription.Status,
+			ToStatus:       subscription.Status,
+			CreatedAt:      now,
+		}, nil
+	}
+	if subscription.Status != models.SubscriptionPastDue {
+		return models.SubscriptionEvent{}, invalid(subscription.Status, models.SubscriptionActive)
+	}
+
+	next, err := NextDelivery(subscription.Frequency, subscription.CreatedAt, now)
+	if err != nil {
+		return models.SubscriptionEvent{}, err
+	}
+
+	event, err := transition(subscription, models.SubscriptionActive, models.SubscriptionEventRecovered, SystemActor, now)
+	if err != nil {
+		return event, err
+	}
+	subscription.NextDeliveryAt = &next
+	clearDunning(subscription)
+	return event, nil
+}
+
+// transition moves the subscription to the target state if allowed
+func transition(subscription *models.Subscription, target models.SubscriptionStatus, action string, actorID uint, now time.Time) (models.SubscriptionEvent, error) {
+	from := subscription.Status
+	if !from.CanTransitionTo(target) {
+		return models.SubscriptionEvent{}, invalid(from, target)
+	}
+	subscription.Status = target
+
+	return models.SubscriptionEvent{
+		SubscriptionID: subscription.ID,
+		ActorID:        actorID,
+		Action:         action,
+		FromStatus:     from,
+		ToStatus:       target,
+		CreatedAt:      now,
+	}, nil
+}
+
+// clearDunning forgets the failed charge of a subscription once it is paid or
+// the subscription is cancelled
+func clearDunning(subscription *models.Subscription) {
+	subscription.PastDueAt = nil
+	subscription.DunningAttempts = 0
+	subscription.NextRetryAt = nil
+	subscription.DunningOrderID = 0
+}
+
+// invalid describes a rejected transition
+func invalid(from, to models.SubscriptionStatus) error {
+	return fmt.Errorf("%w: cannot go from %s to %s", ErrInvalidTransition, from, to)
+}
@@ -0,0 +1,122 @@
+package lifecycle
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+func TestSubscriptionTransitions(t *testing.T) {
+	// allowed lists every permitted move; every other pair must be refused
+	allowed := map[models.SubscriptionStatus][]models.SubscriptionStatus{
+		models.SubscriptionTrialing:  {models.SubscriptionActive, models.SubscriptionPaused, models.SubscriptionCancelled},
+		models.SubscriptionActive:    {models.SubscriptionPaused, models.SubscriptionPastDue, models.SubscriptionCancelled},
+		models.SubscriptionPaused:    {models.SubscriptionTrialing, models.SubscriptionActive, models.SubscriptionPastDue, models.SubscriptionCancelled},
+		models.SubscriptionPastDue:   {models.SubscriptionActive, models.SubscriptionPaused, models.SubscriptionCancelled},
+		models.SubscriptionCancelled: {},
+	}
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+	for _, from := range models.SubscriptionStatuses {
+		for _, to := range models.SubscriptionStatuses {
+			want := false
+			for _, next := range allowed[from] {
+				want = want || next == to
+			}
+			if got := from.CanTransitionTo(to); got != want {
+				t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", from, to, got, want)
+			}
+
+			subscription := models.Subscription{Status: from}
+			event, err := transition(&subscription, to, "test", 7, now)
+			if !want {
+				if !errors.Is(err, ErrInvalidTransition) {
+					t.Errorf("transition %s -> %s error = %v, want ErrInvalidTransition", from, to, err)
+				}
+				if subscription.Status != from {
+					t.Errorf("refused transition %s -> %s changed the status to %s", from, to, subscription.Status)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("transition %s -> %s: %v", from, to, err)
+				continue
+			}
+			if subscription.Status != to || event.FromStatus != from || event.ToStatus != to || event.ActorID != 7 {
+				t.Errorf("transition %s -> %s = status %s, event %s -> %s by %d", from, to, subscription.Status, event.FromStatus, event.ToStatus, event.ActorID)
+			}
+		}
+	}
+
+	for _, status := range []models.SubscriptionStatus{"", "suspended"} {
+		if status.IsValid() {
+			t.Errorf("%q.IsValid() = true, want false", status)
+		}
+		if status.CanTransitionTo(models.SubscriptionActive) {
+			t.Errorf("%q.CanTransitionTo(active) = true, want false", status)
+		}
+	}
+}
+
+func TestOrderTransitions(t *testing.T) {
+	// allowed lists every permitted move; every other pair must be refused
+	allowed := map[models.OrderStatus][]models.OrderStatus{
+		models.OrderPreparing:      {models.OrderShipped, models.OrderCancelled},
+		models.OrderShipped:        {models.OrderDelivered, models.OrderFailedDelivery, models.OrderReturned},
+		models.OrderFailedDelivery: {models.OrderShipped, models.OrderReturned, models.OrderCancelled},
+		models.OrderDelivered:      {models.OrderReturned},
+		models.OrderReturned:       {},
+		models.OrderCancelled:      {},
+	}
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+	for _, from := range models.OrderStatuses {
+		if final := len(allowed[from]) == 0; from.IsFinal() != final {
+			t.Errorf("%s.IsFinal() = %v, want %v", from, from.IsFinal(), final)
+		}
+		for _, to := range models.OrderStatuses {
+			want := false
+			for _, next := range allowed[from] {
+				want = want || next == to
+			}
+			if got := from.CanTransitionTo(to); got != want {
+				t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", from, to, got, want)
+			}
+
+			order := models.Order{Status: from}
+			err := order.TransitionTo(to, now)
+			if !want {
+				if !errors.Is(err, models.ErrInvalidOrderTransition) {
+					t.Errorf("TransitionTo %s -> %s error = %v, want ErrInvalidOrderTransition", from, to, err)
+				}
+				if order.Status != from || order.ShippedAt != nil || order.DeliveredAt != nil {
+					t.Errorf("refused transition %s -> %s changed the order to %+v", from, to, order)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("TransitionTo %s -> %s: %v", from, to, err)
+				continue
+			}
+			if order.Status != to {
+				t.Errorf("TransitionTo %s -> %s left status %s", from, to, order.Status)
+			}
+			if shipped := order.ShippedAt != nil; shipped != (to == models.OrderShipped) {
+				t.Errorf("TransitionTo %s -> %s set ShippedAt = %v", from, to, shipped)
+			}
+			if delivered := order.DeliveredAt != nil; delivered != (to == models.OrderDelivered) {
+				t.Errorf("TransitionTo %s -> %s set DeliveredAt = %v", from, to, delivered)
+			}
+		}
+	}
+
+	order := models.Order{Status: models.OrderPreparing}
+	if err := order.TransitionTo("lost", now); !errors.Is(err, models.ErrInvalidOrderTransition) {
+		t.Errorf("TransitionTo unknown status error = %v, want ErrInvalidOrderTransition", err)
+	}
+	if models.OrderStatus("Processing").IsValid() {
+		t.Errorf(`"Processing".IsValid() = true, want false`)
+	}
+}
@@ -0,0 +1,57 @@
+package lifecycle
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// NextDelivery returns the first delivery strictly after the given time.
+// Deliveries are anchored on the subscription date: weekly and biweekly ones
+// fall on the same weekday, monthly ones on the same day of the month,
+// clamped to the last day of shorter months (Jan 31, Feb 28, Mar 31, ...).
+func NextDelivery(frequency string, anchor, after time.Time) (time.Time, error) {
+	if after.Before(anchor) {
+		return anchor, nil
+	}
+
+	switch frequency {
+	case models.FrequencyWeekly, models.FrequencyBiweekly:
+		period := 7 * 24 * time.Hour
+		if frequency == models.FrequencyBiweekly {
+			period *= 2
+		}
+		periods := after.Sub(anchor)/period + 1
+		return anchor.Add(periods * period), nil
+
+	case models.FrequencyMonthly:
+		months := (after.Year()-anchor.Year())*12 + int(after.Month()-anchor.Month())
+		next := addMonths(anchor, months)
+		for !next.After(after) {
+			months++
+			next = addMonths(anchor, months)
+		}
+		return next, nil
+
+	default:
+		return time.Time{}, fmt.Errorf("unknown subscription frequency %q", frequency)
+	}
+}
+
+// addMonths adds months to t keeping the day of the month when possible
+func addMonths(t time.Time, months int) time.Time {
+	firstOfMonth := time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	target := firstOfMonth.AddDate(0, months, 0)
+
+	day := t.Day()
+	if last := daysIn(target.Year(), target.Month(), t.Location()); day > last {
+		day = last
+	}
+	return target.AddDate(0, 0, day-1)
+}
+
+// daysIn returns the number of days of a month
+func daysIn(year int, month time.Month, loc *time.Location) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, loc).Day()
+}
@@ -1,42 +1,81 @@
 package middleware
 
 import (
-	"github.com/alexandreffaria/hoby-loop/internal/database"
+	"net/http"
+	"strings"
+
+	"github.com/alexandreffaria/hoby-loop/config"
+	"github.com/alexandreffaria/hoby-loop/internal/auth"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
 	"github.com/alexandreffaria/hoby-loop/models"
 	"github.com/gin-gonic/gin"
-	"strconv"
 )
 
-// AuthMiddleware extracts user information from request
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware validates the bearer access token and loads the user it belongs to
+func AuthMiddleware(users repository.UserRepository, tokens repository.TokenRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// For simplicity, we'll use a header-based authentication
-		userIDHeader := c.GetHeader("X-User-ID")
-		
-		// If no user ID provided, continue (authentication will be checked in protected routes)
-		if userIDHeader == "" {
+		header := c.GetHeader("Authorization")
+
+		// If no token provided, continue (authentication will be checked in protected routes)
+		if header == "" {
 			c.Next()
 			return
 		}
-		
-		// Convert ID to uint
-		userID, err := strconv.ParseUint(userIDHeader, 10, 64)
+
+		token, found := strings.CutPrefix(header, "Bearer ")
+		if !found || token == "" {
+			Unauthorized(c)
+			c.Abort()
+			return
+		}
+
+		cfg := config.GetAuthConfig()
+		claims, err := auth.ParseAccessToken(token, cfg.TokenIssuer, cfg.TokenSecret)
 		if err != nil {
 			Unauthorized(c)
 			c.Abort()
 			return
 		}
-		
+
+		// Reject tokens revoked by logout
+		revoked, err := tokens.IsAccessTokenRevoked(c.Request.Context(), claims.ID)
+		if err != nil || revoked {
+			Unauthorized(c)
+			c.Abort()
+			return
+		}
+
 		// Get user from database
-		var user models.User
-		if err := database.DB.First(&user, userID).Error; err != nil {
+		user, err := users.FindByID(c.Request.Context(), claims.Subject)
+		if err != nil {
 			Unauthorized(c)
 			c.Abort()
 			return
 		}
-		
-		// Set user in context
-		c.Set("user", user)
+
+		// Disabled accounts lose access immediately, even with a valid token
+		if !user.IsActive {
+			Forbidden(c, "Account is disabled")
+			c.Abort()
+			return
+		}
+
+		// Set user and token claims in context
+		c.Set("user", *user)
+		c.Set("claims", claims)
+		c.Next()
+	}
+}
+
+// RequireAuth middleware to protect routes that need an authenticated user
+func RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, exists := c.Get("user"); !exists {
+			Unauthorized(c)
+			c.Abort()
+			return
+		}
+
 		c.Next()
 	}
 }
@@ -58,6 +97,36 @@ func RequireAdmin() gin.HandlerFunc {
 			return
 		}
 		
+		c.Next()
+	}
+}
+
+// RequirePermission middleware to protect admin routes that need specific permissions.
+// The user must be an admin holding every listed permission.
+func RequirePermission(permissions ...auth.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := CurrentUser(c)
+		if !ok {
+			Unauthorized(c)
+			c.Abort()
+			return
+		}
+
+		if !IsAdmin(user) {
+			Forbidden(c, "Admin access required")
+			c.Abort()
+			return
+		}
+
+		granted := auth.ParsePermissions(user.Permissions)
+		for _, permission := range permissions {
+			if !granted.Has(permission) {
+				Error(c, http.StatusForbidden, "Missing permission", string(permission))
+				c.Abort()
+				return
+			}
+		}
+
 		c.Next()
 	}
 }
\ No newline at end of file
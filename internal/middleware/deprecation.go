@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Headers of deprecated routes
+const (
+	DeprecationHeader = "Deprecation" // RFC 9745
+	SunsetHeader      = "Sunset"      // RFC 8594
+	LinkHeader        = "Link"
+)
+
+// Deprecated marks routes replaced by the same route under successor, e.g.
+// /login by /v1/login. Responses carry a Deprecation header with the date the
+// routes were deprecated, a Sunset header with the date they may be removed
+// (when planned) and a Link to the successor. Every call is logged with its
+// caller, so the clients that still need to migrate can be found. Zero dates
+// are left out. This must run after authentication to log the caller.
+func Deprecated(successor string, deprecatedAt, sunset time.Time) gin.HandlerFunc {
+	deprecation := "true"
+	if !deprecatedAt.IsZero() {
+		deprecation = "@" + strconv.FormatInt(deprecatedAt.Unix(), 10)
+	}
+
+	return func(c *gin.Context) {
+		c.Header(DeprecationHeader, deprecation)
+		if !sunset.IsZero() {
+			c.Header(SunsetHeader, sunset.UTC().Format(http.TimeFormat))
+		}
+		c.Header(LinkHeader, fmt.Sprintf(`<%s%s>; rel="successor-version"`, successor, c.Request.URL.Path))
+
+		c.Next()
+
+		caller := "anonymous client"
+		if user, ok := CurrentUser(c); ok {
+			caller = fmt.Sprintf("user %d (%s)", user.ID, user.Role)
+		}
+		log.Printf("📉 Deprecated route %s %s called by %s with %q, answered %d; use %s%s",
+			c.Request.Method, c.FullPath(), caller, c.Request.UserAgent(), c.Writer.Status(), successor, c.FullPath())
+	}
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Idempotency headers
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed" // Set on responses replayed from a stored key
+)
+
+// maxIdempotencyKeyLength bounds the keys clients may send
+const maxIdempotencyKeyLength = 255
+
+// Idempotency makes a POST endpoint safe to retry. The first request with an
+// Idempotency-Key header runs normally and its response is stored for ttl;
+// retries with the same key get the stored response without running the
+// handler again. Reusing a key for a different request is rejected with 422,
+// and a retry arriving while the first request is still running gets 409.
+// Server errors are not stored, so the request can be retried. Requests
+// without the header are not affected. Keys are scoped to the current user,
+// so this must run after authentication.
+func Idempotency(keys repository.IdempotencyRepository, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			BadRequest(c, "Invalid Idempotency-Key", "keys are at most 255 characters")
+			c.Abort()
+			return
+		}
+
+		user, ok := CurrentUser(c)
+		if !ok {
+			Unauthorized(c)
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			BadRequest(c, "Invalid request body", err.Error())
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Storing the outcome must not fail because the client went away
+		ctx := context.WithoutCancel(c.Request.Context())
+		now := time.Now()
+		record := models.IdempotencyKey{
+			UserID:      user.ID,
+			Key:         key,
+			Method:      c.Request.Method,
+			Path:        c.Request.URL.Path,
+			Fingerprint: requestFingerprint(c.Request.Method, c.Request.URL.Path, body),
+			ExpiresAt:   now.Add(ttl),
+		}
+
+		stored, err := keys.Reserve(ctx, &record, now)
+		if errors.Is(err, repository.ErrConflict) {
+			replay(c, stored, record.Fingerprint)
+			c.Abort()
+			return
+		}
+		if err != nil {
+			ServerError(c, "Failed to check Idempotency-Key: "+err.Error())
+			c.Abort()
+			return
+		}
+
+		// Expired keys can no longer be replayed, so drop them
+		keys.PurgeExpired(ctx, now)
+
+		// Release the key if the handler panics or fails, so it can be retried
+		completed := false
+		defer func() {
+			if !completed {
+				if err := keys.Release(ctx, record.ID); err != nil {
+					log.Printf("idempotency: releasing key %d: %v", record.ID, err)
+				}
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		if recorder.Status() >= http.StatusInternalServerError {
+			return
+		}
+		record.StatusCode = recorder.Status()
+		record.ContentType = recorder.Header().Get("Content-Type")
+		record.Response = recorder.body.Bytes()
+		if err := keys.Complete(ctx, &record); err != nil {
+			log.Printf("idempotency: storing response of key %d: %v", record.ID, err)
+			return
+		}
+		completed = true
+	}
+}
+
+// replay answers a retry with the response stored for its key
+func replay(c *gin.Context, stored *models.IdempotencyKey, fingerprint string) {
+	switch {
+	case stored.Fingerprint != fingerprint:
+		Error(c, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request",
+			"send the same method, path and body when retrying, or use a new key")
+	case stored.StatusCode == 0:
+		Conflict(c, "A request with this Idempotency-Key is still being processed", "retry later")
+	default:
+		c.Header(IdempotentReplayedHeader, "true")
+		c.Data(stored.StatusCode, stored.ContentType, stored.Response)
+	}
+}
+
+// requestFingerprint identifies a request by its method, path and body
+func requestFingerprint(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder copies the response body while it is written
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
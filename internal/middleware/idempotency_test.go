@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+	"github.com/gin-gonic/gin"
+)
+
+// idempotencyServer serves POST /orders behind Idempotency for user 1,
+// answering with the number of times handler ran
+type idempotencyServer struct {
+	router *gin.Engine
+	runs   atomic.Int32
+}
+
+func newIdempotencyServer(t *testing.T, ttl time.Duration, handler gin.HandlerFunc) *idempotencyServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	s := &idempotencyServer{router: gin.New()}
+	authenticate := func(c *gin.Context) {
+		user := models.User{Role: "consumer"}
+		user.ID = 1
+		c.Set("user", user)
+	}
+	s.router.POST("/orders", authenticate, Idempotency(repository.NewMemoryRepositories().Idempotency, ttl), func(c *gin.Context) {
+		runs := s.runs.Add(1)
+		if handler != nil {
+			handler(c)
+		}
+		if !c.Writer.Written() {
+			c.JSON(http.StatusCreated, gin.H{"run": runs})
+		}
+	})
+	return s
+}
+
+// post sends body to the server with key as its Idempotency-Key
+func (s *idempotencyServer) post(key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysSameKey(t *testing.T) {
+	s := newIdempotencyServer(t, time.Hour, nil)
+
+	first := s.post("key-1", `{"basket_id":1}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first status = %d, want %d", first.Code, http.StatusCreated)
+	}
+	if first.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Errorf("first response has %s", IdempotentReplayedHeader)
+	}
+
+	retry := s.post("key-1", `{"basket_id":1}`)
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry = %d %s, want %d %s", retry.Code, retry.Body, first.Code, first.Body)
+	}
+	if retry.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("retry %s = %q, want true", IdempotentReplayedHeader, retry.Header().Get(IdempotentReplayedHeader))
+	}
+	if got := s.runs.Load(); got != 1 {
+		t.Errorf("handler ran %d times, want 1", got)
+	}
+
+	other := s.post("key-2", `{"basket_id":1}`)
+	if other.Code != http.StatusCreated || s.runs.Load() != 2 {
+		t.Errorf("new key = %d after %d runs, want %d after 2", other.Code, s.runs.Load(), http.StatusCreated)
+	}
+	s.post("", `{"basket_id":1}`)
+	s.post("", `{"basket_id":1}`)
+	if got := s.runs.Load(); got != 4 {
+		t.Errorf("handler ran %d times after requests without a key, want 4", got)
+	}
+}
+
+func TestIdempotencyRejectsMismatch(t *testing.T) {
+	s := newIdempotencyServer(t, time.Hour, nil)
+	s.post("key-1", `{"basket_id":1}`)
+
+	w := s.post("key-1", `{"basket_id":2}`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("different body status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if got := s.runs.Load(); got != 1 {
+		t.Errorf("handler ran %d times, want 1", got)
+	}
+}
+
+func TestIdempotencyRejectsInFlightKey(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	s := newIdempotencyServer(t, time.Hour, func(c *gin.Context) {
+		close(started)
+		<-release
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- s.post("key-1", `{"basket_id":1}`) }()
+	<-started
+
+	w := s.post("key-1", `{"basket_id":1}`)
+	if w.Code != http.StatusConflict {
+		t.Errorf("in-flight retry status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	close(release)
+	if first := <-done; first.Code != http.StatusCreated {
+		t.Errorf("first status = %d, want %d", first.Code, http.StatusCreated)
+	}
+	if w := s.post("key-1", `{"basket_id":1}`); w.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("retry after completion was not replayed: %d %s", w.Code, w.Body)
+	}
+	if got := s.runs.Load(); got != 1 {
+		t.Errorf("handler ran %d times, want 1", got)
+	}
+}
+
+func TestIdempotencyExpiry(t *testing.T) {
+	ttl := 20 * time.Millisecond
+	s := newIdempotencyServer(t, ttl, nil)
+	s.post("key-1", `{"basket_id":1}`)
+	time.Sleep(2 * ttl)
+
+	w := s.post("key-1", `{"basket_id":2}`)
+	if w.Code != http.StatusCreated || w.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Errorf("expired key = %d replayed %q, want a new %d", w.Code, w.Header().Get(IdempotentReplayedHeader), http.StatusCreated)
+	}
+	if got := s.runs.Load(); got != 2 {
+		t.Errorf("handler ran %d times, want 2", got)
+	}
+}
+
+func TestIdempotencyReleasesServerErrors(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	s := newIdempotencyServer(t, time.Hour, func(c *gin.Context) {
+		if fail.Load() {
+			ServerError(c, "boom")
+		}
+	})
+
+	if w := s.post("key-1", `{"basket_id":1}`); w.Code != http.StatusInternalServerError {
+		t.Fatalf("failing status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	fail.Store(false)
+	w := s.post("key-1", `{"basket_id":1}`)
+	if w.Code != http.StatusCreated || w.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Errorf("retry after server error = %d replayed %q, want a new %d", w.Code, w.Header().Get(IdempotentReplayedHeader), http.StatusCreated)
+	}
+	if got := s.runs.Load(); got != 2 {
+		t.Errorf("handler ran %d times, want 2", got)
+	}
+}
+
+func TestIdempotencyRejectsLongKey(t *testing.T) {
+	s := newIdempotencyServer(t, time.Hour, nil)
+	if w := s.post(strings.Repeat("k", maxIdempotencyKeyLength+1), `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("long key status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if got := s.runs.Load(); got != 0 {
+		t.Errorf("handler ran %d times, want 0", got)
+	}
+}
@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/alexandreffaria/hoby-loop/internal/auth"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+	"github.com/gin-gonic/gin"
+)
+
+// CurrentUser returns the authenticated user set by AuthMiddleware
+func CurrentUser(c *gin.Context) (models.User, bool) {
+	value, exists := c.Get("user")
+	if !exists {
+		return models.User{}, false
+	}
+	user, ok := value.(models.User)
+	return user, ok
+}
+
+// RequireRole allows the request only if the user has one of the given roles
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := CurrentUser(c)
+		if !ok {
+			Unauthorized(c)
+			c.Abort()
+			return
+		}
+
+		for _, role := range roles {
+			if user.Role == role {
+				c.Next()
+				return
+			}
+		}
+
+		Forbidden(c, "Insufficient role")
+		c.Abort()
+	}
+}
+
+// RequireSelf allows the request only if the route parameter matches the user's ID.
+// Admins holding one of the override permissions may act on behalf of any user.
+func RequireSelf(param string, overrides ...auth.Permission) gin.HandlerFunc {
+	return requireOwnership(func(ctx context.Context, user models.User, id uint) (bool, error) {
+		return user.ID == id, nil
+	}, param, "", overrides)
+}
+
+// RequireBasketOwner allows the request only if the user is the seller of the basket
+func RequireBasketOwner(baskets repository.BasketRepository, param string, overrides ...auth.Permission) gin.HandlerFunc {
+	return requireOwnership(func(ctx context.Context, user models.User, id uint) (bool, error) {
+		basket, err := baskets.FindByID(ctx, id)
+		if err != nil {
+			return false, err
+		}
+		return basket.UserID == user.ID, nil
+	}, param, "Basket not found", overrides)
+}
+
+// RequireSubscriptionAccess allows the request only if the user is the subscriber
+// or the seller of the subscribed basket
+func RequireSubscriptionAccess(subscriptions repository.SubscriptionRepository, param string, overrides ...auth.Permission) gin.HandlerFunc {
+	return requireOwnership(func(ctx context.Context, user models.User, id uint) (bool, error) {
+		subscription, err := subscriptions.FindByID(ctx, id)
+		if err != nil {
+			return false, err
+		}
+		return subscription.UserID == user.ID || subscription.Basket.UserID == user.ID, nil
+	}, param, "Subscription not found", overrides)
+}
+
+// RequireSubscriber allows the request only if the user is the subscriber
+func RequireSubscriber(subscriptions repository.SubscriptionRepository, param string, overrides ...auth.Permission) gin.HandlerFunc {
+	return requireOwnership(func(ctx context.Context, user models.User, id uint) (bool, error) {
+		subscription, err := subscriptions.FindByID(ctx, id)
+		if err != nil {
+			return false, err
+		}
+		return subscription.UserID == user.ID, nil
+	}, param, "Subscription not found", overrides)
+}
+
+// RequireOrderAccess allows the request only if the user is the subscriber or
+// the seller behind the order
+func RequireOrderAccess(orders repository.OrderRepository, param string, overrides ...auth.Permission) gin.HandlerFunc {
+	return requireOwnership(func(ctx context.Context, user models.User, id uint) (bool, error) {
+		sellerID, consumerID, err := orderParties(ctx, orders, id)
+		if err != nil {
+			return false, err
+		}
+		return sellerID == user.ID || consumerID == user.ID, nil
+	}, param, "Order not found", overrides)
+}
+
+// RequireOrderSeller allows the request only if the user is the seller fulfilling the order
+func RequireOrderSeller(orders repository.OrderRepository, param string, overrides ...auth.Permission) gin.HandlerFunc {
+	return requireOwnership(func(ctx context.Context, user models.User, id uint) (bool, error) {
+		sellerID, _, err := orderParties(ctx, orders, id)
+		if err != nil {
+			return false, err
+		}
+		return sellerID == user.ID, nil
+	}, param, "Order not found", overrides)
+}
+
+// RequireInvoiceAccess allows the request only if the user is the consumer or
+// the seller the invoice was issued for
+func RequireInvoiceAccess(invoices repository.InvoiceRepository, param string, overrides ...auth.Permission) gin.HandlerFunc {
+	return requireOwnership(func(ctx context.Context, user models.User, id uint) (bool, error) {
+		invoice, err := invoices.FindByID(ctx, id)
+		if err != nil {
+			return false, err
+		}
+		return invoice.ConsumerID == user.ID || invoice.SellerID == user.ID, nil
+	}, param, "Invoice not found", overrides)
+}
+
+// IsAdmin reports whether the user has the admin role
+func IsAdmin(user models.User) bool {
+	return user.Role == "admin"
+}
+
+// HasPermission reports whether the user is an admin holding one of the permissions
+func HasPermission(user models.User, permissions ...auth.Permission) bool {
+	if !IsAdmin(user) {
+		return false
+	}
+	granted := auth.ParsePermissions(user.Permissions)
+	for _, permission := range permissions {
+		if granted.Has(permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// ownershipCheck decides whether the user may access the resource with the given ID
+type ownershipCheck func(ctx context.Context, user models.User, id uint) (bool, error)
+
+// requireOwnership wraps an ownership check with authentication, ID parsing
+// and the admin override shared by every policy. Admins skip the check only
+// when they hold one of the override permissions; otherwise they are treated
+// like any other user.
+func requireOwnership(check ownershipCheck, param string, notFound string, overrides []auth.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := CurrentUser(c)
+		if !ok {
+			Unauthorized(c)
+			c.Abort()
+			return
+		}
+
+		if HasPermission(user, overrides...) {
+			c.Next()
+			return
+		}
+
+		id, err := strconv.ParseUint(c.Param(param), 10, 64)
+		if err != nil {
+			BadRequest(c, "Invalid ID", err.Error())
+			c.Abort()
+			return
+		}
+
+		allowed, err := check(c.Request.Context(), user, uint(id))
+		if err != nil {
+			// Hide the existence of resources the caller cannot see
+			NotFound(c, notFound)
+			c.Abort()
+			return
+		}
+		if !allowed {
+			Forbidden(c, "You do not have access to this resource")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// orderParties returns the seller and consumer IDs behind an order
+func orderParties(ctx context.Context, orders repository.OrderRepository, orderID uint) (sellerID uint, consumerID uint, err error) {
+	order, err := orders.FindByID(ctx, orderID)
+	if err != nil {
+		return 0, 0, err
+	}
+	return order.Subscription.Basket.UserID, order.Subscription.UserID, nil
+}
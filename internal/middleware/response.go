@@ -17,6 +17,16 @@ type ErrorResponse struct {
 type SuccessResponse struct {
 	Status int         `json:"status"`
 	Data   interface{} `json:"data"`
+	Meta   *Meta       `json:"meta,omitempty"` // Only on paginated lists
+}
+
+// Meta describes the page returned by a list endpoint
+type Meta struct {
+	Limit      int    `json:"limit"`
+	Count      int    `json:"count"`                 // Items on this page
+	Total      int64  `json:"total"`                 // Items matching the filters across every page
+	NextCursor string `json:"next_cursor,omitempty"` // Pass as ?cursor= to get the next page
+	HasMore    bool   `json:"has_more"`
 }
 
 // ResponseMiddleware adds response helper methods to gin.Context
@@ -51,6 +61,15 @@ func Success(c *gin.Context, data interface{}) {
 	})
 }
 
+// SuccessPage sends a standardized success response for one page of a list
+func SuccessPage(c *gin.Context, data interface{}, meta Meta) {
+	c.JSON(http.StatusOK, SuccessResponse{
+		Status: http.StatusOK,
+		Data:   data,
+		Meta:   &meta,
+	})
+}
+
 // Error sends a standardized error response
 func Error(c *gin.Context, status int, message string, details string) {
 	c.JSON(status, ErrorResponse{
@@ -75,6 +94,11 @@ func ServerError(c *gin.Context, details string) {
 	Error(c, http.StatusInternalServerError, "Internal server error", details)
 }
 
+// Conflict is a helper for 409 conflict errors
+func Conflict(c *gin.Context, message string, details string) {
+	Error(c, http.StatusConflict, message, details)
+}
+
 // Unauthorized is a helper for 401 unauthorized errors
 func Unauthorized(c *gin.Context) {
 	Error(c, http.StatusUnauthorized, "Unauthorized", "")
@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"strings"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// catalogs holds the translated names used by templates, keyed by locale
+var catalogs = map[string]map[string]string{
+	models.LocalePortuguese: {
+		"order_status.preparing":       "Preparando",
+		"order_status.shipped":         "Enviado",
+		"order_status.delivered":       "Entregue",
+		"order_status.failed_delivery": "Falha na entrega",
+		"order_status.returned":        "Devolvido",
+		"order_status.cancelled":       "Cancelado",
+
+		"subscription_status.trialing":  "Em período de teste",
+		"subscription_status.active":    "Ativa",
+		"subscription_status.paused":    "Pausada",
+		"subscription_status.past_due":  "Pagamento pendente",
+		"subscription_status.cancelled": "Cancelada",
+	},
+	models.LocaleEnglish: {
+		"order_status.preparing":       "Preparing",
+		"order_status.shipped":         "Shipped",
+		"order_status.delivered":       "Delivered",
+		"order_status.failed_delivery": "Delivery failed",
+		"order_status.returned":        "Returned",
+		"order_status.cancelled":       "Cancelled",
+
+		"subscription_status.trialing":  "Trial",
+		"subscription_status.active":    "Active",
+		"subscription_status.paused":    "Paused",
+		"subscription_status.past_due":  "Payment overdue",
+		"subscription_status.cancelled": "Cancelled",
+	},
+}
+
+// dateLayouts formats dates the way each locale writes them
+var dateLayouts = map[string]string{
+	models.LocalePortuguese: "02/01/2006",
+	models.LocaleEnglish:    "Jan 2, 2006",
+}
+
+// NormalizeLocale returns the supported locale matching locale, ignoring case
+// and accepting underscores ("pt_br") and regional English ("en-US")
+func NormalizeLocale(locale string) (string, bool) {
+	locale = strings.ReplaceAll(strings.TrimSpace(locale), "_", "-")
+	for _, supported := range models.Locales {
+		if strings.EqualFold(locale, supported) {
+			return supported, true
+		}
+	}
+	if language, _, ok := strings.Cut(locale, "-"); ok && strings.EqualFold(language, models.LocaleEnglish) {
+		return models.LocaleEnglish, true
+	}
+	return "", false
+}
+
+// translate looks up key in the catalog of locale, returning fallback if missing
+func translate(locale, key, fallback string) string {
+	if name, ok := catalogs[locale][key]; ok {
+		return name
+	}
+	return fallback
+}
+
+// OrderStatusName returns the translated name of an order status
+func OrderStatusName(locale string, status models.OrderStatus) string {
+	return translate(locale, "order_status."+string(status), string(status))
+}
+
+// SubscriptionStatusName returns the translated name of a subscription state
+func SubscriptionStatusName(locale string, status models.SubscriptionStatus) string {
+	return translate(locale, "subscription_status."+string(status), string(status))
+}
+
+// FormatDate renders a date in the convention of locale
+func FormatDate(locale string, t time.Time) string {
+	layout, ok := dateLayouts[locale]
+	if !ok {
+		layout = time.DateOnly
+	}
+	return t.Format(layout)
+}
@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// LogNotifier writes notifications as JSON lines, for development
+type LogNotifier struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewLogNotifier appends to the file at path, or writes to stdout if path is empty
+func NewLogNotifier(path string) (*LogNotifier, error) {
+	if path == "" {
+		return &LogNotifier{out: os.Stdout}, nil
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &LogNotifier{out: file}, nil
+}
+
+// Channel implements Notifier
+func (n *LogNotifier) Channel() string {
+	return models.ChannelLog
+}
+
+// Send implements Notifier
+func (n *LogNotifier) Send(ctx context.Context, to models.User, msg Message) error {
+	line, err := json.Marshal(map[string]interface{}{
+		"time":    time.Now().Format(time.RFC3339),
+		"user_id": to.ID,
+		"to":      to.Email,
+		"event":   msg.Event,
+		"subject": msg.Subject,
+		"text":    msg.Text,
+	})
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	_, err = n.out.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,99 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/config"
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// MessagingProvider sends short text messages. Implement it to plug in a
+// specific WhatsApp or SMS vendor.
+type MessagingProvider interface {
+	SendMessage(ctx context.Context, channel, phone, text string) error
+}
+
+// MessagingNotifier delivers notifications as WhatsApp or SMS messages
+type MessagingNotifier struct {
+	channel  string
+	provider MessagingProvider
+}
+
+// NewMessagingNotifier creates a notifier for channel (whatsapp or sms)
+func NewMessagingNotifier(channel string, provider MessagingProvider) *MessagingNotifier {
+	return &MessagingNotifier{channel: channel, provider: provider}
+}
+
+// Channel implements Notifier
+func (n *MessagingNotifier) Channel() string {
+	return n.channel
+}
+
+// Send implements Notifier. Messages carry the plain text variant only.
+func (n *MessagingNotifier) Send(ctx context.Context, to models.User, msg Message) error {
+	if to.Phone == "" {
+		return ErrNoAddress
+	}
+	return n.provider.SendMessage(ctx, n.channel, to.Phone, msg.Text)
+}
+
+// HTTPMessagingProvider is a generic provider adapter. It posts
+//
+//	{"channel": "whatsapp", "from": "...", "to": "+5511987654321", "text": "..."}
+//
+// with a bearer token to the configured endpoint, which is the shape most
+// messaging gateways accept directly or through a thin relay.
+type HTTPMessagingProvider struct {
+	URL    string
+	Token  string
+	From   string
+	Client *http.Client
+}
+
+// NewHTTPMessagingProvider creates an HTTPMessagingProvider
+func NewHTTPMessagingProvider(cfg config.MessagingConfig, timeout time.Duration) *HTTPMessagingProvider {
+	return &HTTPMessagingProvider{
+		URL:    cfg.ProviderURL,
+		Token:  cfg.Token,
+		From:   cfg.From,
+		Client: &http.Client{Timeout: timeout},
+	}
+}
+
+// SendMessage implements MessagingProvider
+func (p *HTTPMessagingProvider) SendMessage(ctx context.Context, channel, phone, text string) error {
+	body, err := json.Marshal(map[string]string{
+		"channel": channel,
+		"from":    p.From,
+		"to":      phone,
+		"text":    text,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("messaging provider responded %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+// Package notify delivers notifications to users over pluggable channels
+// (email, webhook, WhatsApp, SMS and a development log sink), honouring
+// each user's channel preferences and recording every delivery attempt.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/config"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// ErrNoAddress is returned when the user has no address for a channel,
+// e.g. no phone number for WhatsApp
+var ErrNoAddress = errors.New("user has no address for this channel")
+
+// Message is a notification rendered for one recipient
+type Message struct {
+	Event   string                 `json:"event"` // e.g. order.status_changed
+	Subject string                 `json:"subject"`
+	Text    string                 `json:"text"`
+	HTML    string                 `json:"html,omitempty"`
+	Data    map[string]interface{} `json:"data,omitempty"` // Structured payload for machine consumers
+}
+
+// Notifier sends messages over one channel
+type Notifier interface {
+	Channel() string
+	Send(ctx context.Context, to models.User, msg Message) error
+}
+
+// Service routes messages to the channels each user has enabled
+type Service struct {
+	Users           repository.UserRepository
+	Notifications   repository.NotificationRepository
+	DefaultChannels []string
+	Timeout         time.Duration
+	Templates       *Templates
+
+	notifiers map[string]Notifier
+}
+
+// NewService creates a Service delivering through the given notifiers
+func NewService(users repository.UserRepository, notifications repository.NotificationRepository, defaults []string, notifiers ...Notifier) *Service {
+	s := &Service{
+		Users:           users,
+		Notifications:   notifications,
+		DefaultChannels: defaults,
+		Timeout:         10 * time.Second,
+		Templates:       MustLoadTemplates(),
+		notifiers:       map[string]Notifier{},
+	}
+	for _, n := range notifiers {
+		s.notifiers[n.Channel()] = n
+	}
+	return s
+}
+
+// NewServiceFromConfig creates a Service with every channel that is configured
+func NewServiceFromConfig(cfg config.NotificationsConfig, users repository.UserRepository, notifications repository.NotificationRepository) (*Service, error) {
+	notifiers := []Notifier{}
+
+	if cfg.SMTP.Host != "" {
+		notifiers = append(notifiers, NewSMTPNotifier(cfg.SMTP))
+	}
+	if cfg.Webhook.URL != "" {
+		notifiers = append(notifiers, NewWebhookNotifier(cfg.Webhook.URL, cfg.Timeout))
+	}
+	if cfg.Messaging.ProviderURL != "" {
+		provider := NewHTTPMessagingProvider(cfg.Messaging, cfg.Timeout)
+		notifiers = append(notifiers,
+			NewMessagingNotifier(models.ChannelWhatsApp, provider),
+			NewMessagingNotifier(models.ChannelSMS, provider))
+	}
+
+	logSink, err := NewLogNotifier(cfg.Log.Path)
+	if err != nil {
+		return nil, err
+	}
+	notifiers = append(notifiers, logSink)
+
+	s := NewService(users, notifications, cfg.DefaultChannels, notifiers...)
+	s.Timeout = cfg.Timeout
+	s.Templates.DefaultLocale = cfg.DefaultLocale
+	return s, nil
+}
+
+// Available reports whether a channel is configured
+func (s *Service) Available(channel string) bool {
+	_, ok := s.notifiers[channel]
+	return ok
+}
+
+// Channels returns the channels a user receives notifications on: their
+// stored preferences, or the default channels if they never chose
+func (s *Service) Channels(ctx context.Context, userID uint) ([]string, error) {
+	preferences, err := s.Notifications.ListPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(preferences) == 0 {
+		return s.DefaultChannels, nil
+	}
+
+	channels := []string{}
+	for _, preference := range preferences {
+		if preference.Enabled {
+			channels = append(channels, preference.Channel)
+		}
+	}
+	return channels, nil
+}
+
+// Notify sends msg to the user on every channel they receive notifications on.
+// Each attempt is recorded; the returned error joins the failed channels.
+func (s *Service) Notify(ctx context.Context, userID uint, msg Message) error {
+	channels, err := s.Channels(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, channel := range channels {
+		if err := s.NotifyChannel(ctx, userID, channel, msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NotifyChannel sends msg to the user on one channel and records the result.
+// Unconfigured channels and missing addresses are recorded as skipped and
+// are not errors, since retrying would not help.
+func (s *Service) NotifyChannel(ctx context.Context, userID uint, channel string, msg Message) error {
+	user, err := s.Users.FindByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("loading user %d: %w", userID, err)
+	}
+
+	delivery := models.NotificationDelivery{
+		UserID:  userID,
+		Channel: channel,
+		Event:   msg.Event,
+		Subject: msg.Subject,
+	}
+
+	notifier, ok := s.notifiers[channel]
+	if !ok {
+		delivery.Status = models.DeliverySkipped
+		delivery.Error = "channel not configured"
+		return s.record(ctx, &delivery, nil)
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+	sendErr := notifier.Send(sendCtx, *user, msg)
+
+	switch {
+	case sendErr == nil:
+		delivery.Status = models.DeliverySent
+	case errors.Is(sendErr, ErrNoAddress):
+		delivery.Status = models.DeliverySkipped
+		delivery.Error = sendErr.Error()
+		sendErr = nil
+	default:
+		delivery.Status = models.DeliveryFailed
+		delivery.Error = sendErr.Error()
+		sendErr = fmt.Errorf("%s: %w", channel, sendErr)
+	}
+	return s.record(ctx, &delivery, sendErr)
+}
+
+// record stores a delivery result. Failing to record never hides the send result.
+func (s *Service) record(ctx context.Context, delivery *models.NotificationDelivery, sendErr error) error {
+	if err := s.Notifications.RecordDelivery(ctx, delivery); err != nil {
+		log.Printf("notify: recording %s delivery to user %d: %v", delivery.Channel, delivery.UserID, err)
+	}
+	return sendErr
+}
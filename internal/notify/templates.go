@@ -0,0 +1,241 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// Notification events with templates
+const (
+	EventOrderStatusChanged = "order.status_changed"
+	EventPaymentFailed      = "payment.failed"
+)
+
+// ErrNoTemplate is returned when no template exists for an event
+var ErrNoTemplate = errors.New("no template for event")
+
+// templateFS holds the message templates as templates/<locale>/<event>.txt.tmpl,
+// which defines "subject" and "text", and the optional <event>.html.tmpl
+//
+//go:embed templates
+var templateFS embed.FS
+
+// OrderStatusData is the data of EventOrderStatusChanged templates
+type OrderStatusData struct {
+	Name         string             `json:"name"`
+	Basket       string             `json:"basket"`
+	OrderID      uint               `json:"order_id"`
+	Status       models.OrderStatus `json:"status"`
+	TrackingCode string             `json:"tracking_code,omitempty"`
+	ScheduledFor *time.Time         `json:"scheduled_for,omitempty"`
+}
+
+// PaymentFailedData is the data of EventPaymentFailed templates, sent at each
+// failed charge of a subscription in dunning
+type PaymentFailedData struct {
+	Name          string                    `json:"name"`
+	Basket        string                    `json:"basket"`
+	OrderID       uint                      `json:"order_id"`
+	Amount        models.Money              `json:"amount"`
+	PaymentMethod string                    `json:"payment_method"`
+	Attempt       int                       `json:"attempt"`       // Failed attempts so far
+	MaxAttempts   int                       `json:"max_attempts"`  // Attempts before the subscription is paused or cancelled
+	NextRetryAt   *time.Time                `json:"next_retry_at"` // Nil after the last attempt
+	Status        models.SubscriptionStatus `json:"status"`        // past_due, or paused or cancelled after the last attempt
+}
+
+// Templates renders notification messages by event and locale
+type Templates struct {
+	// DefaultLocale is used for users without a supported locale and for
+	// events not translated to the user's locale
+	DefaultLocale string
+
+	text map[string]*texttemplate.Template // By locale/event
+	html map[string]*htmltemplate.Template
+}
+
+// LoadTemplates parses the embedded templates
+func LoadTemplates() (*Templates, error) {
+	t := &Templates{
+		DefaultLocale: models.LocalePortuguese,
+		text:          map[string]*texttemplate.Template{},
+		html:          map[string]*htmltemplate.Template{},
+	}
+
+	err := fs.WalkDir(templateFS, "templates", func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		locale := path.Base(path.Dir(file))
+		if _, ok := catalogs[locale]; !ok {
+			return fmt.Errorf("template %s: unsupported locale %q", file, locale)
+		}
+		content, err := fs.ReadFile(templateFS, file)
+		if err != nil {
+			return err
+		}
+
+		name := path.Base(file)
+		switch {
+		case strings.HasSuffix(name, ".txt.tmpl"):
+			event := strings.TrimSuffix(name, ".txt.tmpl")
+			parsed, err := texttemplate.New(name).Funcs(texttemplate.FuncMap(templateFuncs(locale))).Parse(string(content))
+			if err != nil {
+				return err
+			}
+			t.text[locale+"/"+event] = parsed
+		case strings.HasSuffix(name, ".html.tmpl"):
+			event := strings.TrimSuffix(name, ".html.tmpl")
+			parsed, err := htmltemplate.New(name).Funcs(htmltemplate.FuncMap(templateFuncs(locale))).Parse(string(content))
+			if err != nil {
+				return err
+			}
+			t.html[locale+"/"+event] = parsed
+		default:
+			return fmt.Errorf("template %s: expected a .txt.tmpl or .html.tmpl file", file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// MustLoadTemplates is LoadTemplates for the embedded templates, which are
+// checked when the binary is built and cannot fail at runtime
+func MustLoadTemplates() *Templates {
+	t, err := LoadTemplates()
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Events lists every event with a template
+func (t *Templates) Events() []string {
+	seen := map[string]bool{}
+	events := []string{}
+	for key := range t.text {
+		_, event, _ := strings.Cut(key, "/")
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+	sort.Strings(events)
+	return events
+}
+
+// Locale returns the supported locale used for a user's locale preference
+func (t *Templates) Locale(preference string) string {
+	if locale, ok := NormalizeLocale(preference); ok {
+		return locale
+	}
+	return t.DefaultLocale
+}
+
+// Render builds the message of event in locale. Events not translated to
+// locale are rendered in the default locale.
+func (t *Templates) Render(event, locale string, data interface{}) (Message, error) {
+	locale = t.Locale(locale)
+	text, ok := t.text[locale+"/"+event]
+	if !ok {
+		locale = t.DefaultLocale
+		if text, ok = t.text[locale+"/"+event]; !ok {
+			return Message{}, fmt.Errorf("%w %q", ErrNoTemplate, event)
+		}
+	}
+
+	msg := Message{Event: event}
+	var buf bytes.Buffer
+	for _, part := range []struct {
+		name string
+		dst  *string
+	}{{"subject", &msg.Subject}, {"text", &msg.Text}} {
+		buf.Reset()
+		if err := text.ExecuteTemplate(&buf, part.name, data); err != nil {
+			return Message{}, fmt.Errorf("rendering %s %s of %q: %w", locale, part.name, event, err)
+		}
+		*part.dst = strings.TrimSpace(buf.String())
+	}
+
+	if html, ok := t.html[locale+"/"+event]; ok {
+		buf.Reset()
+		if err := html.Execute(&buf, data); err != nil {
+			return Message{}, fmt.Errorf("rendering %s html of %q: %w", locale, event, err)
+		}
+		msg.HTML = strings.TrimSpace(buf.String())
+	}
+	return msg, nil
+}
+
+// SampleData returns example data for previewing the templates of event
+func SampleData(event string) (interface{}, bool) {
+	switch event {
+	case EventOrderStatusChanged:
+		scheduled := time.Date(2026, time.March, 14, 9, 0, 0, 0, time.UTC)
+		return OrderStatusData{
+			Name:         "Maria Silva",
+			Basket:       "Cesta Orgânica da Semana",
+			OrderID:      42,
+			Status:       models.OrderShipped,
+			TrackingCode: "BR123456789BR",
+			ScheduledFor: &scheduled,
+		}, true
+	case EventPaymentFailed:
+		retry := time.Date(2026, time.March, 17, 9, 0, 0, 0, time.UTC)
+		return PaymentFailedData{
+			Name:          "Maria Silva",
+			Basket:        "Cesta Orgânica da Semana",
+			OrderID:       42,
+			Amount:        models.NewMoney(11000, "BRL"),
+			PaymentMethod: models.PaymentMethodCard,
+			Attempt:       1,
+			MaxAttempts:   4,
+			NextRetryAt:   &retry,
+			Status:        models.SubscriptionPastDue,
+		}, true
+	default:
+		return nil, false
+	}
+}
+
+// templateFuncs are the helpers available to the templates of locale
+func templateFuncs(locale string) map[string]interface{} {
+	return map[string]interface{}{
+		"orderStatus": func(status models.OrderStatus) string {
+			return OrderStatusName(locale, status)
+		},
+		"subscriptionStatus": func(status models.SubscriptionStatus) string {
+			return SubscriptionStatusName(locale, status)
+		},
+		"money": func(m models.Money) string {
+			return m.Format()
+		},
+		"date": func(value interface{}) string {
+			switch t := value.(type) {
+			case time.Time:
+				return FormatDate(locale, t)
+			case *time.Time:
+				if t == nil {
+					return ""
+				}
+				return FormatDate(locale, *t)
+			default:
+				return fmt.Sprint(value)
+			}
+		},
+	}
+}
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// WebhookNotifier posts notifications as JSON to a fixed URL, e.g. a chat
+// integration or an internal service
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// webhookPayload is the body posted by WebhookNotifier
+type webhookPayload struct {
+	Message
+	UserID uint   `json:"user_id"`
+	Email  string `json:"email"`
+	SentAt string `json:"sent_at"`
+}
+
+// NewWebhookNotifier creates a WebhookNotifier
+func NewWebhookNotifier(url string, timeout time.Duration) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, Client: &http.Client{Timeout: timeout}}
+}
+
+// Channel implements Notifier
+func (n *WebhookNotifier) Channel() string {
+	return models.ChannelWebhook
+}
+
+// Send implements Notifier
+func (n *WebhookNotifier) Send(ctx context.Context, to models.User, msg Message) error {
+	body, err := json.Marshal(webhookPayload{
+		Message: msg,
+		UserID:  to.ID,
+		Email:   to.Email,
+		SentAt:  time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
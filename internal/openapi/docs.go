@@ -0,0 +1,26 @@
+package openapi
+
+import (
+	_ "embed"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// docsPage renders an OpenAPI document in the browser without loading
+// anything from outside the API
+//
+//go:embed docs.html
+var docsPage string
+
+var docsTemplate = template.Must(template.New("docs").Parse(docsPage))
+
+// DocsHandler serves the docs UI for the document at specURL
+func DocsHandler(title, specURL string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Status(http.StatusOK)
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		docsTemplate.Execute(c.Writer, map[string]string{"Title": title, "SpecURL": specURL})
+	}
+}
@@ -0,0 +1,425 @@
+// Package openapi generates the OpenAPI 3.1 document of the API from the
+// routes registered on gin and the Go types of their requests and responses.
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/alexandreffaria/hoby-loop/internal/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// Version is the OpenAPI version of generated documents
+const Version = "3.1.0"
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Servers    []Server              `json:"servers,omitempty"`
+	Tags       []Tag                 `json:"tags,omitempty"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []map[string][]string `json:"security,omitempty"`
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Server is a base URL the paths of the document are relative to
+type Server struct {
+	URL string `json:"url"`
+}
+
+// Tag groups operations in the docs UI
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of a path by lowercase HTTP method
+type PathItem map[string]*Operation
+
+// Components holds the schemas referenced by operations
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme describes how requests authenticate
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// Operation is one method of a path
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security"` // Empty for public operations
+}
+
+// Parameter is a path, query or header parameter of an operation
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is the body an operation accepts
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is one possible response of an operation
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a body in one content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// bearerAuth is the name of the JWT security scheme
+const bearerAuth = "bearerAuth"
+
+// Route documents one route registered on gin. Path and request/response
+// schemas are derived from the route itself and the Go types it uses; the
+// rest is written by hand next to the route table.
+type Route struct {
+	Method       string
+	Path         string // As registered on gin, e.g. /orders/:id
+	Summary      string
+	Description  string
+	Tag          string
+	Public       bool        // Callable without an access token
+	Query        []Param     // Filters, not counting the page parameters
+	Paginated    bool        // Accepts limit, cursor and sort, and returns meta
+	Idempotent   bool        // Accepts an Idempotency-Key header
+	Request      interface{} // Zero value of the JSON body, nil without one
+	OptionalBody bool        // The request body may be left out
+	Response     interface{} // Zero value of the data returned in the success envelope
+	ContentType  string      // Set for responses sent as is instead of in the envelope
+	Errors       []int       // Error statuses besides the ones implied by the other fields
+}
+
+// Param is a query parameter of a route
+type Param struct {
+	Name        string
+	Description string
+	Schema      *Schema
+}
+
+// StringParam is a free text query parameter
+func StringParam(name, description string) Param {
+	return Param{Name: name, Description: description, Schema: &Schema{Type: "string"}}
+}
+
+// IDParam is a numeric ID query parameter
+func IDParam(name, description string) Param {
+	return Param{Name: name, Description: description, Schema: &Schema{Type: "integer", Minimum: float(1)}}
+}
+
+// BoolParam is a true/false query parameter
+func BoolParam(name, description string) Param {
+	return Param{Name: name, Description: description, Schema: &Schema{Type: "boolean"}}
+}
+
+// DateParam is a query parameter taking a date (2006-01-02) or an RFC 3339 time
+func DateParam(name, description string) Param {
+	return Param{Name: name, Description: description + ", as a date (2006-01-02) or an RFC 3339 time", Schema: &Schema{Type: "string"}}
+}
+
+// EnumParam is a query parameter that must be one of values
+func EnumParam[S ~string](name, description string, values []S) Param {
+	return Param{Name: name, Description: description, Schema: &Schema{Type: "string", Enum: enumValues(values)}}
+}
+
+// pageParams are accepted by every paginated route
+var pageParams = []Parameter{
+	{Name: "limit", In: "query", Description: "Page size, 50 by default and at most 200", Schema: &Schema{Type: "integer", Minimum: float(1)}},
+	{Name: "cursor", In: "query", Description: "next_cursor of the previous page, only valid with the same sort", Schema: &Schema{Type: "string"}},
+	{Name: "sort", In: "query", Description: "Comma separated fields, prefixed with - for descending order, e.g. -created_at,name", Schema: &Schema{Type: "string"}},
+}
+
+// Spec is the hand written part of the document: the API description and
+// the documentation of every route. Route paths are relative to Prefix.
+type Spec struct {
+	Prefix string // Where the routes are mounted, e.g. /v1; routes outside it are not part of the document
+	Info   Info
+	Tags   []Tag
+	Routes []Route
+}
+
+// Build generates the document of the registered routes. Registered routes
+// without documentation are still listed, with their path parameters only;
+// Drift reports them.
+func (s Spec) Build(registered gin.RoutesInfo) *Document {
+	registered = s.mounted(registered)
+	g := newGenerator()
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    s.Info,
+		Tags:    s.Tags,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			SecuritySchemes: map[string]SecurityScheme{
+				bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+		Security: []map[string][]string{{bearerAuth: {}}},
+	}
+	if s.Prefix != "" {
+		doc.Servers = []Server{{URL: s.Prefix}}
+	}
+
+	documented := s.index()
+	ids := map[string]bool{}
+	for _, info := range registered {
+		route, ok := documented[routeKey(info.Method, info.Path)]
+		if !ok {
+			route = Route{Method: info.Method, Path: info.Path, Summary: "Undocumented route"}
+		}
+
+		op := g.operation(route)
+		op.OperationID = operationID(route, info.Handler)
+		for n := 2; ids[op.OperationID]; n++ {
+			op.OperationID = strings.TrimRight(op.OperationID, "0123456789") + strconv.Itoa(n)
+		}
+		ids[op.OperationID] = true
+
+		path := specPath(info.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = PathItem{}
+		}
+		doc.Paths[path][strings.ToLower(info.Method)] = op
+	}
+
+	doc.Components.Schemas = g.schemas
+	return doc
+}
+
+// Drift compares the registered routes with their documentation and
+// describes every difference, in the order of the registered routes
+func (s Spec) Drift(registered gin.RoutesInfo) []string {
+	registered = s.mounted(registered)
+	var drift []string
+	seen := map[string]bool{}
+	for _, route := range s.Routes {
+		key := routeKey(route.Method, route.Path)
+		if seen[key] {
+			drift = append(drift, key+" is documented twice")
+		}
+		seen[key] = true
+	}
+
+	documented := s.index()
+	for _, info := range registered {
+		key := routeKey(info.Method, info.Path)
+		if _, ok := documented[key]; !ok {
+			drift = append(drift, key+" is registered but not documented")
+		}
+		delete(documented, key)
+	}
+	for _, route := range s.Routes {
+		key := routeKey(route.Method, route.Path)
+		if _, ok := documented[key]; ok {
+			drift = append(drift, key+" is documented but not registered")
+		}
+	}
+	return drift
+}
+
+// mounted returns the registered routes under the prefix, with paths
+// relative to it
+func (s Spec) mounted(registered gin.RoutesInfo) gin.RoutesInfo {
+	if s.Prefix == "" {
+		return registered
+	}
+	var routes gin.RoutesInfo
+	for _, info := range registered {
+		if path, ok := strings.CutPrefix(info.Path, s.Prefix); ok && strings.HasPrefix(path, "/") {
+			info.Path = path
+			routes = append(routes, info)
+		}
+	}
+	return routes
+}
+
+// index returns the documented routes by method and path
+func (s Spec) index() map[string]Route {
+	routes := make(map[string]Route, len(s.Routes))
+	for _, route := range s.Routes {
+		routes[routeKey(route.Method, route.Path)] = route
+	}
+	return routes
+}
+
+func routeKey(method, path string) string {
+	return method + " " + path
+}
+
+// operation builds the operation of a documented route
+func (g *generator) operation(route Route) *Operation {
+	op := &Operation{
+		Summary:     route.Summary,
+		Description: route.Description,
+		Responses:   map[string]Response{},
+		Security:    []map[string][]string{},
+	}
+	if route.Tag != "" {
+		op.Tags = []string{route.Tag}
+	}
+	if !route.Public {
+		op.Security = append(op.Security, map[string][]string{bearerAuth: {}})
+	}
+
+	pathParams := pathParams(route.Path)
+	op.Parameters = append(op.Parameters, pathParams...)
+	for _, param := range route.Query {
+		op.Parameters = append(op.Parameters, Parameter{Name: param.Name, In: "query", Description: param.Description, Schema: param.Schema})
+	}
+	if route.Paginated {
+		op.Parameters = append(op.Parameters, pageParams...)
+	}
+	if route.Idempotent {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:        middleware.IdempotencyKeyHeader,
+			In:          "header",
+			Description: "Retries with the same key replay the first response instead of repeating the request",
+			Schema:      &Schema{Type: "string", MaxLength: intPtr(255)},
+		})
+	}
+
+	if route.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: !route.OptionalBody,
+			Content:  map[string]MediaType{"application/json": {Schema: g.schema(reflect.TypeOf(route.Request))}},
+		}
+	}
+
+	if route.ContentType != "" {
+		op.Responses["200"] = Response{
+			Description: http.StatusText(http.StatusOK),
+			Content:     map[string]MediaType{route.ContentType: {Schema: g.schema(reflect.TypeOf(route.Response))}},
+		}
+		return op
+	}
+	op.Responses["200"] = Response{
+		Description: http.StatusText(http.StatusOK),
+		Content:     map[string]MediaType{"application/json": {Schema: g.envelope(route)}},
+	}
+
+	statuses := []int{http.StatusInternalServerError}
+	if route.Request != nil || len(route.Query) > 0 || route.Paginated || len(pathParams) > 0 {
+		statuses = append(statuses, http.StatusBadRequest)
+	}
+	if !route.Public {
+		statuses = append(statuses, http.StatusUnauthorized, http.StatusForbidden)
+	}
+	if len(pathParams) > 0 {
+		statuses = append(statuses, http.StatusNotFound)
+	}
+	if route.Idempotent {
+		statuses = append(statuses, http.StatusConflict, http.StatusUnprocessableEntity)
+	}
+	for _, status := range route.Errors {
+		if !slices.Contains(statuses, status) {
+			statuses = append(statuses, status)
+		}
+	}
+	errorSchema := g.schema(reflect.TypeOf(middleware.ErrorResponse{}))
+	for _, status := range statuses {
+		op.Responses[strconv.Itoa(status)] = Response{
+			Description: http.StatusText(status),
+			Content:     map[string]MediaType{"application/json": {Schema: errorSchema}},
+		}
+	}
+	return op
+}
+
+// envelope returns the schema of the success response of a route, which
+// wraps the data as middleware.Success and middleware.SuccessPage do
+func (g *generator) envelope(route Route) *Schema {
+	schema := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"status": {Type: "integer"},
+			"data":   g.schema(reflect.TypeOf(route.Response)),
+		},
+		Required: []string{"status", "data"},
+	}
+	if route.Paginated {
+		schema.Properties["meta"] = g.schema(reflect.TypeOf(middleware.Meta{}))
+		schema.Required = append(schema.Required, "meta")
+	}
+	return schema
+}
+
+// pathParams returns the parameters of a gin path. IDs are numbers.
+func pathParams(path string) []Parameter {
+	var params []Parameter
+	for _, segment := range strings.Split(path, "/") {
+		if !strings.HasPrefix(segment, ":") && !strings.HasPrefix(segment, "*") {
+			continue
+		}
+		name := segment[1:]
+		schema := &Schema{Type: "string"}
+		if name == "id" || strings.HasSuffix(name, "_id") {
+			schema = &Schema{Type: "integer", Minimum: float(1)}
+		}
+		params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	return params
+}
+
+// specPath converts a gin path to an OpenAPI path: /orders/:id is /orders/{id}
+func specPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// operationID names an operation after its handler method, e.g. getOrder for
+// (*OrderController).GetOrder, or after its method and path for closures
+func operationID(route Route, handler string) string {
+	name := strings.TrimSuffix(handler[strings.LastIndex(handler, ".")+1:], "-fm")
+	if name == "" || strings.HasPrefix(name, "func") {
+		name = strings.ToLower(route.Method)
+		for _, segment := range strings.Split(route.Path, "/") {
+			segment = strings.Trim(segment, ":*{}")
+			for _, word := range strings.FieldsFunc(segment, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+				name += strings.ToUpper(word[:1]) + word[1:]
+			}
+		}
+		return name
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+func intPtr(n int) *int {
+	return &n
+}
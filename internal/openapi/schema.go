@@ -0,0 +1,265 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/internal/auth"
+	"github.com/alexandreffaria/hoby-loop/models"
+	"gorm.io/gorm"
+)
+
+// Schema is a JSON Schema as used by OpenAPI 3.1
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 interface{}        `json:"type,omitempty"` // A type name, or a list of them for nullable values
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ReadOnly             bool               `json:"readOnly,omitempty"`
+}
+
+// Ref returns a reference to a schema in the components of the document
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// Types whose JSON form differs from their Go fields
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+	rawJSONType   = reflect.TypeOf(models.RawJSON{})
+	rawMessage    = reflect.TypeOf(json.RawMessage{})
+)
+
+// customSchemas are components for types with custom JSON encoding
+var customSchemas = map[reflect.Type]*Schema{
+	reflect.TypeOf(models.Money{}): {
+		Description: "An amount in minor units (centavos). Responses always use the object form; " +
+			"requests also accept the amount as an integer (12990) or a decimal string in major units (\"129.90\").",
+		OneOf: []*Schema{
+			{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"amount":    {Type: "integer", Format: "int64"},
+					"currency":  {Type: "string", Description: "ISO 4217 code, BRL when omitted"},
+					"formatted": {Type: "string", ReadOnly: true, Description: "Display string, e.g. R$ 129,90"},
+				},
+				Required: []string{"amount"},
+			},
+			{Type: "integer", Format: "int64"},
+			{Type: "string"},
+		},
+	},
+}
+
+// enums lists the values of the string types with a fixed set of values
+var enums = map[reflect.Type][]interface{}{
+	reflect.TypeOf(models.SubscriptionStatus("")): enumValues(models.SubscriptionStatuses),
+	reflect.TypeOf(models.OrderStatus("")):        enumValues(models.OrderStatuses),
+	reflect.TypeOf(models.JobStatus("")):          enumValues(models.JobStatuses),
+	reflect.TypeOf(models.PaymentStatus("")):      enumValues(models.PaymentStatuses),
+	reflect.TypeOf(models.BoletoStatus("")):       enumValues(models.BoletoStatuses),
+	reflect.TypeOf(models.InvoiceStatus("")):      enumValues(models.InvoiceStatuses),
+	reflect.TypeOf(models.InvoiceItemKind("")):    enumValues(models.InvoiceItemKinds),
+	reflect.TypeOf(auth.Permission("")):           enumValues(auth.AllPermissions),
+}
+
+// enumValues converts the values of a string type for Schema.Enum
+func enumValues[S ~string](values []S) []interface{} {
+	enum := make([]interface{}, len(values))
+	for i, value := range values {
+		enum[i] = string(value)
+	}
+	return enum
+}
+
+// generator builds schemas from Go types. Named structs, enums and custom
+// types become components that are referenced by name.
+type generator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+func newGenerator() *generator {
+	return &generator{schemas: map[string]*Schema{}, names: map[reflect.Type]string{}}
+}
+
+// schema returns the schema of values of type t
+func (g *generator) schema(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case deletedAtType:
+		return &Schema{Type: []string{"string", "null"}, Format: "date-time"}
+	case rawJSONType, rawMessage:
+		return &Schema{}
+	}
+	if custom, ok := customSchemas[t]; ok {
+		return g.component(t, func() *Schema { return custom })
+	}
+	if enum, ok := enums[t]; ok {
+		return g.component(t, func() *Schema { return &Schema{Type: "string", Enum: enum} })
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: float(0)}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return g.component(t, func() *Schema { return g.object(t) })
+	}
+	return &Schema{}
+}
+
+// component registers the schema of a named type once and returns a
+// reference to it. Types with the same name in different packages are
+// prefixed with their package name.
+func (g *generator) component(t reflect.Type, build func() *Schema) *Schema {
+	if name, ok := g.names[t]; ok {
+		return Ref(name)
+	}
+
+	name := t.Name()
+	if _, taken := g.schemas[name]; taken {
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+
+	// Register before building so recursive types end in a reference
+	schema := &Schema{}
+	g.names[t] = name
+	g.schemas[name] = schema
+	*schema = *build()
+	return Ref(name)
+}
+
+// object returns the schema of a struct from its exported fields and their
+// json and binding tags. Embedded structs, like gorm.Model, are flattened.
+func (g *generator) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.fields(schema, t)
+	return schema
+}
+
+func (g *generator) fields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.fields(schema, embedded)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := g.schema(field.Type)
+		if applyBinding(property, field.Tag.Get("binding")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+}
+
+// applyBinding adds the validator rules of a binding tag to a schema and
+// reports whether the field is required. Rules on referenced components
+// are not added, since they would change the shared schema.
+func applyBinding(schema *Schema, binding string) (required bool) {
+	for _, rule := range strings.Split(binding, ",") {
+		rule, arg, _ := strings.Cut(rule, "=")
+		if rule == "required" {
+			required = true
+		}
+		if schema.Ref != "" {
+			continue
+		}
+
+		switch rule {
+		case "email":
+			schema.Format = "email"
+		case "url":
+			schema.Format = "uri"
+		case "oneof":
+			for _, value := range strings.Fields(arg) {
+				schema.Enum = append(schema.Enum, value)
+			}
+		case "min", "max":
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				continue
+			}
+			switch {
+			case schema.Type == "string" && rule == "min":
+				schema.MinLength = &n
+			case schema.Type == "string":
+				schema.MaxLength = &n
+			case schema.Type == "array" && rule == "min":
+				schema.MinItems = &n
+			case schema.Type == "array":
+				schema.MaxItems = &n
+			case rule == "min":
+				schema.Minimum = float(float64(n))
+			default:
+				schema.Maximum = float(float64(n))
+			}
+		}
+	}
+	return required
+}
+
+func float(n float64) *float64 {
+	return &n
+}
@@ -0,0 +1,190 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/internal/notify"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// KindOrderNotification tells a subscriber about the status of their order
+const KindOrderNotification = "notify.order_status"
+
+// OrderNotification is the payload of KindOrderNotification jobs
+type OrderNotification struct {
+	OrderID        uint               `json:"order_id,omitempty"`
+	SubscriptionID uint               `json:"subscription_id"`
+	Status         models.OrderStatus `json:"status"`
+	TrackingCode   string             `json:"tracking_code,omitempty"`
+	ScheduledFor   *time.Time         `json:"scheduled_for,omitempty"`
+	// Channels still to be sent to. Empty until the first attempt resolves
+	// the subscriber's channels, so retries do not repeat successful sends.
+	Channels []string `json:"channels,omitempty"`
+}
+
+// NewOrderNotification creates the job notifying the subscriber of order
+func NewOrderNotification(order models.Order) (models.OutboxJob, error) {
+	return NewJob(KindOrderNotification, OrderNotification{
+		OrderID:        order.ID,
+		SubscriptionID: order.SubscriptionID,
+		Status:         order.Status,
+		TrackingCode:   order.TrackingCode,
+		ScheduledFor:   order.ScheduledFor,
+	})
+}
+
+// OrderNotificationJobs builds the notification job of a new order, for use
+// as a repository.JobBuilder
+func OrderNotificationJobs(order models.Order) ([]models.OutboxJob, error) {
+	job, err := NewOrderNotification(order)
+	if err != nil {
+		return nil, err
+	}
+	return []models.OutboxJob{job}, nil
+}
+
+// OrderNotificationHandler sends KindOrderNotification jobs through notifier
+func OrderNotificationHandler(subscriptions repository.SubscriptionRepository, notifier *notify.Service) Handler {
+	return func(ctx context.Context, job *models.OutboxJob) error {
+		var payload OrderNotification
+		if err := Decode(job, &payload); err != nil {
+			return err
+		}
+
+		return deliver(ctx, job, subscriptions, notifier, payload.SubscriptionID, &payload.Channels, &payload, func(sub *models.Subscription) (notify.Message, error) {
+			data := notify.OrderStatusData{
+				Name:         sub.User.Name,
+				Basket:       sub.Basket.Name,
+				OrderID:      payload.OrderID,
+				Status:       payload.Status,
+				TrackingCode: payload.TrackingCode,
+				ScheduledFor: payload.ScheduledFor,
+			}
+			msg, err := notifier.Templates.Render(notify.EventOrderStatusChanged, sub.User.Locale, data)
+			if err != nil {
+				return msg, err
+			}
+			msg.Data = map[string]interface{}{
+				"order_id":        payload.OrderID,
+				"subscription_id": sub.ID,
+				"basket":          sub.Basket.Name,
+				"status":          payload.Status,
+				"tracking_code":   payload.TrackingCode,
+			}
+			return msg, nil
+		})
+	}
+}
+
+// KindPaymentReminder tells a subscriber that the charge of their order
+// failed, at each attempt of the dunning schedule
+const KindPaymentReminder = "notify.payment_failed"
+
+// PaymentReminder is the payload of KindPaymentReminder jobs
+type PaymentReminder struct {
+	SubscriptionID uint                      `json:"subscription_id"`
+	OrderID        uint                      `json:"order_id"`
+	Amount         models.Money              `json:"amount"`
+	PaymentMethod  string                    `json:"payment_method"`
+	Attempt        int                       `json:"attempt"`
+	MaxAttempts    int                       `json:"max_attempts"`
+	NextRetryAt    *time.Time                `json:"next_retry_at,omitempty"`
+	Status         models.SubscriptionStatus `json:"status"`
+	// Channels still to be sent to, as in OrderNotification
+	Channels []string `json:"channels,omitempty"`
+}
+
+// PaymentReminderHandler sends KindPaymentReminder jobs through notifier
+func PaymentReminderHandler(subscriptions repository.SubscriptionRepository, notifier *notify.Service) Handler {
+	return func(ctx context.Context, job *models.OutboxJob) error {
+		var payload PaymentReminder
+		if err := Decode(job, &payload); err != nil {
+			return err
+		}
+
+		return deliver(ctx, job, subscriptions, notifier, payload.SubscriptionID, &payload.Channels, &payload, func(sub *models.Subscription) (notify.Message, error) {
+			data := notify.PaymentFailedData{
+				Name:          sub.User.Name,
+				Basket:        sub.Basket.Name,
+				OrderID:       payload.OrderID,
+				Amount:        payload.Amount,
+				PaymentMethod: payload.PaymentMethod,
+				Attempt:       payload.Attempt,
+				MaxAttempts:   payload.MaxAttempts,
+				NextRetryAt:   payload.NextRetryAt,
+				Status:        payload.Status,
+			}
+			msg, err := notifier.Templates.Render(notify.EventPaymentFailed, sub.User.Locale, data)
+			if err != nil {
+				return msg, err
+			}
+			msg.Data = map[string]interface{}{
+				"order_id":        payload.OrderID,
+				"subscription_id": sub.ID,
+				"basket":          sub.Basket.Name,
+				"attempt":         payload.Attempt,
+				"max_attempts":    payload.MaxAttempts,
+				"next_retry_at":   payload.NextRetryAt,
+				"status":          payload.Status,
+			}
+			return msg, nil
+		})
+	}
+}
+
+// deliver sends the message render builds for the subscription to its
+// subscriber. The subscriber's channels are resolved into *channels on the
+// first attempt; when some of them fail, payload is written back into the job
+// with only those left, so retries do not repeat successful sends.
+func deliver(ctx context.Context, job *models.OutboxJob, subscriptions repository.SubscriptionRepository, notifier *notify.Service,
+	subscriptionID uint, channels *[]string, payload interface{}, render func(*models.Subscription) (notify.Message, error)) error {
+	sub, err := subscriptions.FindByID(ctx, subscriptionID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return Permanent(fmt.Errorf("subscription %d not found", subscriptionID))
+		}
+		return err
+	}
+
+	if *channels == nil {
+		if *channels, err = notifier.Channels(ctx, sub.UserID); err != nil {
+			return err
+		}
+	}
+
+	msg, err := render(sub)
+	if err != nil {
+		return Permanent(err)
+	}
+
+	failed, err := send(ctx, notifier, sub.UserID, *channels, msg)
+	if len(failed) == 0 {
+		return nil
+	}
+
+	*channels = failed
+	next, nextErr := NewJob(job.Kind, payload)
+	if nextErr != nil {
+		return nextErr
+	}
+	job.Payload = next.Payload
+	return err
+}
+
+// send delivers msg to userID on every channel and returns the channels that
+// failed along with their errors
+func send(ctx context.Context, notifier *notify.Service, userID uint, channels []string, msg notify.Message) ([]string, error) {
+	var failed []string
+	var errs []error
+	for _, channel := range channels {
+		if err := notifier.NotifyChannel(ctx, userID, channel, msg); err != nil {
+			failed = append(failed, channel)
+			errs = append(errs, err)
+		}
+	}
+	return failed, errors.Join(errs...)
+}
@@ -0,0 +1,197 @@
+// Package outbox runs background jobs stored in the outbox_jobs table.
+//
+// Jobs are inserted in the same transaction as the change that caused them,
+// so they are never lost when the process crashes or restarts. Workers claim
+// jobs with SELECT ... FOR UPDATE SKIP LOCKED and hold them for a lease; a
+// job whose worker died is picked up again once the lease expires. Failed
+// jobs are retried with exponential backoff and moved to the dead-letter
+// state after the configured number of attempts.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/config"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// Handler runs one job. It may change job.Payload, for example to remember
+// which parts already succeeded, and the change is kept for the next attempt.
+type Handler func(ctx context.Context, job *models.OutboxJob) error
+
+// permanentError marks failures that retrying cannot fix
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the job goes straight to the dead-letter state
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// NewJob creates a job of the given kind with payload encoded as JSON
+func NewJob(kind string, payload interface{}) (models.OutboxJob, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return models.OutboxJob{}, fmt.Errorf("encoding %s payload: %w", kind, err)
+	}
+	return models.OutboxJob{Kind: kind, Payload: data}, nil
+}
+
+// Decode reads the payload of a job. Payloads that cannot be decoded are
+// reported as permanent failures.
+func Decode(job *models.OutboxJob, payload interface{}) error {
+	if err := json.Unmarshal(job.Payload, payload); err != nil {
+		return Permanent(fmt.Errorf("decoding %s payload: %w", job.Kind, err))
+	}
+	return nil
+}
+
+// Worker claims jobs from the outbox and runs them on a pool of goroutines
+type Worker struct {
+	Jobs         repository.JobRepository
+	Workers      int
+	PollInterval time.Duration
+	BatchSize    int
+	Lease        time.Duration
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	Now          func() time.Time
+
+	handlers map[string]Handler
+}
+
+// New creates a Worker from the given configuration
+func New(jobs repository.JobRepository, cfg config.OutboxConfig) *Worker {
+	return &Worker{
+		Jobs:         jobs,
+		Workers:      cfg.Workers,
+		PollInterval: cfg.PollInterval,
+		BatchSize:    cfg.BatchSize,
+		Lease:        cfg.Lease,
+		MaxAttempts:  cfg.MaxAttempts,
+		BaseBackoff:  cfg.BaseBackoff,
+		MaxBackoff:   cfg.MaxBackoff,
+		Now:          time.Now,
+		handlers:     map[string]Handler{},
+	}
+}
+
+// Handle registers the handler of a job kind
+func (w *Worker) Handle(kind string, handler Handler) {
+	w.handlers[kind] = handler
+}
+
+// Start runs the worker pool in the background until ctx is cancelled
+func (w *Worker) Start(ctx context.Context) {
+	for i := 0; i < w.Workers; i++ {
+		go w.loop(ctx)
+	}
+}
+
+// loop claims and runs jobs, waiting PollInterval whenever the outbox is empty
+func (w *Worker) loop(ctx context.Context) {
+	for {
+		ran, err := w.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("outbox: %v", err)
+		}
+		if ran > 0 && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(w.PollInterval):
+		}
+	}
+}
+
+// RunOnce claims one batch of jobs, runs them concurrently and returns how
+// many were run
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	jobs, err := w.Jobs.Claim(ctx, w.Now(), w.Lease, w.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for i := range jobs {
+		wg.Add(1)
+		go func(job *models.OutboxJob) {
+			defer wg.Done()
+			w.run(ctx, job)
+		}(&jobs[i])
+	}
+	wg.Wait()
+	return len(jobs), nil
+}
+
+// run executes a claimed job and stores its outcome
+func (w *Worker) run(ctx context.Context, job *models.OutboxJob) {
+	jobCtx, cancel := context.WithTimeout(ctx, w.Lease)
+	err := w.execute(jobCtx, job)
+	cancel()
+
+	now := w.Now()
+	switch {
+	case err == nil:
+		job.Status = models.JobDone
+		job.CompletedAt = &now
+		job.LastError = ""
+	case errors.As(err, &permanentError{}) || job.Attempts >= w.MaxAttempts:
+		job.Status = models.JobDead
+		job.LastError = err.Error()
+		log.Printf("outbox: job %d (%s) is dead after %d attempts: %v", job.ID, job.Kind, job.Attempts, err)
+	default:
+		job.Status = models.JobPending
+		job.RunAt = now.Add(w.Backoff(job.Attempts))
+		job.LastError = err.Error()
+	}
+
+	if err := w.Jobs.Finish(ctx, job); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			log.Printf("outbox: job %d (%s) outlived its lease and was claimed again", job.ID, job.Kind)
+			return
+		}
+		log.Printf("outbox: saving job %d: %v", job.ID, err)
+	}
+}
+
+// execute calls the handler of a job, turning panics into errors
+func (w *Worker) execute(ctx context.Context, job *models.OutboxJob) (err error) {
+	handler, ok := w.handlers[job.Kind]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler for job kind %q", job.Kind))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// Backoff returns the delay before retrying a job that failed its given
+// attempt: BaseBackoff doubled for every earlier failure, capped at MaxBackoff
+func (w *Worker) Backoff(attempt int) time.Duration {
+	delay := w.BaseBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= w.MaxBackoff {
+			return w.MaxBackoff
+		}
+	}
+	return min(delay, w.MaxBackoff)
+}
@@ -0,0 +1,232 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// testKind is the kind of the jobs run by test workers
+const testKind = "test.job"
+
+// testWorker is a Worker on in-memory jobs whose clock only moves when told
+type testWorker struct {
+	*Worker
+	now time.Time
+}
+
+func newTestWorker(t *testing.T, handler Handler) *testWorker {
+	t.Helper()
+	w := &testWorker{now: time.Now().Truncate(time.Second)}
+	w.Worker = &Worker{
+		Jobs:        repository.NewMemoryRepositories().Jobs,
+		Workers:     1,
+		BatchSize:   10,
+		Lease:       time.Minute,
+		MaxAttempts: 3,
+		BaseBackoff: time.Second,
+		MaxBackoff:  5 * time.Second,
+		Now:         func() time.Time { return w.now },
+		handlers:    map[string]Handler{},
+	}
+	w.Handle(testKind, handler)
+	return w
+}
+
+// enqueue stores a job of kind runnable now
+func (w *testWorker) enqueue(t *testing.T, kind string) uint {
+	t.Helper()
+	job, err := NewJob(kind, map[string]int{"n": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.RunAt = w.now
+	if err := w.Jobs.Enqueue(context.Background(), &job); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	return job.ID
+}
+
+// runOnce runs a batch and checks how many jobs it ran
+func (w *testWorker) runOnce(t *testing.T, want int) {
+	t.Helper()
+	ran, err := w.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if ran != want {
+		t.Fatalf("RunOnce ran %d jobs, want %d", ran, want)
+	}
+}
+
+// job returns the stored job
+func (w *testWorker) job(t *testing.T, id uint) *models.OutboxJob {
+	t.Helper()
+	job, err := w.Jobs.FindByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	return job
+}
+
+func TestBackoff(t *testing.T) {
+	w := &Worker{BaseBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{40, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := w.Backoff(tt.attempt); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestRetriesWithBackoffUntilMaxAttempts(t *testing.T) {
+	calls := 0
+	w := newTestWorker(t, func(ctx context.Context, job *models.OutboxJob) error {
+		calls++
+		return errors.New("unavailable")
+	})
+	id := w.enqueue(t, testKind)
+
+	for attempt, backoff := range []time.Duration{time.Second, 2 * time.Second} {
+		w.runOnce(t, 1)
+		job := w.job(t, id)
+		if job.Status != models.JobPending || job.Attempts != attempt+1 || job.LastError != "unavailable" {
+			t.Fatalf("after attempt %d job = %s, %d attempts, %q", attempt+1, job.Status, job.Attempts, job.LastError)
+		}
+		if want := w.now.Add(backoff); !job.RunAt.Equal(want) {
+			t.Errorf("after attempt %d run_at = %v, want %v", attempt+1, job.RunAt, want)
+		}
+
+		// Not runnable until the backoff passed
+		w.now = job.RunAt.Add(-time.Millisecond)
+		w.runOnce(t, 0)
+		w.now = job.RunAt
+	}
+
+	w.runOnce(t, 1)
+	job := w.job(t, id)
+	if job.Status != models.JobDead || job.Attempts != 3 {
+		t.Errorf("after the last attempt job = %s, %d attempts, want dead after 3", job.Status, job.Attempts)
+	}
+	w.now = w.now.Add(time.Hour)
+	w.runOnce(t, 0)
+	if calls != 3 {
+		t.Errorf("handler ran %d times, want 3", calls)
+	}
+}
+
+func TestPermanentFailuresAreDeadLettered(t *testing.T) {
+	w := newTestWorker(t, func(ctx context.Context, job *models.OutboxJob) error {
+		return Permanent(errors.New("bad payload"))
+	})
+	id := w.enqueue(t, testKind)
+	unknown := w.enqueue(t, "test.unknown")
+
+	w.runOnce(t, 2)
+	for _, id := range []uint{id, unknown} {
+		if job := w.job(t, id); job.Status != models.JobDead || job.Attempts != 1 {
+			t.Errorf("job %d = %s after %d attempts, want dead after 1", id, job.Status, job.Attempts)
+		}
+	}
+}
+
+func TestSuccessAndPanics(t *testing.T) {
+	fail := true
+	w := newTestWorker(t, func(ctx context.Context, job *models.OutboxJob) error {
+		if fail {
+			panic("boom")
+		}
+		job.Payload = models.RawJSON(`{"n":2}`)
+		return nil
+	})
+	id := w.enqueue(t, testKind)
+
+	w.runOnce(t, 1)
+	job := w.job(t, id)
+	if job.Status != models.JobPending || job.LastError != "panic: boom" {
+		t.Fatalf("after a panic job = %s, %q, want pending with the panic", job.Status, job.LastError)
+	}
+
+	fail = false
+	w.now = job.RunAt
+	w.runOnce(t, 1)
+	job = w.job(t, id)
+	if job.Status != models.JobDone || job.CompletedAt == nil || job.LastError != "" || job.LockedUntil != nil {
+		t.Errorf("after success job = %+v, want done", job)
+	}
+	if string(job.Payload) != `{"n":2}` {
+		t.Errorf("payload = %s, want the one saved by the handler", job.Payload)
+	}
+}
+
+func TestExpiredLeaseIsReclaimed(t *testing.T) {
+	calls := 0
+	w := newTestWorker(t, func(ctx context.Context, job *models.OutboxJob) error {
+		calls++
+		return nil
+	})
+	id := w.enqueue(t, testKind)
+
+	// A worker claims the job and dies
+	ctx := context.Background()
+	claimed, err := w.Jobs.Claim(ctx, w.now, w.Lease, 10)
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("Claim = %d jobs, %v", len(claimed), err)
+	}
+	w.runOnce(t, 0)
+
+	w.now = w.now.Add(w.Lease)
+	w.runOnce(t, 1)
+	job := w.job(t, id)
+	if job.Status != models.JobDone || job.Attempts != 2 || calls != 1 {
+		t.Errorf("reclaimed job = %s after %d attempts and %d calls, want done after 2 attempts and 1 call", job.Status, job.Attempts, calls)
+	}
+
+	// The first worker comes back and must not overwrite the outcome
+	stale := claimed[0]
+	stale.Status, stale.LastError = models.JobPending, "timeout"
+	if err := w.Jobs.Finish(ctx, &stale); !errors.Is(err, repository.ErrConflict) {
+		t.Errorf("Finish of the expired claim error = %v, want ErrConflict", err)
+	}
+	if job := w.job(t, id); job.Status != models.JobDone || job.LastError != "" {
+		t.Errorf("job after the stale Finish = %s, %q, want done", job.Status, job.LastError)
+	}
+}
+
+func TestFinishRequiresRunningJob(t *testing.T) {
+	w := newTestWorker(t, nil)
+	id := w.enqueue(t, testKind)
+	ctx := context.Background()
+
+	pending := *w.job(t, id)
+	pending.Status = models.JobDone
+	if err := w.Jobs.Finish(ctx, &pending); !errors.Is(err, repository.ErrConflict) {
+		t.Errorf("Finish of an unclaimed job error = %v, want ErrConflict", err)
+	}
+
+	claimed, err := w.Jobs.Claim(ctx, w.now, w.Lease, 10)
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("Claim = %d jobs, %v", len(claimed), err)
+	}
+	claimed[0].Status = models.JobDone
+	if err := w.Jobs.Finish(ctx, &claimed[0]); err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+	if err := w.Jobs.Finish(ctx, &claimed[0]); !errors.Is(err, repository.ErrConflict) {
+		t.Errorf("second Finish error = %v, want ErrConflict", err)
+	}
+}
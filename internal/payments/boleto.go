@@ -0,0 +1,428 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/internal/boleto"
+	"github.com/alexandreffaria/hoby-loop/internal/outbox"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// BoletoProvider is the Provider of boleto payments, which are paid by the
+// subscriber at any bank and settled by the issuing bank
+const BoletoProvider = "boleto"
+
+// ErrCNPJRequired is returned when a subscriber without a CNPJ asks for
+// boletos
+var ErrCNPJRequired = errors.New("boletos are only issued to subscribers with a CNPJ")
+
+// KindBoletoOverdue marks a boleto overdue if it was not paid in time
+const KindBoletoOverdue = "boleto.overdue"
+
+// boletoClearing is how long after the end of the due date a boleto may
+// still be settled, since banks report payments the next business day
+const boletoClearing = 24 * time.Hour
+
+// Results of matching a boleto settlement
+const (
+	BoletoMatched        = "matched"         // Paid an open or overdue boleto
+	BoletoDuplicate      = "duplicate"       // Already applied
+	BoletoUnmatched      = "unmatched"       // No boleto has the nosso número
+	BoletoAmountMismatch = "amount_mismatch" // Paid less than the amount, left unpaid
+	BoletoAlreadyPaid    = "already_paid"    // The order was paid by another payment
+)
+
+// BoletoReconciliation is the outcome of one boleto settlement
+type BoletoReconciliation struct {
+	OurNumber string `json:"our_number"`
+	Result    string `json:"result"` // One of the boleto results above
+	BoletoID  uint   `json:"boleto_id,omitempty"`
+	PaymentID uint   `json:"payment_id,omitempty"`
+	OrderID   uint   `json:"order_id,omitempty"`
+}
+
+// BoletoLine describes a linha digitável or barcode typed by a user
+type BoletoLine struct {
+	Barcode       string        `json:"barcode"`
+	DigitableLine string        `json:"digitable_line"`
+	FormattedLine string        `json:"formatted_line"`
+	BankCode      string        `json:"bank_code"`
+	DueDate       *time.Time    `json:"due_date,omitempty"` // Nil for boletos without due date
+	Amount        *models.Money `json:"amount,omitempty"`   // Nil when the payer types the amount
+	Overdue       bool          `json:"overdue"`
+	// Status of the matching boleto issued by the platform, empty for others
+	Status models.BoletoStatus `json:"status,omitempty"`
+}
+
+// boletoOverduePayload is the payload of KindBoletoOverdue jobs
+type boletoOverduePayload struct {
+	BoletoID uint `json:"boleto_id"`
+}
+
+// BoletoEnabled reports whether a boleto agreement is configured
+func (s *Service) BoletoEnabled() bool {
+	return s.Boleto != nil
+}
+
+// UseBoleto makes boletos the payment method of subscription: its orders
+// get a boleto for the subscriber to pay. The subscription must have its
+// User loaded, and only subscribers with a CNPJ may use boletos.
+func (s *Service) UseBoleto(ctx context.Context, subscription *models.Subscription) error {
+	if !s.BoletoEnabled() {
+		return ErrDisabled
+	}
+	if subscription.User.CNPJ == "" {
+		return ErrCNPJRequired
+	}
+	subscription.PaymentMethod = models.PaymentMethodBoleto
+	subscription.PaymentToken, subscription.CardBrand, subscription.CardLast4 = "", "", ""
+	return s.Subscriptions.SavePaymentMethod(ctx, subscription)
+}
+
+// IssueBoleto returns the boleto of an order: the one that paid it, the open
+// one, or a new one when there is none or the last is overdue. Any order of
+// a subscriber with a CNPJ may be paid with a boleto.
+func (s *Service) IssueBoleto(ctx context.Context, orderID uint) (*models.Boleto, error) {
+	if !s.BoletoEnabled() {
+		return nil, ErrDisabled
+	}
+
+	order, err := s.Orders.FindByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Subscription.Status == models.SubscriptionTrialing {
+		return nil, ErrNotCharged
+	}
+	if order.Subscription.User.CNPJ == "" {
+		return nil, ErrCNPJRequired
+	}
+	previous, err := s.Payments.ListByOrder(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	boletos, err := s.Boletos.ListByOrder(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, payment := range previous {
+		if payment.Status != models.PaymentSucceeded && payment.Status != models.PaymentRefunded {
+			continue
+		}
+		for i := range boletos {
+			if boletos[i].PaymentID == payment.ID {
+				return &boletos[i], nil
+			}
+		}
+		return nil, ErrAlreadyPaid
+	}
+
+	_, issued, err := s.boletoPayment(ctx, order, previous)
+	return issued, err
+}
+
+// boletoPayment returns the pending boleto payment among previous, the
+// payments of order, and its open boleto, creating either as needed. The
+// payment's ID is the boleto's sequence in the agreement and its ChargeID
+// the nosso número.
+func (s *Service) boletoPayment(ctx context.Context, order *models.Order, previous []models.Payment) (*models.Payment, *models.Boleto, error) {
+	var payment *models.Payment
+	for i := range previous {
+		if previous[i].Method == models.PaymentMethodBoleto && previous[i].Status == models.PaymentPending {
+			payment = &previous[i]
+		}
+	}
+	if payment != nil {
+		boletos, err := s.Boletos.ListByOrder(ctx, order.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := range boletos {
+			if boletos[i].PaymentID == payment.ID && boletos[i].Status == models.BoletoOpen {
+				return payment, &boletos[i], nil
+			}
+		}
+	} else {
+		payment = &models.Payment{
+			OrderID:        order.ID,
+			SubscriptionID: order.Subscription.ID,
+			Provider:       BoletoProvider,
+			Method:         models.PaymentMethodBoleto,
+			Amount:         order.Subscription.Basket.Price,
+			Status:         models.PaymentPending,
+			Attempt:        len(previous) + 1,
+		}
+		if err := s.Payments.Create(ctx, payment); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	issued, err := s.Boleto.Issue(payment.ID, payment.Amount, s.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	payer := order.Subscription.User
+	b := &models.Boleto{
+		OrderID:       order.ID,
+		PaymentID:     payment.ID,
+		BankCode:      s.Boleto.BankCode,
+		OurNumber:     issued.OurNumber,
+		Barcode:       issued.Barcode,
+		DigitableLine: issued.DigitableLine,
+		Amount:        payment.Amount,
+		DueDate:       issued.DueDate,
+		PayerName:     payer.Name,
+		PayerDocument: payer.CNPJ,
+		PayerAddress:  payer.PostalAddress(),
+		Status:        models.BoletoOpen,
+	}
+	if err := s.Boletos.Create(ctx, b, boletoOverdueJobs); err != nil {
+		return nil, nil, err
+	}
+	payment.ChargeID = issued.OurNumber
+	return payment, b, s.Payments.Save(ctx, payment)
+}
+
+// boletoOverdueJobs builds the job expiring a new boleto once its due date
+// and clearing are over, for use as a repository.JobBuilder
+func boletoOverdueJobs(b models.Boleto) ([]models.OutboxJob, error) {
+	job, err := outbox.NewJob(KindBoletoOverdue, boletoOverduePayload{BoletoID: b.ID})
+	if err != nil {
+		return nil, err
+	}
+	job.RunAt = b.DueDate.AddDate(0, 0, 1).Add(boletoClearing)
+	return []models.OutboxJob{job}, nil
+}
+
+// BoletoPDF renders b for printing
+func (s *Service) BoletoPDF(b *models.Boleto) ([]byte, error) {
+	if !s.BoletoEnabled() {
+		return nil, ErrDisabled
+	}
+	return s.Boleto.PDF(b, boleto.Payer{Name: b.PayerName, Document: b.PayerDocument, Address: b.PayerAddress})
+}
+
+// ExpireBoleto marks b overdue if it is still open, failing its payment
+// and recording it on the order's timeline. Overdue boletos may still be
+// paid late.
+func (s *Service) ExpireBoleto(ctx context.Context, boletoID uint) error {
+	b, err := s.Boletos.FindByID(ctx, boletoID)
+	if err != nil {
+		return err
+	}
+	if b.Status != models.BoletoOpen {
+		return nil
+	}
+	b.Status = models.BoletoOverdue
+	err = s.Boletos.Transition(ctx, b, models.BoletoOpen)
+	if errors.Is(err, repository.ErrConflict) {
+		return nil // Paid or expired concurrently
+	}
+	if err != nil {
+		return err
+	}
+
+	payment, err := s.Payments.FindByID(ctx, b.PaymentID)
+	if err != nil {
+		return err
+	}
+	if payment.Status != models.PaymentPending {
+		return nil
+	}
+	s.apply(payment, models.PaymentFailed, FailureBoletoOverdue, "The boleto was not paid by its due date")
+	err = s.Payments.Transition(ctx, payment, models.PaymentPending)
+	if errors.Is(err, repository.ErrConflict) {
+		return nil // Paid concurrently
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("payments: boleto %s of order %d is overdue", b.OurNumber, b.OrderID)
+	s.settled(ctx, payment)
+	return s.orderEvent(ctx, b.OrderID, models.OrderEvent{
+		Type: models.OrderEventPaymentOverdue,
+		Note: fmt.Sprintf("Boleto %s due %s was not paid", b.OurNumber, b.DueDate.Format(time.DateOnly)),
+	})
+}
+
+// BoletoOverdueHandler runs KindBoletoOverdue jobs
+func BoletoOverdueHandler(service *Service) outbox.Handler {
+	return func(ctx context.Context, job *models.OutboxJob) error {
+		var payload boletoOverduePayload
+		if err := outbox.Decode(job, &payload); err != nil {
+			return err
+		}
+		err := service.ExpireBoleto(ctx, payload.BoletoID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return outbox.Permanent(fmt.Errorf("boleto %d not found", payload.BoletoID))
+		}
+		return err
+	}
+}
+
+// orderEvent appends event to the timeline of an order without changing its
+// status, retrying if the order changes concurrently
+func (s *Service) orderEvent(ctx context.Context, orderID uint, event models.OrderEvent) error {
+	for attempt := 0; ; attempt++ {
+		order, err := s.Orders.FindByID(ctx, orderID)
+		if err != nil {
+			return err
+		}
+		event.FromStatus, event.ToStatus = order.Status, order.Status
+		err = s.Orders.Transition(ctx, order, order.Status, []models.OrderEvent{event}, nil)
+		if !errors.Is(err, repository.ErrConflict) || attempt == 2 {
+			return err
+		}
+	}
+}
+
+// HandleBoletoNotification verifies a webhook call of the bank and
+// reconciles its settlements
+func (s *Service) HandleBoletoNotification(ctx context.Context, header http.Header, body []byte) ([]BoletoReconciliation, error) {
+	if !s.BoletoEnabled() {
+		return nil, ErrDisabled
+	}
+	settlements, err := s.Boleto.VerifyNotification(header, body)
+	if err != nil {
+		return nil, err
+	}
+	return s.ReconcileBoletos(ctx, settlements)
+}
+
+// ReconcileBoletos applies settlements to the boletos with their nosso
+// número. A settlement pays its boleto, even overdue, for at least the
+// amount, which leaves room for late fees; any other outcome is logged and
+// reported for follow-up. Settlements may be sent more than once.
+func (s *Service) ReconcileBoletos(ctx context.Context, settlements []boleto.Settlement) ([]BoletoReconciliation, error) {
+	results := make([]BoletoReconciliation, 0, len(settlements))
+	for _, settlement := range settlements {
+		result, err := s.settle(ctx, settlement)
+		if err != nil {
+			return results, fmt.Errorf("boleto %s: %w", settlement.OurNumber, err)
+		}
+		if result.Result != BoletoMatched && result.Result != BoletoDuplicate {
+			log.Printf("payments: boleto settlement %q of %s: %s", settlement.OurNumber, settlement.Amount, result.Result)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// settle applies one settlement
+func (s *Service) settle(ctx context.Context, settlement boleto.Settlement) (BoletoReconciliation, error) {
+	result := BoletoReconciliation{OurNumber: settlement.OurNumber, Result: BoletoUnmatched}
+	if settlement.OurNumber == "" {
+		return result, fmt.Errorf("%w: missing our_number", boleto.ErrInvalidNotification)
+	}
+	amount, err := settlement.Money()
+	if err != nil {
+		return result, fmt.Errorf("%w: %v", boleto.ErrInvalidNotification, err)
+	}
+	paidAt, err := settlement.Time()
+	if err != nil {
+		paidAt = s.Now()
+	}
+
+	b, err := s.Boletos.FindByOurNumber(ctx, settlement.OurNumber)
+	if errors.Is(err, repository.ErrNotFound) {
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	result.BoletoID, result.PaymentID, result.OrderID = b.ID, b.PaymentID, b.OrderID
+
+	if b.Status == models.BoletoPaid {
+		result.Result = BoletoDuplicate
+		return result, nil
+	}
+	if amount.Currency != b.Amount.Currency || amount.Amount < b.Amount.Amount {
+		result.Result = BoletoAmountMismatch
+		return result, nil
+	}
+	payment, err := s.Payments.FindByID(ctx, b.PaymentID)
+	if err != nil {
+		return result, err
+	}
+	b.Status = models.BoletoPaid
+	b.PaidAt = &paidAt
+	b.PaidAmount = amount
+	payment.PaidAt = &paidAt
+
+	// The boleto, its payment and the order event are written together, so
+	// a failure leaves the boleto open for the bank's retry. The money was
+	// received either way; the payment only succeeds if the order was not
+	// paid meanwhile by another payment.
+	succeeded, err := s.Payments.Settle(ctx, &repository.PaymentSettlement{
+		Payment: payment,
+		From:    []models.PaymentStatus{models.PaymentPending, models.PaymentFailed},
+		Boleto:  b,
+		Event: models.OrderEvent{
+			Type: models.OrderEventPaid,
+			Note: fmt.Sprintf("Boleto %s paid %s", b.OurNumber, amount.Format()),
+		},
+	})
+	switch {
+	case errors.Is(err, repository.ErrConflict):
+		result.Result = BoletoDuplicate
+		return result, nil
+	case err != nil:
+		return result, err
+	case !succeeded:
+		result.Result = BoletoAlreadyPaid
+		return result, nil
+	}
+	s.settled(ctx, payment)
+	result.Result = BoletoMatched
+	return result, nil
+}
+
+// ValidateLine checks a linha digitável or barcode typed by a user and
+// describes it, including the platform's boleto it belongs to
+func (s *Service) ValidateLine(ctx context.Context, input string) (*BoletoLine, error) {
+	now := s.Now()
+	fields, barcode, err := boleto.Parse(input, now)
+	if err != nil {
+		return nil, err
+	}
+	line, err := boleto.DigitableLine(barcode)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &BoletoLine{
+		Barcode:       barcode,
+		DigitableLine: line,
+		FormattedLine: boleto.FormatLine(line),
+		BankCode:      fields.BankCode,
+	}
+	if !fields.DueDate.IsZero() {
+		due := fields.DueDate
+		result.DueDate = &due
+		result.Overdue = due.AddDate(0, 0, 1).Before(now)
+	}
+	if !fields.Amount.IsZero() {
+		amount := fields.Amount
+		result.Amount = &amount
+	}
+
+	if !s.BoletoEnabled() {
+		return result, nil
+	}
+	if ourNumber, ok := s.Boleto.OurNumber(fields); ok {
+		b, err := s.Boletos.FindByOurNumber(ctx, ourNumber)
+		switch {
+		case err == nil && b.Barcode == barcode:
+			result.Status = b.Status
+		case err != nil && !errors.Is(err, repository.ErrNotFound):
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,85 @@
+package payments
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+func TestExpireBoleto(t *testing.T) {
+	tests := []struct {
+		name        string
+		boleto      models.BoletoStatus
+		payment     models.PaymentStatus
+		wantBoleto  models.BoletoStatus
+		wantPayment models.PaymentStatus
+		wantOverdue bool
+	}{
+		{"open", models.BoletoOpen, models.PaymentPending, models.BoletoOverdue, models.PaymentFailed, true},
+		{"already overdue", models.BoletoOverdue, models.PaymentFailed, models.BoletoOverdue, models.PaymentFailed, false},
+		{"paid", models.BoletoPaid, models.PaymentSucceeded, models.BoletoPaid, models.PaymentSucceeded, false},
+		{"payment settled otherwise", models.BoletoOpen, models.PaymentSucceeded, models.BoletoOverdue, models.PaymentSucceeded, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, _, repos := newTestService(t)
+			subscription, order := newTestOrder(t, repos, models.SubscriptionActive)
+			payment := newTestPayment(t, repos, order, tt.payment)
+			b := models.Boleto{
+				OrderID:   order.ID,
+				PaymentID: payment.ID,
+				OurNumber: "00000000001",
+				Amount:    payment.Amount,
+				DueDate:   testNow,
+				Status:    tt.boleto,
+			}
+			if err := repos.Boletos.Create(ctx, &b, nil); err != nil {
+				t.Fatalf("creating boleto: %v", err)
+			}
+
+			if err := s.ExpireBoleto(ctx, b.ID); err != nil {
+				t.Fatalf("ExpireBoleto: %v", err)
+			}
+
+			stored, err := repos.Boletos.FindByID(ctx, b.ID)
+			if err != nil {
+				t.Fatalf("FindByID: %v", err)
+			}
+			if stored.Status != tt.wantBoleto {
+				t.Errorf("boleto status = %s, want %s", stored.Status, tt.wantBoleto)
+			}
+			storedPayment, err := repos.Payments.FindByID(ctx, payment.ID)
+			if err != nil {
+				t.Fatalf("FindByID: %v", err)
+			}
+			if storedPayment.Status != tt.wantPayment {
+				t.Errorf("payment status = %s, want %s", storedPayment.Status, tt.wantPayment)
+			}
+
+			events, err := repos.Orders.ListEvents(ctx, order.ID)
+			if err != nil {
+				t.Fatalf("ListEvents: %v", err)
+			}
+			overdue := false
+			for _, event := range events {
+				overdue = overdue || event.Type == models.OrderEventPaymentOverdue
+			}
+			if overdue != tt.wantOverdue {
+				t.Errorf("payment_overdue event = %v, want %v", overdue, tt.wantOverdue)
+			}
+			sub, err := repos.Subscriptions.FindByID(ctx, subscription.ID)
+			if err != nil {
+				t.Fatalf("FindByID: %v", err)
+			}
+			wantSub := models.SubscriptionActive
+			if tt.wantOverdue {
+				wantSub = models.SubscriptionPastDue
+			}
+			if sub.Status != wantSub {
+				t.Errorf("subscription status = %s, want %s", sub.Status, wantSub)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/alexandreffaria/hoby-loop/internal/outbox"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// KindCharge charges the subscriber for an order
+const KindCharge = "payment.charge"
+
+// chargePayload is the payload of KindCharge jobs
+type chargePayload struct {
+	OrderID uint `json:"order_id"`
+}
+
+// ChargeJobs builds the job charging a new order, for use as a
+// repository.JobBuilder
+func ChargeJobs(order models.Order) ([]models.OutboxJob, error) {
+	job, err := outbox.NewJob(KindCharge, chargePayload{OrderID: order.ID})
+	if err != nil {
+		return nil, err
+	}
+	return []models.OutboxJob{job}, nil
+}
+
+// ChargeHandler runs KindCharge jobs. Provider errors are retried with the
+// outbox backoff; declines are final for the attempt and left to the
+// payment record.
+func ChargeHandler(service *Service) outbox.Handler {
+	return func(ctx context.Context, job *models.OutboxJob) error {
+		var payload chargePayload
+		if err := outbox.Decode(job, &payload); err != nil {
+			return err
+		}
+
+		_, err := service.ChargeOrder(ctx, payload.OrderID)
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			return outbox.Permanent(fmt.Errorf("order %d not found", payload.OrderID))
+		case errors.Is(err, ErrDisabled), errors.Is(err, ErrCNPJRequired):
+			return outbox.Permanent(err)
+		}
+		return err
+	}
+}
@@ -0,0 +1,368 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/config"
+	"github.com/alexandreffaria/hoby-loop/internal/lifecycle"
+	"github.com/alexandreffaria/hoby-loop/internal/outbox"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/internal/webhooks"
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// Dunning: when the charge of an order of an active subscription fails, the
+// subscription becomes past_due and deliveries stop. The charge is retried
+// at each delay of the retry schedule, counted from the first failure, and
+// the subscriber is reminded after every failed attempt. Paying the order,
+// by a retry or by the consumer, makes the subscription active again; once
+// the last retry fails it is paused or cancelled.
+//
+// Pix and boleto charges do not fail on their own: a retry leaves a pending
+// payment for the consumer to pay and counts as failed until it is paid.
+
+// KindDunningRetry retries the failed charge of a past due subscription
+const KindDunningRetry = "dunning.retry"
+
+// dunningRetryPayload is the payload of KindDunningRetry jobs. The job is
+// stale, and skipped, unless the subscription is still past due for the
+// same order after the same number of attempts.
+type dunningRetryPayload struct {
+	SubscriptionID uint `json:"subscription_id"`
+	OrderID        uint `json:"order_id"`
+	Attempt        int  `json:"attempt"`
+}
+
+// DunningEntry is a subscription in dunning, as listed in the admin report
+type DunningEntry struct {
+	SubscriptionID uint         `json:"subscription_id"`
+	ConsumerID     uint         `json:"consumer_id"`
+	ConsumerName   string       `json:"consumer_name"`
+	ConsumerEmail  string       `json:"consumer_email"`
+	BasketID       uint         `json:"basket_id"`
+	BasketName     string       `json:"basket_name"`
+	OrderID        uint         `json:"order_id"`
+	Amount         models.Money `json:"amount"`
+	PaymentMethod  string       `json:"payment_method"`
+	PastDueAt      *time.Time   `json:"past_due_at"`
+	Attempts       int          `json:"attempts"`
+	MaxAttempts    int          `json:"max_attempts"`
+	NextRetryAt    *time.Time   `json:"next_retry_at"` // Nil after the last attempt
+	FinalAction    string       `json:"final_action"`  // pause or cancel, applied if the next retry fails
+	FailureCode    string       `json:"failure_code,omitempty"`
+	FailureMessage string       `json:"failure_message,omitempty"`
+}
+
+// MaxDunningAttempts is the number of charge attempts, the first one
+// included, before a past due subscription is paused or cancelled
+func (s *Service) MaxDunningAttempts() int {
+	return len(s.Dunning.RetrySchedule) + 1
+}
+
+// nextRetry returns when the charge of a subscription past due since
+// pastDueAt is retried after failures failed attempts, nil when no retry is
+// left
+func (s *Service) nextRetry(pastDueAt time.Time, failures int) *time.Time {
+	if failures > len(s.Dunning.RetrySchedule) {
+		return nil
+	}
+	next := pastDueAt.Add(s.Dunning.RetrySchedule[failures-1])
+	return &next
+}
+
+// settled updates the dunning of the subscription of payment once payment
+// failed or succeeded. The payment is already recorded, so errors are only
+// logged: retrying the caller would charge the order again.
+func (s *Service) settled(ctx context.Context, payment *models.Payment) {
+	var err error
+	switch payment.Status {
+	case models.PaymentFailed:
+		err = s.startDunning(ctx, payment)
+	case models.PaymentSucceeded:
+		err = s.recover(ctx, payment.SubscriptionID, payment.OrderID)
+	}
+	if err != nil {
+		log.Printf("payments: dunning of subscription %d after payment %d: %v", payment.SubscriptionID, payment.ID, err)
+	}
+}
+
+// startDunning makes the subscription of a failed payment past due and
+// schedules the first retry. Subscriptions not active, including those
+// already in dunning, are left alone.
+func (s *Service) startDunning(ctx context.Context, payment *models.Payment) error {
+	return s.changeSubscription(ctx, payment.SubscriptionID, func(subscription *models.Subscription) (*models.SubscriptionEvent, []models.OutboxJob, error) {
+		if subscription.Status != models.SubscriptionActive {
+			return nil, nil, nil
+		}
+
+		now := s.Now()
+		event, err := lifecycle.MarkPastDue(subscription, payment.OrderID, s.nextRetry(now, 1), failureReason(payment), now)
+		if err != nil {
+			return nil, nil, err
+		}
+		jobs, err := s.dunningJobs(*subscription)
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("payments: subscription %d is past due after order %d failed: %s", subscription.ID, payment.OrderID, event.Reason)
+		return &event, jobs, nil
+	})
+}
+
+// recover makes a past due subscription active once the order it is in
+// dunning for is paid. Paused subscriptions only forget the failed charge.
+func (s *Service) recover(ctx context.Context, subscriptionID, orderID uint) error {
+	return s.changeSubscription(ctx, subscriptionID, func(subscription *models.Subscription) (*models.SubscriptionEvent, []models.OutboxJob, error) {
+		if subscription.DunningOrderID != orderID || (subscription.Status != models.SubscriptionPastDue && subscription.Status != models.SubscriptionPaused) {
+			return nil, nil, nil
+		}
+		event, err := lifecycle.Recover(subscription, s.Now())
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("payments: subscription %d recovered, order %d was paid", subscription.ID, orderID)
+		return &event, nil, nil
+	})
+}
+
+// RetryDunning charges the order a past due subscription is in dunning for
+// again. A failed attempt schedules the next retry, or pauses or cancels the
+// subscription after the last one. Stale retries are ignored.
+func (s *Service) RetryDunning(ctx context.Context, subscriptionID, orderID uint, attempt int) error {
+	subscription, err := s.Subscriptions.FindByID(ctx, subscriptionID)
+	if err != nil {
+		return err
+	}
+	if !inDunning(subscription, orderID, attempt) {
+		return nil
+	}
+
+	payment, err := s.ChargeOrder(ctx, orderID)
+	reason := ""
+	switch {
+	case errors.Is(err, ErrDisabled), errors.Is(err, ErrCNPJRequired):
+		// The charge cannot be made with the current settings, which is
+		// a failed attempt like a decline
+		reason = err.Error()
+	case err != nil:
+		return err
+	case payment != nil && payment.Status == models.PaymentSucceeded:
+		return s.recover(ctx, subscriptionID, orderID)
+	case payment != nil:
+		reason = failureReason(payment)
+	}
+
+	return s.changeSubscription(ctx, subscriptionID, func(subscription *models.Subscription) (*models.SubscriptionEvent, []models.OutboxJob, error) {
+		if !inDunning(subscription, orderID, attempt) {
+			return nil, nil, nil
+		}
+
+		now := s.Now()
+		if attempt+1 >= s.MaxDunningAttempts() {
+			return s.endDunning(subscription, reason, now)
+		}
+
+		event, err := lifecycle.RetryFailed(subscription, s.nextRetry(*subscription.PastDueAt, attempt+1), reason, now)
+		if err != nil {
+			return nil, nil, err
+		}
+		jobs, err := s.dunningJobs(*subscription)
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("payments: retry %d of order %d failed: %s", attempt, orderID, reason)
+		return &event, jobs, nil
+	})
+}
+
+// endDunning pauses or cancels a subscription whose last retry failed and
+// reminds the subscriber
+func (s *Service) endDunning(subscription *models.Subscription, reason string, now time.Time) (*models.SubscriptionEvent, []models.OutboxJob, error) {
+	reminder := outbox.PaymentReminder{
+		SubscriptionID: subscription.ID,
+		OrderID:        subscription.DunningOrderID,
+		Amount:         subscription.Basket.Price,
+		PaymentMethod:  subscription.PaymentMethod,
+		Attempt:        s.MaxDunningAttempts(),
+		MaxAttempts:    s.MaxDunningAttempts(),
+	}
+	reason = fmt.Sprintf("Payment of order %d failed %d times: %s", subscription.DunningOrderID, s.MaxDunningAttempts(), reason)
+
+	var event models.SubscriptionEvent
+	var err error
+	var jobs []models.OutboxJob
+	if s.Dunning.FinalAction == config.DunningCancel {
+		event, err = lifecycle.Cancel(subscription, lifecycle.SystemActor, reason, now)
+		if err != nil {
+			return nil, nil, err
+		}
+		job, err := webhooks.SubscriptionJob(webhooks.EventSubscriptionCancelled, *subscription)
+		if err != nil {
+			return nil, nil, err
+		}
+		jobs = append(jobs, job)
+	} else {
+		event, err = lifecycle.Pause(subscription, lifecycle.SystemActor, nil, reason, now)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	reminder.Status = subscription.Status
+	job, err := outbox.NewJob(outbox.KindPaymentReminder, reminder)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("payments: subscription %d is %s after %d failed attempts", subscription.ID, subscription.Status, reminder.Attempt)
+	return &event, append(jobs, job), nil
+}
+
+// dunningJobs builds the reminder of the last failed attempt of a past due
+// subscription and the job retrying it, if any retry is left
+func (s *Service) dunningJobs(subscription models.Subscription) ([]models.OutboxJob, error) {
+	reminder, err := outbox.NewJob(outbox.KindPaymentReminder, outbox.PaymentReminder{
+		SubscriptionID: subscription.ID,
+		OrderID:        subscription.DunningOrderID,
+		Amount:         subscription.Basket.Price,
+		PaymentMethod:  subscription.PaymentMethod,
+		Attempt:        subscription.DunningAttempts,
+		MaxAttempts:    s.MaxDunningAttempts(),
+		NextRetryAt:    subscription.NextRetryAt,
+		Status:         subscription.Status,
+	})
+	if err != nil {
+		return nil, err
+	}
+	retry, err := DunningRetryJobs(subscription)
+	if err != nil {
+		return nil, err
+	}
+	return append([]models.OutboxJob{reminder}, retry...), nil
+}
+
+// DunningRetryJobs builds the job retrying the failed charge of a past due
+// subscription at its next_retry_at, if any, for use as a
+// repository.JobBuilder when a paused subscription resumes in dunning
+func DunningRetryJobs(subscription models.Subscription) ([]models.OutboxJob, error) {
+	if subscription.Status != models.SubscriptionPastDue || subscription.NextRetryAt == nil {
+		return nil, nil
+	}
+	retry, err := outbox.NewJob(KindDunningRetry, dunningRetryPayload{
+		SubscriptionID: subscription.ID,
+		OrderID:        subscription.DunningOrderID,
+		Attempt:        subscription.DunningAttempts,
+	})
+	if err != nil {
+		return nil, err
+	}
+	retry.RunAt = *subscription.NextRetryAt
+	return []models.OutboxJob{retry}, nil
+}
+
+// changeSubscription loads a subscription and applies change to it,
+// retrying if the subscription changes concurrently. change returns a nil
+// event when there is nothing to do.
+func (s *Service) changeSubscription(ctx context.Context, id uint, change func(*models.Subscription) (*models.SubscriptionEvent, []models.OutboxJob, error)) error {
+	for attempt := 0; ; attempt++ {
+		subscription, err := s.Subscriptions.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		from := subscription.Status
+		event, jobs, err := change(subscription)
+		if err != nil || event == nil {
+			return err
+		}
+		err = s.Subscriptions.Transition(ctx, subscription, from, event, jobs)
+		if !errors.Is(err, repository.ErrConflict) || attempt == 2 {
+			return err
+		}
+	}
+}
+
+// inDunning reports whether subscription is past due for orderID after
+// attempt failed attempts
+func inDunning(subscription *models.Subscription, orderID uint, attempt int) bool {
+	return subscription.Status == models.SubscriptionPastDue &&
+		subscription.DunningOrderID == orderID &&
+		subscription.DunningAttempts == attempt
+}
+
+// failureReason describes why payment did not go through
+func failureReason(payment *models.Payment) string {
+	switch {
+	case payment.Status == models.PaymentPending:
+		return "awaiting payment"
+	case payment.FailureMessage != "":
+		return payment.FailureMessage
+	case payment.FailureCode != "":
+		return payment.FailureCode
+	}
+	return string(payment.Status)
+}
+
+// DunningReport returns a page of the subscriptions currently in dunning
+// with the failure of their last attempt
+func (s *Service) DunningReport(ctx context.Context, filter repository.SubscriptionFilter, page repository.PageRequest) (repository.Page[DunningEntry], error) {
+	filter.Status = models.SubscriptionPastDue
+	subscriptions, err := s.Subscriptions.List(ctx, filter, page)
+	if err != nil {
+		return repository.Page[DunningEntry]{}, err
+	}
+
+	report := repository.Page[DunningEntry]{
+		Items:      make([]DunningEntry, 0, len(subscriptions.Items)),
+		NextCursor: subscriptions.NextCursor,
+		Total:      subscriptions.Total,
+	}
+	for _, subscription := range subscriptions.Items {
+		entry := DunningEntry{
+			SubscriptionID: subscription.ID,
+			ConsumerID:     subscription.UserID,
+			ConsumerName:   subscription.User.Name,
+			ConsumerEmail:  subscription.User.Email,
+			BasketID:       subscription.BasketID,
+			BasketName:     subscription.Basket.Name,
+			OrderID:        subscription.DunningOrderID,
+			Amount:         subscription.Basket.Price,
+			PaymentMethod:  subscription.PaymentMethod,
+			PastDueAt:      subscription.PastDueAt,
+			Attempts:       subscription.DunningAttempts,
+			MaxAttempts:    s.MaxDunningAttempts(),
+			NextRetryAt:    subscription.NextRetryAt,
+			FinalAction:    s.Dunning.FinalAction,
+		}
+		if subscription.DunningOrderID != 0 {
+			attempts, err := s.Payments.ListByOrder(ctx, subscription.DunningOrderID)
+			if err != nil {
+				return repository.Page[DunningEntry]{}, err
+			}
+			for _, payment := range attempts {
+				if payment.Status == models.PaymentFailed {
+					entry.FailureCode, entry.FailureMessage = payment.FailureCode, payment.FailureMessage
+				}
+			}
+		}
+		report.Items = append(report.Items, entry)
+	}
+	return report, nil
+}
+
+// DunningRetryHandler runs KindDunningRetry jobs
+func DunningRetryHandler(service *Service) outbox.Handler {
+	return func(ctx context.Context, job *models.OutboxJob) error {
+		var payload dunningRetryPayload
+		if err := outbox.Decode(job, &payload); err != nil {
+			return err
+		}
+		err := service.RetryDunning(ctx, payload.SubscriptionID, payload.OrderID, payload.Attempt)
+		if errors.Is(err, repository.ErrNotFound) {
+			return outbox.Permanent(fmt.Errorf("subscription %d or order %d not found", payload.SubscriptionID, payload.OrderID))
+		}
+		return err
+	}
+}
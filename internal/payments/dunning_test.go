@@ -0,0 +1,300 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/config"
+	"github.com/alexandreffaria/hoby-loop/internal/lifecycle"
+	"github.com/alexandreffaria/hoby-loop/internal/outbox"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// saveTestCard makes number the card charged for the subscription
+func saveTestCard(t *testing.T, s *Service, subscriptionID uint, number string) {
+	t.Helper()
+	subscription, err := s.Subscriptions.FindByID(context.Background(), subscriptionID)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	card := Card{Number: number, HolderName: "Maria", ExpMonth: 12, ExpYear: testNow.Year() + 4, CVC: "123"}
+	if err := s.SaveCard(context.Background(), subscription, card); err != nil {
+		t.Fatalf("SaveCard: %v", err)
+	}
+}
+
+// findSubscription returns the stored subscription
+func findSubscription(t *testing.T, s *Service, id uint) *models.Subscription {
+	t.Helper()
+	subscription, err := s.Subscriptions.FindByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+	return subscription
+}
+
+// queuedJobs returns the payloads and run times of the queued jobs of kind,
+// oldest first
+func queuedJobs[T any](t *testing.T, repos repository.Repositories, kind string) ([]T, []time.Time) {
+	t.Helper()
+	page, err := repos.Jobs.List(context.Background(), repository.JobFilter{Kind: kind},
+		repository.PageRequest{Limit: 100, Sort: []repository.SortField{{Field: "id"}}})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	payloads := make([]T, len(page.Items))
+	runAt := make([]time.Time, len(page.Items))
+	for i, job := range page.Items {
+		if err := json.Unmarshal(job.Payload, &payloads[i]); err != nil {
+			t.Fatalf("decoding %s payload: %v", kind, err)
+		}
+		runAt[i] = job.RunAt
+	}
+	return payloads, runAt
+}
+
+// startTestDunning charges a new order with a declined card, making its
+// active subscription past due
+func startTestDunning(t *testing.T, s *Service, repos repository.Repositories) (*models.Subscription, *models.Order) {
+	t.Helper()
+	subscription, order := newTestOrder(t, repos, models.SubscriptionActive)
+	saveTestCard(t, s, subscription.ID, FakeCardDeclined)
+
+	payment, err := s.ChargeOrder(context.Background(), order.ID)
+	if err != nil {
+		t.Fatalf("ChargeOrder: %v", err)
+	}
+	if payment.Status != models.PaymentFailed || payment.FailureCode != FailureCardDeclined {
+		t.Fatalf("payment = %s %s, want failed with %s", payment.Status, payment.FailureCode, FailureCardDeclined)
+	}
+	return findSubscription(t, s, subscription.ID), order
+}
+
+func TestStartDunning(t *testing.T) {
+	s, _, repos := newTestService(t)
+	subscription, order := startTestDunning(t, s, repos)
+
+	if subscription.Status != models.SubscriptionPastDue {
+		t.Fatalf("status = %s, want past_due", subscription.Status)
+	}
+	wantRetry := testNow.Add(24 * time.Hour)
+	if subscription.DunningOrderID != order.ID || subscription.DunningAttempts != 1 ||
+		subscription.PastDueAt == nil || !subscription.PastDueAt.Equal(testNow) ||
+		subscription.NextRetryAt == nil || !subscription.NextRetryAt.Equal(wantRetry) {
+		t.Errorf("dunning = order %d, %d attempts, past due at %v, next retry %v; want order %d, 1 attempt, %v, %v",
+			subscription.DunningOrderID, subscription.DunningAttempts, subscription.PastDueAt, subscription.NextRetryAt, order.ID, testNow, wantRetry)
+	}
+	if subscription.NextDeliveryAt != nil {
+		t.Errorf("next delivery = %v, want none while past due", subscription.NextDeliveryAt)
+	}
+
+	retries, runAt := queuedJobs[dunningRetryPayload](t, repos, KindDunningRetry)
+	want := dunningRetryPayload{SubscriptionID: subscription.ID, OrderID: order.ID, Attempt: 1}
+	if len(retries) != 1 || retries[0] != want || !runAt[0].Equal(wantRetry) {
+		t.Errorf("retry jobs = %+v at %v, want %+v at %v", retries, runAt, want, wantRetry)
+	}
+	reminders, _ := queuedJobs[outbox.PaymentReminder](t, repos, outbox.KindPaymentReminder)
+	if len(reminders) != 1 || reminders[0].Attempt != 1 || reminders[0].MaxAttempts != 3 || reminders[0].Status != models.SubscriptionPastDue {
+		t.Errorf("reminders = %+v, want attempt 1 of 3 past due", reminders)
+	}
+
+	// Another failure of the same subscription does not restart dunning
+	payment := newTestPayment(t, repos, order, models.PaymentFailed)
+	if err := s.startDunning(context.Background(), payment); err != nil {
+		t.Fatalf("startDunning: %v", err)
+	}
+	if again := findSubscription(t, s, subscription.ID); again.DunningAttempts != 1 || !again.NextRetryAt.Equal(wantRetry) {
+		t.Errorf("second failure changed dunning to %d attempts, next retry %v", again.DunningAttempts, again.NextRetryAt)
+	}
+}
+
+func TestStartDunningIgnoresInactiveSubscriptions(t *testing.T) {
+	for _, status := range []models.SubscriptionStatus{models.SubscriptionTrialing, models.SubscriptionPaused, models.SubscriptionCancelled} {
+		s, _, repos := newTestService(t)
+		subscription, order := newTestOrder(t, repos, status)
+		payment := newTestPayment(t, repos, order, models.PaymentFailed)
+
+		if err := s.startDunning(context.Background(), payment); err != nil {
+			t.Fatalf("%s: startDunning: %v", status, err)
+		}
+		if got := findSubscription(t, s, subscription.ID); got.Status != status || got.DunningOrderID != 0 {
+			t.Errorf("%s: subscription became %s in dunning for order %d", status, got.Status, got.DunningOrderID)
+		}
+	}
+}
+
+func TestRetryDunningSkipsStaleRetries(t *testing.T) {
+	s, _, repos := newTestService(t)
+	subscription, order := startTestDunning(t, s, repos)
+	ctx := context.Background()
+
+	stale := []struct {
+		name    string
+		orderID uint
+		attempt int
+	}{
+		{"earlier attempt", order.ID, 0},
+		{"later attempt", order.ID, 2},
+		{"other order", order.ID + 1, 1},
+	}
+	for _, tt := range stale {
+		if err := s.RetryDunning(ctx, subscription.ID, tt.orderID, tt.attempt); err != nil {
+			t.Fatalf("%s: RetryDunning: %v", tt.name, err)
+		}
+	}
+	payments, err := repos.Payments.ListByOrder(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("ListByOrder: %v", err)
+	}
+	if len(payments) != 1 {
+		t.Errorf("stale retries charged the order: %d payments, want 1", len(payments))
+	}
+	if got := findSubscription(t, s, subscription.ID); got.DunningAttempts != 1 || got.Status != models.SubscriptionPastDue {
+		t.Errorf("stale retries changed the subscription to %s after %d attempts", got.Status, got.DunningAttempts)
+	}
+}
+
+func TestDunningEnds(t *testing.T) {
+	tests := []struct {
+		action string
+		want   models.SubscriptionStatus
+	}{
+		{config.DunningPause, models.SubscriptionPaused},
+		{config.DunningCancel, models.SubscriptionCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			s, _, repos := newTestService(t)
+			s.Dunning.FinalAction = tt.action
+			subscription, order := startTestDunning(t, s, repos)
+			ctx := context.Background()
+
+			s.Now = func() time.Time { return testNow.Add(24 * time.Hour) }
+			if err := s.RetryDunning(ctx, subscription.ID, order.ID, 1); err != nil {
+				t.Fatalf("RetryDunning 1: %v", err)
+			}
+			retried := findSubscription(t, s, subscription.ID)
+			wantRetry := testNow.Add(72 * time.Hour)
+			if retried.Status != models.SubscriptionPastDue || retried.DunningAttempts != 2 || !retried.NextRetryAt.Equal(wantRetry) {
+				t.Fatalf("after retry 1 = %s, %d attempts, next retry %v; want past_due, 2, %v",
+					retried.Status, retried.DunningAttempts, retried.NextRetryAt, wantRetry)
+			}
+
+			s.Now = func() time.Time { return wantRetry }
+			if err := s.RetryDunning(ctx, subscription.ID, order.ID, 2); err != nil {
+				t.Fatalf("RetryDunning 2: %v", err)
+			}
+			ended := findSubscription(t, s, subscription.ID)
+			if ended.Status != tt.want || ended.NextRetryAt != nil || ended.NextDeliveryAt != nil {
+				t.Errorf("after the last retry = %s, next retry %v, next delivery %v; want %s with neither",
+					ended.Status, ended.NextRetryAt, ended.NextDeliveryAt, tt.want)
+			}
+			// A paused subscription keeps the failed charge for when it resumes
+			if keeps := ended.DunningOrderID == order.ID; keeps != (tt.want == models.SubscriptionPaused) {
+				t.Errorf("dunning order after %s = %d", tt.action, ended.DunningOrderID)
+			}
+
+			payments, err := repos.Payments.ListByOrder(ctx, order.ID)
+			if err != nil {
+				t.Fatalf("ListByOrder: %v", err)
+			}
+			if len(payments) != 3 {
+				t.Errorf("payments = %d, want 3 attempts", len(payments))
+			}
+			reminders, _ := queuedJobs[outbox.PaymentReminder](t, repos, outbox.KindPaymentReminder)
+			if len(reminders) != 3 || reminders[2].Attempt != 3 || reminders[2].Status != tt.want {
+				t.Errorf("reminders = %+v, want 3, the last one %s", reminders, tt.want)
+			}
+			retries, _ := queuedJobs[dunningRetryPayload](t, repos, KindDunningRetry)
+			if len(retries) != 2 {
+				t.Errorf("retry jobs = %+v, want 2", retries)
+			}
+		})
+	}
+}
+
+func TestDunningRecovers(t *testing.T) {
+	s, _, repos := newTestService(t)
+	subscription, order := startTestDunning(t, s, repos)
+	ctx := context.Background()
+
+	saveTestCard(t, s, subscription.ID, FakeCardApproved)
+	retryAt := testNow.Add(24 * time.Hour)
+	s.Now = func() time.Time { return retryAt }
+	if err := s.RetryDunning(ctx, subscription.ID, order.ID, 1); err != nil {
+		t.Fatalf("RetryDunning: %v", err)
+	}
+
+	recovered := findSubscription(t, s, subscription.ID)
+	if recovered.Status != models.SubscriptionActive {
+		t.Fatalf("status = %s, want active", recovered.Status)
+	}
+	if recovered.DunningOrderID != 0 || recovered.DunningAttempts != 0 || recovered.PastDueAt != nil || recovered.NextRetryAt != nil {
+		t.Errorf("dunning was not cleared: %+v", recovered)
+	}
+	if recovered.NextDeliveryAt == nil || !recovered.NextDeliveryAt.After(retryAt) {
+		t.Errorf("next delivery = %v, want after %v", recovered.NextDeliveryAt, retryAt)
+	}
+
+	// The retry queued by the first failure is now stale
+	if err := s.RetryDunning(ctx, subscription.ID, order.ID, 1); err != nil {
+		t.Fatalf("stale RetryDunning: %v", err)
+	}
+	payments, err := repos.Payments.ListByOrder(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("ListByOrder: %v", err)
+	}
+	if len(payments) != 2 || payments[1].Status != models.PaymentSucceeded {
+		t.Errorf("payments = %+v, want a failed and a succeeded one", payments)
+	}
+}
+
+func TestRecoverPausedSubscription(t *testing.T) {
+	s, _, repos := newTestService(t)
+	subscription, order := startTestDunning(t, s, repos)
+	ctx := context.Background()
+
+	// Retries run out and the subscription is paused with the failed charge
+	for attempt := 1; attempt < s.MaxDunningAttempts(); attempt++ {
+		if err := s.RetryDunning(ctx, subscription.ID, order.ID, attempt); err != nil {
+			t.Fatalf("RetryDunning %d: %v", attempt, err)
+		}
+	}
+	paused := findSubscription(t, s, subscription.ID)
+	if paused.Status != models.SubscriptionPaused || paused.DunningOrderID != order.ID {
+		t.Fatalf("after the last retry = %s in dunning for %d, want paused for %d", paused.Status, paused.DunningOrderID, order.ID)
+	}
+
+	// Paying another order does not recover it
+	if err := s.recover(ctx, subscription.ID, order.ID+1); err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	if got := findSubscription(t, s, subscription.ID); got.DunningOrderID != order.ID {
+		t.Errorf("paying another order cleared dunning")
+	}
+
+	// The consumer pays the order: still paused, without the failed charge
+	if err := s.recover(ctx, subscription.ID, order.ID); err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	recovered := findSubscription(t, s, subscription.ID)
+	if recovered.Status != models.SubscriptionPaused || recovered.DunningOrderID != 0 || recovered.DunningAttempts != 0 {
+		t.Fatalf("after paying = %s in dunning for %d after %d attempts, want paused without dunning",
+			recovered.Status, recovered.DunningOrderID, recovered.DunningAttempts)
+	}
+
+	// Resuming it restarts deliveries instead of dunning
+	event, err := lifecycle.Resume(recovered, 1, s.Now())
+	if err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	if err := repos.Subscriptions.Transition(ctx, recovered, models.SubscriptionPaused, &event, nil); err != nil {
+		t.Fatalf("Transition: %v", err)
+	}
+	if got := findSubscription(t, s, subscription.ID); got.Status != models.SubscriptionActive || got.NextDeliveryAt == nil {
+		t.Errorf("after resuming = %s, next delivery %v, want active with a delivery", got.Status, got.NextDeliveryAt)
+	}
+}
@@ -0,0 +1,237 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// Test cards of the fake provider. Any other card with a valid check digit
+// is approved.
+const (
+	FakeCardApproved          = "4242424242424242"
+	FakeCardDeclined          = "4000000000000002"
+	FakeCardInsufficientFunds = "4000000000009995"
+	FakeCardExpired           = "4000000000000069"
+	// FakeCardUnavailable makes every charge fail as if the gateway were down
+	FakeCardUnavailable = "4000000000000119"
+)
+
+// FakeSignatureHeader carries the hex HMAC-SHA256 of the body of fake webhook calls
+const FakeSignatureHeader = "X-Fake-Signature"
+
+// fakeTokenPrefix starts every fake card token, followed by the last four
+// digits that decide the outcome of its charges
+const fakeTokenPrefix = "tok_fake_"
+
+// fakeOutcomes maps the last four digits of the test cards to their decline
+var fakeOutcomes = map[string]struct{ code, message string }{
+	FakeCardDeclined[12:]:          {FailureCardDeclined, "The card was declined"},
+	FakeCardInsufficientFunds[12:]: {FailureInsufficientFunds, "The card has insufficient funds"},
+	FakeCardExpired[12:]:           {FailureExpiredCard, "The card has expired"},
+}
+
+// FakeProvider is a PaymentProvider that never leaves the process. Tokens
+// carry everything needed to charge them, so they keep working across
+// restarts; only idempotency keys are remembered in memory.
+type FakeProvider struct {
+	WebhookSecret string
+	Now           func() time.Time
+
+	mu      sync.Mutex
+	charges map[string]Charge // By idempotency key
+}
+
+// NewFakeProvider creates a FakeProvider verifying webhooks with secret
+func NewFakeProvider(webhookSecret string) *FakeProvider {
+	return &FakeProvider{
+		WebhookSecret: webhookSecret,
+		Now:           time.Now,
+		charges:       map[string]Charge{},
+	}
+}
+
+// Name implements PaymentProvider
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+// CreateCustomer implements PaymentProvider
+func (p *FakeProvider) CreateCustomer(ctx context.Context, customer Customer) (string, error) {
+	return fakeID("cus_fake_")
+}
+
+// TokenizeCard implements PaymentProvider. It checks the number, expiry and
+// CVC like a real gateway would.
+func (p *FakeProvider) TokenizeCard(ctx context.Context, customerID string, card Card) (CardToken, error) {
+	number := strings.ReplaceAll(strings.ReplaceAll(card.Number, " ", ""), "-", "")
+	if len(number) < 12 || len(number) > 19 || !digitsOnly(number) {
+		return CardToken{}, fmt.Errorf("%w: the number must have 12 to 19 digits", ErrInvalidCard)
+	}
+	if !luhnValid(number) {
+		return CardToken{}, fmt.Errorf("%w: the number fails the check digit", ErrInvalidCard)
+	}
+	if card.ExpMonth < 1 || card.ExpMonth > 12 {
+		return CardToken{}, fmt.Errorf("%w: the expiry month must be between 1 and 12", ErrInvalidCard)
+	}
+	now := p.Now()
+	if card.ExpYear < now.Year() || (card.ExpYear == now.Year() && card.ExpMonth < int(now.Month())) {
+		return CardToken{}, fmt.Errorf("%w: the card has expired", ErrInvalidCard)
+	}
+	if len(card.CVC) < 3 || len(card.CVC) > 4 || !digitsOnly(card.CVC) {
+		return CardToken{}, fmt.Errorf("%w: the CVC must have 3 or 4 digits", ErrInvalidCard)
+	}
+
+	last4 := number[len(number)-4:]
+	id, err := fakeID(fakeTokenPrefix + last4 + "_")
+	if err != nil {
+		return CardToken{}, err
+	}
+	return CardToken{Token: id, Brand: cardBrand(number), Last4: last4}, nil
+}
+
+// Charge implements PaymentProvider. The outcome depends on the test card
+// the token was created from.
+func (p *FakeProvider) Charge(ctx context.Context, req ChargeRequest) (Charge, error) {
+	if !req.Amount.IsPositive() {
+		return Charge{}, fmt.Errorf("fake provider: amount must be positive, got %s", req.Amount)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if charge, ok := p.charges[req.IdempotencyKey]; ok && req.IdempotencyKey != "" {
+		return charge, nil
+	}
+
+	last4, ok := strings.CutPrefix(req.Token, fakeTokenPrefix)
+	if !ok || len(last4) < 4 {
+		return Charge{Status: models.PaymentFailed, FailureCode: FailureInvalidPaymentMethod, FailureMessage: "Unknown card token"}, nil
+	}
+	last4 = last4[:4]
+	if last4 == FakeCardUnavailable[12:] {
+		return Charge{}, fmt.Errorf("%w: fake gateway timeout", ErrUnavailable)
+	}
+
+	id, err := fakeID("ch_fake_")
+	if err != nil {
+		return Charge{}, err
+	}
+	charge := Charge{ID: id, Status: models.PaymentSucceeded}
+	if decline, ok := fakeOutcomes[last4]; ok {
+		charge.Status = models.PaymentFailed
+		charge.FailureCode, charge.FailureMessage = decline.code, decline.message
+	}
+	if req.IdempotencyKey != "" {
+		p.charges[req.IdempotencyKey] = charge
+	}
+	return charge, nil
+}
+
+// Refund implements PaymentProvider
+func (p *FakeProvider) Refund(ctx context.Context, req RefundRequest) (Refund, error) {
+	if !strings.HasPrefix(req.ChargeID, "ch_fake_") {
+		return Refund{}, fmt.Errorf("fake provider: unknown charge %q", req.ChargeID)
+	}
+	if !req.Amount.IsPositive() {
+		return Refund{}, fmt.Errorf("fake provider: amount must be positive, got %s", req.Amount)
+	}
+	id, err := fakeID("re_fake_")
+	if err != nil {
+		return Refund{}, err
+	}
+	return Refund{ID: id}, nil
+}
+
+// VerifyWebhook implements PaymentProvider. Calls are signed with
+// SignWebhook in the FakeSignatureHeader.
+func (p *FakeProvider) VerifyWebhook(header http.Header, body []byte) (WebhookEvent, error) {
+	expected := p.SignWebhook(body)
+	if !hmac.Equal([]byte(header.Get(FakeSignatureHeader)), []byte(expected)) {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return WebhookEvent{}, fmt.Errorf("decoding fake webhook: %w", err)
+	}
+	return event, nil
+}
+
+// SignWebhook returns the FakeSignatureHeader value of body, to simulate
+// provider calls during development
+func (p *FakeProvider) SignWebhook(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(p.WebhookSecret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// fakeID returns prefix followed by random hex
+func fakeID(prefix string) (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(buf), nil
+}
+
+// digitsOnly reports whether s is made of ASCII digits
+func digitsOnly(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII || !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// luhnValid checks the mod 10 check digit of a card number
+func luhnValid(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		digit := int(number[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// cardBrand guesses the brand of a card from its number. Elo and Hipercard
+// ranges are checked first since they overlap with Visa and Mastercard.
+func cardBrand(number string) string {
+	for _, prefix := range []string{"401178", "401179", "431274", "438935", "451416", "457393", "504175", "506699", "5067", "509", "627780", "636297", "636368", "650", "6516", "6550"} {
+		if strings.HasPrefix(number, prefix) {
+			return "elo"
+		}
+	}
+	switch {
+	case strings.HasPrefix(number, "606282") || strings.HasPrefix(number, "3841"):
+		return "hipercard"
+	case strings.HasPrefix(number, "4"):
+		return "visa"
+	case strings.HasPrefix(number, "34") || strings.HasPrefix(number, "37"):
+		return "amex"
+	case number[0] == '5' && number[1] >= '1' && number[1] <= '5',
+		number >= "2221" && number[:4] <= "2720":
+		return "mastercard"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,153 @@
+// Package payments charges consumers for the orders of their subscriptions.
+//
+// Gateways are hidden behind PaymentProvider so the rest of the application
+// never handles card data beyond passing it to the provider once: customers
+// and cards are stored at the provider and only referenced by ID. Charges run
+// as outbox jobs queued with every recurring order, and each attempt is kept
+// as a models.Payment. A fake provider runs entirely locally for development.
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/alexandreffaria/hoby-loop/config"
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// ErrInvalidCard is returned by TokenizeCard when the card is rejected
+var ErrInvalidCard = errors.New("invalid card")
+
+// ErrInvalidSignature is returned by VerifyWebhook when a call was not sent by the provider
+var ErrInvalidSignature = errors.New("invalid payment webhook signature")
+
+// ErrUnavailable is returned when the provider could not be reached or failed
+// to answer. The call may be retried with the same idempotency key.
+var ErrUnavailable = errors.New("payment provider unavailable")
+
+// Failure codes of declined charges
+const (
+	FailureCardDeclined         = "card_declined"
+	FailureInsufficientFunds    = "insufficient_funds"
+	FailureExpiredCard          = "expired_card"
+	FailureInvalidPaymentMethod = "invalid_payment_method"
+	FailureNoPaymentMethod      = "no_payment_method"
+	FailureBoletoOverdue        = "boleto_overdue" // The boleto expired unpaid
+)
+
+// Webhook event types sent by providers
+const (
+	EventChargeSucceeded = "charge.succeeded"
+	EventChargeFailed    = "charge.failed"
+	EventChargeRefunded  = "charge.refunded"
+)
+
+// Customer identifies the payer at the provider
+type Customer struct {
+	Name     string
+	Email    string
+	Document string // CPF or CNPJ
+}
+
+// Card holds the card details exchanged once for a token. They are never stored.
+type Card struct {
+	Number     string
+	HolderName string
+	ExpMonth   int
+	ExpYear    int
+	CVC        string
+}
+
+// CardToken is a card stored at the provider
+type CardToken struct {
+	Token string
+	Brand string // visa, mastercard, amex, elo, hipercard or unknown
+	Last4 string
+}
+
+// ChargeRequest asks the provider to charge a stored card. Requests with the
+// same IdempotencyKey are charged only once.
+type ChargeRequest struct {
+	CustomerID     string
+	Token          string
+	Amount         models.Money
+	Description    string
+	IdempotencyKey string
+}
+
+// Charge is the provider's answer to a ChargeRequest. Declines are reported
+// through Status and the failure fields, not as errors.
+type Charge struct {
+	ID             string
+	Status         models.PaymentStatus
+	FailureCode    string
+	FailureMessage string
+}
+
+// RefundRequest asks the provider to give back the amount of a charge
+type RefundRequest struct {
+	ChargeID       string
+	Amount         models.Money
+	IdempotencyKey string
+}
+
+// Refund is the provider's answer to a RefundRequest
+type Refund struct {
+	ID string
+}
+
+// WebhookEvent is a verified notification from the provider about a charge
+type WebhookEvent struct {
+	ID             string `json:"id"`
+	Type           string `json:"type"`
+	ChargeID       string `json:"charge_id"`
+	FailureCode    string `json:"failure_code,omitempty"`
+	FailureMessage string `json:"failure_message,omitempty"`
+}
+
+// PaymentProvider is a payment gateway
+type PaymentProvider interface {
+	// Name identifies the provider in stored payments
+	Name() string
+	// CreateCustomer registers a payer and returns its ID at the provider
+	CreateCustomer(ctx context.Context, customer Customer) (string, error)
+	// TokenizeCard stores a card for a customer. Rejected cards return ErrInvalidCard.
+	TokenizeCard(ctx context.Context, customerID string, card Card) (CardToken, error)
+	// Charge charges a stored card. Errors mean the outcome is unknown and
+	// the request should be retried with the same idempotency key.
+	Charge(ctx context.Context, req ChargeRequest) (Charge, error)
+	// Refund gives back the amount of a successful charge
+	Refund(ctx context.Context, req RefundRequest) (Refund, error)
+	// VerifyWebhook checks that a webhook call was sent by the provider and
+	// decodes its event, returning ErrInvalidSignature otherwise
+	VerifyWebhook(header http.Header, body []byte) (WebhookEvent, error)
+}
+
+// NewProvider creates the provider selected in the configuration, or nil
+// when payments are disabled
+func NewProvider(cfg config.PaymentsConfig) (PaymentProvider, error) {
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case "fake":
+		return NewFakeProvider(cfg.WebhookSecret), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", cfg.Provider)
+	}
+}
+
+// eventStatus returns the payment state a webhook event moves its charge to
+func eventStatus(eventType string) (models.PaymentStatus, bool) {
+	switch eventType {
+	case EventChargeSucceeded:
+		return models.PaymentSucceeded, true
+	case EventChargeFailed:
+		return models.PaymentFailed, true
+	case EventChargeRefunded:
+		return models.PaymentRefunded, true
+	default:
+		return "", false
+	}
+}
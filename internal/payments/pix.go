@@ -0,0 +1,238 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/alexandreffaria/hoby-loop/internal/pix"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// PixProvider is the Provider of Pix payments, which are paid by the
+// consumer from their bank instead of charged by a gateway
+const PixProvider = "pix"
+
+// ErrAlreadyPaid is returned when asking to pay an order that was paid
+var ErrAlreadyPaid = errors.New("the order is already paid")
+
+// ErrNotCharged is returned when asking to pay an order of a trial
+var ErrNotCharged = errors.New("trial orders are not charged")
+
+// Results of matching a Pix confirmation
+const (
+	PixMatched        = "matched"         // Paid a pending payment
+	PixDuplicate      = "duplicate"       // Already applied
+	PixUnmatched      = "unmatched"       // No payment has its txid
+	PixAmountMismatch = "amount_mismatch" // Paid a different amount, left pending
+	PixAlreadyPaid    = "already_paid"    // Paid a payment that was no longer pending, or an order paid otherwise
+)
+
+// PixReconciliation is the outcome of one Pix confirmation
+type PixReconciliation struct {
+	EndToEndID string `json:"end_to_end_id"`
+	TxID       string `json:"txid"`
+	Result     string `json:"result"` // One of the Pix results above
+	PaymentID  uint   `json:"payment_id,omitempty"`
+	OrderID    uint   `json:"order_id,omitempty"`
+}
+
+// PixEnabled reports whether a Pix account is configured
+func (s *Service) PixEnabled() bool {
+	return s.Pix != nil
+}
+
+// UsePix makes Pix the payment method of subscription: its orders get a
+// pending Pix payment for the consumer to pay instead of a card charge
+func (s *Service) UsePix(ctx context.Context, subscription *models.Subscription) error {
+	if !s.PixEnabled() {
+		return ErrDisabled
+	}
+	subscription.PaymentMethod = models.PaymentMethodPix
+	subscription.PaymentToken, subscription.CardBrand, subscription.CardLast4 = "", "", ""
+	return s.Subscriptions.SavePaymentMethod(ctx, subscription)
+}
+
+// PixCharge returns the pending Pix payment of an order and its "copia e
+// cola" code, creating the payment on the first call. Any order may be paid
+// with Pix, including those of card subscriptions whose charge failed.
+func (s *Service) PixCharge(ctx context.Context, orderID uint) (*models.Payment, string, error) {
+	if !s.PixEnabled() {
+		return nil, "", ErrDisabled
+	}
+
+	order, err := s.Orders.FindByID(ctx, orderID)
+	if err != nil {
+		return nil, "", err
+	}
+	if order.Subscription.Status == models.SubscriptionTrialing {
+		return nil, "", ErrNotCharged
+	}
+	previous, err := s.Payments.ListByOrder(ctx, order.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, payment := range previous {
+		if payment.Status == models.PaymentSucceeded || payment.Status == models.PaymentRefunded {
+			return nil, "", ErrAlreadyPaid
+		}
+	}
+
+	payment, err := s.pixPayment(ctx, order, previous)
+	if err != nil {
+		return nil, "", err
+	}
+	code, err := s.Pix.Code(payment.Amount, payment.ChargeID)
+	if err != nil {
+		return nil, "", err
+	}
+	return payment, code, nil
+}
+
+// pixPayment returns the pending Pix payment among previous, the payments of
+// order, or creates one. Its txid is derived from its ID, and set again
+// on a pending payment whose txid was not saved.
+func (s *Service) pixPayment(ctx context.Context, order *models.Order, previous []models.Payment) (*models.Payment, error) {
+	for i := range previous {
+		if previous[i].Method == models.PaymentMethodPix && previous[i].Status == models.PaymentPending {
+			payment := &previous[i]
+			if payment.ChargeID != "" {
+				return payment, nil
+			}
+			// Created, but the txid was not saved
+			payment.ChargeID = pixTxID(payment.ID)
+			return payment, s.Payments.Save(ctx, payment)
+		}
+	}
+
+	payment := &models.Payment{
+		OrderID:        order.ID,
+		SubscriptionID: order.Subscription.ID,
+		Provider:       PixProvider,
+		Method:         models.PaymentMethodPix,
+		Amount:         order.Subscription.Basket.Price,
+		Status:         models.PaymentPending,
+		Attempt:        len(previous) + 1,
+	}
+	if err := s.Payments.Create(ctx, payment); err != nil {
+		return nil, err
+	}
+	payment.ChargeID = pixTxID(payment.ID)
+	return payment, s.Payments.Save(ctx, payment)
+}
+
+// pixTxID returns the txid of the Pix payment with id, as long as allowed
+func pixTxID(id uint) string {
+	return fmt.Sprintf("HOBY%0*d", pix.MaxTxID-4, id)
+}
+
+// HandlePixNotification verifies a webhook call of the bank and reconciles
+// its confirmations
+func (s *Service) HandlePixNotification(ctx context.Context, header http.Header, body []byte) ([]PixReconciliation, error) {
+	if !s.PixEnabled() {
+		return nil, ErrDisabled
+	}
+	confirmations, err := s.Pix.VerifyNotification(header, body)
+	if err != nil {
+		return nil, err
+	}
+	return s.ReconcilePix(ctx, confirmations)
+}
+
+// ReconcilePix matches received Pix transfers to the pending payments with
+// their txid. A transfer pays its payment only for the exact amount; any
+// other outcome is logged and reported for follow-up. Confirmations may be
+// sent more than once.
+func (s *Service) ReconcilePix(ctx context.Context, confirmations []pix.Confirmation) ([]PixReconciliation, error) {
+	results := make([]PixReconciliation, 0, len(confirmations))
+	for _, confirmation := range confirmations {
+		result, err := s.reconcile(ctx, confirmation)
+		if err != nil {
+			return results, fmt.Errorf("pix %s: %w", confirmation.EndToEndID, err)
+		}
+		if result.Result != PixMatched && result.Result != PixDuplicate {
+			log.Printf("payments: pix %s with txid %q of %s: %s", confirmation.EndToEndID, confirmation.TxID, confirmation.Amount, result.Result)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// reconcile applies one confirmation
+func (s *Service) reconcile(ctx context.Context, confirmation pix.Confirmation) (PixReconciliation, error) {
+	result := PixReconciliation{EndToEndID: confirmation.EndToEndID, TxID: confirmation.TxID, Result: PixUnmatched}
+	if confirmation.EndToEndID == "" {
+		return result, fmt.Errorf("%w: missing endToEndId", pix.ErrInvalidNotification)
+	}
+	amount, err := confirmation.Money()
+	if err != nil {
+		return result, fmt.Errorf("%w: %v", pix.ErrInvalidNotification, err)
+	}
+	paidAt, err := confirmation.Time()
+	if err != nil {
+		paidAt = s.Now()
+	}
+
+	if confirmation.TxID == "" {
+		return result, nil // Paid a static code, nothing to match it with
+	}
+	payment, err := s.Payments.FindByCharge(ctx, PixProvider, confirmation.TxID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	result.PaymentID, result.OrderID = payment.ID, payment.OrderID
+
+	switch {
+	case payment.EndToEndID == confirmation.EndToEndID:
+		result.Result = PixDuplicate
+		return result, nil
+	case payment.Status != models.PaymentPending:
+		result.Result = PixAlreadyPaid
+		return result, nil
+	case amount != payment.Amount:
+		result.Result = PixAmountMismatch
+		return result, nil
+	}
+
+	// The payment only succeeds while it is still pending and no other
+	// payment of the order succeeded, so of two transfers for one txid the
+	// second is reported for refund
+	payment.PaidAt = &paidAt
+	payment.EndToEndID = confirmation.EndToEndID
+	succeeded, err := s.Payments.Settle(ctx, &repository.PaymentSettlement{
+		Payment: payment,
+		From:    []models.PaymentStatus{models.PaymentPending},
+		Event: models.OrderEvent{
+			Type: models.OrderEventPaid,
+			Note: fmt.Sprintf("Pix %s paid %s", confirmation.EndToEndID, amount.Format()),
+		},
+	})
+	if errors.Is(err, repository.ErrConflict) {
+		// Applied concurrently, by this transfer or another one
+		stored, findErr := s.Payments.FindByID(ctx, payment.ID)
+		if findErr != nil {
+			return result, findErr
+		}
+		result.Result = PixAlreadyPaid
+		if stored.EndToEndID == confirmation.EndToEndID {
+			result.Result = PixDuplicate
+		}
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	if !succeeded {
+		result.Result = PixAlreadyPaid
+		return result, nil
+	}
+	s.settled(ctx, payment)
+	result.Result = PixMatched
+	return result, nil
+}
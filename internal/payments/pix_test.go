@@ -0,0 +1,74 @@
+package payments
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+func TestPixPayment(t *testing.T) {
+	ctx := context.Background()
+	s, _, repos := newTestService(t)
+	_, created := newTestOrder(t, repos, models.SubscriptionActive)
+	order, err := repos.Orders.FindByID(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("FindByID: %v", err)
+	}
+
+	payment, err := s.pixPayment(ctx, order, nil)
+	if err != nil {
+		t.Fatalf("pixPayment: %v", err)
+	}
+	if payment.ChargeID != pixTxID(payment.ID) || payment.Status != models.PaymentPending || payment.Amount != models.BRL(12990) {
+		t.Fatalf("payment = %+v, want a pending payment of R$ 129,90 with its txid", payment)
+	}
+	found, err := repos.Payments.FindByCharge(ctx, PixProvider, payment.ChargeID)
+	if err != nil || found.ID != payment.ID {
+		t.Fatalf("FindByCharge(%s) = %+v, %v, want payment %d", payment.ChargeID, found, err, payment.ID)
+	}
+
+	previous, err := repos.Payments.ListByOrder(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("ListByOrder: %v", err)
+	}
+	again, err := s.pixPayment(ctx, order, previous)
+	if err != nil {
+		t.Fatalf("pixPayment again: %v", err)
+	}
+	if again.ID != payment.ID || again.ChargeID != payment.ChargeID {
+		t.Errorf("second pixPayment = %d %s, want the pending payment %d %s", again.ID, again.ChargeID, payment.ID, payment.ChargeID)
+	}
+}
+
+func TestPixPaymentRestoresMissingTxID(t *testing.T) {
+	ctx := context.Background()
+	s, _, repos := newTestService(t)
+	_, order := newTestOrder(t, repos, models.SubscriptionActive)
+
+	// A payment created by a request that failed before saving its txid
+	payment := models.Payment{
+		OrderID:        order.ID,
+		SubscriptionID: order.SubscriptionID,
+		Provider:       PixProvider,
+		Method:         models.PaymentMethodPix,
+		Amount:         models.BRL(12990),
+		Status:         models.PaymentPending,
+		Attempt:        1,
+	}
+	if err := repos.Payments.Create(ctx, &payment); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	got, err := s.pixPayment(ctx, order, []models.Payment{payment})
+	if err != nil {
+		t.Fatalf("pixPayment: %v", err)
+	}
+	if got.ID != payment.ID || got.ChargeID != pixTxID(payment.ID) {
+		t.Errorf("pixPayment = %d %q, want payment %d with txid %s", got.ID, got.ChargeID, payment.ID, pixTxID(payment.ID))
+	}
+	found, err := repos.Payments.FindByCharge(ctx, PixProvider, pixTxID(payment.ID))
+	if err != nil || found.ID != payment.ID {
+		t.Errorf("FindByCharge = %+v, %v, want payment %d reachable by its txid", found, err, payment.ID)
+	}
+}
@@ -0,0 +1,357 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/config"
+	"github.com/alexandreffaria/hoby-loop/internal/boleto"
+	"github.com/alexandreffaria/hoby-loop/internal/pix"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// ErrDisabled is returned when the payment provider, Pix account or boleto
+// agreement needed is not configured
+var ErrDisabled = errors.New("payments are disabled")
+
+// ErrNotRefundable is returned when refunding a payment that did not succeed
+var ErrNotRefundable = errors.New("only succeeded payments can be refunded")
+
+// Service charges orders through a provider, Pix or boletos and keeps track
+// of every attempt
+type Service struct {
+	Provider      PaymentProvider // Nil when card payments are disabled
+	Pix           *pix.Merchant   // Nil when Pix is disabled
+	Boleto        *boleto.Issuer  // Nil when boletos are disabled
+	Payments      repository.PaymentRepository
+	Boletos       repository.BoletoRepository
+	Orders        repository.OrderRepository
+	Subscriptions repository.SubscriptionRepository
+	Users         repository.UserRepository
+	Dunning       config.DunningConfig // Retry schedule of failed recurring charges
+	Now           func() time.Time
+}
+
+// NewService creates a Service charging cards through provider, receiving
+// Pix into merchant and issuing boletos through issuer, any of which may be
+// nil
+func NewService(provider PaymentProvider, merchant *pix.Merchant, issuer *boleto.Issuer, repos repository.Repositories) *Service {
+	return &Service{
+		Provider:      provider,
+		Pix:           merchant,
+		Boleto:        issuer,
+		Payments:      repos.Payments,
+		Boletos:       repos.Boletos,
+		Orders:        repos.Orders,
+		Subscriptions: repos.Subscriptions,
+		Users:         repos.Users,
+		Dunning:       config.GetDunningConfig(),
+		Now:           time.Now,
+	}
+}
+
+// Enabled reports whether orders can be charged, by card, Pix or boleto
+func (s *Service) Enabled() bool {
+	return s.Provider != nil || s.Pix != nil || s.Boleto != nil
+}
+
+// SaveCard stores card at the provider as the payment method of
+// subscription, registering the subscriber as a customer first if needed.
+// The subscription must have its User loaded.
+func (s *Service) SaveCard(ctx context.Context, subscription *models.Subscription, card Card) error {
+	if s.Provider == nil {
+		return ErrDisabled
+	}
+
+	user := subscription.User
+	if user.PaymentCustomerID == "" {
+		document := user.CPF
+		if document == "" {
+			document = user.CNPJ
+		}
+		customerID, err := s.Provider.CreateCustomer(ctx, Customer{Name: user.Name, Email: user.Email, Document: document})
+		if err != nil {
+			return fmt.Errorf("creating customer: %w", err)
+		}
+		user.PaymentCustomerID = customerID
+		if err := s.Users.Save(ctx, &user); err != nil {
+			return err
+		}
+		subscription.User = user
+	}
+
+	token, err := s.Provider.TokenizeCard(ctx, user.PaymentCustomerID, card)
+	if err != nil {
+		return err
+	}
+	subscription.PaymentMethod = models.PaymentMethodCard
+	subscription.PaymentToken = token.Token
+	subscription.CardBrand = token.Brand
+	subscription.CardLast4 = token.Last4
+	return s.Subscriptions.SavePaymentMethod(ctx, subscription)
+}
+
+// ChargeOrder charges the subscriber for an order and returns the payment.
+// Orders already paid are not charged again and a pending attempt, left by a
+// provider error, is retried with the same idempotency key. Declines are
+// recorded as failed payments; errors mean the attempt should be retried.
+// Trialing subscriptions are not charged and return a nil payment. Orders of
+// Pix and boleto subscriptions get a pending payment for the consumer to pay.
+func (s *Service) ChargeOrder(ctx context.Context, orderID uint) (*models.Payment, error) {
+	if !s.Enabled() {
+		return nil, ErrDisabled
+	}
+
+	order, err := s.Orders.FindByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	subscription := order.Subscription
+	if subscription.Status == models.SubscriptionTrialing {
+		return nil, nil
+	}
+
+	previous, err := s.Payments.ListByOrder(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	var payment *models.Payment
+	for i := range previous {
+		switch previous[i].Status {
+		case models.PaymentSucceeded, models.PaymentRefunded:
+			return &previous[i], nil
+		case models.PaymentPending:
+			if previous[i].Method != models.PaymentMethodPix && previous[i].Method != models.PaymentMethodBoleto {
+				payment = &previous[i]
+			}
+		}
+	}
+
+	if subscription.PaymentMethod == models.PaymentMethodPix {
+		if !s.PixEnabled() {
+			return nil, ErrDisabled
+		}
+		return s.pixPayment(ctx, order, previous)
+	}
+	if subscription.PaymentMethod == models.PaymentMethodBoleto {
+		if !s.BoletoEnabled() {
+			return nil, ErrDisabled
+		}
+		if subscription.User.CNPJ == "" {
+			return nil, ErrCNPJRequired
+		}
+		payment, _, err := s.boletoPayment(ctx, order, previous)
+		return payment, err
+	}
+	if s.Provider == nil {
+		return nil, ErrDisabled
+	}
+
+	if payment == nil {
+		payment = &models.Payment{
+			OrderID:        order.ID,
+			SubscriptionID: subscription.ID,
+			Provider:       s.Provider.Name(),
+			Method:         subscription.PaymentMethod,
+			Amount:         subscription.Basket.Price,
+			Status:         models.PaymentPending,
+			Attempt:        len(previous) + 1,
+		}
+		if err := s.Payments.Create(ctx, payment); err != nil {
+			return nil, err
+		}
+	}
+
+	if subscription.PaymentToken == "" {
+		s.apply(payment, models.PaymentFailed, FailureNoPaymentMethod, "The subscription has no payment method")
+		if err := s.Payments.Save(ctx, payment); err != nil {
+			return payment, err
+		}
+		s.settled(ctx, payment)
+		return payment, nil
+	}
+
+	charge, err := s.Provider.Charge(ctx, ChargeRequest{
+		CustomerID:     subscription.User.PaymentCustomerID,
+		Token:          subscription.PaymentToken,
+		Amount:         payment.Amount,
+		Description:    fmt.Sprintf("%s, order %d", subscription.Basket.Name, order.ID),
+		IdempotencyKey: fmt.Sprintf("payment-%d", payment.ID),
+	})
+	if err != nil {
+		return payment, err
+	}
+
+	payment.ChargeID = charge.ID
+	s.apply(payment, charge.Status, charge.FailureCode, charge.FailureMessage)
+	if payment.Status == models.PaymentFailed {
+		log.Printf("payments: order %d attempt %d declined: %s", order.ID, payment.Attempt, payment.FailureCode)
+	}
+	if err := s.Payments.Save(ctx, payment); err != nil {
+		return payment, err
+	}
+	s.settled(ctx, payment)
+	return payment, nil
+}
+
+// Refund gives back the full amount of a succeeded payment
+func (s *Service) Refund(ctx context.Context, payment *models.Payment) error {
+	if payment.Status != models.PaymentSucceeded {
+		return ErrNotRefundable
+	}
+	if payment.Provider == PixProvider {
+		return fmt.Errorf("%w: pix payments are returned from the bank", ErrNotRefundable)
+	}
+	if payment.Provider == BoletoProvider {
+		return fmt.Errorf("%w: boleto payments are returned from the bank", ErrNotRefundable)
+	}
+	if s.Provider == nil {
+		return ErrDisabled
+	}
+
+	_, err := s.Provider.Refund(ctx, RefundRequest{
+		ChargeID:       payment.ChargeID,
+		Amount:         payment.Amount,
+		IdempotencyKey: fmt.Sprintf("refund-%d", payment.ID),
+	})
+	if err != nil {
+		return err
+	}
+	s.apply(payment, models.PaymentRefunded, "", "")
+	return s.Payments.Save(ctx, payment)
+}
+
+// HandleWebhook verifies a provider call and applies its event to the
+// payment of the charge. A success moves a pending or failed payment, a
+// failure only a pending one and a refund only a succeeded one; other events,
+// including repeated ones and those about unknown charges, are ignored, so
+// providers may send them more than once. It returns the updated payment, or
+// nil when nothing changed.
+func (s *Service) HandleWebhook(ctx context.Context, header http.Header, body []byte) (*models.Payment, error) {
+	if s.Provider == nil {
+		return nil, ErrDisabled
+	}
+
+	event, err := s.Provider.VerifyWebhook(header, body)
+	if err != nil {
+		return nil, err
+	}
+	status, ok := eventStatus(event.Type)
+	if !ok {
+		return nil, nil
+	}
+
+	payment, err := s.Payments.FindByCharge(ctx, s.Provider.Name(), event.ChargeID)
+	if errors.Is(err, repository.ErrNotFound) {
+		log.Printf("payments: ignoring %s event %s for unknown charge %s", event.Type, event.ID, event.ChargeID)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch status {
+	case models.PaymentSucceeded:
+		return s.webhookPaid(ctx, payment, event)
+	case models.PaymentFailed:
+		return s.webhookTransition(ctx, payment, models.PaymentPending, status, event)
+	default:
+		return s.webhookTransition(ctx, payment, models.PaymentSucceeded, status, event)
+	}
+}
+
+// webhookPaid settles a pending or failed payment confirmed by the provider,
+// unless another payment of its order succeeded meanwhile
+func (s *Service) webhookPaid(ctx context.Context, payment *models.Payment, event WebhookEvent) (*models.Payment, error) {
+	from := []models.PaymentStatus{models.PaymentPending, models.PaymentFailed}
+	if !slices.Contains(from, payment.Status) {
+		return nil, nil
+	}
+
+	s.apply(payment, models.PaymentSucceeded, "", "")
+	succeeded, err := s.Payments.Settle(ctx, &repository.PaymentSettlement{
+		Payment: payment,
+		From:    from,
+		Event: models.OrderEvent{
+			Type: models.OrderEventPaid,
+			Note: fmt.Sprintf("Card charge %s paid %s", event.ChargeID, payment.Amount.Format()),
+		},
+	})
+	if errors.Is(err, repository.ErrConflict) {
+		return nil, nil // Applied concurrently
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !succeeded {
+		log.Printf("payments: charge %s of order %d succeeded but the order was already paid", event.ChargeID, payment.OrderID)
+		return nil, nil
+	}
+	s.settled(ctx, payment)
+	return payment, nil
+}
+
+// webhookTransition moves payment from from to status as the provider
+// reported. A failure only starts dunning while the order is unpaid.
+func (s *Service) webhookTransition(ctx context.Context, payment *models.Payment, from, status models.PaymentStatus, event WebhookEvent) (*models.Payment, error) {
+	if payment.Status != from {
+		return nil, nil
+	}
+
+	s.apply(payment, status, event.FailureCode, event.FailureMessage)
+	err := s.Payments.Transition(ctx, payment, from)
+	if errors.Is(err, repository.ErrConflict) {
+		return nil, nil // Applied concurrently
+	}
+	if err != nil {
+		return nil, err
+	}
+	if status != models.PaymentFailed {
+		return payment, nil
+	}
+
+	paid, err := s.orderPaid(ctx, payment.OrderID)
+	if err != nil {
+		return payment, err
+	}
+	if !paid {
+		s.settled(ctx, payment)
+	}
+	return payment, nil
+}
+
+// orderPaid reports whether a payment of the order succeeded, even if it was
+// refunded later
+func (s *Service) orderPaid(ctx context.Context, orderID uint) (bool, error) {
+	payments, err := s.Payments.ListByOrder(ctx, orderID)
+	if err != nil {
+		return false, err
+	}
+	for _, payment := range payments {
+		if payment.Status == models.PaymentSucceeded || payment.Status == models.PaymentRefunded {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// apply moves payment to status, recording when it was paid or refunded
+func (s *Service) apply(payment *models.Payment, status models.PaymentStatus, failureCode, failureMessage string) {
+	now := s.Now()
+	payment.Status = status
+	payment.FailureCode, payment.FailureMessage = "", ""
+	switch status {
+	case models.PaymentSucceeded:
+		payment.PaidAt = &now
+	case models.PaymentFailed:
+		payment.FailureCode, payment.FailureMessage = failureCode, failureMessage
+	case models.PaymentRefunded:
+		payment.RefundedAt = &now
+	}
+}
@@ -0,0 +1,222 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/config"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// testNow is the clock of test services
+var testNow = time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+// newTestService returns a Service charging through the fake provider and
+// storing everything in memory, retrying failed charges after one and three
+// days
+func newTestService(t *testing.T) (*Service, *FakeProvider, repository.Repositories) {
+	t.Helper()
+	repos := repository.NewMemoryRepositories()
+	provider := NewFakeProvider("secret")
+	s := NewService(provider, nil, nil, repos)
+	s.Dunning = config.DunningConfig{
+		RetrySchedule: []time.Duration{24 * time.Hour, 72 * time.Hour},
+		FinalAction:   config.DunningPause,
+	}
+	s.Now = func() time.Time { return testNow }
+	return s, provider, repos
+}
+
+// newTestOrder stores an order of a subscription in status, charged by card
+func newTestOrder(t *testing.T, repos repository.Repositories, status models.SubscriptionStatus) (*models.Subscription, *models.Order) {
+	t.Helper()
+	ctx := context.Background()
+	consumer := models.User{Name: "Maria", Email: "maria@example.com", Role: "consumer"}
+	if err := repos.Users.Create(ctx, &consumer); err != nil {
+		t.Fatalf("creating consumer: %v", err)
+	}
+	basket := models.Basket{UserID: consumer.ID, Name: "Cesta", Price: models.BRL(12990)}
+	if err := repos.Baskets.Create(ctx, &basket); err != nil {
+		t.Fatalf("creating basket: %v", err)
+	}
+	subscription := models.Subscription{
+		UserID:        consumer.ID,
+		BasketID:      basket.ID,
+		Frequency:     models.FrequencyMonthly,
+		Status:        status,
+		PaymentMethod: models.PaymentMethodCard,
+	}
+	if err := repos.Subscriptions.Create(ctx, &subscription, &models.SubscriptionEvent{Action: models.SubscriptionEventCreated}, nil); err != nil {
+		t.Fatalf("creating subscription: %v", err)
+	}
+	order := models.Order{SubscriptionID: subscription.ID, Status: models.OrderPreparing}
+	if err := repos.Orders.Create(ctx, &order, &models.OrderEvent{Type: models.OrderEventCreated}, nil); err != nil {
+		t.Fatalf("creating order: %v", err)
+	}
+	return &subscription, &order
+}
+
+// newTestPayment stores a card payment of order in status
+func newTestPayment(t *testing.T, repos repository.Repositories, order *models.Order, status models.PaymentStatus) *models.Payment {
+	t.Helper()
+	payment := models.Payment{
+		OrderID:        order.ID,
+		SubscriptionID: order.SubscriptionID,
+		Provider:       "fake",
+		Method:         models.PaymentMethodCard,
+		ChargeID:       "ch_fake_" + string(status),
+		Amount:         models.BRL(12990),
+		Status:         status,
+		Attempt:        1,
+	}
+	if err := repos.Payments.Create(context.Background(), &payment); err != nil {
+		t.Fatalf("creating payment: %v", err)
+	}
+	return &payment
+}
+
+// sendWebhook delivers a fake provider event about chargeID to s
+func sendWebhook(t *testing.T, s *Service, provider *FakeProvider, eventType, chargeID string) (*models.Payment, error) {
+	t.Helper()
+	body, err := json.Marshal(WebhookEvent{ID: "evt_1", Type: eventType, ChargeID: chargeID, FailureCode: FailureCardDeclined})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := http.Header{}
+	header.Set(FakeSignatureHeader, provider.SignWebhook(body))
+	return s.HandleWebhook(context.Background(), header, body)
+}
+
+// paidEvents counts the paid events of the timeline of order
+func paidEvents(t *testing.T, repos repository.Repositories, orderID uint) int {
+	t.Helper()
+	events, err := repos.Orders.ListEvents(context.Background(), orderID)
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	count := 0
+	for _, event := range events {
+		if event.Type == models.OrderEventPaid {
+			count++
+		}
+	}
+	return count
+}
+
+func TestHandleWebhookTransitions(t *testing.T) {
+	tests := []struct {
+		name         string
+		from         models.PaymentStatus
+		event        string
+		want         models.PaymentStatus
+		changed      bool
+		wantPaid     int
+		subscription models.SubscriptionStatus
+	}{
+		{"success of pending", models.PaymentPending, EventChargeSucceeded, models.PaymentSucceeded, true, 1, models.SubscriptionActive},
+		{"success of failed", models.PaymentFailed, EventChargeSucceeded, models.PaymentSucceeded, true, 1, models.SubscriptionActive},
+		{"success of succeeded", models.PaymentSucceeded, EventChargeSucceeded, models.PaymentSucceeded, false, 0, models.SubscriptionActive},
+		{"success of refunded", models.PaymentRefunded, EventChargeSucceeded, models.PaymentRefunded, false, 0, models.SubscriptionActive},
+		{"failure of pending", models.PaymentPending, EventChargeFailed, models.PaymentFailed, true, 0, models.SubscriptionPastDue},
+		{"failure of failed", models.PaymentFailed, EventChargeFailed, models.PaymentFailed, false, 0, models.SubscriptionActive},
+		{"failure of succeeded", models.PaymentSucceeded, EventChargeFailed, models.PaymentSucceeded, false, 0, models.SubscriptionActive},
+		{"failure of refunded", models.PaymentRefunded, EventChargeFailed, models.PaymentRefunded, false, 0, models.SubscriptionActive},
+		{"refund of succeeded", models.PaymentSucceeded, EventChargeRefunded, models.PaymentRefunded, true, 0, models.SubscriptionActive},
+		{"refund of pending", models.PaymentPending, EventChargeRefunded, models.PaymentPending, false, 0, models.SubscriptionActive},
+		{"refund of failed", models.PaymentFailed, EventChargeRefunded, models.PaymentFailed, false, 0, models.SubscriptionActive},
+		{"refund of refunded", models.PaymentRefunded, EventChargeRefunded, models.PaymentRefunded, false, 0, models.SubscriptionActive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, provider, repos := newTestService(t)
+			subscription, order := newTestOrder(t, repos, models.SubscriptionActive)
+			payment := newTestPayment(t, repos, order, tt.from)
+
+			got, err := sendWebhook(t, s, provider, tt.event, payment.ChargeID)
+			if err != nil {
+				t.Fatalf("HandleWebhook: %v", err)
+			}
+			if changed := got != nil; changed != tt.changed {
+				t.Errorf("HandleWebhook changed = %v, want %v", changed, tt.changed)
+			}
+
+			stored, err := repos.Payments.FindByID(context.Background(), payment.ID)
+			if err != nil {
+				t.Fatalf("FindByID: %v", err)
+			}
+			if stored.Status != tt.want {
+				t.Errorf("payment status = %s, want %s", stored.Status, tt.want)
+			}
+			if paid := paidEvents(t, repos, order.ID); paid != tt.wantPaid {
+				t.Errorf("paid events = %d, want %d", paid, tt.wantPaid)
+			}
+			sub, err := repos.Subscriptions.FindByID(context.Background(), subscription.ID)
+			if err != nil {
+				t.Fatalf("FindByID: %v", err)
+			}
+			if sub.Status != tt.subscription {
+				t.Errorf("subscription status = %s, want %s", sub.Status, tt.subscription)
+			}
+		})
+	}
+}
+
+func TestHandleWebhookOrderAlreadyPaid(t *testing.T) {
+	tests := []struct {
+		name  string
+		event string
+		from  models.PaymentStatus
+		want  models.PaymentStatus
+	}{
+		{"success", EventChargeSucceeded, models.PaymentFailed, models.PaymentFailed},
+		{"failure", EventChargeFailed, models.PaymentPending, models.PaymentFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, provider, repos := newTestService(t)
+			subscription, order := newTestOrder(t, repos, models.SubscriptionActive)
+			paid := newTestPayment(t, repos, order, models.PaymentSucceeded)
+			paid.ChargeID = "ch_fake_paid"
+			if err := repos.Payments.Save(context.Background(), paid); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+			payment := newTestPayment(t, repos, order, tt.from)
+
+			if _, err := sendWebhook(t, s, provider, tt.event, payment.ChargeID); err != nil {
+				t.Fatalf("HandleWebhook: %v", err)
+			}
+
+			stored, err := repos.Payments.FindByID(context.Background(), payment.ID)
+			if err != nil {
+				t.Fatalf("FindByID: %v", err)
+			}
+			if stored.Status != tt.want {
+				t.Errorf("payment status = %s, want %s", stored.Status, tt.want)
+			}
+			if n := paidEvents(t, repos, order.ID); n != 0 {
+				t.Errorf("paid events = %d, want 0", n)
+			}
+			sub, err := repos.Subscriptions.FindByID(context.Background(), subscription.ID)
+			if err != nil {
+				t.Fatalf("FindByID: %v", err)
+			}
+			if sub.Status != models.SubscriptionActive {
+				t.Errorf("subscription status = %s, want active", sub.Status)
+			}
+		})
+	}
+}
+
+func TestHandleWebhookRejectsBadSignature(t *testing.T) {
+	s, _, _ := newTestService(t)
+	header := http.Header{}
+	header.Set(FakeSignatureHeader, "00")
+	if _, err := s.HandleWebhook(context.Background(), header, []byte(`{}`)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("HandleWebhook error = %v, want ErrInvalidSignature", err)
+	}
+}
@@ -0,0 +1,262 @@
+// Package pix builds and reads Pix "copia e cola" payloads, the BR Code
+// variant of the EMV merchant presented QR code defined by the Banco Central
+// do Brasil, and verifies the payment confirmations sent by the receiving
+// bank.
+//
+// A payload is a list of ID, length, value fields ending with a CRC16. It is
+// shown as text for the payer to paste in their bank app or rendered as a QR
+// code with the qrcode package.
+package pix
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// ErrInvalidPayload is returned for payloads that break the BR Code rules
+var ErrInvalidPayload = errors.New("invalid pix payload")
+
+// gui identifies Pix inside the merchant account information field
+const gui = "br.gov.bcb.pix"
+
+// noTxID is the transaction ID of payloads that do not carry one
+const noTxID = "***"
+
+// Field IDs of the payload
+const (
+	idFormatIndicator  = "00"
+	idInitiationMethod = "01"
+	idMerchantAccount  = "26"
+	idCategoryCode     = "52"
+	idCurrency         = "53"
+	idAmount           = "54"
+	idCountryCode      = "58"
+	idMerchantName     = "59"
+	idMerchantCity     = "60"
+	idAdditionalData   = "62"
+	idCRC              = "63"
+
+	// Inside the merchant account information
+	idGUI         = "00"
+	idKey         = "01"
+	idDescription = "02"
+	idLocation    = "25"
+
+	// Inside the additional data
+	idTxID = "05"
+)
+
+// Point of initiation methods
+const (
+	initiationStatic  = "11" // May be paid many times
+	initiationDynamic = "12" // Paid once
+)
+
+// Field length limits
+const (
+	MaxMerchantName = 25
+	MaxMerchantCity = 15
+	MaxTxID         = 25
+)
+
+// Payload is a Pix charge.
+//
+// Static payloads carry the receiver's key and may be paid any number of
+// times, with or without a fixed amount. Dynamic payloads are paid once and
+// carry a TxID the confirmation is matched by; they reference the charge
+// either with the key or with the Location URL of a charge hosted by the
+// receiving bank.
+type Payload struct {
+	Dynamic      bool
+	Key          string // Pix key: CPF, CNPJ, email, phone or random key
+	Location     string // URL of the charge at the bank, without https://, instead of Key
+	Description  string // Shown to the payer by some banks
+	Amount       models.Money
+	MerchantName string
+	MerchantCity string
+	TxID         string // Letters and digits, up to MaxTxID; "***" or empty when not set
+}
+
+// Encode returns the "copia e cola" text of p, CRC included
+func (p Payload) Encode() (string, error) {
+	if err := p.validate(); err != nil {
+		return "", err
+	}
+
+	account := field(idGUI, gui)
+	if p.Location != "" {
+		account += field(idLocation, p.Location)
+	} else {
+		account += field(idKey, p.Key)
+	}
+	if p.Description != "" {
+		account += field(idDescription, p.Description)
+	}
+
+	txID := p.TxID
+	if txID == "" {
+		txID = noTxID
+	}
+
+	var b strings.Builder
+	b.WriteString(field(idFormatIndicator, "01"))
+	if p.Dynamic {
+		b.WriteString(field(idInitiationMethod, initiationDynamic))
+	} else {
+		b.WriteString(field(idInitiationMethod, initiationStatic))
+	}
+	b.WriteString(field(idMerchantAccount, account))
+	b.WriteString(field(idCategoryCode, "0000"))
+	b.WriteString(field(idCurrency, "986")) // BRL
+	if !p.Amount.IsZero() {
+		b.WriteString(field(idAmount, p.Amount.Decimal()))
+	}
+	b.WriteString(field(idCountryCode, "BR"))
+	b.WriteString(field(idMerchantName, p.MerchantName))
+	b.WriteString(field(idMerchantCity, p.MerchantCity))
+	b.WriteString(field(idAdditionalData, field(idTxID, txID)))
+	b.WriteString(idCRC + "04")
+	b.WriteString(fmt.Sprintf("%04X", CRC16(b.String())))
+	return b.String(), nil
+}
+
+// Parse reads a "copia e cola" payload, checking its CRC and required fields
+func Parse(code string) (Payload, error) {
+	code = strings.TrimSpace(code)
+	if len(code) < 8 || code[len(code)-8:len(code)-4] != idCRC+"04" {
+		return Payload{}, fmt.Errorf("%w: missing CRC", ErrInvalidPayload)
+	}
+	crc, err := strconv.ParseUint(code[len(code)-4:], 16, 16)
+	if err != nil || uint16(crc) != CRC16(code[:len(code)-4]) {
+		return Payload{}, fmt.Errorf("%w: CRC does not match", ErrInvalidPayload)
+	}
+
+	fields, err := parseFields(code[:len(code)-8])
+	if err != nil {
+		return Payload{}, err
+	}
+	if fields[idFormatIndicator] != "01" {
+		return Payload{}, fmt.Errorf("%w: unknown payload format", ErrInvalidPayload)
+	}
+	if currency := fields[idCurrency]; currency != "986" {
+		return Payload{}, fmt.Errorf("%w: currency %q is not BRL", ErrInvalidPayload, currency)
+	}
+
+	account, err := parseFields(fields[idMerchantAccount])
+	if err != nil {
+		return Payload{}, err
+	}
+	if !strings.EqualFold(account[idGUI], gui) {
+		return Payload{}, fmt.Errorf("%w: not a Pix payload", ErrInvalidPayload)
+	}
+
+	p := Payload{
+		Dynamic:      fields[idInitiationMethod] == initiationDynamic,
+		Key:          account[idKey],
+		Location:     account[idLocation],
+		Description:  account[idDescription],
+		MerchantName: fields[idMerchantName],
+		MerchantCity: fields[idMerchantCity],
+	}
+	if amount := fields[idAmount]; amount != "" {
+		if p.Amount, err = models.ParseMoney(amount, "BRL"); err != nil {
+			return Payload{}, fmt.Errorf("%w: amount %q", ErrInvalidPayload, amount)
+		}
+	}
+	if additional, ok := fields[idAdditionalData]; ok {
+		data, err := parseFields(additional)
+		if err != nil {
+			return Payload{}, err
+		}
+		if txID := data[idTxID]; txID != noTxID {
+			p.TxID = txID
+		}
+	}
+	if err := p.validate(); err != nil {
+		return Payload{}, err
+	}
+	return p, nil
+}
+
+// validate checks the fields of p against the BR Code limits
+func (p Payload) validate() error {
+	switch {
+	case p.Key == "" && p.Location == "":
+		return fmt.Errorf("%w: a key or location is required", ErrInvalidPayload)
+	case p.Key != "" && p.Location != "":
+		return fmt.Errorf("%w: key and location are exclusive", ErrInvalidPayload)
+	case p.Location != "" && !p.Dynamic:
+		return fmt.Errorf("%w: only dynamic payloads have a location", ErrInvalidPayload)
+	case p.MerchantName == "" || len(p.MerchantName) > MaxMerchantName:
+		return fmt.Errorf("%w: merchant name must have 1 to %d characters", ErrInvalidPayload, MaxMerchantName)
+	case p.MerchantCity == "" || len(p.MerchantCity) > MaxMerchantCity:
+		return fmt.Errorf("%w: merchant city must have 1 to %d characters", ErrInvalidPayload, MaxMerchantCity)
+	case p.Amount.Amount < 0 || (!p.Amount.IsZero() && p.Amount.Currency != "BRL"):
+		return fmt.Errorf("%w: amount must be a positive BRL value", ErrInvalidPayload)
+	case p.Dynamic && (p.TxID == "" || p.TxID == noTxID):
+		return fmt.Errorf("%w: dynamic payloads need a transaction ID", ErrInvalidPayload)
+	}
+	if p.TxID != noTxID && !validTxID(p.TxID) {
+		return fmt.Errorf("%w: transaction ID must have up to %d letters and digits", ErrInvalidPayload, MaxTxID)
+	}
+	if len(field(idGUI, gui)+field(idKey, p.Key+p.Location)+field(idDescription, p.Description)) > 99 {
+		return fmt.Errorf("%w: key and description are too long", ErrInvalidPayload)
+	}
+	return nil
+}
+
+// validTxID reports whether id is made of up to MaxTxID ASCII letters and digits
+func validTxID(id string) bool {
+	if len(id) > MaxTxID {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// field encodes one ID, length, value field
+func field(id, value string) string {
+	return fmt.Sprintf("%s%02d%s", id, len(value), value)
+}
+
+// parseFields splits a list of ID, length, value fields
+func parseFields(s string) (map[string]string, error) {
+	fields := map[string]string{}
+	for len(s) > 0 {
+		if len(s) < 4 {
+			return nil, fmt.Errorf("%w: truncated field", ErrInvalidPayload)
+		}
+		length, err := strconv.Atoi(s[2:4])
+		if err != nil || len(s) < 4+length {
+			return nil, fmt.Errorf("%w: bad length of field %s", ErrInvalidPayload, s[:2])
+		}
+		fields[s[:2]] = s[4 : 4+length]
+		s = s[4+length:]
+	}
+	return fields, nil
+}
+
+// CRC16 returns the CRC-16/CCITT-FALSE of s (polynomial 0x1021, initial
+// value 0xFFFF), the checksum closing every payload
+func CRC16(s string) uint16 {
+	crc := uint16(0xFFFF)
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,151 @@
+package pix
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// bcbExample is the static payload of the Pix BR Code manual of the Banco
+// Central do Brasil, closed by its published CRC
+const bcbExample = "00020126580014br.gov.bcb.pix0136123e4567-e12b-12d1-a456-426655440000" +
+	"5204000053039865802BR5913Fulano de Tal6008BRASILIA62070503***63041D3D"
+
+func TestCRC16(t *testing.T) {
+	tests := []struct {
+		input string
+		want  uint16
+	}{
+		{"", 0xFFFF},
+		{"123456789", 0x29B1}, // CRC-16/CCITT-FALSE check value
+		{bcbExample[:len(bcbExample)-4], 0x1D3D},
+	}
+	for _, tt := range tests {
+		if got := CRC16(tt.input); got != tt.want {
+			t.Errorf("CRC16(%q) = %04X, want %04X", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseBCBExample(t *testing.T) {
+	p, err := Parse(bcbExample)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := Payload{
+		Key:          "123e4567-e12b-12d1-a456-426655440000",
+		MerchantName: "Fulano de Tal",
+		MerchantCity: "BRASILIA",
+	}
+	if p != want {
+		t.Errorf("Parse = %+v, want %+v", p, want)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	brl := func(amount int64) models.Money { return models.Money{Amount: amount, Currency: "BRL"} }
+	tests := []struct {
+		name    string
+		payload Payload
+		want    string // Without the CRC value
+	}{
+		{
+			name:    "static without amount",
+			payload: Payload{Key: "123e4567-e12b-12d1-a456-426655440000", MerchantName: "Fulano de Tal", MerchantCity: "BRASILIA"},
+			want: "000201010211" + "26580014br.gov.bcb.pix0136123e4567-e12b-12d1-a456-426655440000" +
+				"52040000" + "5303986" + "5802BR" + "5913Fulano de Tal" + "6008BRASILIA" + "62070503***" + "6304",
+		},
+		{
+			name:    "static with amount and description",
+			payload: Payload{Key: "loja@example.com", Description: "Cesta", Amount: brl(12990), MerchantName: "Loja", MerchantCity: "SAO PAULO"},
+			want: "000201010211" + "26470014br.gov.bcb.pix0116loja@example.com0205Cesta" +
+				"52040000" + "5303986" + "5406129.90" + "5802BR" + "5904Loja" + "6009SAO PAULO" + "62070503***" + "6304",
+		},
+		{
+			name:    "dynamic with location",
+			payload: Payload{Dynamic: true, Location: "pix.example.com/qr/v2/9d36b84f", Amount: brl(5), MerchantName: "Loja", MerchantCity: "RIO", TxID: "ORDER42"},
+			want: "000201010212" + "26520014br.gov.bcb.pix2530pix.example.com/qr/v2/9d36b84f" +
+				"52040000" + "5303986" + "54040.05" + "5802BR" + "5904Loja" + "6003RIO" + "62110507ORDER42" + "6304",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := tt.payload.Encode()
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			body, crc := code[:len(code)-4], code[len(code)-4:]
+			if body != tt.want {
+				t.Errorf("Encode = %s, want %s", body, tt.want)
+			}
+			if want := fmt.Sprintf("%04X", CRC16(body)); crc != want {
+				t.Errorf("CRC = %s, want %s", crc, want)
+			}
+
+			parsed, err := Parse(code)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if parsed != tt.payload {
+				t.Errorf("Parse = %+v, want %+v", parsed, tt.payload)
+			}
+		})
+	}
+}
+
+func TestEncodeRejects(t *testing.T) {
+	valid := Payload{Key: "loja@example.com", MerchantName: "Loja", MerchantCity: "RIO"}
+	tests := []struct {
+		name   string
+		change func(p *Payload)
+	}{
+		{"no key", func(p *Payload) { p.Key = "" }},
+		{"key and location", func(p *Payload) { p.Dynamic, p.TxID, p.Location = true, "A1", "pix.example.com/1" }},
+		{"static location", func(p *Payload) { p.Key, p.Location = "", "pix.example.com/1" }},
+		{"long name", func(p *Payload) { p.MerchantName = strings.Repeat("a", MaxMerchantName+1) }},
+		{"long city", func(p *Payload) { p.MerchantCity = strings.Repeat("a", MaxMerchantCity+1) }},
+		{"negative amount", func(p *Payload) { p.Amount = models.Money{Amount: -1, Currency: "BRL"} }},
+		{"other currency", func(p *Payload) { p.Amount = models.Money{Amount: 100, Currency: "USD"} }},
+		{"dynamic without txid", func(p *Payload) { p.Dynamic = true }},
+		{"txid with symbols", func(p *Payload) { p.TxID = "order-42" }},
+		{"long txid", func(p *Payload) { p.TxID = strings.Repeat("a", MaxTxID+1) }},
+		{"long account", func(p *Payload) { p.Description = strings.Repeat("a", 70) }},
+	}
+	for _, tt := range tests {
+		p := valid
+		tt.change(&p)
+		if _, err := p.Encode(); !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("%s: Encode error = %v, want ErrInvalidPayload", tt.name, err)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+	}{
+		{"empty", ""},
+		{"no CRC", bcbExample[:len(bcbExample)-8]},
+		{"wrong CRC", bcbExample[:len(bcbExample)-4] + "1D3E"},
+		{"changed name", strings.Replace(bcbExample, "Fulano", "Fulana", 1)},
+		{"other currency", withCRC("00020126580014br.gov.bcb.pix0136123e4567-e12b-12d1-a456-426655440000" +
+			"5204000053038405802BR5913Fulano de Tal6008BRASILIA62070503***6304")},
+		{"not pix", withCRC("00020126580014br.gov.bcb.xyz0136123e4567-e12b-12d1-a456-426655440000" +
+			"5204000053039865802BR5913Fulano de Tal6008BRASILIA62070503***6304")},
+		{"truncated field", withCRC("0002012658")},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(tt.code); !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("%s: Parse error = %v, want ErrInvalidPayload", tt.name, err)
+		}
+	}
+}
+
+// withCRC closes body, which ends with the CRC field header, with its CRC
+func withCRC(body string) string {
+	return body + fmt.Sprintf("%04X", CRC16(body))
+}
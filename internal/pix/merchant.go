@@ -0,0 +1,46 @@
+package pix
+
+import (
+	"github.com/alexandreffaria/hoby-loop/config"
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// Merchant is the receiver of the Pix charges of the platform
+type Merchant struct {
+	Key           string
+	Name          string
+	City          string
+	WebhookSecret string // Verifies the confirmations sent by the bank
+}
+
+// NewMerchant creates the merchant of the configuration, or nil when Pix is
+// disabled
+func NewMerchant(cfg config.PixConfig) *Merchant {
+	if cfg.Key == "" {
+		return nil
+	}
+	return &Merchant{Key: cfg.Key, Name: cfg.MerchantName, City: cfg.MerchantCity, WebhookSecret: cfg.WebhookSecret}
+}
+
+// Code returns the dynamic payload charging amount under txID
+func (m *Merchant) Code(amount models.Money, txID string) (string, error) {
+	return Payload{
+		Dynamic:      true,
+		Key:          m.Key,
+		Amount:       amount,
+		MerchantName: m.Name,
+		MerchantCity: m.City,
+		TxID:         txID,
+	}.Encode()
+}
+
+// StaticCode returns a payload that may be paid any number of times, for a
+// fixed amount or, when amount is zero, any amount the payer chooses
+func (m *Merchant) StaticCode(amount models.Money) (string, error) {
+	return Payload{
+		Key:          m.Key,
+		Amount:       amount,
+		MerchantName: m.Name,
+		MerchantCity: m.City,
+	}.Encode()
+}
@@ -0,0 +1,70 @@
+package pix
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// ErrInvalidSignature is returned for notifications not sent by the bank
+var ErrInvalidSignature = errors.New("invalid pix notification signature")
+
+// ErrInvalidNotification is returned for notifications that cannot be read
+var ErrInvalidNotification = errors.New("invalid pix notification")
+
+// SignatureHeader carries the hex HMAC-SHA256 of the body of notifications
+const SignatureHeader = "X-Pix-Signature"
+
+// Confirmation is a Pix received by the merchant, in the format of the
+// Banco Central's Pix API webhooks
+type Confirmation struct {
+	EndToEndID string `json:"endToEndId"` // Unique ID of the transfer
+	TxID       string `json:"txid"`       // Transaction ID of the paid payload, empty for static ones
+	Amount     string `json:"valor"`      // Decimal amount in reais, e.g. "110.00"
+	PaidAt     string `json:"horario"`    // RFC 3339 time of the transfer
+	PayerInfo  string `json:"infoPagador,omitempty"`
+}
+
+// Notification is the body of a webhook call from the receiving bank
+type Notification struct {
+	Pix []Confirmation `json:"pix"`
+}
+
+// Money returns the amount of c
+func (c Confirmation) Money() (models.Money, error) {
+	return models.ParseMoney(c.Amount, "BRL")
+}
+
+// Time returns when c was paid
+func (c Confirmation) Time() (time.Time, error) {
+	return time.Parse(time.RFC3339, c.PaidAt)
+}
+
+// VerifyNotification checks the SignatureHeader of a webhook call and
+// decodes its confirmations
+func (m *Merchant) VerifyNotification(header http.Header, body []byte) ([]Confirmation, error) {
+	if !hmac.Equal([]byte(header.Get(SignatureHeader)), []byte(m.Sign(body))) {
+		return nil, ErrInvalidSignature
+	}
+
+	var notification Notification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidNotification, err)
+	}
+	return notification.Pix, nil
+}
+
+// Sign returns the SignatureHeader value of body, to simulate notifications
+// during development
+func (m *Merchant) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(m.WebhookSecret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
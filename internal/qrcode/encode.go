@@ -0,0 +1,182 @@
+package qrcode
+
+// blockLayout describes how the codewords of a version are split into
+// Reed-Solomon blocks at error correction level M
+type blockLayout struct {
+	ecPerBlock  int // Error correction codewords of every block
+	shortBlocks int // Blocks holding shortData data codewords
+	shortData   int
+	longBlocks  int // Blocks holding one more data codeword
+}
+
+// layoutsM lists the blocks of each version at level M, from table 9 of
+// ISO/IEC 18004, indexed by version
+var layoutsM = [41]blockLayout{
+	{},
+	{10, 1, 16, 0}, {16, 1, 28, 0}, {26, 1, 44, 0}, {18, 2, 32, 0}, {24, 2, 43, 0},
+	{16, 4, 27, 0}, {18, 4, 31, 0}, {22, 2, 38, 2}, {22, 3, 36, 2}, {26, 4, 43, 1},
+	{30, 1, 50, 4}, {22, 6, 36, 2}, {22, 8, 37, 1}, {24, 4, 40, 5}, {24, 5, 41, 5},
+	{28, 7, 45, 3}, {28, 10, 46, 1}, {26, 9, 43, 4}, {26, 3, 44, 11}, {26, 3, 41, 13},
+	{26, 17, 42, 0}, {28, 17, 46, 0}, {28, 4, 47, 14}, {28, 6, 45, 14}, {28, 8, 47, 13},
+	{28, 19, 46, 4}, {28, 22, 45, 3}, {28, 3, 45, 23}, {28, 21, 45, 7}, {28, 19, 47, 10},
+	{28, 2, 46, 29}, {28, 10, 46, 23}, {28, 14, 46, 21}, {28, 14, 46, 23}, {28, 12, 47, 26},
+	{28, 6, 47, 34}, {28, 29, 46, 14}, {28, 13, 46, 32}, {28, 40, 47, 7}, {28, 18, 47, 31},
+}
+
+// dataCodewords returns how many data codewords a version holds at level M
+func dataCodewords(version int) int {
+	l := layoutsM[version]
+	return l.shortBlocks*l.shortData + l.longBlocks*(l.shortData+1)
+}
+
+// rawModules returns how many modules of a version hold codewords, remainder
+// bits included
+func rawModules(version int) int {
+	modules := (16*version+128)*version + 64
+	if version >= 2 {
+		alignments := version/7 + 2
+		modules -= (25*alignments-10)*alignments - 55
+		if version >= 7 {
+			modules -= 36
+		}
+	}
+	return modules
+}
+
+// alignmentPositions returns the row and column centers of the alignment
+// patterns of a version
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	count := version/7 + 2
+	step := (version*8 + count*3 + 5) / (count*4 - 4) * 2
+	positions := make([]int, count)
+	positions[0] = 6
+	for i, pos := count-1, 4*version+10; i >= 1; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+	return positions
+}
+
+// charCountBits returns the length of the byte mode character count
+func charCountBits(version int) int {
+	if version <= 9 {
+		return 8
+	}
+	return 16
+}
+
+// encodeData builds the data codewords of version: the byte mode header,
+// data, terminator and padding
+func encodeData(version int, data []byte) []byte {
+	var w bitWriter
+	w.write(0b0100, 4) // Byte mode
+	w.write(len(data), charCountBits(version))
+	for _, b := range data {
+		w.write(int(b), 8)
+	}
+
+	capacity := dataCodewords(version) * 8
+	w.write(0, min(4, capacity-w.n))
+	w.write(0, (8-w.n%8)%8)
+	for pad := 0xEC; w.n < capacity; pad ^= 0xEC ^ 0x11 {
+		w.write(pad, 8)
+	}
+	return w.bytes
+}
+
+// addErrorCorrection splits data into blocks, appends their error correction
+// codewords and interleaves the result
+func addErrorCorrection(version int, data []byte) []byte {
+	l := layoutsM[version]
+	divisor := rsDivisor(l.ecPerBlock)
+
+	var blocks, ecBlocks [][]byte
+	for i, offset := 0, 0; i < l.shortBlocks+l.longBlocks; i++ {
+		size := l.shortData
+		if i >= l.shortBlocks {
+			size++
+		}
+		block := data[offset : offset+size]
+		offset += size
+		blocks = append(blocks, block)
+		ecBlocks = append(ecBlocks, rsRemainder(block, divisor))
+	}
+
+	result := make([]byte, 0, rawModules(version)/8)
+	for i := 0; i <= l.shortData; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < l.ecPerBlock; i++ {
+		for _, ec := range ecBlocks {
+			result = append(result, ec[i])
+		}
+	}
+	return result
+}
+
+// rsDivisor returns the Reed-Solomon generator polynomial of degree,
+// highest coefficient first and without the leading 1
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder returns the error correction codewords of data
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coefficient := range divisor {
+			result[i] ^= gfMultiply(coefficient, factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+func gfMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+// bitWriter appends bits to a byte slice, most significant first
+type bitWriter struct {
+	bytes []byte
+	n     int // Bits written
+}
+
+// write appends the low length bits of value
+func (w *bitWriter) write(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.bytes = append(w.bytes, 0)
+		}
+		if value>>i&1 == 1 {
+			w.bytes[len(w.bytes)-1] |= 0x80 >> (w.n % 8)
+		}
+		w.n++
+	}
+}
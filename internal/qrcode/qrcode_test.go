@@ -0,0 +1,283 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestEncodeChoosesSmallestVersion(t *testing.T) {
+	tests := []struct {
+		length  int
+		version int
+	}{
+		{0, 1},
+		{14, 1}, // Byte mode capacity of 1-M
+		{15, 2},
+		{26, 2},
+		{27, 3},
+		{213, 10}, // First version with a 16 bit character count
+		{2331, 40},
+	}
+	for _, tt := range tests {
+		code, err := Encode(bytes.Repeat([]byte("a"), tt.length))
+		if err != nil {
+			t.Fatalf("Encode(%d bytes): %v", tt.length, err)
+		}
+		if code.Version != tt.version || code.Size != 4*tt.version+17 {
+			t.Errorf("Encode(%d bytes) = version %d size %d, want version %d", tt.length, code.Version, code.Size, tt.version)
+		}
+	}
+
+	if _, err := Encode(bytes.Repeat([]byte("a"), 2332)); !errors.Is(err, ErrTooLong) {
+		t.Errorf("Encode(2332 bytes) error = %v, want ErrTooLong", err)
+	}
+}
+
+func TestLayoutsFillEveryVersion(t *testing.T) {
+	for version := 1; version <= 40; version++ {
+		l := layoutsM[version]
+		blocks := l.shortBlocks + l.longBlocks
+		if got, want := dataCodewords(version)+blocks*l.ecPerBlock, rawModules(version)/8; got != want {
+			t.Errorf("version %d holds %d codewords, want %d", version, got, want)
+		}
+	}
+}
+
+// The "HELLO WORLD" 1-M example: alphanumeric data codewords and their
+// Reed-Solomon error correction
+func TestReedSolomonVector(t *testing.T) {
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	if got := rsRemainder(data, rsDivisor(len(want))); !bytes.Equal(got, want) {
+		t.Errorf("rsRemainder = %v, want %v", got, want)
+	}
+}
+
+func TestPNGDecodesToData(t *testing.T) {
+	pixPayload := "00020101021226580014br.gov.bcb.pix0136123e4567-e12b-12d1-a456-426655440000" +
+		"520400005303986540529.905802BR5913Fulano de Tal6008BRASILIA62130509ORDER12346304ABCD"
+	tests := []struct {
+		name  string
+		data  string
+		scale int
+	}{
+		{"empty", "", 1},
+		{"short", "hoby-loop", 4},
+		{"pix payload", pixPayload, 8},
+		{"version 7 and up", strings.Repeat("0123456789abcdef", 20), 2},
+		{"utf-8", "Cesta orgânica · R$ 129,90", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := Encode([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			img, err := code.PNG(tt.scale)
+			if err != nil {
+				t.Fatalf("PNG: %v", err)
+			}
+
+			got, err := decodePNG(img, tt.scale)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if got != tt.data {
+				t.Errorf("decoded %q, want %q", got, tt.data)
+			}
+		})
+	}
+}
+
+// decodePNG reads a code rendered by PNG back to its data: it samples the
+// modules, checks the finders, format and version bits, removes the mask,
+// reads the codewords in zigzag order, checks their error correction and
+// parses the byte mode segment
+func decodePNG(data []byte, scale int) (string, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	size := img.Bounds().Dx()/scale - 2*QuietZone
+	if size < 21 || (size-17)%4 != 0 || img.Bounds().Dy() != img.Bounds().Dx() {
+		return "", errors.New("image is not a QR code")
+	}
+	version := (size - 17) / 4
+	dark := sample(img, size, scale)
+
+	for _, corner := range [][2]int{{0, 0}, {size - 7, 0}, {0, size - 7}} {
+		for dy := 0; dy < 7; dy++ {
+			for dx := 0; dx < 7; dx++ {
+				ring := max(abs(dx-3), abs(dy-3))
+				if dark[corner[1]+dy][corner[0]+dx] != (ring != 2) {
+					return "", errors.New("finder pattern not found")
+				}
+			}
+		}
+	}
+
+	format := 0
+	for i, at := range formatPositions() {
+		if dark[at[1]][at[0]] {
+			format |= 1 << i
+		}
+	}
+	format ^= 0x5412
+	rem := format >> 10
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	if format>>10<<10|rem != format || format>>13 != 0 {
+		return "", errors.New("format bits are not level M")
+	}
+	mask := format >> 10 & 7
+
+	if version >= 7 {
+		info := 0
+		for i := 0; i < 18; i++ {
+			if dark[i/3][size-11+i%3] {
+				info |= 1 << i
+			}
+		}
+		if info>>12 != version {
+			return "", errors.New("version bits do not match the size")
+		}
+	}
+
+	functions := newCode(version)
+	functions.drawFunctionPatterns()
+	var bits []bool
+	for right := size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = size - 1 - vert
+				}
+				if functions.isFunction[y][x] {
+					continue
+				}
+				bits = append(bits, dark[y][x] != masked(mask, x, y))
+			}
+		}
+	}
+	codewords := make([]byte, rawModules(version)/8)
+	for i := range codewords {
+		for _, b := range bits[i*8 : i*8+8] {
+			codewords[i] <<= 1
+			if b {
+				codewords[i] |= 1
+			}
+		}
+	}
+
+	l := layoutsM[version]
+	blocks := make([][]byte, l.shortBlocks+l.longBlocks)
+	next := 0
+	for i := 0; i <= l.shortData; i++ {
+		for b := range blocks {
+			if i < l.shortData || b >= l.shortBlocks {
+				blocks[b] = append(blocks[b], codewords[next])
+				next++
+			}
+		}
+	}
+	ec := make([][]byte, len(blocks))
+	for i := 0; i < l.ecPerBlock; i++ {
+		for b := range blocks {
+			ec[b] = append(ec[b], codewords[next])
+			next++
+		}
+	}
+	var stream []byte
+	for b, block := range blocks {
+		if !bytes.Equal(rsRemainder(block, rsDivisor(l.ecPerBlock)), ec[b]) {
+			return "", errors.New("error correction does not match")
+		}
+		stream = append(stream, block...)
+	}
+
+	r := bitReader{data: stream}
+	if r.read(4) != 0b0100 {
+		return "", errors.New("not a byte mode segment")
+	}
+	length := r.read(charCountBits(version))
+	out := make([]byte, length)
+	for i := range out {
+		out[i] = byte(r.read(8))
+	}
+	return string(out), nil
+}
+
+// sample reads the color of each module from the center of its pixels
+func sample(img image.Image, size, scale int) [][]bool {
+	dark := make([][]bool, size)
+	for y := range dark {
+		dark[y] = make([]bool, size)
+		for x := range dark[y] {
+			r, g, b, _ := img.At((x+QuietZone)*scale+scale/2, (y+QuietZone)*scale+scale/2).RGBA()
+			dark[y][x] = r+g+b < 3*0x8000
+		}
+	}
+	return dark
+}
+
+// formatPositions returns the modules of the copy of the format bits around
+// the top left finder, least significant bit first
+func formatPositions() [][2]int {
+	var positions [][2]int
+	for i := 0; i <= 5; i++ {
+		positions = append(positions, [2]int{8, i})
+	}
+	positions = append(positions, [2]int{8, 7}, [2]int{8, 8}, [2]int{7, 8})
+	for i := 9; i < 15; i++ {
+		positions = append(positions, [2]int{14 - i, 8})
+	}
+	return positions
+}
+
+// masked reports whether mask flips the module at x, y, per table 10 of
+// ISO/IEC 18004 with i the row and j the column
+func masked(mask, j, i int) bool {
+	switch mask {
+	case 0:
+		return (i+j)%2 == 0
+	case 1:
+		return i%2 == 0
+	case 2:
+		return j%3 == 0
+	case 3:
+		return (i+j)%3 == 0
+	case 4:
+		return (i/2+j/3)%2 == 0
+	case 5:
+		return i*j%2+i*j%3 == 0
+	case 6:
+		return (i*j%2+i*j%3)%2 == 0
+	default:
+		return ((i+j)%2+i*j%3)%2 == 0
+	}
+}
+
+// bitReader reads bits from a byte slice, most significant first
+type bitReader struct {
+	data []byte
+	n    int
+}
+
+func (r *bitReader) read(length int) int {
+	value := 0
+	for i := 0; i < length; i++ {
+		value = value<<1 | int(r.data[r.n/8]>>(7-r.n%8)&1)
+		r.n++
+	}
+	return value
+}
@@ -0,0 +1,1043 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NewGormRepositories returns repositories backed by the given GORM connection
+func NewGormRepositories(db *gorm.DB) Repositories {
+	return Repositories{
+		Users:         &gormUsers{db: db},
+		Baskets:       &gormBaskets{db: db},
+		Subscriptions: &gormSubscriptions{db: db},
+		Orders:        &gormOrders{db: db},
+		Tokens:        &gormTokens{db: db},
+		Notifications: &gormNotifications{db: db},
+		Jobs:          &gormJobs{db: db},
+		Webhooks:      &gormWebhooks{db: db},
+		Idempotency:   &gormIdempotency{db: db},
+		Payments:      &gormPayments{db: db},
+		Boletos:       &gormBoletos{db: db},
+		Invoices:      &gormInvoices{db: db},
+	}
+}
+
+// translateError maps GORM errors to repository errors
+func translateError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrConflict
+	default:
+		return err
+	}
+}
+
+// paginate counts the records matching db, which must already be filtered,
+// and fetches the page selected by q with the given associations preloaded
+func paginate[T any](db *gorm.DB, q pageQuery[T], preloads ...string) (Page[T], error) {
+	var total int64
+	if err := db.Session(&gorm.Session{}).Model(new(T)).Count(&total).Error; err != nil {
+		return Page[T]{}, translateError(err)
+	}
+
+	query := db.Session(&gorm.Session{})
+	for _, association := range preloads {
+		query = query.Preload(association)
+	}
+	if q.after != nil {
+		condition, args := q.keyset()
+		query = query.Where(condition, args...)
+	}
+
+	var records []T
+	if err := query.Order(q.orderBy()).Limit(q.limit + 1).Find(&records).Error; err != nil {
+		return Page[T]{}, translateError(err)
+	}
+	return q.page(records, total), nil
+}
+
+// createdBetween filters a table's created_at to [from, to)
+func createdBetween(db *gorm.DB, table string, from, to time.Time) *gorm.DB {
+	if !from.IsZero() {
+		db = db.Where(table+".created_at >= ?", from)
+	}
+	if !to.IsZero() {
+		db = db.Where(table+".created_at < ?", to)
+	}
+	return db
+}
+
+// gormUsers implements UserRepository
+type gormUsers struct {
+	db *gorm.DB
+}
+
+func (r *gormUsers) FindByID(ctx context.Context, id uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUsers) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUsers) List(ctx context.Context, filter UserFilter, page PageRequest) (Page[models.User], error) {
+	q, err := newPageQuery(page, userSortKeys, SortField{Field: "id"})
+	if err != nil {
+		return Page[models.User]{}, err
+	}
+
+	db := createdBetween(r.db.WithContext(ctx), "users", filter.CreatedFrom, filter.CreatedTo)
+	if filter.Role != "" {
+		db = db.Where("users.role = ?", filter.Role)
+	}
+	if filter.Active != nil {
+		db = db.Where("users.is_active = ?", *filter.Active)
+	}
+	if filter.City != "" {
+		db = db.Where("LOWER(users.address_city) = LOWER(?)", filter.City)
+	}
+	if filter.State != "" {
+		db = db.Where("LOWER(users.address_state) = LOWER(?)", filter.State)
+	}
+	return paginate(db, q)
+}
+
+func (r *gormUsers) Create(ctx context.Context, user *models.User) error {
+	return translateError(r.db.WithContext(ctx).Create(user).Error)
+}
+
+func (r *gormUsers) Save(ctx context.Context, user *models.User) error {
+	return translateError(r.db.WithContext(ctx).Save(user).Error)
+}
+
+func (r *gormUsers) UpdatePassword(ctx context.Context, userID uint, hash string) error {
+	result := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Update("password", hash)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// gormBaskets implements BasketRepository
+type gormBaskets struct {
+	db *gorm.DB
+}
+
+func (r *gormBaskets) FindByID(ctx context.Context, id uint) (*models.Basket, error) {
+	var basket models.Basket
+	if err := r.db.WithContext(ctx).First(&basket, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &basket, nil
+}
+
+func (r *gormBaskets) List(ctx context.Context, filter BasketFilter, page PageRequest) (Page[models.Basket], error) {
+	q, err := newPageQuery(page, basketSortKeys, SortField{Field: "id"})
+	if err != nil {
+		return Page[models.Basket]{}, err
+	}
+
+	db := createdBetween(r.db.WithContext(ctx), "baskets", filter.CreatedFrom, filter.CreatedTo)
+	if filter.SellerID != 0 {
+		db = db.Where("baskets.user_id = ?", filter.SellerID)
+	}
+	return paginate(db, q)
+}
+
+func (r *gormBaskets) Create(ctx context.Context, basket *models.Basket) error {
+	return translateError(r.db.WithContext(ctx).Create(basket).Error)
+}
+
+// gormSubscriptions implements SubscriptionRepository
+type gormSubscriptions struct {
+	db *gorm.DB
+}
+
+func (r *gormSubscriptions) preloaded(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Preload("User").Preload("Basket")
+}
+
+func (r *gormSubscriptions) FindByID(ctx context.Context, id uint) (*models.Subscription, error) {
+	var subscription models.Subscription
+	if err := r.preloaded(ctx).First(&subscription, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &subscription, nil
+}
+
+func (r *gormSubscriptions) List(ctx context.Context, filter SubscriptionFilter, page PageRequest) (Page[models.Subscription], error) {
+	q, err := newPageQuery(page, subscriptionSortKeys, SortField{Field: "id"})
+	if err != nil {
+		return Page[models.Subscription]{}, err
+	}
+
+	db := createdBetween(r.db.WithContext(ctx), "subscriptions", filter.CreatedFrom, filter.CreatedTo)
+	if filter.SellerID != 0 {
+		db = db.Joins("JOIN baskets ON baskets.id = subscriptions.basket_id").
+			Where("baskets.user_id = ?", filter.SellerID)
+	}
+	if filter.ConsumerID != 0 {
+		db = db.Where("subscriptions.user_id = ?", filter.ConsumerID)
+	}
+	if filter.BasketID != 0 {
+		db = db.Where("subscriptions.basket_id = ?", filter.BasketID)
+	}
+	if filter.Status != "" {
+		db = db.Where("subscriptions.status = ?", filter.Status)
+	}
+	if filter.Frequency != "" {
+		db = db.Where("subscriptions.frequency = ?", filter.Frequency)
+	}
+	if filter.City != "" || filter.State != "" {
+		db = db.Joins("JOIN users ON users.id = subscriptions.user_id")
+		if filter.City != "" {
+			db = db.Where("LOWER(users.address_city) = LOWER(?)", filter.City)
+		}
+		if filter.State != "" {
+			db = db.Where("LOWER(users.address_state) = LOWER(?)", filter.State)
+		}
+	}
+	return paginate(db, q, "User", "Basket")
+}
+
+func (r *gormSubscriptions) Create(ctx context.Context, subscription *models.Subscription, event *models.SubscriptionEvent, jobs JobBuilder[models.Subscription]) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("User", "Basket").Create(subscription).Error; err != nil {
+			return err
+		}
+		event.SubscriptionID = subscription.ID
+		if err := tx.Create(event).Error; err != nil {
+			return err
+		}
+		built, err := jobs.Build(*subscription)
+		if err != nil {
+			return err
+		}
+		return enqueueJobs(tx, built)
+	})
+	return translateError(err)
+}
+
+func (r *gormSubscriptions) Transition(ctx context.Context, subscription *models.Subscription, from models.SubscriptionStatus, event *models.SubscriptionEvent, jobs []models.OutboxJob) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Subscription{}).
+			Where("id = ? AND status = ?", subscription.ID, from).
+			Updates(map[string]interface{}{
+				"status":              subscription.Status,
+				"next_delivery_at":    subscription.NextDeliveryAt,
+				"trial_ends_at":       subscription.TrialEndsAt,
+				"paused_until":        subscription.PausedUntil,
+				"cancelled_at":        subscription.CancelledAt,
+				"cancellation_reason": subscription.CancellationReason,
+				"past_due_at":         subscription.PastDueAt,
+				"dunning_attempts":    subscription.DunningAttempts,
+				"next_retry_at":       subscription.NextRetryAt,
+				"dunning_order_id":    subscription.DunningOrderID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrConflict
+		}
+		event.SubscriptionID = subscription.ID
+		if err := tx.Create(event).Error; err != nil {
+			return err
+		}
+		return enqueueJobs(tx, jobs)
+	})
+	return translateError(err)
+}
+
+func (r *gormSubscriptions) ListEvents(ctx context.Context, subscriptionID uint, page PageRequest) (Page[models.SubscriptionEvent], error) {
+	q, err := newPageQuery(page, subscriptionEventSortKeys, SortField{Field: "created_at"})
+	if err != nil {
+		return Page[models.SubscriptionEvent]{}, err
+	}
+	return paginate(r.db.WithContext(ctx).Where("subscription_events.subscription_id = ?", subscriptionID), q)
+}
+
+func (r *gormSubscriptions) ListLapsed(ctx context.Context, now time.Time) ([]models.Subscription, error) {
+	var subscriptions []models.Subscription
+	err := r.db.WithContext(ctx).
+		Where("(status = ? AND trial_ends_at <= ?) OR (status = ? AND paused_until <= ?)",
+			models.SubscriptionTrialing, now, models.SubscriptionPaused, now).
+		Find(&subscriptions).Error
+	return subscriptions, translateError(err)
+}
+
+func (r *gormSubscriptions) ProcessDue(ctx context.Context, now time.Time, limit int, plan DeliveryPlanner, jobs JobBuilder[models.Order]) (int, error) {
+	processed := 0
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var due []models.Subscription
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status IN ? AND next_delivery_at <= ?", models.DeliveringStatuses, now).
+			Order("next_delivery_at").
+			Limit(limit).
+			Find(&due).Error
+		if err != nil {
+			return err
+		}
+
+		for _, subscription := range due {
+			orders, next := plan(subscription)
+			for i := range orders {
+				result := tx.Omit("Subscription").
+					Clauses(clause.OnConflict{DoNothing: true}).
+					Create(&orders[i])
+				if result.Error != nil {
+					return result.Error
+				}
+				if result.RowsAffected == 0 {
+					continue // Already generated
+				}
+				event := scheduledOrderEvent(orders[i], now)
+				if err := tx.Create(&event).Error; err != nil {
+					return err
+				}
+				built, err := jobs.Build(orders[i])
+				if err != nil {
+					return err
+				}
+				if err := enqueueJobs(tx, built); err != nil {
+					return err
+				}
+			}
+			err := tx.Model(&models.Subscription{}).
+				Where("id = ?", subscription.ID).
+				Update("next_delivery_at", next).Error
+			if err != nil {
+				return err
+			}
+			processed++
+		}
+		return nil
+	})
+	return processed, translateError(err)
+}
+
+func (r *gormSubscriptions) SavePaymentMethod(ctx context.Context, subscription *models.Subscription) error {
+	result := r.db.WithContext(ctx).Model(&models.Subscription{}).
+		Where("id = ?", subscription.ID).
+		Updates(map[string]interface{}{
+			"payment_method": subscription.PaymentMethod,
+			"payment_token":  subscription.PaymentToken,
+			"card_brand":     subscription.CardBrand,
+			"card_last4":     subscription.CardLast4,
+		})
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// gormOrders implements OrderRepository
+type gormOrders struct {
+	db *gorm.DB
+}
+
+func (r *gormOrders) preloaded(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).
+		Preload("Subscription").
+		Preload("Subscription.User").
+		Preload("Subscription.Basket")
+}
+
+func (r *gormOrders) FindByID(ctx context.Context, id uint) (*models.Order, error) {
+	var order models.Order
+	if err := r.preloaded(ctx).First(&order, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &order, nil
+}
+
+func (r *gormOrders) List(ctx context.Context, filter OrderFilter, page PageRequest) (Page[models.Order], error) {
+	q, err := newPageQuery(page, orderSortKeys, SortField{Field: "created_at", Desc: true})
+	if err != nil {
+		return Page[models.Order]{}, err
+	}
+
+	db := createdBetween(r.db.WithContext(ctx), "orders", filter.CreatedFrom, filter.CreatedTo)
+	if filter.BasketID != 0 {
+		db = db.Joins("JOIN subscriptions ON subscriptions.id = orders.subscription_id").
+			Where("subscriptions.basket_id = ?", filter.BasketID)
+	}
+	if filter.SubscriptionID != 0 {
+		db = db.Where("orders.subscription_id = ?", filter.SubscriptionID)
+	}
+	if filter.Status != "" {
+		db = db.Where("orders.status = ?", filter.Status)
+	}
+	if !filter.ScheduledFrom.IsZero() {
+		db = db.Where("orders.scheduled_for >= ?", filter.ScheduledFrom)
+	}
+	if !filter.ScheduledTo.IsZero() {
+		db = db.Where("orders.scheduled_for < ?", filter.ScheduledTo)
+	}
+	return paginate(db, q, "Subscription", "Subscription.User", "Subscription.Basket")
+}
+
+func (r *gormOrders) Create(ctx context.Context, order *models.Order, event *models.OrderEvent, jobs JobBuilder[models.Order]) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Subscription").Create(order).Error; err != nil {
+			return err
+		}
+		event.OrderID = order.ID
+		if err := tx.Create(event).Error; err != nil {
+			return err
+		}
+		built, err := jobs.Build(*order)
+		if err != nil {
+			return err
+		}
+		return enqueueJobs(tx, built)
+	})
+	return translateError(err)
+}
+
+func (r *gormOrders) Transition(ctx context.Context, order *models.Order, from models.OrderStatus, events []models.OrderEvent, jobs []models.OutboxJob) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Order{}).
+			Where("id = ? AND status = ?", order.ID, from).
+			Updates(map[string]interface{}{
+				"status":        order.Status,
+				"tracking_code": order.TrackingCode,
+				"shipped_at":    order.ShippedAt,
+				"delivered_at":  order.DeliveredAt,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrConflict
+		}
+		for i := range events {
+			events[i].OrderID = order.ID
+		}
+		if len(events) > 0 {
+			if err := tx.Create(&events).Error; err != nil {
+				return err
+			}
+		}
+		return enqueueJobs(tx, jobs)
+	})
+	return translateError(err)
+}
+
+func (r *gormOrders) ListEvents(ctx context.Context, orderID uint) ([]models.OrderEvent, error) {
+	var events []models.OrderEvent
+	err := r.db.WithContext(ctx).
+		Where("order_id = ?", orderID).
+		Order("created_at, id").
+		Find(&events).Error
+	return events, translateError(err)
+}
+
+// gormTokens implements TokenRepository
+type gormTokens struct {
+	db *gorm.DB
+}
+
+func (r *gormTokens) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	return translateError(r.db.WithContext(ctx).Create(token).Error)
+}
+
+func (r *gormTokens) FindRefreshTokenByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &token, nil
+}
+
+func (r *gormTokens) RotateRefreshToken(ctx context.Context, oldID uint, next *models.RefreshToken, at time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(next).Error; err != nil {
+			return translateError(err)
+		}
+
+		// Only rotate if nobody else rotated this token concurrently
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", oldID).
+			Updates(map[string]interface{}{"revoked_at": at, "replaced_by": next.ID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+func (r *gormTokens) RevokeRefreshToken(ctx context.Context, hash string, userID uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("token_hash = ? AND user_id = ? AND revoked_at IS NULL", hash, userID).
+		Update("revoked_at", at).Error
+}
+
+func (r *gormTokens) RevokeAllRefreshTokens(ctx context.Context, userID uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", at).Error
+}
+
+func (r *gormTokens) RevokeAccessToken(ctx context.Context, token *models.RevokedToken) error {
+	return translateError(r.db.WithContext(ctx).Create(token).Error)
+}
+
+func (r *gormTokens) IsAccessTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.RevokedToken{}).Where("token_id = ?", tokenID).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormTokens) PurgeExpiredRevocations(ctx context.Context, before time.Time) error {
+	return r.db.WithContext(ctx).Where("expires_at < ?", before).Delete(&models.RevokedToken{}).Error
+}
+
+// gormNotifications implements NotificationRepository
+type gormNotifications struct {
+	db *gorm.DB
+}
+
+func (r *gormNotifications) ListPreferences(ctx context.Context, userID uint) ([]models.NotificationPreference, error) {
+	var preferences []models.NotificationPreference
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("channel").Find(&preferences).Error
+	return preferences, translateError(err)
+}
+
+func (r *gormNotifications) SavePreferences(ctx context.Context, userID uint, preferences []models.NotificationPreference) error {
+	return translateError(r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.NotificationPreference{}).Error; err != nil {
+			return err
+		}
+		for i := range preferences {
+			preferences[i].UserID = userID
+		}
+		if len(preferences) == 0 {
+			return nil
+		}
+		return tx.Create(&preferences).Error
+	}))
+}
+
+func (r *gormNotifications) RecordDelivery(ctx context.Context, delivery *models.NotificationDelivery) error {
+	return translateError(r.db.WithContext(ctx).Create(delivery).Error)
+}
+
+func (r *gormNotifications) ListDeliveries(ctx context.Context, filter DeliveryFilter, page PageRequest) (Page[models.NotificationDelivery], error) {
+	q, err := newPageQuery(page, deliverySortKeys, SortField{Field: "created_at", Desc: true})
+	if err != nil {
+		return Page[models.NotificationDelivery]{}, err
+	}
+
+	db := r.db.WithContext(ctx)
+	if filter.UserID != 0 {
+		db = db.Where("notification_deliveries.user_id = ?", filter.UserID)
+	}
+	if filter.Status != "" {
+		db = db.Where("notification_deliveries.status = ?", filter.Status)
+	}
+	return paginate(db, q)
+}
+
+// enqueueJobs inserts jobs as part of the caller's transaction
+func enqueueJobs(tx *gorm.DB, jobs []models.OutboxJob) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for i := range jobs {
+		pendingJob(&jobs[i], now)
+	}
+	return tx.Create(&jobs).Error
+}
+
+// gormJobs implements JobRepository
+type gormJobs struct {
+	db *gorm.DB
+}
+
+func (r *gormJobs) FindByID(ctx context.Context, id uint) (*models.OutboxJob, error) {
+	var job models.OutboxJob
+	if err := r.db.WithContext(ctx).First(&job, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &job, nil
+}
+
+func (r *gormJobs) List(ctx context.Context, filter JobFilter, page PageRequest) (Page[models.OutboxJob], error) {
+	q, err := newPageQuery(page, jobSortKeys, SortField{Field: "id", Desc: true})
+	if err != nil {
+		return Page[models.OutboxJob]{}, err
+	}
+
+	db := r.db.WithContext(ctx)
+	if filter.Status != "" {
+		db = db.Where("outbox_jobs.status = ?", filter.Status)
+	}
+	if filter.Kind != "" {
+		db = db.Where("outbox_jobs.kind = ?", filter.Kind)
+	}
+	return paginate(db, q)
+}
+
+func (r *gormJobs) Enqueue(ctx context.Context, job *models.OutboxJob) error {
+	pendingJob(job, time.Now())
+	return translateError(r.db.WithContext(ctx).Create(job).Error)
+}
+
+func (r *gormJobs) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.OutboxJob, error) {
+	var jobs []models.OutboxJob
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_until <= ?)",
+				models.JobPending, now, models.JobRunning, now).
+			Order("run_at, id").
+			Limit(limit).
+			Find(&jobs).Error
+		if err != nil || len(jobs) == 0 {
+			return err
+		}
+
+		lockedUntil := now.Add(lease)
+		ids := make([]uint, len(jobs))
+		for i := range jobs {
+			ids[i] = jobs[i].ID
+			jobs[i].Status = models.JobRunning
+			jobs[i].Attempts++
+			jobs[i].LockedUntil = &lockedUntil
+		}
+		return tx.Model(&models.OutboxJob{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"status":       models.JobRunning,
+				"attempts":     gorm.Expr("attempts + 1"),
+				"locked_until": lockedUntil,
+				"updated_at":   now,
+			}).Error
+	})
+	return jobs, translateError(err)
+}
+
+func (r *gormJobs) Finish(ctx context.Context, job *models.OutboxJob) error {
+	job.LockedUntil = nil
+	result := r.db.WithContext(ctx).Model(&models.OutboxJob{}).
+		Where("id = ? AND status = ? AND attempts = ?", job.ID, models.JobRunning, job.Attempts).
+		Updates(map[string]interface{}{
+			"status":       job.Status,
+			"run_at":       job.RunAt,
+			"payload":      job.Payload,
+			"last_error":   job.LastError,
+			"completed_at": job.CompletedAt,
+			"locked_until": nil,
+		})
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r *gormJobs) Replay(ctx context.Context, id uint, now time.Time) (*models.OutboxJob, error) {
+	result := r.db.WithContext(ctx).Model(&models.OutboxJob{}).
+		Where("id = ? AND status IN ?", id, []models.JobStatus{models.JobDone, models.JobDead}).
+		Updates(map[string]interface{}{
+			"status":       models.JobPending,
+			"attempts":     0,
+			"run_at":       now,
+			"completed_at": nil,
+			"locked_until": nil,
+		})
+	if result.Error != nil {
+		return nil, translateError(result.Error)
+	}
+
+	job, err := r.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrConflict
+	}
+	return job, nil
+}
+
+// gormWebhooks implements WebhookRepository
+type gormWebhooks struct {
+	db *gorm.DB
+}
+
+func (r *gormWebhooks) ListEndpoints(ctx context.Context, sellerID uint) ([]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+	err := r.db.WithContext(ctx).Where("user_id = ?", sellerID).Order("id").Find(&endpoints).Error
+	return endpoints, translateError(err)
+}
+
+func (r *gormWebhooks) List(ctx context.Context, sellerID uint, page PageRequest) (Page[models.WebhookEndpoint], error) {
+	q, err := newPageQuery(page, webhookEndpointSortKeys, SortField{Field: "id"})
+	if err != nil {
+		return Page[models.WebhookEndpoint]{}, err
+	}
+	return paginate(r.db.WithContext(ctx).Where("webhook_endpoints.user_id = ?", sellerID), q)
+}
+
+func (r *gormWebhooks) FindEndpoint(ctx context.Context, id uint) (*models.WebhookEndpoint, error) {
+	var endpoint models.WebhookEndpoint
+	if err := r.db.WithContext(ctx).First(&endpoint, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &endpoint, nil
+}
+
+func (r *gormWebhooks) CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	return translateError(r.db.WithContext(ctx).Create(endpoint).Error)
+}
+
+func (r *gormWebhooks) SaveEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	return translateError(r.db.WithContext(ctx).Save(endpoint).Error)
+}
+
+func (r *gormWebhooks) DeleteEndpoint(ctx context.Context, id uint) error {
+	return translateError(r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("endpoint_id = ?", id).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&models.WebhookEndpoint{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	}))
+}
+
+func (r *gormWebhooks) RecordDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	return translateError(r.db.WithContext(ctx).Create(delivery).Error)
+}
+
+func (r *gormWebhooks) ListDeliveries(ctx context.Context, endpointID uint, page PageRequest) (Page[models.WebhookDelivery], error) {
+	q, err := newPageQuery(page, webhookDeliverySortKeys, SortField{Field: "id", Desc: true})
+	if err != nil {
+		return Page[models.WebhookDelivery]{}, err
+	}
+	return paginate(r.db.WithContext(ctx).Where("webhook_deliveries.endpoint_id = ?", endpointID), q)
+}
+
+// gormIdempotency implements IdempotencyRepository
+type gormIdempotency struct {
+	db *gorm.DB
+}
+
+func (r *gormIdempotency) Reserve(ctx context.Context, key *models.IdempotencyKey, now time.Time) (*models.IdempotencyKey, error) {
+	var stored *models.IdempotencyKey
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+		if result.Error != nil || result.RowsAffected == 1 {
+			return result.Error
+		}
+
+		var existing models.IdempotencyKey
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND key = ?", key.UserID, key.Key).
+			First(&existing).Error; err != nil {
+			return err
+		}
+		if existing.ExpiresAt.After(now) {
+			stored = &existing
+			return ErrConflict
+		}
+
+		// The key expired, so it starts over as a new request
+		key.ID = existing.ID
+		key.CreatedAt = now
+		return tx.Select("*").Save(key).Error
+	})
+	return stored, translateError(err)
+}
+
+func (r *gormIdempotency) Complete(ctx context.Context, key *models.IdempotencyKey) error {
+	return translateError(r.db.WithContext(ctx).Model(&models.IdempotencyKey{}).
+		Where("id = ?", key.ID).
+		Updates(map[string]interface{}{
+			"status_code":  key.StatusCode,
+			"content_type": key.ContentType,
+			"response":     key.Response,
+		}).Error)
+}
+
+func (r *gormIdempotency) Release(ctx context.Context, id uint) error {
+	return translateError(r.db.WithContext(ctx).Delete(&models.IdempotencyKey{}, id).Error)
+}
+
+func (r *gormIdempotency) PurgeExpired(ctx context.Context, before time.Time) error {
+	return translateError(r.db.WithContext(ctx).Where("expires_at <= ?", before).Delete(&models.IdempotencyKey{}).Error)
+}
+
+// gormPayments implements PaymentRepository
+type gormPayments struct {
+	db *gorm.DB
+}
+
+func (r *gormPayments) FindByID(ctx context.Context, id uint) (*models.Payment, error) {
+	var payment models.Payment
+	if err := r.db.WithContext(ctx).First(&payment, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &payment, nil
+}
+
+func (r *gormPayments) FindByCharge(ctx context.Context, provider, chargeID string) (*models.Payment, error) {
+	var payment models.Payment
+	err := r.db.WithContext(ctx).
+		Where("provider = ? AND charge_id = ?", provider, chargeID).
+		First(&payment).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &payment, nil
+}
+
+func (r *gormPayments) ListByOrder(ctx context.Context, orderID uint) ([]models.Payment, error) {
+	var payments []models.Payment
+	err := r.db.WithContext(ctx).Where("order_id = ?", orderID).Order("id").Find(&payments).Error
+	return payments, translateError(err)
+}
+
+func (r *gormPayments) Create(ctx context.Context, payment *models.Payment) error {
+	return translateError(r.db.WithContext(ctx).Create(payment).Error)
+}
+
+func (r *gormPayments) Save(ctx context.Context, payment *models.Payment) error {
+	return translateError(r.db.WithContext(ctx).Save(payment).Error)
+}
+
+func (r *gormPayments) Transition(ctx context.Context, payment *models.Payment, from models.PaymentStatus) error {
+	result := r.db.WithContext(ctx).Model(&models.Payment{}).
+		Where("id = ? AND status = ?", payment.ID, from).
+		Updates(map[string]interface{}{
+			"status":          payment.Status,
+			"paid_at":         payment.PaidAt,
+			"refunded_at":     payment.RefundedAt,
+			"failure_code":    payment.FailureCode,
+			"failure_message": payment.FailureMessage,
+		})
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (r *gormPayments) Settle(ctx context.Context, settlement *PaymentSettlement) (bool, error) {
+	payment := settlement.Payment
+	succeeded := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Settlements of the same order wait for each other on its row
+		var order models.Order
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "status").
+			First(&order, payment.OrderID).Error
+		if err != nil {
+			return err
+		}
+
+		if b := settlement.Boleto; b != nil {
+			result := tx.Model(&models.Boleto{}).
+				Where("id = ? AND status <> ?", b.ID, models.BoletoPaid).
+				Updates(map[string]interface{}{
+					"status":               models.BoletoPaid,
+					"paid_at":              b.PaidAt,
+					"paid_amount_amount":   b.PaidAmount.Amount,
+					"paid_amount_currency": b.PaidAmount.Currency,
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrConflict
+			}
+		}
+
+		var paid int64
+		err = tx.Model(&models.Payment{}).
+			Where("order_id = ? AND id <> ? AND status IN ?", payment.OrderID, payment.ID,
+				[]models.PaymentStatus{models.PaymentSucceeded, models.PaymentRefunded}).
+			Count(&paid).Error
+		if err != nil || paid > 0 {
+			return err
+		}
+
+		result := tx.Model(&models.Payment{}).
+			Where("id = ? AND status IN ?", payment.ID, settlement.From).
+			Updates(map[string]interface{}{
+				"status":          models.PaymentSucceeded,
+				"paid_at":         payment.PaidAt,
+				"end_to_end_id":   payment.EndToEndID,
+				"failure_code":    "",
+				"failure_message": "",
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrConflict
+		}
+
+		event := settlement.Event
+		event.OrderID = order.ID
+		event.FromStatus, event.ToStatus = order.Status, order.Status
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+		succeeded = true
+		return nil
+	})
+	if err != nil {
+		return false, translateError(err)
+	}
+	if succeeded {
+		payment.Status = models.PaymentSucceeded
+		payment.FailureCode, payment.FailureMessage = "", ""
+	}
+	return succeeded, nil
+}
+
+// gormBoletos implements BoletoRepository
+type gormBoletos struct {
+	db *gorm.DB
+}
+
+func (r *gormBoletos) FindByID(ctx context.Context, id uint) (*models.Boleto, error) {
+	var boleto models.Boleto
+	if err := r.db.WithContext(ctx).First(&boleto, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &boleto, nil
+}
+
+func (r *gormBoletos) FindByOurNumber(ctx context.Context, ourNumber string) (*models.Boleto, error) {
+	var boleto models.Boleto
+	if err := r.db.WithContext(ctx).Where("our_number = ?", ourNumber).First(&boleto).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &boleto, nil
+}
+
+func (r *gormBoletos) ListByOrder(ctx context.Context, orderID uint) ([]models.Boleto, error) {
+	var boletos []models.Boleto
+	err := r.db.WithContext(ctx).Where("order_id = ?", orderID).Order("id").Find(&boletos).Error
+	return boletos, translateError(err)
+}
+
+func (r *gormBoletos) Create(ctx context.Context, boleto *models.Boleto, jobs JobBuilder[models.Boleto]) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(boleto).Error; err != nil {
+			return err
+		}
+		built, err := jobs.Build(*boleto)
+		if err != nil {
+			return err
+		}
+		return enqueueJobs(tx, built)
+	})
+	return translateError(err)
+}
+
+func (r *gormBoletos) Transition(ctx context.Context, boleto *models.Boleto, from models.BoletoStatus) error {
+	result := r.db.WithContext(ctx).Model(&models.Boleto{}).
+		Where("id = ? AND status = ?", boleto.ID, from).
+		Update("status", boleto.Status)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// gormInvoices implements InvoiceRepository
+type gormInvoices struct {
+	db *gorm.DB
+}
+
+// orderedItems preloads invoice items in line order
+func orderedItems(db *gorm.DB) *gorm.DB {
+	return db.Order("invoice_items.id")
+}
+
+func (r *gormInvoices) FindByID(ctx context.Context, id uint) (*models.Invoice, error) {
+	var invoice models.Invoice
+	if err := r.db.WithContext(ctx).Preload("Items", orderedItems).First(&invoice, id).Error; err != nil {
+		return nil, translateError(err)
+	}
+	return &invoice, nil
+}
+
+func (r *gormInvoices) FindByOrder(ctx context.Context, orderID uint) (*models.Invoice, error) {
+	var invoice models.Invoice
+	err := r.db.WithContext(ctx).Preload("Items", orderedItems).Where("order_id = ?", orderID).First(&invoice).Error
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &invoice, nil
+}
+
+func (r *gormInvoices) ListByConsumer(ctx context.Context, consumerID uint, page PageRequest) (Page[models.Invoice], error) {
+	q, err := newPageQuery(page, invoiceSortKeys, SortField{Field: "issued_at", Desc: true})
+	if err != nil {
+		return Page[models.Invoice]{}, err
+	}
+	return paginate(r.db.WithContext(ctx).Where("invoices.consumer_id = ?", consumerID), q, "Items")
+}
+
+func (r *gormInvoices) Create(ctx context.Context, invoice *models.Invoice) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The seller's row stays locked until the transaction ends, so
+		// concurrent invoices get consecutive numbers and a failed insert
+		// gives its number back
+		var sequence int
+		err := tx.Raw(`INSERT INTO invoice_sequences (seller_id, last_number) VALUES (?, 1)
+			ON CONFLICT (seller_id) DO UPDATE SET last_number = invoice_sequences.last_number + 1
+			RETURNING last_number`, invoice.SellerID).Scan(&sequence).Error
+		if err != nil {
+			return err
+		}
+		invoice.Sequence = sequence
+		invoice.Number = models.InvoiceNumber(sequence)
+		return tx.Create(invoice).Error
+	})
+	return translateError(err)
+}
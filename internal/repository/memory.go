@@ -0,0 +1,1255 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// NewMemoryRepositories returns repositories that keep everything in memory.
+// All repositories share one store so relations resolve across them.
+func NewMemoryRepositories() Repositories {
+	store := &memoryStore{
+		users:              map[uint]models.User{},
+		baskets:            map[uint]models.Basket{},
+		subscriptions:      map[uint]models.Subscription{},
+		orders:             map[uint]models.Order{},
+		refreshTokens:      map[uint]models.RefreshToken{},
+		revokedTokens:      map[string]models.RevokedToken{},
+		subscriptionEvents: map[uint]models.SubscriptionEvent{},
+		orderEvents:        map[uint]models.OrderEvent{},
+		preferences:        map[uint][]models.NotificationPreference{},
+		deliveries:         map[uint]models.NotificationDelivery{},
+		jobs:               map[uint]models.OutboxJob{},
+		webhookEndpoints:   map[uint]models.WebhookEndpoint{},
+		webhookDeliveries:  map[uint]models.WebhookDelivery{},
+		idempotencyKeys:    map[uint]models.IdempotencyKey{},
+		payments:           map[uint]models.Payment{},
+		boletos:            map[uint]models.Boleto{},
+		invoices:           map[uint]models.Invoice{},
+		sequences:          map[string]uint{},
+	}
+	return Repositories{
+		Users:         &memoryUsers{store},
+		Baskets:       &memoryBaskets{store},
+		Subscriptions: &memorySubscriptions{store},
+		Orders:        &memoryOrders{store},
+		Tokens:        &memoryTokens{store},
+		Notifications: &memoryNotifications{store},
+		Jobs:          &memoryJobs{store},
+		Webhooks:      &memoryWebhooks{store},
+		Idempotency:   &memoryIdempotency{store},
+		Payments:      &memoryPayments{store},
+		Boletos:       &memoryBoletos{store},
+		Invoices:      &memoryInvoices{store},
+	}
+}
+
+// memoryStore holds every in-memory table behind a single lock
+type memoryStore struct {
+	mu            sync.RWMutex
+	sequences     map[string]uint
+	users         map[uint]models.User
+	baskets       map[uint]models.Basket
+	subscriptions map[uint]models.Subscription
+	orders        map[uint]models.Order
+	refreshTokens map[uint]models.RefreshToken
+	revokedTokens map[string]models.RevokedToken
+
+	subscriptionEvents map[uint]models.SubscriptionEvent
+	orderEvents        map[uint]models.OrderEvent
+	preferences        map[uint][]models.NotificationPreference // By user
+	deliveries         map[uint]models.NotificationDelivery
+	jobs               map[uint]models.OutboxJob
+	webhookEndpoints   map[uint]models.WebhookEndpoint
+	webhookDeliveries  map[uint]models.WebhookDelivery
+	idempotencyKeys    map[uint]models.IdempotencyKey
+	payments           map[uint]models.Payment
+	boletos            map[uint]models.Boleto
+	invoices           map[uint]models.Invoice // Items included
+}
+
+// newID returns the next ID of a table, like a Postgres serial column
+func (s *memoryStore) newID(table string) uint {
+	s.sequences[table]++
+	return s.sequences[table]
+}
+
+// subscriptionWithRelations loads the user and basket of a subscription
+func (s *memoryStore) subscriptionWithRelations(subscription models.Subscription) models.Subscription {
+	subscription.User = s.users[subscription.UserID]
+	subscription.Basket = s.baskets[subscription.BasketID]
+	return subscription
+}
+
+// orderWithRelations loads the subscription of an order with its relations
+func (s *memoryStore) orderWithRelations(order models.Order) models.Order {
+	order.Subscription = s.subscriptionWithRelations(s.subscriptions[order.SubscriptionID])
+	return order
+}
+
+// inRange reports whether t is in [from, to), zero bounds matching everything
+func inRange(t, from, to time.Time) bool {
+	return (from.IsZero() || !t.Before(from)) && (to.IsZero() || t.Before(to))
+}
+
+// sortByID keeps list results deterministic
+func sortByID[T any](items []T, id func(T) uint) {
+	sort.Slice(items, func(i, j int) bool { return id(items[i]) < id(items[j]) })
+}
+
+// memoryUsers implements UserRepository
+type memoryUsers struct{ *memoryStore }
+
+func (r *memoryUsers) FindByID(ctx context.Context, id uint) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	user, ok := r.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (r *memoryUsers) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, user := range r.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryUsers) List(ctx context.Context, filter UserFilter, page PageRequest) (Page[models.User], error) {
+	q, err := newPageQuery(page, userSortKeys, SortField{Field: "id"})
+	if err != nil {
+		return Page[models.User]{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	users := []models.User{}
+	for _, user := range r.users {
+		if (filter.Role == "" || user.Role == filter.Role) &&
+			(filter.Active == nil || user.IsActive == *filter.Active) &&
+			(filter.City == "" || strings.EqualFold(user.AddressCity, filter.City)) &&
+			(filter.State == "" || strings.EqualFold(user.AddressState, filter.State)) &&
+			inRange(user.CreatedAt, filter.CreatedFrom, filter.CreatedTo) {
+			users = append(users, user)
+		}
+	}
+	return q.slice(users), nil
+}
+
+func (r *memoryUsers) Create(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.users {
+		if existing.Email == user.Email {
+			return ErrConflict
+		}
+	}
+	now := time.Now()
+	user.ID = r.newID("users")
+	user.CreatedAt, user.UpdatedAt = now, now
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *memoryUsers) Save(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[user.ID]; !ok {
+		return ErrNotFound
+	}
+	user.UpdatedAt = time.Now()
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *memoryUsers) UpdatePassword(ctx context.Context, userID uint, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	stored.Password = hash
+	stored.UpdatedAt = time.Now()
+	r.users[userID] = stored
+	return nil
+}
+
+// memoryBaskets implements BasketRepository
+type memoryBaskets struct{ *memoryStore }
+
+func (r *memoryBaskets) FindByID(ctx context.Context, id uint) (*models.Basket, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	basket, ok := r.baskets[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &basket, nil
+}
+
+func (r *memoryBaskets) List(ctx context.Context, filter BasketFilter, page PageRequest) (Page[models.Basket], error) {
+	q, err := newPageQuery(page, basketSortKeys, SortField{Field: "id"})
+	if err != nil {
+		return Page[models.Basket]{}, err
+	}
+	baskets := r.filter(func(b models.Basket) bool {
+		return (filter.SellerID == 0 || b.UserID == filter.SellerID) &&
+			inRange(b.CreatedAt, filter.CreatedFrom, filter.CreatedTo)
+	})
+	return q.slice(baskets), nil
+}
+
+func (r *memoryBaskets) filter(keep func(models.Basket) bool) []models.Basket {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	baskets := []models.Basket{}
+	for _, basket := range r.baskets {
+		if keep(basket) {
+			baskets = append(baskets, basket)
+		}
+	}
+	sortByID(baskets, func(b models.Basket) uint { return b.ID })
+	return baskets
+}
+
+func (r *memoryBaskets) Create(ctx context.Context, basket *models.Basket) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	basket.ID = r.newID("baskets")
+	basket.CreatedAt, basket.UpdatedAt = now, now
+	r.baskets[basket.ID] = *basket
+	return nil
+}
+
+// memorySubscriptions implements SubscriptionRepository
+type memorySubscriptions struct{ *memoryStore }
+
+func (r *memorySubscriptions) FindByID(ctx context.Context, id uint) (*models.Subscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	subscription, ok := r.subscriptions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	subscription = r.subscriptionWithRelations(subscription)
+	return &subscription, nil
+}
+
+func (r *memorySubscriptions) List(ctx context.Context, filter SubscriptionFilter, page PageRequest) (Page[models.Subscription], error) {
+	q, err := newPageQuery(page, subscriptionSortKeys, SortField{Field: "id"})
+	if err != nil {
+		return Page[models.Subscription]{}, err
+	}
+	subscriptions := r.filter(func(s models.Subscription) bool {
+		return (filter.SellerID == 0 || s.Basket.UserID == filter.SellerID) &&
+			(filter.ConsumerID == 0 || s.UserID == filter.ConsumerID) &&
+			(filter.BasketID == 0 || s.BasketID == filter.BasketID) &&
+			(filter.Status == "" || s.Status == filter.Status) &&
+			(filter.Frequency == "" || s.Frequency == filter.Frequency) &&
+			(filter.City == "" || strings.EqualFold(s.User.AddressCity, filter.City)) &&
+			(filter.State == "" || strings.EqualFold(s.User.AddressState, filter.State)) &&
+			inRange(s.CreatedAt, filter.CreatedFrom, filter.CreatedTo)
+	})
+	return q.slice(subscriptions), nil
+}
+
+func (r *memorySubscriptions) filter(keep func(models.Subscription) bool) []models.Subscription {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	subscriptions := []models.Subscription{}
+	for _, subscription := range r.subscriptions {
+		subscription = r.subscriptionWithRelations(subscription)
+		if keep(subscription) {
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	sortByID(subscriptions, func(s models.Subscription) uint { return s.ID })
+	return subscriptions
+}
+
+func (r *memorySubscriptions) Create(ctx context.Context, subscription *models.Subscription, event *models.SubscriptionEvent, jobs JobBuilder[models.Subscription]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	subscription.ID = r.newID("subscriptions")
+	if subscription.CreatedAt.IsZero() {
+		// Like GORM, keep a creation time set by the caller
+		subscription.CreatedAt = now
+	}
+	subscription.UpdatedAt = now
+	built, err := jobs.Build(*subscription)
+	if err != nil {
+		return err
+	}
+	stored := *subscription
+	stored.User, stored.Basket = models.User{}, models.Basket{}
+	r.subscriptions[subscription.ID] = stored
+	r.addEvent(subscription.ID, event)
+	r.enqueueJobs(built, now)
+	return nil
+}
+
+func (r *memorySubscriptions) Transition(ctx context.Context, subscription *models.Subscription, from models.SubscriptionStatus, event *models.SubscriptionEvent, jobs []models.OutboxJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.subscriptions[subscription.ID]
+	if !ok || stored.Status != from {
+		return ErrConflict
+	}
+	stored.Status = subscription.Status
+	stored.NextDeliveryAt = subscription.NextDeliveryAt
+	stored.TrialEndsAt = subscription.TrialEndsAt
+	stored.PausedUntil = subscription.PausedUntil
+	stored.CancelledAt = subscription.CancelledAt
+	stored.CancellationReason = subscription.CancellationReason
+	stored.PastDueAt = subscription.PastDueAt
+	stored.DunningAttempts = subscription.DunningAttempts
+	stored.NextRetryAt = subscription.NextRetryAt
+	stored.DunningOrderID = subscription.DunningOrderID
+	stored.UpdatedAt = time.Now()
+	r.subscriptions[subscription.ID] = stored
+	r.addEvent(subscription.ID, event)
+	r.enqueueJobs(jobs, stored.UpdatedAt)
+	return nil
+}
+
+func (r *memorySubscriptions) ListEvents(ctx context.Context, subscriptionID uint, page PageRequest) (Page[models.SubscriptionEvent], error) {
+	q, err := newPageQuery(page, subscriptionEventSortKeys, SortField{Field: "created_at"})
+	if err != nil {
+		return Page[models.SubscriptionEvent]{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	events := []models.SubscriptionEvent{}
+	for _, event := range r.subscriptionEvents {
+		if event.SubscriptionID == subscriptionID {
+			events = append(events, event)
+		}
+	}
+	return q.slice(events), nil
+}
+
+func (r *memorySubscriptions) ListLapsed(ctx context.Context, now time.Time) ([]models.Subscription, error) {
+	return r.filter(func(s models.Subscription) bool {
+		trialOver := s.Status == models.SubscriptionTrialing && s.TrialEndsAt != nil && !s.TrialEndsAt.After(now)
+		pauseOver := s.Status == models.SubscriptionPaused && s.PausedUntil != nil && !s.PausedUntil.After(now)
+		return trialOver || pauseOver
+	}), nil
+}
+
+// addEvent appends to the history of a subscription
+func (s *memoryStore) addEvent(subscriptionID uint, event *models.SubscriptionEvent) {
+	event.ID = s.newID("subscription_events")
+	event.SubscriptionID = subscriptionID
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	s.subscriptionEvents[event.ID] = *event
+}
+
+func (r *memorySubscriptions) ProcessDue(ctx context.Context, now time.Time, limit int, plan DeliveryPlanner, jobs JobBuilder[models.Order]) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due []models.Subscription
+	for _, subscription := range r.subscriptions {
+		if subscription.Status.ReceivesDeliveries() &&
+			subscription.NextDeliveryAt != nil && !subscription.NextDeliveryAt.After(now) {
+			due = append(due, subscription)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextDeliveryAt.Before(*due[j].NextDeliveryAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	for _, subscription := range due {
+		orders, next := plan(subscription)
+		for _, order := range orders {
+			if r.hasScheduledOrder(order) {
+				continue
+			}
+			stamp := time.Now()
+			order.ID = r.newID("orders")
+			order.CreatedAt, order.UpdatedAt = stamp, stamp
+			order.Subscription = models.Subscription{}
+			built, err := jobs.Build(order)
+			if err != nil {
+				return 0, err
+			}
+			r.orders[order.ID] = order
+			event := scheduledOrderEvent(order, now)
+			r.addOrderEvent(order.ID, &event)
+			r.enqueueJobs(built, stamp)
+		}
+		subscription.NextDeliveryAt = &next
+		r.subscriptions[subscription.ID] = subscription
+	}
+	return len(due), nil
+}
+
+// hasScheduledOrder mirrors the unique index on (subscription_id, scheduled_for)
+func (s *memoryStore) hasScheduledOrder(order models.Order) bool {
+	if order.ScheduledFor == nil {
+		return false
+	}
+	for _, existing := range s.orders {
+		if existing.SubscriptionID == order.SubscriptionID &&
+			existing.ScheduledFor != nil && existing.ScheduledFor.Equal(*order.ScheduledFor) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memorySubscriptions) SavePaymentMethod(ctx context.Context, subscription *models.Subscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.subscriptions[subscription.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	stored.PaymentMethod = subscription.PaymentMethod
+	stored.PaymentToken = subscription.PaymentToken
+	stored.CardBrand = subscription.CardBrand
+	stored.CardLast4 = subscription.CardLast4
+	stored.UpdatedAt = time.Now()
+	r.subscriptions[subscription.ID] = stored
+	return nil
+}
+
+// memoryOrders implements OrderRepository
+type memoryOrders struct{ *memoryStore }
+
+func (r *memoryOrders) FindByID(ctx context.Context, id uint) (*models.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	order, ok := r.orders[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	order = r.orderWithRelations(order)
+	return &order, nil
+}
+
+func (r *memoryOrders) List(ctx context.Context, filter OrderFilter, page PageRequest) (Page[models.Order], error) {
+	q, err := newPageQuery(page, orderSortKeys, SortField{Field: "created_at", Desc: true})
+	if err != nil {
+		return Page[models.Order]{}, err
+	}
+	orders := r.filter(func(o models.Order) bool {
+		var scheduled time.Time
+		if o.ScheduledFor != nil {
+			scheduled = *o.ScheduledFor
+		} else if !filter.ScheduledFrom.IsZero() || !filter.ScheduledTo.IsZero() {
+			return false
+		}
+		return (filter.BasketID == 0 || o.Subscription.BasketID == filter.BasketID) &&
+			(filter.SubscriptionID == 0 || o.SubscriptionID == filter.SubscriptionID) &&
+			(filter.Status == "" || o.Status == filter.Status) &&
+			inRange(o.CreatedAt, filter.CreatedFrom, filter.CreatedTo) &&
+			inRange(scheduled, filter.ScheduledFrom, filter.ScheduledTo)
+	}, true)
+	return q.slice(orders), nil
+}
+
+// filter returns matching orders newest first, optionally with relations loaded
+func (r *memoryOrders) filter(keep func(models.Order) bool, withRelations bool) []models.Order {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	orders := []models.Order{}
+	for _, order := range r.orders {
+		loaded := r.orderWithRelations(order)
+		if !keep(loaded) {
+			continue
+		}
+		if withRelations {
+			order = loaded
+		}
+		orders = append(orders, order)
+	}
+	sort.Slice(orders, func(i, j int) bool { return orders[i].ID > orders[j].ID })
+	return orders
+}
+
+func (r *memoryOrders) Create(ctx context.Context, order *models.Order, event *models.OrderEvent, jobs JobBuilder[models.Order]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	order.ID = r.newID("orders")
+	order.CreatedAt, order.UpdatedAt = now, now
+	built, err := jobs.Build(*order)
+	if err != nil {
+		return err
+	}
+	stored := *order
+	stored.Subscription = models.Subscription{}
+	r.orders[order.ID] = stored
+	r.addOrderEvent(order.ID, event)
+	r.enqueueJobs(built, now)
+	return nil
+}
+
+func (r *memoryOrders) Transition(ctx context.Context, order *models.Order, from models.OrderStatus, events []models.OrderEvent, jobs []models.OutboxJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.orders[order.ID]
+	if !ok || stored.Status != from {
+		return ErrConflict
+	}
+	stored.Status = order.Status
+	stored.TrackingCode = order.TrackingCode
+	stored.ShippedAt = order.ShippedAt
+	stored.DeliveredAt = order.DeliveredAt
+	stored.UpdatedAt = time.Now()
+	r.orders[order.ID] = stored
+	for i := range events {
+		r.addOrderEvent(order.ID, &events[i])
+	}
+	r.enqueueJobs(jobs, stored.UpdatedAt)
+	return nil
+}
+
+func (r *memoryOrders) ListEvents(ctx context.Context, orderID uint) ([]models.OrderEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	events := []models.OrderEvent{}
+	for _, event := range r.orderEvents {
+		if event.OrderID == orderID {
+			events = append(events, event)
+		}
+	}
+	sortByID(events, func(e models.OrderEvent) uint { return e.ID })
+	return events, nil
+}
+
+// addOrderEvent appends to the timeline of an order
+func (s *memoryStore) addOrderEvent(orderID uint, event *models.OrderEvent) {
+	event.ID = s.newID("order_events")
+	event.OrderID = orderID
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	s.orderEvents[event.ID] = *event
+}
+
+// memoryTokens implements TokenRepository
+type memoryTokens struct{ *memoryStore }
+
+func (r *memoryTokens) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.createRefreshToken(token)
+}
+
+func (r *memoryTokens) createRefreshToken(token *models.RefreshToken) error {
+	for _, existing := range r.refreshTokens {
+		if existing.TokenHash == token.TokenHash {
+			return ErrConflict
+		}
+	}
+	now := time.Now()
+	token.ID = r.newID("refresh_tokens")
+	token.CreatedAt, token.UpdatedAt = now, now
+	r.refreshTokens[token.ID] = *token
+	return nil
+}
+
+func (r *memoryTokens) FindRefreshTokenByHash(ctx context.Context, hash string) (*models.RefreshToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, token := range r.refreshTokens {
+		if token.TokenHash == hash {
+			return &token, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryTokens) RotateRefreshToken(ctx context.Context, oldID uint, next *models.RefreshToken, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old, ok := r.refreshTokens[oldID]
+	if !ok || old.RevokedAt != nil {
+		return ErrNotFound
+	}
+	if err := r.createRefreshToken(next); err != nil {
+		return err
+	}
+	old.RevokedAt = &at
+	old.ReplacedBy = next.ID
+	r.refreshTokens[oldID] = old
+	return nil
+}
+
+func (r *memoryTokens) RevokeRefreshToken(ctx context.Context, hash string, userID uint, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, token := range r.refreshTokens {
+		if token.TokenHash == hash && token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = &at
+			r.refreshTokens[id] = token
+		}
+	}
+	return nil
+}
+
+func (r *memoryTokens) RevokeAllRefreshTokens(ctx context.Context, userID uint, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, token := range r.refreshTokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = &at
+			r.refreshTokens[id] = token
+		}
+	}
+	return nil
+}
+
+func (r *memoryTokens) RevokeAccessToken(ctx context.Context, token *models.RevokedToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.revokedTokens[token.TokenID]; exists {
+		return ErrConflict
+	}
+	token.ID = r.newID("revoked_tokens")
+	token.CreatedAt = time.Now()
+	r.revokedTokens[token.TokenID] = *token
+	return nil
+}
+
+func (r *memoryTokens) IsAccessTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, revoked := r.revokedTokens[tokenID]
+	return revoked, nil
+}
+
+func (r *memoryTokens) PurgeExpiredRevocations(ctx context.Context, before time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, token := range r.revokedTokens {
+		if token.ExpiresAt.Before(before) {
+			delete(r.revokedTokens, id)
+		}
+	}
+	return nil
+}
+
+// memoryNotifications implements NotificationRepository
+type memoryNotifications struct{ *memoryStore }
+
+func (r *memoryNotifications) ListPreferences(ctx context.Context, userID uint) ([]models.NotificationPreference, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]models.NotificationPreference{}, r.preferences[userID]...), nil
+}
+
+func (r *memoryNotifications) SavePreferences(ctx context.Context, userID uint, preferences []models.NotificationPreference) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	stored := make([]models.NotificationPreference, 0, len(preferences))
+	for i := range preferences {
+		preferences[i].ID = r.newID("notification_preferences")
+		preferences[i].UserID = userID
+		preferences[i].UpdatedAt = now
+		stored = append(stored, preferences[i])
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].Channel < stored[j].Channel })
+	r.preferences[userID] = stored
+	return nil
+}
+
+func (r *memoryNotifications) RecordDelivery(ctx context.Context, delivery *models.NotificationDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delivery.ID = r.newID("notification_deliveries")
+	delivery.CreatedAt = time.Now()
+	r.deliveries[delivery.ID] = *delivery
+	return nil
+}
+
+func (r *memoryNotifications) ListDeliveries(ctx context.Context, filter DeliveryFilter, page PageRequest) (Page[models.NotificationDelivery], error) {
+	q, err := newPageQuery(page, deliverySortKeys, SortField{Field: "created_at", Desc: true})
+	if err != nil {
+		return Page[models.NotificationDelivery]{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	deliveries := []models.NotificationDelivery{}
+	for _, delivery := range r.deliveries {
+		if (filter.UserID == 0 || delivery.UserID == filter.UserID) &&
+			(filter.Status == "" || delivery.Status == filter.Status) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return q.slice(deliveries), nil
+}
+
+// enqueueJobs adds jobs to the outbox
+func (s *memoryStore) enqueueJobs(jobs []models.OutboxJob, now time.Time) {
+	for i := range jobs {
+		pendingJob(&jobs[i], now)
+		jobs[i].ID = s.newID("outbox_jobs")
+		jobs[i].CreatedAt, jobs[i].UpdatedAt = now, now
+		s.jobs[jobs[i].ID] = jobs[i]
+	}
+}
+
+// memoryJobs implements JobRepository
+type memoryJobs struct{ *memoryStore }
+
+func (r *memoryJobs) FindByID(ctx context.Context, id uint) (*models.OutboxJob, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	job, ok := r.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &job, nil
+}
+
+func (r *memoryJobs) List(ctx context.Context, filter JobFilter, page PageRequest) (Page[models.OutboxJob], error) {
+	q, err := newPageQuery(page, jobSortKeys, SortField{Field: "id", Desc: true})
+	if err != nil {
+		return Page[models.OutboxJob]{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	jobs := []models.OutboxJob{}
+	for _, job := range r.jobs {
+		if (filter.Status == "" || job.Status == filter.Status) &&
+			(filter.Kind == "" || job.Kind == filter.Kind) {
+			jobs = append(jobs, job)
+		}
+	}
+	return q.slice(jobs), nil
+}
+
+func (r *memoryJobs) Enqueue(ctx context.Context, job *models.OutboxJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	jobs := []models.OutboxJob{*job}
+	r.enqueueJobs(jobs, time.Now())
+	*job = jobs[0]
+	return nil
+}
+
+func (r *memoryJobs) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.OutboxJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	runnable := []models.OutboxJob{}
+	for _, job := range r.jobs {
+		if (job.Status == models.JobPending && !job.RunAt.After(now)) ||
+			(job.Status == models.JobRunning && job.LockedUntil != nil && !job.LockedUntil.After(now)) {
+			runnable = append(runnable, job)
+		}
+	}
+	sort.Slice(runnable, func(i, j int) bool {
+		if !runnable[i].RunAt.Equal(runnable[j].RunAt) {
+			return runnable[i].RunAt.Before(runnable[j].RunAt)
+		}
+		return runnable[i].ID < runnable[j].ID
+	})
+	if len(runnable) > limit {
+		runnable = runnable[:limit]
+	}
+
+	lockedUntil := now.Add(lease)
+	for i := range runnable {
+		runnable[i].Status = models.JobRunning
+		runnable[i].Attempts++
+		runnable[i].LockedUntil = &lockedUntil
+		runnable[i].UpdatedAt = now
+		r.jobs[runnable[i].ID] = runnable[i]
+	}
+	return runnable, nil
+}
+
+func (r *memoryJobs) Finish(ctx context.Context, job *models.OutboxJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.jobs[job.ID]
+	if !ok || stored.Status != models.JobRunning || stored.Attempts != job.Attempts {
+		return ErrConflict
+	}
+	job.LockedUntil = nil
+	stored.Status = job.Status
+	stored.RunAt = job.RunAt
+	stored.Payload = job.Payload
+	stored.LastError = job.LastError
+	stored.CompletedAt = job.CompletedAt
+	stored.LockedUntil = nil
+	stored.UpdatedAt = time.Now()
+	r.jobs[job.ID] = stored
+	return nil
+}
+
+func (r *memoryJobs) Replay(ctx context.Context, id uint, now time.Time) (*models.OutboxJob, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if job.Status != models.JobDone && job.Status != models.JobDead {
+		return nil, ErrConflict
+	}
+	job.Status = models.JobPending
+	job.Attempts = 0
+	job.RunAt = now
+	job.CompletedAt = nil
+	job.LockedUntil = nil
+	job.UpdatedAt = now
+	r.jobs[id] = job
+	return &job, nil
+}
+
+// memoryWebhooks implements WebhookRepository
+type memoryWebhooks struct{ *memoryStore }
+
+func (r *memoryWebhooks) ListEndpoints(ctx context.Context, sellerID uint) ([]models.WebhookEndpoint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	endpoints := []models.WebhookEndpoint{}
+	for _, endpoint := range r.webhookEndpoints {
+		if endpoint.UserID == sellerID {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	sortByID(endpoints, func(e models.WebhookEndpoint) uint { return e.ID })
+	return endpoints, nil
+}
+
+func (r *memoryWebhooks) List(ctx context.Context, sellerID uint, page PageRequest) (Page[models.WebhookEndpoint], error) {
+	q, err := newPageQuery(page, webhookEndpointSortKeys, SortField{Field: "id"})
+	if err != nil {
+		return Page[models.WebhookEndpoint]{}, err
+	}
+	endpoints, _ := r.ListEndpoints(ctx, sellerID)
+	return q.slice(endpoints), nil
+}
+
+func (r *memoryWebhooks) FindEndpoint(ctx context.Context, id uint) (*models.WebhookEndpoint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	endpoint, ok := r.webhookEndpoints[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &endpoint, nil
+}
+
+func (r *memoryWebhooks) CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	endpoint.ID = r.newID("webhook_endpoints")
+	endpoint.CreatedAt, endpoint.UpdatedAt = now, now
+	r.webhookEndpoints[endpoint.ID] = *endpoint
+	return nil
+}
+
+func (r *memoryWebhooks) SaveEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.webhookEndpoints[endpoint.ID]; !ok {
+		return ErrNotFound
+	}
+	endpoint.UpdatedAt = time.Now()
+	r.webhookEndpoints[endpoint.ID] = *endpoint
+	return nil
+}
+
+func (r *memoryWebhooks) DeleteEndpoint(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.webhookEndpoints[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.webhookEndpoints, id)
+	for deliveryID, delivery := range r.webhookDeliveries {
+		if delivery.EndpointID == id {
+			delete(r.webhookDeliveries, deliveryID)
+		}
+	}
+	return nil
+}
+
+func (r *memoryWebhooks) RecordDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delivery.ID = r.newID("webhook_deliveries")
+	if delivery.CreatedAt.IsZero() {
+		delivery.CreatedAt = time.Now()
+	}
+	r.webhookDeliveries[delivery.ID] = *delivery
+	return nil
+}
+
+func (r *memoryWebhooks) ListDeliveries(ctx context.Context, endpointID uint, page PageRequest) (Page[models.WebhookDelivery], error) {
+	q, err := newPageQuery(page, webhookDeliverySortKeys, SortField{Field: "id", Desc: true})
+	if err != nil {
+		return Page[models.WebhookDelivery]{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	deliveries := []models.WebhookDelivery{}
+	for _, delivery := range r.webhookDeliveries {
+		if delivery.EndpointID == endpointID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return q.slice(deliveries), nil
+}
+
+// memoryIdempotency implements IdempotencyRepository
+type memoryIdempotency struct{ *memoryStore }
+
+func (r *memoryIdempotency) Reserve(ctx context.Context, key *models.IdempotencyKey, now time.Time) (*models.IdempotencyKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, existing := range r.idempotencyKeys {
+		if existing.UserID != key.UserID || existing.Key != key.Key {
+			continue
+		}
+		if existing.ExpiresAt.After(now) {
+			return &existing, ErrConflict
+		}
+		delete(r.idempotencyKeys, id)
+	}
+	key.ID = r.newID("idempotency_keys")
+	key.CreatedAt = now
+	r.idempotencyKeys[key.ID] = *key
+	return nil, nil
+}
+
+func (r *memoryIdempotency) Complete(ctx context.Context, key *models.IdempotencyKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.idempotencyKeys[key.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	stored.StatusCode = key.StatusCode
+	stored.ContentType = key.ContentType
+	stored.Response = key.Response
+	r.idempotencyKeys[key.ID] = stored
+	return nil
+}
+
+func (r *memoryIdempotency) Release(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.idempotencyKeys, id)
+	return nil
+}
+
+func (r *memoryIdempotency) PurgeExpired(ctx context.Context, before time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, key := range r.idempotencyKeys {
+		if !key.ExpiresAt.After(before) {
+			delete(r.idempotencyKeys, id)
+		}
+	}
+	return nil
+}
+
+// memoryPayments implements PaymentRepository
+type memoryPayments struct{ *memoryStore }
+
+func (r *memoryPayments) FindByID(ctx context.Context, id uint) (*models.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	payment, ok := r.payments[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &payment, nil
+}
+
+func (r *memoryPayments) FindByCharge(ctx context.Context, provider, chargeID string) (*models.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, payment := range r.payments {
+		if payment.Provider == provider && payment.ChargeID == chargeID {
+			return &payment, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryPayments) ListByOrder(ctx context.Context, orderID uint) ([]models.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	payments := []models.Payment{}
+	for _, payment := range r.payments {
+		if payment.OrderID == orderID {
+			payments = append(payments, payment)
+		}
+	}
+	sortByID(payments, func(p models.Payment) uint { return p.ID })
+	return payments, nil
+}
+
+func (r *memoryPayments) Create(ctx context.Context, payment *models.Payment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	payment.ID = r.newID("payments")
+	payment.CreatedAt, payment.UpdatedAt = now, now
+	r.payments[payment.ID] = *payment
+	return nil
+}
+
+func (r *memoryPayments) Save(ctx context.Context, payment *models.Payment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.payments[payment.ID]; !ok {
+		return ErrNotFound
+	}
+	for _, other := range r.payments {
+		if payment.EndToEndID != "" && other.ID != payment.ID && other.EndToEndID == payment.EndToEndID {
+			return ErrConflict
+		}
+	}
+	payment.UpdatedAt = time.Now()
+	r.payments[payment.ID] = *payment
+	return nil
+}
+
+func (r *memoryPayments) Transition(ctx context.Context, payment *models.Payment, from models.PaymentStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.payments[payment.ID]
+	if !ok || stored.Status != from {
+		return ErrConflict
+	}
+	stored.Status = payment.Status
+	stored.PaidAt, stored.RefundedAt = payment.PaidAt, payment.RefundedAt
+	stored.FailureCode, stored.FailureMessage = payment.FailureCode, payment.FailureMessage
+	stored.UpdatedAt = time.Now()
+	r.payments[stored.ID] = stored
+	*payment = stored
+	return nil
+}
+
+func (r *memoryPayments) Settle(ctx context.Context, settlement *PaymentSettlement) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	payment := settlement.Payment
+	order, ok := r.orders[payment.OrderID]
+	if !ok {
+		return false, ErrNotFound
+	}
+	stored, ok := r.payments[payment.ID]
+	if !ok {
+		return false, ErrNotFound
+	}
+
+	var paidBoleto *models.Boleto
+	if b := settlement.Boleto; b != nil {
+		current, ok := r.boletos[b.ID]
+		if !ok {
+			return false, ErrNotFound
+		}
+		if current.Status == models.BoletoPaid {
+			return false, ErrConflict
+		}
+		current.Status, current.PaidAt, current.PaidAmount = models.BoletoPaid, b.PaidAt, b.PaidAmount
+		paidBoleto = &current
+	}
+
+	for _, other := range r.payments {
+		if other.OrderID == payment.OrderID && other.ID != payment.ID &&
+			(other.Status == models.PaymentSucceeded || other.Status == models.PaymentRefunded) {
+			r.saveBoleto(paidBoleto)
+			return false, nil
+		}
+	}
+	from := false
+	for _, status := range settlement.From {
+		from = from || stored.Status == status
+	}
+	if !from {
+		return false, ErrConflict
+	}
+	for _, other := range r.payments {
+		if payment.EndToEndID != "" && other.ID != payment.ID && other.EndToEndID == payment.EndToEndID {
+			return false, ErrConflict
+		}
+	}
+
+	r.saveBoleto(paidBoleto)
+	stored.Status = models.PaymentSucceeded
+	stored.PaidAt, stored.EndToEndID = payment.PaidAt, payment.EndToEndID
+	stored.FailureCode, stored.FailureMessage = "", ""
+	stored.UpdatedAt = time.Now()
+	r.payments[stored.ID] = stored
+	*payment = stored
+
+	event := settlement.Event
+	event.FromStatus, event.ToStatus = order.Status, order.Status
+	r.addOrderEvent(order.ID, &event)
+	return true, nil
+}
+
+// saveBoleto stores b if it is set
+func (s *memoryStore) saveBoleto(b *models.Boleto) {
+	if b == nil {
+		return
+	}
+	b.UpdatedAt = time.Now()
+	s.boletos[b.ID] = *b
+}
+
+// memoryBoletos implements BoletoRepository
+type memoryBoletos struct{ *memoryStore }
+
+func (r *memoryBoletos) FindByID(ctx context.Context, id uint) (*models.Boleto, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	boleto, ok := r.boletos[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &boleto, nil
+}
+
+func (r *memoryBoletos) FindByOurNumber(ctx context.Context, ourNumber string) (*models.Boleto, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, boleto := range r.boletos {
+		if boleto.OurNumber == ourNumber {
+			return &boleto, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryBoletos) ListByOrder(ctx context.Context, orderID uint) ([]models.Boleto, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	boletos := []models.Boleto{}
+	for _, boleto := range r.boletos {
+		if boleto.OrderID == orderID {
+			boletos = append(boletos, boleto)
+		}
+	}
+	sortByID(boletos, func(b models.Boleto) uint { return b.ID })
+	return boletos, nil
+}
+
+func (r *memoryBoletos) Create(ctx context.Context, boleto *models.Boleto, jobs JobBuilder[models.Boleto]) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, other := range r.boletos {
+		if other.OurNumber == boleto.OurNumber {
+			return ErrConflict
+		}
+	}
+	now := time.Now()
+	boleto.ID = r.newID("boletos")
+	boleto.CreatedAt, boleto.UpdatedAt = now, now
+	built, err := jobs.Build(*boleto)
+	if err != nil {
+		return err
+	}
+	r.boletos[boleto.ID] = *boleto
+	r.enqueueJobs(built, now)
+	return nil
+}
+
+func (r *memoryBoletos) Transition(ctx context.Context, boleto *models.Boleto, from models.BoletoStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.boletos[boleto.ID]
+	if !ok || stored.Status != from {
+		return ErrConflict
+	}
+	stored.Status = boleto.Status
+	stored.UpdatedAt = time.Now()
+	r.boletos[stored.ID] = stored
+	*boleto = stored
+	return nil
+}
+
+// memoryInvoices implements InvoiceRepository
+type memoryInvoices struct{ *memoryStore }
+
+func (r *memoryInvoices) FindByID(ctx context.Context, id uint) (*models.Invoice, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	invoice, ok := r.invoices[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &invoice, nil
+}
+
+func (r *memoryInvoices) FindByOrder(ctx context.Context, orderID uint) (*models.Invoice, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, invoice := range r.invoices {
+		if invoice.OrderID == orderID {
+			return &invoice, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryInvoices) ListByConsumer(ctx context.Context, consumerID uint, page PageRequest) (Page[models.Invoice], error) {
+	q, err := newPageQuery(page, invoiceSortKeys, SortField{Field: "issued_at", Desc: true})
+	if err != nil {
+		return Page[models.Invoice]{}, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	invoices := []models.Invoice{}
+	for _, invoice := range r.invoices {
+		if invoice.ConsumerID == consumerID {
+			invoices = append(invoices, invoice)
+		}
+	}
+	return q.slice(invoices), nil
+}
+
+func (r *memoryInvoices) Create(ctx context.Context, invoice *models.Invoice) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, other := range r.invoices {
+		if other.OrderID == invoice.OrderID {
+			return ErrConflict
+		}
+	}
+	now := time.Now()
+	invoice.ID = r.newID("invoices")
+	invoice.Sequence = int(r.newID(fmt.Sprintf("invoice_sequences/%d", invoice.SellerID)))
+	invoice.Number = models.InvoiceNumber(invoice.Sequence)
+	invoice.CreatedAt, invoice.UpdatedAt = now, now
+	items := make([]models.InvoiceItem, len(invoice.Items))
+	for i, item := range invoice.Items {
+		item.ID = r.newID("invoice_items")
+		item.InvoiceID = invoice.ID
+		items[i] = item
+	}
+	invoice.Items = items
+	r.invoices[invoice.ID] = *invoice
+	return nil
+}
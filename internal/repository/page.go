@@ -0,0 +1,348 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// Page sizes of list queries
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
+// ErrInvalidPage is returned for an unknown sort field or a malformed cursor
+var ErrInvalidPage = errors.New("invalid page request")
+
+// SortField orders a list by one field
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// PageRequest selects one page of a list. Cursor is the NextCursor of the
+// previous page, which is only valid with the same sort; empty starts at the
+// first page. An empty Sort uses the default order of the list.
+type PageRequest struct {
+	Limit  int
+	Cursor string
+	Sort   []SortField
+}
+
+// Page is one page of a list
+type Page[T any] struct {
+	Items      []T
+	NextCursor string // Empty on the last page
+	Total      int64  // Records matching the filter across every page
+}
+
+// sortKey is a field a list can be sorted by
+type sortKey[T any] struct {
+	column string              // Qualified SQL column
+	value  func(T) interface{} // An int64, string or time.Time
+}
+
+// sortKeys are the fields of a list that can be sorted by, by name
+type sortKeys[T any] map[string]sortKey[T]
+
+// names returns the sortable fields in alphabetical order
+func (k sortKeys[T]) names() []string {
+	names := make([]string, 0, len(k))
+	for name := range k {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pageQuery is a PageRequest validated against the sort keys of a list.
+// Records are ordered by the requested fields and then by ID, so every
+// record has a distinct position and cursors never skip or repeat one.
+type pageQuery[T any] struct {
+	limit int
+	sort  []SortField
+	keys  []sortKey[T]
+	after []interface{} // Sort values of the last record of the previous page
+}
+
+// cursorData is the decoded form of a cursor
+type cursorData struct {
+	Sort   string            `json:"s"`
+	Values []json.RawMessage `json:"v"`
+}
+
+// newPageQuery validates a page request. Sort fields must be in keys, which
+// must include "id"; without one the list is ordered by defaultSort.
+func newPageQuery[T any](request PageRequest, keys sortKeys[T], defaultSort ...SortField) (pageQuery[T], error) {
+	q := pageQuery[T]{limit: request.Limit, sort: request.Sort}
+	if q.limit <= 0 {
+		q.limit = DefaultPageLimit
+	}
+	if q.limit > MaxPageLimit {
+		q.limit = MaxPageLimit
+	}
+	if len(q.sort) == 0 {
+		q.sort = defaultSort
+	}
+
+	seen := map[string]bool{}
+	for _, field := range q.sort {
+		key, ok := keys[field.Field]
+		if !ok {
+			return q, fmt.Errorf("%w: cannot sort by %q, expected one of %v", ErrInvalidPage, field.Field, keys.names())
+		}
+		if seen[field.Field] {
+			return q, fmt.Errorf("%w: %q is sorted by twice", ErrInvalidPage, field.Field)
+		}
+		seen[field.Field] = true
+		q.keys = append(q.keys, key)
+	}
+	if !seen["id"] {
+		last := SortField{Field: "id"}
+		if len(q.sort) > 0 {
+			last.Desc = q.sort[len(q.sort)-1].Desc
+		}
+		q.sort = append(append([]SortField{}, q.sort...), last)
+		q.keys = append(q.keys, keys["id"])
+	}
+
+	if request.Cursor != "" {
+		after, err := q.decodeCursor(request.Cursor)
+		if err != nil {
+			return q, err
+		}
+		q.after = after
+	}
+	return q, nil
+}
+
+// signature identifies the sort a cursor was created with
+func (q pageQuery[T]) signature() string {
+	fields := make([]string, len(q.sort))
+	for i, field := range q.sort {
+		fields[i] = field.Field
+		if field.Desc {
+			fields[i] = "-" + field.Field
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+// cursor returns the cursor of the page after record
+func (q pageQuery[T]) cursor(record T) string {
+	data := cursorData{Sort: q.signature()}
+	for _, key := range q.keys {
+		value, _ := json.Marshal(key.value(record))
+		data.Values = append(data.Values, value)
+	}
+	encoded, _ := json.Marshal(data)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// decodeCursor returns the sort values stored in a cursor, typed like the
+// values of the sort keys
+func (q pageQuery[T]) decodeCursor(cursor string) ([]interface{}, error) {
+	invalid := fmt.Errorf("%w: malformed cursor", ErrInvalidPage)
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalid
+	}
+	var data cursorData
+	if err := json.Unmarshal(raw, &data); err != nil || len(data.Values) != len(q.keys) {
+		return nil, invalid
+	}
+	if data.Sort != q.signature() {
+		return nil, fmt.Errorf("%w: cursor was created with sort %q", ErrInvalidPage, data.Sort)
+	}
+
+	var zero T
+	values := make([]interface{}, len(q.keys))
+	for i, key := range q.keys {
+		decoder := json.NewDecoder(bytes.NewReader(data.Values[i]))
+		var err error
+		switch key.value(zero).(type) {
+		case int64:
+			var v int64
+			err = decoder.Decode(&v)
+			values[i] = v
+		case time.Time:
+			var v time.Time
+			err = decoder.Decode(&v)
+			values[i] = v
+		default:
+			var v string
+			err = decoder.Decode(&v)
+			values[i] = v
+		}
+		if err != nil {
+			return nil, invalid
+		}
+	}
+	return values, nil
+}
+
+// page trims records, fetched with one more than the limit, to the page size
+// and sets the next cursor when there are more
+func (q pageQuery[T]) page(records []T, total int64) Page[T] {
+	page := Page[T]{Items: records, Total: total}
+	if len(records) > q.limit {
+		page.Items = records[:q.limit]
+		page.NextCursor = q.cursor(page.Items[q.limit-1])
+	}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	return page
+}
+
+// keyset returns the condition selecting records after the cursor, e.g.
+// "((a > ?) OR (a = ? AND b < ?))" for a ascending and b descending
+func (q pageQuery[T]) keyset() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	for i, key := range q.keys {
+		parts := []string{}
+		for j := 0; j < i; j++ {
+			parts = append(parts, q.keys[j].column+" = ?")
+			args = append(args, q.after[j])
+		}
+		operator := " > ?"
+		if q.sort[i].Desc {
+			operator = " < ?"
+		}
+		parts = append(parts, key.column+operator)
+		args = append(args, q.after[i])
+		conditions = append(conditions, "("+strings.Join(parts, " AND ")+")")
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args
+}
+
+// orderBy returns the ORDER BY clause of the query
+func (q pageQuery[T]) orderBy() string {
+	columns := make([]string, len(q.keys))
+	for i, key := range q.keys {
+		columns[i] = key.column
+		if q.sort[i].Desc {
+			columns[i] += " DESC"
+		}
+	}
+	return strings.Join(columns, ", ")
+}
+
+// compare orders a record against sort values
+func (q pageQuery[T]) compare(record T, values []interface{}) int {
+	for i, key := range q.keys {
+		c := compareValues(key.value(record), values[i])
+		if q.sort[i].Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// values returns the sort values of a record
+func (q pageQuery[T]) values(record T) []interface{} {
+	values := make([]interface{}, len(q.keys))
+	for i, key := range q.keys {
+		values[i] = key.value(record)
+	}
+	return values
+}
+
+// slice returns the requested page of records, which must hold every record
+// matching the filter
+func (q pageQuery[T]) slice(records []T) Page[T] {
+	sort.SliceStable(records, func(i, j int) bool {
+		return q.compare(records[i], q.values(records[j])) < 0
+	})
+	start := 0
+	if q.after != nil {
+		start = sort.Search(len(records), func(i int) bool { return q.compare(records[i], q.after) > 0 })
+	}
+	end := min(start+q.limit+1, len(records))
+	return q.page(records[start:end], int64(len(records)))
+}
+
+// compareValues compares two sort values of the same type
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case int64:
+		b := b.(int64)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+		return 0
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	default:
+		return strings.Compare(a.(string), b.(string))
+	}
+}
+
+// Sortable fields of each list
+var (
+	userSortKeys = sortKeys[models.User]{
+		"id":         {column: "users.id", value: func(u models.User) interface{} { return int64(u.ID) }},
+		"created_at": {column: "users.created_at", value: func(u models.User) interface{} { return u.CreatedAt }},
+		"email":      {column: "users.email", value: func(u models.User) interface{} { return u.Email }},
+		"name":       {column: "users.name", value: func(u models.User) interface{} { return u.Name }},
+		"role":       {column: "users.role", value: func(u models.User) interface{} { return u.Role }},
+	}
+	basketSortKeys = sortKeys[models.Basket]{
+		"id":         {column: "baskets.id", value: func(b models.Basket) interface{} { return int64(b.ID) }},
+		"created_at": {column: "baskets.created_at", value: func(b models.Basket) interface{} { return b.CreatedAt }},
+		"name":       {column: "baskets.name", value: func(b models.Basket) interface{} { return b.Name }},
+		"price":      {column: "baskets.price_amount", value: func(b models.Basket) interface{} { return b.Price.Amount }},
+	}
+	subscriptionSortKeys = sortKeys[models.Subscription]{
+		"id":         {column: "subscriptions.id", value: func(s models.Subscription) interface{} { return int64(s.ID) }},
+		"created_at": {column: "subscriptions.created_at", value: func(s models.Subscription) interface{} { return s.CreatedAt }},
+		"status":     {column: "subscriptions.status", value: func(s models.Subscription) interface{} { return string(s.Status) }},
+		"frequency":  {column: "subscriptions.frequency", value: func(s models.Subscription) interface{} { return s.Frequency }},
+	}
+	orderSortKeys = sortKeys[models.Order]{
+		"id":         {column: "orders.id", value: func(o models.Order) interface{} { return int64(o.ID) }},
+		"created_at": {column: "orders.created_at", value: func(o models.Order) interface{} { return o.CreatedAt }},
+		"status":     {column: "orders.status", value: func(o models.Order) interface{} { return string(o.Status) }},
+	}
+	invoiceSortKeys = sortKeys[models.Invoice]{
+		"id":        {column: "invoices.id", value: func(i models.Invoice) interface{} { return int64(i.ID) }},
+		"issued_at": {column: "invoices.issued_at", value: func(i models.Invoice) interface{} { return i.IssuedAt }},
+	}
+	subscriptionEventSortKeys = sortKeys[models.SubscriptionEvent]{
+		"id":         {column: "subscription_events.id", value: func(e models.SubscriptionEvent) interface{} { return int64(e.ID) }},
+		"created_at": {column: "subscription_events.created_at", value: func(e models.SubscriptionEvent) interface{} { return e.CreatedAt }},
+	}
+	deliverySortKeys = sortKeys[models.NotificationDelivery]{
+		"id":         {column: "notification_deliveries.id", value: func(d models.NotificationDelivery) interface{} { return int64(d.ID) }},
+		"created_at": {column: "notification_deliveries.created_at", value: func(d models.NotificationDelivery) interface{} { return d.CreatedAt }},
+	}
+	jobSortKeys = sortKeys[models.OutboxJob]{
+		"id":         {column: "outbox_jobs.id", value: func(j models.OutboxJob) interface{} { return int64(j.ID) }},
+		"created_at": {column: "outbox_jobs.created_at", value: func(j models.OutboxJob) interface{} { return j.CreatedAt }},
+		"run_at":     {column: "outbox_jobs.run_at", value: func(j models.OutboxJob) interface{} { return j.RunAt }},
+	}
+	webhookEndpointSortKeys = sortKeys[models.WebhookEndpoint]{
+		"id":         {column: "webhook_endpoints.id", value: func(e models.WebhookEndpoint) interface{} { return int64(e.ID) }},
+		"created_at": {column: "webhook_endpoints.created_at", value: func(e models.WebhookEndpoint) interface{} { return e.CreatedAt }},
+	}
+	webhookDeliverySortKeys = sortKeys[models.WebhookDelivery]{
+		"id":         {column: "webhook_deliveries.id", value: func(d models.WebhookDelivery) interface{} { return int64(d.ID) }},
+		"created_at": {column: "webhook_deliveries.created_at", value: func(d models.WebhookDelivery) interface{} { return d.CreatedAt }},
+	}
+)
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	sorts := [][]SortField{
+		nil,
+		{{Field: "name"}},
+		{{Field: "price", Desc: true}},
+		{{Field: "created_at", Desc: true}, {Field: "id", Desc: true}},
+	}
+	basket := models.Basket{Name: "Cesta \"orgânica\"", Price: models.BRL(12990)}
+	basket.ID = 7
+	basket.CreatedAt = time.Date(2026, 3, 2, 10, 0, 0, 123456789, time.UTC)
+
+	for _, sort := range sorts {
+		q, err := newPageQuery(PageRequest{Sort: sort}, basketSortKeys, SortField{Field: "id"})
+		if err != nil {
+			t.Fatalf("newPageQuery(%v): %v", sort, err)
+		}
+		cursor := q.cursor(basket)
+
+		next, err := newPageQuery(PageRequest{Sort: sort, Cursor: cursor}, basketSortKeys, SortField{Field: "id"})
+		if err != nil {
+			t.Fatalf("newPageQuery(%v) with its cursor: %v", sort, err)
+		}
+		if want := q.values(basket); !reflect.DeepEqual(next.after, want) {
+			t.Errorf("sort %s: cursor decodes to %#v, want %#v", q.signature(), next.after, want)
+		}
+		if q.compare(basket, next.after) != 0 {
+			t.Errorf("sort %s: decoded cursor does not compare equal to its record", q.signature())
+		}
+	}
+}
+
+func TestNewPageQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		request   PageRequest
+		limit     int
+		signature string
+	}{
+		{"defaults", PageRequest{}, DefaultPageLimit, "id"},
+		{"over the maximum", PageRequest{Limit: MaxPageLimit + 1}, MaxPageLimit, "id"},
+		{"ascending", PageRequest{Limit: 5, Sort: []SortField{{Field: "name"}}}, 5, "name,id"},
+		{"descending ties by descending id", PageRequest{Sort: []SortField{{Field: "price", Desc: true}}}, DefaultPageLimit, "-price,-id"},
+		{"explicit id", PageRequest{Sort: []SortField{{Field: "id", Desc: true}, {Field: "name"}}}, DefaultPageLimit, "-id,name"},
+	}
+	for _, tt := range tests {
+		q, err := newPageQuery(tt.request, basketSortKeys, SortField{Field: "id"})
+		if err != nil {
+			t.Errorf("%s: newPageQuery: %v", tt.name, err)
+			continue
+		}
+		if q.limit != tt.limit || q.signature() != tt.signature {
+			t.Errorf("%s: limit %d sort %s, want %d %s", tt.name, q.limit, q.signature(), tt.limit, tt.signature)
+		}
+	}
+}
+
+func TestNewPageQueryRejects(t *testing.T) {
+	byName := []SortField{{Field: "name"}}
+	q, err := newPageQuery(PageRequest{Sort: byName}, basketSortKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var basket models.Basket
+	basket.ID = 1
+	otherSort, err := newPageQuery(PageRequest{Sort: []SortField{{Field: "name", Desc: true}}}, basketSortKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name    string
+		request PageRequest
+	}{
+		{"unknown field", PageRequest{Sort: []SortField{{Field: "password"}}}},
+		{"field twice", PageRequest{Sort: []SortField{{Field: "name"}, {Field: "name", Desc: true}}}},
+		{"not base64", PageRequest{Sort: byName, Cursor: "not a cursor!"}},
+		{"not JSON", PageRequest{Sort: byName, Cursor: encode("{")}},
+		{"missing values", PageRequest{Sort: byName, Cursor: encode(`{"s":"name,id","v":["a"]}`)}},
+		{"wrong type", PageRequest{Sort: byName, Cursor: encode(`{"s":"name,id","v":["a","b"]}`)}},
+		{"other sort", PageRequest{Sort: byName, Cursor: otherSort.cursor(basket)}},
+		{"other direction", PageRequest{Sort: []SortField{{Field: "name", Desc: true}}, Cursor: q.cursor(basket)}},
+	}
+	for _, tt := range tests {
+		if _, err := newPageQuery(tt.request, basketSortKeys); !errors.Is(err, ErrInvalidPage) {
+			t.Errorf("%s: newPageQuery error = %v, want ErrInvalidPage", tt.name, err)
+		}
+	}
+}
+
+func TestKeyset(t *testing.T) {
+	q, err := newPageQuery(PageRequest{Sort: []SortField{{Field: "price"}, {Field: "name", Desc: true}}}, basketSortKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.after = []interface{}{int64(100), "b", int64(3)}
+
+	where, args := q.keyset()
+	wantWhere := "((baskets.price_amount > ?) OR (baskets.price_amount = ? AND baskets.name < ?) OR " +
+		"(baskets.price_amount = ? AND baskets.name = ? AND baskets.id < ?))"
+	if where != wantWhere {
+		t.Errorf("keyset = %s, want %s", where, wantWhere)
+	}
+	wantArgs := []interface{}{int64(100), int64(100), "b", int64(100), "b", int64(3)}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("keyset args = %v, want %v", args, wantArgs)
+	}
+	if order := q.orderBy(); order != "baskets.price_amount, baskets.name DESC, baskets.id DESC" {
+		t.Errorf("orderBy = %s", order)
+	}
+}
+
+func TestListPagesThroughTies(t *testing.T) {
+	ctx := context.Background()
+	repos := NewMemoryRepositories()
+	// Prices tie in groups of three, so pages of two split every group
+	for i := 1; i <= 9; i++ {
+		basket := models.Basket{UserID: 1, Name: fmt.Sprintf("Cesta %d", 10-i), Price: models.BRL(int64(1000 * ((i + 2) / 3)))}
+		if err := repos.Baskets.Create(ctx, &basket); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		sort []SortField
+		want []uint
+	}{
+		{"default", nil, []uint{1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{"price", []SortField{{Field: "price"}}, []uint{1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{"price descending", []SortField{{Field: "price", Desc: true}}, []uint{9, 8, 7, 6, 5, 4, 3, 2, 1}},
+		{"price descending then id", []SortField{{Field: "price", Desc: true}, {Field: "id"}}, []uint{7, 8, 9, 4, 5, 6, 1, 2, 3}},
+		{"price then name", []SortField{{Field: "price"}, {Field: "name"}}, []uint{3, 2, 1, 6, 5, 4, 9, 8, 7}},
+		{"name descending", []SortField{{Field: "name", Desc: true}}, []uint{1, 2, 3, 4, 5, 6, 7, 8, 9}},
+	}
+	for _, tt := range tests {
+		var got []uint
+		cursor := ""
+		for pages := 0; ; pages++ {
+			if pages > 10 {
+				t.Fatalf("%s: cursors never reach the last page", tt.name)
+			}
+			page, err := repos.Baskets.List(ctx, BasketFilter{}, PageRequest{Limit: 2, Sort: tt.sort, Cursor: cursor})
+			if err != nil {
+				t.Fatalf("%s: List: %v", tt.name, err)
+			}
+			if page.Total != 9 {
+				t.Errorf("%s: total = %d, want 9", tt.name, page.Total)
+			}
+			for _, basket := range page.Items {
+				got = append(got, basket.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			cursor = page.NextCursor
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: pages = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
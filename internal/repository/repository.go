@@ -0,0 +1,360 @@
+// Package repository defines the persistence interfaces used by the HTTP layer,
+// with a GORM implementation for production and an in-memory implementation
+// for hermetic tests and local experiments.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// ErrNotFound is returned when the requested record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// ErrConflict is returned when a write would violate a uniqueness rule
+var ErrConflict = errors.New("record already exists")
+
+// UserFilter narrows the user list. Zero values match everything; date
+// ranges include From and exclude To.
+type UserFilter struct {
+	Role        string
+	Active      *bool
+	City        string
+	State       string
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+}
+
+// UserRepository persists users
+type UserRepository interface {
+	FindByID(ctx context.Context, id uint) (*models.User, error)
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	// List returns a page of users, by ID unless sorted by id, created_at,
+	// email, name or role
+	List(ctx context.Context, filter UserFilter, page PageRequest) (Page[models.User], error)
+	Create(ctx context.Context, user *models.User) error
+	Save(ctx context.Context, user *models.User) error
+	// UpdatePassword replaces only the password hash of a user
+	UpdatePassword(ctx context.Context, userID uint, hash string) error
+}
+
+// BasketFilter narrows the basket list. Zero values match everything.
+type BasketFilter struct {
+	SellerID    uint
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+}
+
+// BasketRepository persists baskets
+type BasketRepository interface {
+	FindByID(ctx context.Context, id uint) (*models.Basket, error)
+	// List returns a page of baskets, by ID unless sorted by id, created_at,
+	// name or price
+	List(ctx context.Context, filter BasketFilter, page PageRequest) (Page[models.Basket], error)
+	Create(ctx context.Context, basket *models.Basket) error
+}
+
+// SubscriptionFilter narrows the subscription list. Zero values match
+// everything; City and State match the consumer's address.
+type SubscriptionFilter struct {
+	SellerID    uint
+	ConsumerID  uint
+	BasketID    uint
+	Status      models.SubscriptionStatus
+	Frequency   string
+	City        string
+	State       string
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+}
+
+// SubscriptionRepository persists subscriptions. Returned subscriptions have
+// their User and Basket loaded.
+type SubscriptionRepository interface {
+	FindByID(ctx context.Context, id uint) (*models.Subscription, error)
+	// List returns a page of subscriptions, by ID unless sorted by id,
+	// created_at, status or frequency
+	List(ctx context.Context, filter SubscriptionFilter, page PageRequest) (Page[models.Subscription], error)
+	// Create stores a new subscription together with its creation event and
+	// the jobs built for it, in one transaction
+	Create(ctx context.Context, subscription *models.Subscription, event *models.SubscriptionEvent, jobs JobBuilder[models.Subscription]) error
+	// Transition saves the lifecycle fields of subscription, appends event
+	// to its history and enqueues jobs, provided the stored status is still
+	// from. It returns ErrConflict if the subscription changed concurrently.
+	Transition(ctx context.Context, subscription *models.Subscription, from models.SubscriptionStatus, event *models.SubscriptionEvent, jobs []models.OutboxJob) error
+	// ListEvents returns a page of the history of a subscription, oldest
+	// first unless sorted by id or created_at
+	ListEvents(ctx context.Context, subscriptionID uint, page PageRequest) (Page[models.SubscriptionEvent], error)
+	// ListLapsed returns trialing subscriptions whose trial ended and paused
+	// subscriptions whose pause ended at or before now
+	ListLapsed(ctx context.Context, now time.Time) ([]models.Subscription, error)
+	// ProcessDue locks up to limit delivering subscriptions whose next delivery is
+	// at or before now and passes each one to plan. The returned orders are
+	// created with a creation event and the jobs built for them, skipping any
+	// already generated for the same subscription and date, and the
+	// subscription's next delivery moves to the returned time.
+	// Subscriptions locked by another instance are skipped, so concurrent
+	// schedulers never generate an order twice. It returns the number of
+	// subscriptions processed.
+	ProcessDue(ctx context.Context, now time.Time, limit int, plan DeliveryPlanner, jobs JobBuilder[models.Order]) (int, error)
+	// SavePaymentMethod saves the payment method fields of subscription
+	SavePaymentMethod(ctx context.Context, subscription *models.Subscription) error
+}
+
+// JobBuilder returns the outbox jobs to enqueue for a record once it has been
+// inserted and has its ID. A nil JobBuilder enqueues nothing.
+type JobBuilder[T any] func(record T) ([]models.OutboxJob, error)
+
+// Build calls b if it is set
+func (b JobBuilder[T]) Build(record T) ([]models.OutboxJob, error) {
+	if b == nil {
+		return nil, nil
+	}
+	return b(record)
+}
+
+// JoinJobs returns a JobBuilder enqueueing the jobs of every builder
+func JoinJobs[T any](builders ...JobBuilder[T]) JobBuilder[T] {
+	return func(record T) ([]models.OutboxJob, error) {
+		var jobs []models.OutboxJob
+		for _, builder := range builders {
+			built, err := builder.Build(record)
+			if err != nil {
+				return nil, err
+			}
+			jobs = append(jobs, built...)
+		}
+		return jobs, nil
+	}
+}
+
+// DeliveryPlanner decides which orders a due subscription gets and when its
+// next delivery is
+type DeliveryPlanner func(subscription models.Subscription) (orders []models.Order, next time.Time)
+
+// OrderFilter narrows the order list. Zero values match everything.
+type OrderFilter struct {
+	BasketID       uint
+	SubscriptionID uint
+	Status         models.OrderStatus
+	CreatedFrom    time.Time
+	CreatedTo      time.Time
+	ScheduledFrom  time.Time
+	ScheduledTo    time.Time
+}
+
+// OrderRepository persists orders. FindByID and List load the subscription
+// with its user and basket.
+type OrderRepository interface {
+	FindByID(ctx context.Context, id uint) (*models.Order, error)
+	// List returns a page of orders, newest first unless sorted by id,
+	// created_at or status
+	List(ctx context.Context, filter OrderFilter, page PageRequest) (Page[models.Order], error)
+	// Create stores a new order together with its creation event and the jobs
+	// built for it, in one transaction
+	Create(ctx context.Context, order *models.Order, event *models.OrderEvent, jobs JobBuilder[models.Order]) error
+	// Transition saves order, appends events to its timeline and enqueues jobs,
+	// provided the stored status is still from. It returns ErrConflict if the
+	// order changed concurrently.
+	Transition(ctx context.Context, order *models.Order, from models.OrderStatus, events []models.OrderEvent, jobs []models.OutboxJob) error
+	// ListEvents returns the timeline of an order, oldest first
+	ListEvents(ctx context.Context, orderID uint) ([]models.OrderEvent, error)
+}
+
+// TokenRepository persists refresh tokens and the access token revocation list
+type TokenRepository interface {
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	FindRefreshTokenByHash(ctx context.Context, hash string) (*models.RefreshToken, error)
+	// RotateRefreshToken stores next and revokes the token with oldID in one step.
+	// It returns ErrNotFound if the old token was already revoked.
+	RotateRefreshToken(ctx context.Context, oldID uint, next *models.RefreshToken, at time.Time) error
+	RevokeRefreshToken(ctx context.Context, hash string, userID uint, at time.Time) error
+	RevokeAllRefreshTokens(ctx context.Context, userID uint, at time.Time) error
+
+	RevokeAccessToken(ctx context.Context, token *models.RevokedToken) error
+	IsAccessTokenRevoked(ctx context.Context, tokenID string) (bool, error)
+	PurgeExpiredRevocations(ctx context.Context, before time.Time) error
+}
+
+// DeliveryFilter narrows ListDeliveries. Zero values match everything.
+type DeliveryFilter struct {
+	UserID uint
+	Status string
+}
+
+// NotificationRepository persists notification preferences and delivery results
+type NotificationRepository interface {
+	ListPreferences(ctx context.Context, userID uint) ([]models.NotificationPreference, error)
+	// SavePreferences replaces the preferences of a user
+	SavePreferences(ctx context.Context, userID uint, preferences []models.NotificationPreference) error
+	RecordDelivery(ctx context.Context, delivery *models.NotificationDelivery) error
+	// ListDeliveries returns a page of delivery results, newest first unless
+	// sorted by id or created_at
+	ListDeliveries(ctx context.Context, filter DeliveryFilter, page PageRequest) (Page[models.NotificationDelivery], error)
+}
+
+// JobFilter narrows the job list. Zero values match everything.
+type JobFilter struct {
+	Status models.JobStatus
+	Kind   string
+}
+
+// JobRepository persists the outbox of background jobs
+type JobRepository interface {
+	FindByID(ctx context.Context, id uint) (*models.OutboxJob, error)
+	// List returns a page of jobs, newest first unless sorted by id,
+	// created_at or run_at
+	List(ctx context.Context, filter JobFilter, page PageRequest) (Page[models.OutboxJob], error)
+	// Enqueue stores a job outside of any other change
+	Enqueue(ctx context.Context, job *models.OutboxJob) error
+	// Claim marks up to limit runnable jobs as running until now+lease and
+	// counts the attempt. Runnable jobs are pending ones whose run_at passed
+	// and running ones whose lease expired. Jobs locked by another worker are
+	// skipped, so a job is never claimed twice.
+	Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.OutboxJob, error)
+	// Finish saves the outcome of a claimed job (status, run_at, payload,
+	// last_error and completed_at) and releases its lease. It returns
+	// ErrConflict if the lease expired and the job was claimed again.
+	Finish(ctx context.Context, job *models.OutboxJob) error
+	// Replay resets a done or dead job to pending so it runs again at now.
+	// It returns ErrConflict for pending and running jobs.
+	Replay(ctx context.Context, id uint, now time.Time) (*models.OutboxJob, error)
+}
+
+// WebhookRepository persists seller webhook endpoints and their delivery log
+type WebhookRepository interface {
+	// ListEndpoints returns every endpoint of a seller, by ID
+	ListEndpoints(ctx context.Context, sellerID uint) ([]models.WebhookEndpoint, error)
+	// List returns a page of the endpoints of a seller, by ID unless sorted
+	// by id or created_at
+	List(ctx context.Context, sellerID uint, page PageRequest) (Page[models.WebhookEndpoint], error)
+	FindEndpoint(ctx context.Context, id uint) (*models.WebhookEndpoint, error)
+	CreateEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error
+	SaveEndpoint(ctx context.Context, endpoint *models.WebhookEndpoint) error
+	// DeleteEndpoint removes an endpoint together with its delivery log
+	DeleteEndpoint(ctx context.Context, id uint) error
+	RecordDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	// ListDeliveries returns a page of the delivery log of an endpoint,
+	// newest first unless sorted by id or created_at
+	ListDeliveries(ctx context.Context, endpointID uint, page PageRequest) (Page[models.WebhookDelivery], error)
+}
+
+// IdempotencyRepository stores the responses to requests sent with an
+// Idempotency-Key
+type IdempotencyRepository interface {
+	// Reserve stores a new key for a request about to be processed. If the
+	// user already used the key and it has not expired, it returns the
+	// stored key and ErrConflict; an expired key is replaced.
+	Reserve(ctx context.Context, key *models.IdempotencyKey, now time.Time) (*models.IdempotencyKey, error)
+	// Complete stores the response of a reserved key
+	Complete(ctx context.Context, key *models.IdempotencyKey) error
+	// Release deletes a reserved key so the request can be sent again
+	Release(ctx context.Context, id uint) error
+	PurgeExpired(ctx context.Context, before time.Time) error
+}
+
+// PaymentRepository persists the charge attempts of orders
+type PaymentRepository interface {
+	FindByID(ctx context.Context, id uint) (*models.Payment, error)
+	// FindByCharge returns the payment of a charge at the given provider
+	FindByCharge(ctx context.Context, provider, chargeID string) (*models.Payment, error)
+	// ListByOrder returns the payments of an order, oldest first
+	ListByOrder(ctx context.Context, orderID uint) ([]models.Payment, error)
+	Create(ctx context.Context, payment *models.Payment) error
+	Save(ctx context.Context, payment *models.Payment) error
+	// Transition saves the status, failure and paid and refunded times of
+	// payment, provided the stored status is still from. It returns
+	// ErrConflict if the payment changed concurrently.
+	Transition(ctx context.Context, payment *models.Payment, from models.PaymentStatus) error
+	// Settle applies money received for a payment in one transaction, one
+	// settlement per order at a time. The payment succeeds unless another
+	// payment of its order succeeded or was refunded; Settle reports whether
+	// it did. It returns ErrConflict, writing nothing, if the boleto was
+	// already paid or the stored payment is no longer in one of From.
+	Settle(ctx context.Context, settlement *PaymentSettlement) (bool, error)
+}
+
+// PaymentSettlement is money received for a payment, applied by
+// PaymentRepository.Settle
+type PaymentSettlement struct {
+	// Payment is marked succeeded with its PaidAt and, for Pix, EndToEndID
+	Payment *models.Payment
+	// From lists the stored statuses the payment may succeed from
+	From []models.PaymentStatus
+	// Boleto is the boleto paid, nil for other methods. It is saved as paid
+	// even if the order was paid by another payment, since the money was
+	// received either way.
+	Boleto *models.Boleto
+	// Event is appended to the order's timeline when the payment succeeds
+	Event models.OrderEvent
+}
+
+// BoletoRepository persists the boletos issued for orders
+type BoletoRepository interface {
+	FindByID(ctx context.Context, id uint) (*models.Boleto, error)
+	// FindByOurNumber returns the boleto with the bank's nosso número
+	FindByOurNumber(ctx context.Context, ourNumber string) (*models.Boleto, error)
+	// ListByOrder returns the boletos of an order, oldest first
+	ListByOrder(ctx context.Context, orderID uint) ([]models.Boleto, error)
+	// Create stores a new boleto together with the jobs built for it, in one
+	// transaction. It returns ErrConflict if its nosso número is taken.
+	Create(ctx context.Context, boleto *models.Boleto, jobs JobBuilder[models.Boleto]) error
+	// Transition saves the status of boleto, provided the stored status is
+	// still from. It returns ErrConflict if the boleto changed concurrently.
+	Transition(ctx context.Context, boleto *models.Boleto, from models.BoletoStatus) error
+}
+
+// InvoiceRepository persists the invoices issued for orders
+type InvoiceRepository interface {
+	// FindByID returns an invoice with its items
+	FindByID(ctx context.Context, id uint) (*models.Invoice, error)
+	// FindByOrder returns the invoice of an order with its items
+	FindByOrder(ctx context.Context, orderID uint) (*models.Invoice, error)
+	// ListByConsumer returns the invoices of a consumer with their items,
+	// newest first by default
+	ListByConsumer(ctx context.Context, consumerID uint, page PageRequest) (Page[models.Invoice], error)
+	// Create numbers a new invoice with its seller's next sequence and stores
+	// it with its items. It returns ErrConflict if the order already has one.
+	Create(ctx context.Context, invoice *models.Invoice) error
+}
+
+// Repositories groups every repository the application needs
+type Repositories struct {
+	Users         UserRepository
+	Baskets       BasketRepository
+	Subscriptions SubscriptionRepository
+	Orders        OrderRepository
+	Tokens        TokenRepository
+	Notifications NotificationRepository
+	Jobs          JobRepository
+	Webhooks      WebhookRepository
+	Idempotency   IdempotencyRepository
+	Payments      PaymentRepository
+	Boletos       BoletoRepository
+	Invoices      InvoiceRepository
+}
+
+// pendingJob prepares a job for insertion
+func pendingJob(job *models.OutboxJob, now time.Time) {
+	job.Status = models.JobPending
+	if job.RunAt.IsZero() {
+		job.RunAt = now
+	}
+}
+
+// scheduledOrderEvent is the creation event of an order generated by ProcessDue
+func scheduledOrderEvent(order models.Order, now time.Time) models.OrderEvent {
+	event := models.OrderEvent{
+		OrderID:   order.ID,
+		Type:      models.OrderEventCreated,
+		ToStatus:  order.Status,
+		CreatedAt: now,
+	}
+	if order.ScheduledFor != nil {
+		event.Note = "Scheduled delivery for " + order.ScheduledFor.Format(time.DateOnly)
+	}
+	return event
+}
@@ -0,0 +1,302 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/alexandreffaria/hoby-loop/internal/auth"
+	"github.com/alexandreffaria/hoby-loop/internal/boleto"
+	"github.com/alexandreffaria/hoby-loop/internal/controllers"
+	"github.com/alexandreffaria/hoby-loop/internal/openapi"
+	"github.com/alexandreffaria/hoby-loop/internal/payments"
+	"github.com/alexandreffaria/hoby-loop/internal/pix"
+	"github.com/alexandreffaria/hoby-loop/models"
+	"github.com/gin-gonic/gin"
+)
+
+// Paths of the API documentation of each version, e.g. /v1/openapi.json.
+// The latest version is also documented at /openapi.json and /docs.
+const (
+	OpenAPIPath = "/openapi.json"
+	DocsPath    = "/docs"
+)
+
+// APIVersions returns the prefixes of the API versions, oldest first
+func APIVersions() []string {
+	prefixes := make([]string, len(apiVersions))
+	for i, version := range apiVersions {
+		prefixes[i] = version.spec.Prefix
+	}
+	return prefixes
+}
+
+// OpenAPI generates the OpenAPI document of the API version mounted at
+// prefix (e.g. /v1) on r, false if there is no such version
+func OpenAPI(r *gin.Engine, prefix string) (*openapi.Document, bool) {
+	for _, version := range apiVersions {
+		if version.spec.Prefix == prefix {
+			return version.spec.Build(r.Routes()), true
+		}
+	}
+	return nil, false
+}
+
+// OpenAPIDrift lists the differences between the routes registered on r and
+// their documentation, for every API version. It is empty when every route
+// is documented.
+func OpenAPIDrift(r *gin.Engine) []string {
+	var drift []string
+	for _, version := range apiVersions {
+		for _, difference := range version.spec.Drift(r.Routes()) {
+			drift = append(drift, version.spec.Prefix+": "+difference)
+		}
+	}
+	return drift
+}
+
+// Filters shared by list endpoints
+var (
+	createdFrom = openapi.DateParam("created_from", "Created at or after")
+	createdTo   = openapi.DateParam("created_to", "Created before the end of")
+	city        = openapi.StringParam("city", "Consumer address city")
+	state       = openapi.StringParam("state", "Consumer address state")
+
+	deliveryStatus = openapi.EnumParam("status", "Delivery result", []string{models.DeliverySent, models.DeliveryFailed, models.DeliverySkipped})
+)
+
+// v1Spec documents every route registered by registerV1. When adding a
+// route, add it here too; `go run ./cmd/openapi -check` fails otherwise.
+var v1Spec = openapi.Spec{
+	Prefix: "/v1",
+	Info: openapi.Info{
+		Title:   "Hoby Loop API",
+		Version: "1.0.0",
+		Description: "Subscription baskets from local sellers. Successful responses wrap their payload as " +
+			"{\"status\", \"data\"} and paginated lists add \"meta\"; errors are {\"status\", \"message\", \"details\"}.",
+	},
+	Tags: []openapi.Tag{
+		{Name: "Auth", Description: "Registration and sessions. Send the access token as Authorization: Bearer <token>."},
+		{Name: "Users", Description: "Profiles and notification settings of the current user"},
+		{Name: "Baskets", Description: "Products sellers offer for subscription"},
+		{Name: "Subscriptions", Description: "Consumer subscriptions to baskets and their lifecycle"},
+		{Name: "Orders", Description: "Deliveries of subscriptions"},
+		{Name: "Payments", Description: "Payment methods and the charges of orders"},
+		{Name: "Invoices", Description: "Billing statements of orders, numbered per seller"},
+		{Name: "Webhooks", Description: "Signed event notifications sent to seller endpoints"},
+		{Name: "Admin", Description: "Platform administration, each endpoint requires a permission"},
+		{Name: "Meta", Description: "API documentation"},
+	},
+	Routes: []openapi.Route{
+		// Meta
+		{Method: http.MethodGet, Path: OpenAPIPath, Tag: "Meta", Public: true, Summary: "This OpenAPI document", ContentType: "application/json"},
+		{Method: http.MethodGet, Path: DocsPath, Tag: "Meta", Public: true, Summary: "API documentation viewer", ContentType: "text/html", Response: ""},
+
+		// Auth
+		{Method: http.MethodPost, Path: "/login", Tag: "Auth", Public: true, Summary: "Log in with email and password",
+			Request: controllers.LoginInput{}, Response: controllers.SessionResponse{}, Errors: []int{http.StatusUnauthorized, http.StatusForbidden}},
+		{Method: http.MethodPost, Path: "/register", Tag: "Auth", Public: true, Summary: "Register a seller or consumer",
+			Description: "Sellers need a valid CNPJ and consumers a valid CPF.",
+			Request:     controllers.RegisterUserInput{}, Response: models.User{}, Errors: []int{http.StatusForbidden}},
+		{Method: http.MethodPost, Path: "/token/refresh", Tag: "Auth", Public: true, Summary: "Exchange a refresh token for a new session",
+			Description: "Refresh tokens are single use. Reusing one revokes every session of the user.",
+			Request:     controllers.RefreshTokenInput{}, Response: controllers.SessionResponse{}, Errors: []int{http.StatusUnauthorized}},
+		{Method: http.MethodPost, Path: "/logout", Tag: "Auth", Summary: "Revoke the access token and, if given, the refresh token",
+			Request: controllers.LogoutInput{}, OptionalBody: true, Response: controllers.MessageResponse{}},
+
+		// Users
+		{Method: http.MethodPut, Path: "/users/:id", Tag: "Users", Summary: "Update a profile",
+			Description: "Only non-empty fields are changed.",
+			Request:     models.User{}, Response: models.User{}},
+		{Method: http.MethodGet, Path: "/users/:id/notification-preferences", Tag: "Users", Summary: "Notification channels of a user",
+			Response: []controllers.ChannelPreference{}},
+		{Method: http.MethodPut, Path: "/users/:id/notification-preferences", Tag: "Users", Summary: "Enable or disable notification channels",
+			Request: controllers.NotificationPreferencesInput{}, Response: []controllers.ChannelPreference{}},
+		{Method: http.MethodGet, Path: "/users/:id/notifications", Tag: "Users", Summary: "Notifications sent to a user, newest first", Paginated: true,
+			Query:    []openapi.Param{deliveryStatus},
+			Response: []models.NotificationDelivery{}},
+
+		// Baskets
+		{Method: http.MethodPost, Path: "/baskets", Tag: "Baskets", Summary: "Create a basket (sellers)",
+			Request: controllers.CreateBasketInput{}, Response: models.Basket{}},
+		{Method: http.MethodGet, Path: "/baskets/:id", Tag: "Baskets", Public: true, Summary: "Get a basket", Response: models.Basket{}},
+		{Method: http.MethodGet, Path: "/sellers/:id/baskets", Tag: "Baskets", Public: true, Summary: "Baskets of a seller", Paginated: true,
+			Query: []openapi.Param{createdFrom, createdTo}, Response: []models.Basket{}},
+
+		// Webhooks
+		{Method: http.MethodPost, Path: "/sellers/:id/webhooks", Tag: "Webhooks", Summary: "Register a webhook endpoint",
+			Description: "The signing secret is only returned in this response.",
+			Request:     controllers.CreateWebhookInput{}, Response: controllers.CreateWebhookResponse{}},
+		{Method: http.MethodGet, Path: "/sellers/:id/webhooks", Tag: "Webhooks", Summary: "Webhook endpoints of a seller", Paginated: true,
+			Response: []models.WebhookEndpoint{}},
+		{Method: http.MethodPut, Path: "/sellers/:id/webhooks/:webhook_id", Tag: "Webhooks", Summary: "Update a webhook endpoint",
+			Request: controllers.UpdateWebhookInput{}, Response: models.WebhookEndpoint{}},
+		{Method: http.MethodDelete, Path: "/sellers/:id/webhooks/:webhook_id", Tag: "Webhooks", Summary: "Delete a webhook endpoint",
+			Response: controllers.MessageResponse{}},
+		{Method: http.MethodGet, Path: "/sellers/:id/webhooks/:webhook_id/deliveries", Tag: "Webhooks", Summary: "Delivery log of an endpoint, newest first", Paginated: true,
+			Response: []models.WebhookDelivery{}},
+		{Method: http.MethodPost, Path: "/sellers/:id/webhooks/:webhook_id/test", Tag: "Webhooks", Summary: "Send a webhook.test event now",
+			Response: controllers.WebhookTestResponse{}},
+
+		// Subscriptions
+		{Method: http.MethodPost, Path: "/subscriptions", Tag: "Subscriptions", Summary: "Subscribe to a basket (consumers)", Idempotent: true,
+			Request: controllers.CreateSubscriptionInput{}, Response: models.Subscription{}},
+		{Method: http.MethodGet, Path: "/sellers/:id/subscriptions", Tag: "Subscriptions", Summary: "Subscriptions to the baskets of a seller", Paginated: true,
+			Query: []openapi.Param{
+				openapi.EnumParam("status", "Subscription status", models.SubscriptionStatuses),
+				openapi.EnumParam("frequency", "Delivery frequency", models.Frequencies),
+				openapi.IDParam("basket_id", "Basket"),
+				city, state, createdFrom, createdTo,
+			},
+			Response: []models.Subscription{}},
+		{Method: http.MethodGet, Path: "/consumers/:id/subscriptions", Tag: "Subscriptions", Summary: "Subscriptions of a consumer", Paginated: true,
+			Query: []openapi.Param{
+				openapi.EnumParam("status", "Subscription status", models.SubscriptionStatuses),
+				openapi.EnumParam("frequency", "Delivery frequency", models.Frequencies),
+				openapi.IDParam("basket_id", "Basket"),
+				createdFrom, createdTo,
+			},
+			Response: []models.Subscription{}},
+		{Method: http.MethodPost, Path: "/subscriptions/:id/pause", Tag: "Subscriptions", Summary: "Pause deliveries, optionally until a date",
+			Request: controllers.PauseSubscriptionInput{}, OptionalBody: true, Response: models.Subscription{}, Errors: []int{http.StatusConflict}},
+		{Method: http.MethodPost, Path: "/subscriptions/:id/resume", Tag: "Subscriptions", Summary: "Resume a paused subscription",
+			Response: models.Subscription{}, Errors: []int{http.StatusConflict}},
+		{Method: http.MethodPost, Path: "/subscriptions/:id/skip-next", Tag: "Subscriptions", Summary: "Skip the next delivery",
+			Response: models.Subscription{}, Errors: []int{http.StatusConflict}},
+		{Method: http.MethodPost, Path: "/subscriptions/:id/cancel", Tag: "Subscriptions", Summary: "Cancel a subscription",
+			Request: controllers.CancelSubscriptionInput{}, Response: models.Subscription{}, Errors: []int{http.StatusConflict}},
+		{Method: http.MethodGet, Path: "/subscriptions/:id/history", Tag: "Subscriptions", Summary: "Lifecycle changes of a subscription, oldest first", Paginated: true,
+			Response: []models.SubscriptionEvent{}},
+
+		// Orders
+		{Method: http.MethodPost, Path: "/orders", Tag: "Orders", Summary: "Create an order for a subscription (sellers)", Idempotent: true,
+			Request: controllers.CreateOrderInput{}, Response: controllers.CreateOrderResponse{}},
+		{Method: http.MethodGet, Path: "/subscriptions/:id/orders", Tag: "Orders", Summary: "Orders of a subscription, newest first", Paginated: true,
+			Query: []openapi.Param{
+				openapi.EnumParam("status", "Order status", models.OrderStatuses),
+				createdFrom, createdTo,
+				openapi.DateParam("scheduled_from", "Scheduled for at or after"),
+				openapi.DateParam("scheduled_to", "Scheduled for before the end of"),
+			},
+			Response: []models.Order{}},
+		{Method: http.MethodGet, Path: "/baskets/:id/orders", Tag: "Orders", Summary: "Orders of a basket, newest first", Paginated: true,
+			Query: []openapi.Param{
+				openapi.EnumParam("status", "Order status", models.OrderStatuses),
+				openapi.IDParam("subscription_id", "Subscription"),
+				createdFrom, createdTo,
+				openapi.DateParam("scheduled_from", "Scheduled for at or after"),
+				openapi.DateParam("scheduled_to", "Scheduled for before the end of"),
+			},
+			Response: []models.Order{}},
+		{Method: http.MethodPut, Path: "/orders/:id/status", Tag: "Orders", Summary: "Move an order to its next status",
+			Request: controllers.UpdateOrderStatusInput{}, Response: models.Order{}, Errors: []int{http.StatusConflict}},
+		{Method: http.MethodGet, Path: "/orders/:id/timeline", Tag: "Orders", Summary: "Changes of an order, oldest first",
+			Response: []models.OrderEvent{}},
+		{Method: http.MethodGet, Path: "/orders/:id", Tag: "Orders", Summary: "Get an order", Response: models.Order{}},
+
+		// Payments
+		{Method: http.MethodPut, Path: "/subscriptions/:id/payment-method", Tag: "Payments", Summary: "Set how each order is paid (subscriber)",
+			Description: "With the card method, the card is passed to the payment provider once; only its token, brand and last four digits are kept. " +
+				"With pix, every order gets a Pix charge to pay from GET /orders/{id}/pix. " +
+				"With boleto, only for subscribers with a CNPJ, every order gets a boleto from GET /orders/{id}/boleto.",
+			Request: controllers.PaymentMethodInput{}, Response: models.Subscription{}, Errors: []int{http.StatusServiceUnavailable}},
+		{Method: http.MethodGet, Path: "/orders/:id/payments", Tag: "Payments", Summary: "Charge attempts of an order, oldest first",
+			Response: []models.Payment{}},
+		{Method: http.MethodPost, Path: "/payments/webhook", Tag: "Payments", Public: true, Summary: "Charge updates from the payment provider",
+			Description: "Authenticated by the provider's signature header (X-Fake-Signature for the fake provider).",
+			Request:     payments.WebhookEvent{}, Response: controllers.MessageResponse{}, Errors: []int{http.StatusUnauthorized, http.StatusServiceUnavailable}},
+		{Method: http.MethodGet, Path: "/orders/:id/pix", Tag: "Payments", Summary: "Pix QR code paying an order", ContentType: "image/png", Response: "",
+			Description: "Returns the same pending Pix payment until it is paid, or 409 once the order is paid. " +
+				"Send Accept: application/json for {\"status\", \"data\": {\"payment\", \"copia_e_cola\"}} instead of the image."},
+		{Method: http.MethodPost, Path: "/payments/pix/webhook", Tag: "Payments", Public: true, Summary: "Pix transfers confirmed by the bank",
+			Description: "Authenticated by the hex HMAC-SHA256 of the body in X-Pix-Signature. Each transfer is matched to the pending payment with its txid.",
+			Request:     pix.Notification{}, Response: []payments.PixReconciliation{}, Errors: []int{http.StatusUnauthorized, http.StatusServiceUnavailable}},
+		{Method: http.MethodGet, Path: "/orders/:id/boleto", Tag: "Payments", Summary: "Boleto paying an order",
+			Description: "Issues a boleto due in boleto.due_days when the order has none open, and returns the same one until it is paid or overdue. " +
+				"Only for subscribers with a CNPJ. Returns the paid boleto once paid, or 409 if the order was paid otherwise.",
+			Response: models.Boleto{}, Errors: []int{http.StatusConflict, http.StatusServiceUnavailable}},
+		{Method: http.MethodGet, Path: "/orders/:id/boleto/pdf", Tag: "Payments", Summary: "Printable boleto paying an order", ContentType: "application/pdf", Response: "",
+			Description: "The boleto of GET /orders/{id}/boleto as an A4 PDF with the payer's receipt and the barcode.",
+			Errors:      []int{http.StatusConflict, http.StatusServiceUnavailable}},
+		{Method: http.MethodPost, Path: "/boletos/validate", Tag: "Payments", Summary: "Check a linha digitável or barcode",
+			Description: "Checks the digits of a 47-digit linha digitável or 44-digit barcode and decodes its bank, due date and amount. " +
+				"Status is set for boletos issued by the platform.",
+			Request: controllers.BoletoLineInput{}, Response: payments.BoletoLine{}},
+		{Method: http.MethodPost, Path: "/payments/boleto/webhook", Tag: "Payments", Public: true, Summary: "Boletos settled by the bank",
+			Description: "Authenticated by the hex HMAC-SHA256 of the body in X-Boleto-Signature. Each settlement pays the boleto with its nosso número, even overdue, for at least its amount.",
+			Request:     boleto.Notification{}, Response: []payments.BoletoReconciliation{}, Errors: []int{http.StatusUnauthorized, http.StatusServiceUnavailable}},
+
+		// Invoices
+		{Method: http.MethodGet, Path: "/consumers/:id/invoices", Tag: "Invoices", Summary: "Invoices of a consumer, newest first", Paginated: true,
+			Response: []models.Invoice{}},
+		{Method: http.MethodGet, Path: "/orders/:id/invoice", Tag: "Invoices", Summary: "Invoice of an order",
+			Description: "Invoices are issued in the background when orders are created; an order without one yet gets it now, with its seller's next number.",
+			Response:    models.Invoice{}},
+		{Method: http.MethodGet, Path: "/invoices/:id", Tag: "Invoices", Summary: "An invoice with its lines",
+			Description: "Available to the consumer and the seller it was issued for. Status and paid_at come from the payments of the order.",
+			Response:    models.Invoice{}},
+		{Method: http.MethodGet, Path: "/invoices/:id/pdf", Tag: "Invoices", Summary: "Printable invoice", ContentType: "application/pdf", Response: "",
+			Description: "The invoice as an A4 PDF in the consumer's language."},
+		{Method: http.MethodGet, Path: "/invoices/:id/html", Tag: "Invoices", Summary: "Invoice as a web page", ContentType: "text/html", Response: "",
+			Description: "The invoice as a standalone HTML page in the consumer's language."},
+
+		// Admin
+		{Method: http.MethodGet, Path: "/admin/users", Tag: "Admin", Summary: "All users", Paginated: true,
+			Query: []openapi.Param{
+				openapi.EnumParam("role", "Role", []string{"seller", "consumer", "admin"}),
+				openapi.BoolParam("is_active", "Whether the account is enabled"),
+				openapi.StringParam("city", "Address city"),
+				openapi.StringParam("state", "Address state"),
+				createdFrom, createdTo,
+			},
+			Response: []models.User{}},
+		{Method: http.MethodPut, Path: "/admin/users/:id/status", Tag: "Admin", Summary: "Enable or disable an account",
+			Request: controllers.UpdateUserStatusInput{}, Response: models.User{}},
+		{Method: http.MethodGet, Path: "/admin/subscriptions", Tag: "Admin", Summary: "All subscriptions", Paginated: true,
+			Query: []openapi.Param{
+				openapi.EnumParam("status", "Subscription status", models.SubscriptionStatuses),
+				openapi.EnumParam("frequency", "Delivery frequency", models.Frequencies),
+				openapi.IDParam("seller_id", "Seller of the basket"),
+				openapi.IDParam("consumer_id", "Subscriber"),
+				openapi.IDParam("basket_id", "Basket"),
+				city, state, createdFrom, createdTo,
+			},
+			Response: []models.Subscription{}},
+		{Method: http.MethodGet, Path: "/admin/baskets", Tag: "Admin", Summary: "All baskets", Paginated: true,
+			Query:    []openapi.Param{openapi.IDParam("seller_id", "Seller"), createdFrom, createdTo},
+			Response: []models.Basket{}},
+		{Method: http.MethodGet, Path: "/admin/notifications", Tag: "Admin", Summary: "Notification delivery results, newest first", Paginated: true,
+			Query: []openapi.Param{
+				deliveryStatus,
+				openapi.IDParam("user_id", "Recipient"),
+			},
+			Response: []models.NotificationDelivery{}},
+		{Method: http.MethodGet, Path: "/admin/notification-templates", Tag: "Admin", Summary: "Events with notification templates and the supported locales",
+			Response: controllers.TemplatesResponse{}},
+		{Method: http.MethodGet, Path: "/admin/notification-templates/:event/preview", Tag: "Admin", Summary: "Render the templates of an event with sample data",
+			Query:    []openapi.Param{openapi.EnumParam("locale", "Template language, the default locale when omitted", models.Locales)},
+			Response: controllers.TemplatePreviewResponse{}},
+		{Method: http.MethodGet, Path: "/admin/jobs", Tag: "Admin", Summary: "Background jobs, newest first", Paginated: true,
+			Query: []openapi.Param{
+				openapi.EnumParam("status", "Job status", models.JobStatuses),
+				openapi.StringParam("kind", "Job kind"),
+			},
+			Response: []models.OutboxJob{}},
+		{Method: http.MethodGet, Path: "/admin/jobs/:id", Tag: "Admin", Summary: "Get a background job", Response: models.OutboxJob{}},
+		{Method: http.MethodPost, Path: "/admin/jobs/:id/replay", Tag: "Admin", Summary: "Run a dead or done job again",
+			Response: models.OutboxJob{}, Errors: []int{http.StatusConflict}},
+		{Method: http.MethodPost, Path: "/admin/payments/:id/refund", Tag: "Admin", Summary: "Refund a succeeded payment in full",
+			Response: models.Payment{}, Errors: []int{http.StatusConflict, http.StatusServiceUnavailable}},
+		{Method: http.MethodGet, Path: "/admin/dunning", Tag: "Admin", Summary: "Subscriptions in dunning after a failed charge", Paginated: true,
+			Description: "Past due subscriptions with the failed order, the attempts made so far, the next retry and the failure of the last attempt.",
+			Query: []openapi.Param{
+				openapi.IDParam("seller_id", "Seller of the basket"),
+				openapi.IDParam("consumer_id", "Subscriber"),
+				openapi.IDParam("basket_id", "Basket"),
+			},
+			Response: []payments.DunningEntry{}},
+		{Method: http.MethodGet, Path: "/admin/permissions", Tag: "Admin", Summary: "Permissions that can be granted", Response: []auth.Permission{}},
+		{Method: http.MethodPost, Path: "/admin/users/:id/permissions", Tag: "Admin", Summary: "Grant permissions to an admin",
+			Request: controllers.PermissionsInput{}, Response: models.User{}},
+		{Method: http.MethodDelete, Path: "/admin/users/:id/permissions/:permission", Tag: "Admin", Summary: "Revoke a permission from an admin",
+			Response: models.User{}},
+	},
+}
@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	r := newTestServer(t).router
+
+	for _, difference := range OpenAPIDrift(r) {
+		t.Errorf("undocumented: %s", difference)
+	}
+}
+
+func TestOpenAPIDocumentEveryVersion(t *testing.T) {
+	r := newTestServer(t).router
+
+	for _, version := range APIVersions() {
+		document, ok := OpenAPI(r, version)
+		if !ok {
+			t.Fatalf("OpenAPI(%q) found no version", version)
+		}
+		if _, err := json.Marshal(document); err != nil {
+			t.Errorf("OpenAPI(%q) does not marshal: %v", version, err)
+		}
+	}
+	if _, ok := OpenAPI(r, "/v0"); ok {
+		t.Error("OpenAPI(\"/v0\") documented an unknown version")
+	}
+}
@@ -1,26 +1,56 @@
 package routes
 
 import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+
+	appconfig "github.com/alexandreffaria/hoby-loop/config"
+	"github.com/alexandreffaria/hoby-loop/internal/auth"
 	"github.com/alexandreffaria/hoby-loop/internal/controllers"
+	"github.com/alexandreffaria/hoby-loop/internal/invoices"
 	"github.com/alexandreffaria/hoby-loop/internal/middleware"
+	"github.com/alexandreffaria/hoby-loop/internal/notify"
+	"github.com/alexandreffaria/hoby-loop/internal/openapi"
+	"github.com/alexandreffaria/hoby-loop/internal/payments"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/internal/webhooks"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-// SetupRouter configures all API routes
-func SetupRouter() *gin.Engine {
+// SetupRouter configures all API routes, wiring controllers to the given
+// repositories, notification service and webhook sender. The API is served
+// under a prefix per version (/v1) and, while legacy routes are enabled,
+// without prefix as a deprecated copy of the first version.
+func SetupRouter(repos repository.Repositories, notifier *notify.Service, sender *webhooks.Sender, billing *payments.Service, invoicing *invoices.Service) *gin.Engine {
+	cfg := appconfig.Get()
+	if cfg.Log.Level != "debug" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
 	r := gin.Default()
 
 	// Configure CORS
 	config := cors.DefaultConfig()
-	config.AllowAllOrigins = true
+	if slices.Contains(cfg.CORS.AllowedOrigins, "*") {
+		config.AllowAllOrigins = true
+	} else {
+		config.AllowOrigins = cfg.CORS.AllowedOrigins
+	}
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "X-User-ID"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", middleware.IdempotencyKeyHeader}
+	config.ExposeHeaders = []string{middleware.IdempotentReplayedHeader, middleware.DeprecationHeader, middleware.SunsetHeader, middleware.LinkHeader}
 	r.Use(cors.New(config))
 
 	// Apply response and auth middleware
 	r.Use(middleware.ResponseMiddleware())
-	r.Use(middleware.AuthMiddleware())
+	r.Use(middleware.AuthMiddleware(repos.Users, repos.Tokens))
+
+	// Retries of creation endpoints with the same Idempotency-Key replay the first response
+	h := newHandlers(repos, notifier, sender, billing, invoicing, middleware.Idempotency(repos.Idempotency, cfg.Idempotency.TTL))
 
 	// Health check
 	r.GET("/ping", func(c *gin.Context) {
@@ -30,38 +60,193 @@ func SetupRouter() *gin.Engine {
 		})
 	})
 
+	// Every API version under its prefix, with its own documentation
+	for _, version := range apiVersions {
+		api := r.Group(version.spec.Prefix)
+		version.register(api, h)
+		serveDocs(r, api, version.spec)
+	}
+
+	// The documentation of the latest version is also served at the root
+	serveDocs(r, r.Group(""), apiVersions[len(apiVersions)-1].spec)
+
+	// Routes from before versioning keep working, with deprecation headers, until their sunset
+	if cfg.API.LegacyRoutes {
+		deprecatedAt, sunset := cfg.API.LegacyDates()
+		legacy := r.Group("", middleware.Deprecated(legacyVersion.spec.Prefix, deprecatedAt, sunset))
+		legacyVersion.register(legacy, h)
+	}
+
+	for _, drift := range OpenAPIDrift(r) {
+		log.Printf("⚠️ OpenAPI: %s", drift)
+	}
+
+	return r
+}
+
+// handlers are the controllers and route middleware every API version is built from
+type handlers struct {
+	repos      repository.Repositories
+	idempotent gin.HandlerFunc
+
+	users         *controllers.UserController
+	auth          *controllers.AuthController
+	baskets       *controllers.BasketController
+	subscriptions *controllers.SubscriptionController
+	orders        *controllers.OrderController
+	notifications *controllers.NotificationController
+	jobs          *controllers.JobController
+	webhooks      *controllers.WebhookController
+	payments      *controllers.PaymentController
+	invoices      *controllers.InvoiceController
+	admin         *controllers.AdminController
+}
+
+// newHandlers creates the controllers of the API
+func newHandlers(repos repository.Repositories, notifier *notify.Service, sender *webhooks.Sender, billing *payments.Service, invoicing *invoices.Service, idempotent gin.HandlerFunc) *handlers {
+	return &handlers{
+		repos:         repos,
+		idempotent:    idempotent,
+		users:         controllers.NewUserController(repos.Users, repos.Tokens),
+		auth:          controllers.NewAuthController(repos.Users, repos.Tokens),
+		baskets:       controllers.NewBasketController(repos.Baskets),
+		subscriptions: controllers.NewSubscriptionController(repos.Subscriptions, repos.Baskets),
+		orders:        controllers.NewOrderController(repos.Orders, repos.Subscriptions),
+		notifications: controllers.NewNotificationController(repos.Notifications, notifier),
+		jobs:          controllers.NewJobController(repos.Jobs),
+		webhooks:      controllers.NewWebhookController(repos.Webhooks, sender),
+		payments:      controllers.NewPaymentController(billing, repos.Subscriptions),
+		invoices:      controllers.NewInvoiceController(invoicing),
+		admin:         controllers.NewAdminController(repos.Users, repos.Baskets, repos.Subscriptions),
+	}
+}
+
+// apiVersion is a version of the API mounted under spec.Prefix
+type apiVersion struct {
+	spec     openapi.Spec
+	register func(api *gin.RouterGroup, h *handlers)
+}
+
+// apiVersions are mounted side by side, oldest first. Breaking changes to
+// request or response shapes go in a new version: add registerV2 calling
+// registerV1's routes that did not change plus the changed ones (new
+// controller methods or response types), document them in a v2Spec with
+// Prefix /v2 and append it here. Older versions keep working unchanged.
+var apiVersions = []apiVersion{
+	{spec: v1Spec, register: registerV1},
+}
+
+// legacyVersion is also served without prefix for clients from before
+// versioning, see middleware.Deprecated
+var legacyVersion = apiVersions[0]
+
+// serveDocs serves the OpenAPI document and docs UI of spec on group. The
+// document is generated on first use, once every route is registered.
+func serveDocs(r *gin.Engine, group *gin.RouterGroup, spec openapi.Spec) {
+	document := sync.OnceValues(func() ([]byte, error) { return json.Marshal(spec.Build(r.Routes())) })
+	group.GET(OpenAPIPath, func(c *gin.Context) {
+		body, err := document()
+		if err != nil {
+			middleware.ServerError(c, "Failed to generate OpenAPI document: "+err.Error())
+			return
+		}
+		c.Data(http.StatusOK, "application/json", body)
+	})
+	group.GET(DocsPath, openapi.DocsHandler(spec.Info.Title+" "+spec.Info.Version, group.BasePath()+OpenAPIPath))
+}
+
+// registerV1 registers the routes of version 1 of the API
+func registerV1(api *gin.RouterGroup, h *handlers) {
 	// Auth routes
-	r.POST("/login", controllers.Login)
-	r.POST("/register", controllers.RegisterUser)
-	
+	api.POST("/login", h.users.Login)
+	api.POST("/register", h.users.RegisterUser)
+	api.POST("/token/refresh", h.auth.RefreshToken)
+	api.POST("/logout", middleware.RequireAuth(), h.auth.Logout)
+
 	// User routes
-	r.PUT("/users/:id", controllers.UpdateUser)
-	
+	api.PUT("/users/:id", middleware.RequireSelf("id", auth.PermissionUsersWrite), h.users.UpdateUser)
+	api.GET("/users/:id/notification-preferences", middleware.RequireSelf("id", auth.PermissionUsersRead), h.notifications.GetPreferences)
+	api.PUT("/users/:id/notification-preferences", middleware.RequireSelf("id", auth.PermissionUsersWrite), h.notifications.UpdatePreferences)
+	api.GET("/users/:id/notifications", middleware.RequireSelf("id", auth.PermissionNotificationsRead), h.notifications.GetUserNotifications)
+
 	// Basket routes
-	r.POST("/baskets", controllers.CreateBasket)
-	r.GET("/baskets/:id", controllers.GetBasket)
-	r.GET("/sellers/:id/baskets", controllers.GetSellerBaskets)
-	
+	api.POST("/baskets", middleware.RequireRole("seller"), h.baskets.CreateBasket)
+	api.GET("/baskets/:id", h.baskets.GetBasket)
+	api.GET("/sellers/:id/baskets", h.baskets.GetSellerBaskets)
+
+	// Seller webhook routes
+	sellerWebhooks := api.Group("/sellers/:id/webhooks", middleware.RequireRole("seller"), middleware.RequireSelf("id"))
+	{
+		sellerWebhooks.POST("", h.webhooks.CreateWebhook)
+		sellerWebhooks.GET("", h.webhooks.GetWebhooks)
+		sellerWebhooks.PUT("/:webhook_id", h.webhooks.UpdateWebhook)
+		sellerWebhooks.DELETE("/:webhook_id", h.webhooks.DeleteWebhook)
+		sellerWebhooks.GET("/:webhook_id/deliveries", h.webhooks.GetWebhookDeliveries)
+		sellerWebhooks.POST("/:webhook_id/test", h.webhooks.SendTestEvent)
+	}
+
 	// Subscription routes
-	r.POST("/subscriptions", controllers.CreateSubscription)
-	r.GET("/sellers/:id/subscriptions", controllers.GetSellerSubscriptions)
-	r.GET("/consumers/:id/subscriptions", controllers.GetConsumerSubscriptions)
-	
+	api.POST("/subscriptions", middleware.RequireRole("consumer"), h.idempotent, h.subscriptions.CreateSubscription)
+	api.GET("/sellers/:id/subscriptions", middleware.RequireSelf("id", auth.PermissionSubscriptionsRead), h.subscriptions.GetSellerSubscriptions)
+	api.GET("/consumers/:id/subscriptions", middleware.RequireSelf("id", auth.PermissionSubscriptionsRead), h.subscriptions.GetConsumerSubscriptions)
+	api.POST("/subscriptions/:id/pause", middleware.RequireSubscriber(h.repos.Subscriptions, "id"), h.subscriptions.PauseSubscription)
+	api.POST("/subscriptions/:id/resume", middleware.RequireSubscriber(h.repos.Subscriptions, "id"), h.subscriptions.ResumeSubscription)
+	api.POST("/subscriptions/:id/skip-next", middleware.RequireSubscriber(h.repos.Subscriptions, "id"), h.subscriptions.SkipNextDelivery)
+	api.POST("/subscriptions/:id/cancel", middleware.RequireSubscriptionAccess(h.repos.Subscriptions, "id"), h.subscriptions.CancelSubscription)
+	api.GET("/subscriptions/:id/history", middleware.RequireSubscriptionAccess(h.repos.Subscriptions, "id", auth.PermissionSubscriptionsRead), h.subscriptions.GetSubscriptionHistory)
+	api.PUT("/subscriptions/:id/payment-method", middleware.RequireSubscriber(h.repos.Subscriptions, "id"), h.payments.UpdatePaymentMethod)
+
 	// Order routes
-	r.POST("/orders", controllers.CreateOrder)
-	r.GET("/subscriptions/:id/orders", controllers.GetSubscriptionOrders)
-	r.GET("/baskets/:id/orders", controllers.GetBasketOrders)
-	r.PUT("/orders/:id/status", controllers.UpdateOrderStatus)
-	r.GET("/orders/:id", controllers.GetOrder)
-	
+	api.POST("/orders", middleware.RequireRole("seller", "admin"), h.idempotent, h.orders.CreateOrder)
+	api.GET("/subscriptions/:id/orders", middleware.RequireSubscriptionAccess(h.repos.Subscriptions, "id", auth.PermissionOrdersRead), h.orders.GetSubscriptionOrders)
+	api.GET("/baskets/:id/orders", middleware.RequireBasketOwner(h.repos.Baskets, "id", auth.PermissionOrdersRead), h.orders.GetBasketOrders)
+	api.PUT("/orders/:id/status", middleware.RequireOrderSeller(h.repos.Orders, "id", auth.PermissionOrdersWrite), h.orders.UpdateOrderStatus)
+	api.GET("/orders/:id/timeline", middleware.RequireOrderAccess(h.repos.Orders, "id", auth.PermissionOrdersRead), h.orders.GetOrderTimeline)
+	api.GET("/orders/:id", middleware.RequireOrderAccess(h.repos.Orders, "id", auth.PermissionOrdersRead), h.orders.GetOrder)
+
+	// Payment routes, the provider's and bank's webhooks are authenticated by their signature
+	api.GET("/orders/:id/payments", middleware.RequireOrderAccess(h.repos.Orders, "id", auth.PermissionOrdersRead), h.payments.GetOrderPayments)
+	api.POST("/payments/webhook", h.payments.ReceiveWebhook)
+	api.GET("/orders/:id/pix", middleware.RequireOrderAccess(h.repos.Orders, "id", auth.PermissionOrdersRead), h.payments.GetOrderPix)
+	api.POST("/payments/pix/webhook", h.payments.ReceivePixWebhook)
+	api.GET("/orders/:id/boleto", middleware.RequireOrderAccess(h.repos.Orders, "id", auth.PermissionOrdersRead), h.payments.GetOrderBoleto)
+	api.GET("/orders/:id/boleto/pdf", middleware.RequireOrderAccess(h.repos.Orders, "id", auth.PermissionOrdersRead), h.payments.GetOrderBoletoPDF)
+	api.POST("/boletos/validate", middleware.RequireAuth(), h.payments.ValidateBoletoLine)
+	api.POST("/payments/boleto/webhook", h.payments.ReceiveBoletoWebhook)
+
+	// Invoices
+	api.GET("/consumers/:id/invoices", middleware.RequireSelf("id", auth.PermissionFinanceExport), h.invoices.GetConsumerInvoices)
+	api.GET("/orders/:id/invoice", middleware.RequireOrderAccess(h.repos.Orders, "id", auth.PermissionFinanceExport), h.invoices.GetOrderInvoice)
+	api.GET("/invoices/:id", middleware.RequireInvoiceAccess(h.repos.Invoices, "id", auth.PermissionFinanceExport), h.invoices.GetInvoice)
+	api.GET("/invoices/:id/pdf", middleware.RequireInvoiceAccess(h.repos.Invoices, "id", auth.PermissionFinanceExport), h.invoices.GetInvoicePDF)
+	api.GET("/invoices/:id/html", middleware.RequireInvoiceAccess(h.repos.Invoices, "id", auth.PermissionFinanceExport), h.invoices.GetInvoiceHTML)
+
 	// Admin routes with authentication
-	admin := r.Group("/admin")
+	admin := api.Group("/admin")
 	admin.Use(middleware.RequireAdmin())
 	{
-		admin.GET("/users", controllers.GetAllUsers)
-		admin.GET("/subscriptions", controllers.GetAllSubscriptions)
-		admin.GET("/baskets", controllers.GetAllBaskets)
-	}
+		admin.GET("/users", middleware.RequirePermission(auth.PermissionUsersRead), h.admin.GetAllUsers)
+		admin.PUT("/users/:id/status", middleware.RequirePermission(auth.PermissionUsersWrite), h.admin.UpdateUserStatus)
+		admin.GET("/subscriptions", middleware.RequirePermission(auth.PermissionSubscriptionsRead), h.admin.GetAllSubscriptions)
+		admin.GET("/baskets", middleware.RequirePermission(auth.PermissionBasketsModerate), h.admin.GetAllBaskets)
 
-	return r
-}
\ No newline at end of file
+		// Notification delivery results
+		admin.GET("/notifications", middleware.RequirePermission(auth.PermissionNotificationsRead), h.notifications.GetAllNotifications)
+		admin.GET("/notification-templates", middleware.RequirePermission(auth.PermissionNotificationsRead), h.notifications.GetTemplates)
+		admin.GET("/notification-templates/:event/preview", middleware.RequirePermission(auth.PermissionNotificationsRead), h.notifications.PreviewTemplate)
+
+		// Background job outbox
+		admin.GET("/jobs", middleware.RequirePermission(auth.PermissionJobsRead), h.jobs.GetJobs)
+		admin.GET("/jobs/:id", middleware.RequirePermission(auth.PermissionJobsRead), h.jobs.GetJob)
+		admin.POST("/jobs/:id/replay", middleware.RequirePermission(auth.PermissionJobsManage), h.jobs.ReplayJob)
+
+		// Payments
+		admin.POST("/payments/:id/refund", middleware.RequirePermission(auth.PermissionPaymentsRefund), h.payments.RefundPayment)
+		admin.GET("/dunning", middleware.RequirePermission(auth.PermissionSubscriptionsRead), h.payments.GetDunning)
+
+		// Permission management
+		admin.GET("/permissions", middleware.RequirePermission(auth.PermissionPermissionsManage), h.admin.GetPermissions)
+		admin.POST("/users/:id/permissions", middleware.RequirePermission(auth.PermissionPermissionsManage), h.admin.GrantPermissions)
+		admin.DELETE("/users/:id/permissions/:permission", middleware.RequirePermission(auth.PermissionPermissionsManage), h.admin.RevokePermission)
+	}
+}
@@ -0,0 +1,378 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	appconfig "github.com/alexandreffaria/hoby-loop/config"
+	"github.com/alexandreffaria/hoby-loop/internal/auth"
+	"github.com/alexandreffaria/hoby-loop/internal/boleto"
+	"github.com/alexandreffaria/hoby-loop/internal/invoices"
+	"github.com/alexandreffaria/hoby-loop/internal/notify"
+	"github.com/alexandreffaria/hoby-loop/internal/payments"
+	"github.com/alexandreffaria/hoby-loop/internal/pix"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/internal/webhooks"
+	"github.com/alexandreffaria/hoby-loop/models"
+	"github.com/gin-gonic/gin"
+)
+
+// testServer is the router on in-memory repositories
+type testServer struct {
+	t      *testing.T
+	router *gin.Engine
+	repos  repository.Repositories
+	users  int
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	repos := repository.NewMemoryRepositories()
+	notifier := notify.NewService(repos.Users, repos.Notifications, nil)
+	billing := payments.NewService(payments.NewFakeProvider(""), &pix.Merchant{}, &boleto.Issuer{}, repos)
+	r := SetupRouter(repos, notifier, webhooks.NewSender(repos.Webhooks, 0), billing, invoices.NewService(repos))
+	return &testServer{t: t, router: r, repos: repos}
+}
+
+// user stores an active user with role and returns it with an access token
+func (s *testServer) user(role string, permissions ...auth.Permission) (models.User, string) {
+	s.t.Helper()
+	s.users++
+	granted := auth.PermissionSet{}
+	for _, permission := range permissions {
+		granted[permission] = true
+	}
+	user := models.User{
+		Email:       fmt.Sprintf("%s%d@example.com", role, s.users),
+		Name:        fmt.Sprintf("%s %d", role, s.users),
+		Role:        role,
+		IsActive:    true,
+		Permissions: granted.Encode(),
+	}
+	if err := s.repos.Users.Create(context.Background(), &user); err != nil {
+		s.t.Fatalf("create %s: %v", role, err)
+	}
+	return user, s.token(user)
+}
+
+// token issues an access token for user
+func (s *testServer) token(user models.User) string {
+	s.t.Helper()
+	cfg := appconfig.GetAuthConfig()
+	token, _, err := auth.IssueAccessToken(user.ID, user.Role, cfg.TokenIssuer, cfg.TokenSecret, time.Hour)
+	if err != nil {
+		s.t.Fatalf("issue token: %v", err)
+	}
+	return token
+}
+
+// do sends a request to /v1 with an optional bearer token and JSON body
+func (s *testServer) do(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var reader bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reader).Encode(body); err != nil {
+			s.t.Fatalf("encode body: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, "/v1"+path, &reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// expect sends a request and fails unless it is answered with status. The
+// data of a successful response is decoded into out when given.
+func (s *testServer) expect(status int, method, path, token string, body interface{}, out interface{}) {
+	s.t.Helper()
+	w := s.do(method, path, token, body)
+	if w.Code != status {
+		s.t.Fatalf("%s %s = %d %s, want %d", method, path, w.Code, w.Body.String(), status)
+	}
+	if out != nil {
+		envelope := struct{ Data interface{} }{Data: out}
+		if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+			s.t.Fatalf("%s %s: decode %s: %v", method, path, w.Body.String(), err)
+		}
+	}
+}
+
+// fixture is a seller's basket with a consumer's subscription and an order
+type fixture struct {
+	seller, consumer           models.User
+	sellerToken, consumerToken string
+	basket                     models.Basket
+	subscription               models.Subscription
+	order                      models.Order
+}
+
+// newFixture creates the fixture through the API
+func (s *testServer) newFixture() fixture {
+	s.t.Helper()
+	var f fixture
+	f.seller, f.sellerToken = s.user("seller")
+	f.consumer, f.consumerToken = s.user("consumer")
+
+	s.expect(http.StatusOK, http.MethodPost, "/baskets", f.sellerToken,
+		map[string]interface{}{"name": "Cesta", "description": "Orgânicos da semana", "price": "129.90"}, &f.basket)
+	s.expect(http.StatusOK, http.MethodPost, "/subscriptions", f.consumerToken,
+		map[string]interface{}{"basket_id": f.basket.ID, "frequency": "weekly"}, &f.subscription)
+	var created struct{ Order models.Order }
+	s.expect(http.StatusOK, http.MethodPost, "/orders", f.sellerToken,
+		map[string]interface{}{"subscription_id": f.subscription.ID}, &created)
+	f.order = created.Order
+	return f
+}
+
+func TestAuthentication(t *testing.T) {
+	s := newTestServer(t)
+	consumer, token := s.user("consumer")
+	path := fmt.Sprintf("/consumers/%d/subscriptions", consumer.ID)
+
+	hash, err := auth.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.repos.Users.UpdatePassword(context.Background(), consumer.ID, hash); err != nil {
+		t.Fatal(err)
+	}
+	consumer.Password = hash
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"not bearer", "Basic " + token, http.StatusUnauthorized},
+		{"empty bearer", "Bearer ", http.StatusUnauthorized},
+		{"bad signature", "Bearer " + token[:len(token)-2] + "xx", http.StatusUnauthorized},
+		{"valid", "Bearer " + token, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/v1"+path, nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: GET %s = %d, want %d", tt.name, path, w.Code, tt.want)
+		}
+	}
+
+	s.expect(http.StatusUnauthorized, http.MethodPost, "/login", "", map[string]string{"email": consumer.Email, "password": "wrong horse"}, nil)
+	s.expect(http.StatusUnauthorized, http.MethodPost, "/login", "", map[string]string{"email": "nobody@example.com", "password": "correct horse"}, nil)
+	var session struct {
+		AccessToken string `json:"access_token"`
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/login", "", map[string]string{"email": consumer.Email, "password": "correct horse"}, &session)
+	s.expect(http.StatusOK, http.MethodGet, path, session.AccessToken, nil, nil)
+
+	// Logging out revokes the access token
+	s.expect(http.StatusOK, http.MethodPost, "/logout", session.AccessToken, nil, nil)
+	s.expect(http.StatusUnauthorized, http.MethodGet, path, session.AccessToken, nil, nil)
+
+	// Disabled accounts lose access with tokens issued before
+	consumer.IsActive = false
+	if err := s.repos.Users.Save(context.Background(), &consumer); err != nil {
+		t.Fatal(err)
+	}
+	s.expect(http.StatusForbidden, http.MethodGet, path, token, nil, nil)
+	s.expect(http.StatusForbidden, http.MethodPost, "/login", "", map[string]string{"email": consumer.Email, "password": "correct horse"}, nil)
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	s := newTestServer(t)
+	consumer, _ := s.user("consumer")
+	hash, err := auth.HashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.repos.Users.UpdatePassword(context.Background(), consumer.ID, hash); err != nil {
+		t.Fatal(err)
+	}
+
+	type session struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	var first, second, other session
+	s.expect(http.StatusOK, http.MethodPost, "/login", "", map[string]string{"email": consumer.Email, "password": "correct horse"}, &first)
+	s.expect(http.StatusOK, http.MethodPost, "/login", "", map[string]string{"email": consumer.Email, "password": "correct horse"}, &other)
+
+	// Refreshing rotates the token
+	s.expect(http.StatusOK, http.MethodPost, "/token/refresh", "", map[string]string{"refresh_token": first.RefreshToken}, &second)
+	if second.RefreshToken == "" || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("refresh returned refresh token %q, want a new one", second.RefreshToken)
+	}
+	s.expect(http.StatusUnauthorized, http.MethodPost, "/token/refresh", "", map[string]string{"refresh_token": "unknown"}, nil)
+
+	// Reusing the rotated token revokes every session of the user
+	s.expect(http.StatusUnauthorized, http.MethodPost, "/token/refresh", "", map[string]string{"refresh_token": first.RefreshToken}, nil)
+	s.expect(http.StatusUnauthorized, http.MethodPost, "/token/refresh", "", map[string]string{"refresh_token": second.RefreshToken}, nil)
+	s.expect(http.StatusUnauthorized, http.MethodPost, "/token/refresh", "", map[string]string{"refresh_token": other.RefreshToken}, nil)
+}
+
+func TestOwnershipPolicies(t *testing.T) {
+	s := newTestServer(t)
+	f := s.newFixture()
+	_, strangerToken := s.user("consumer")
+	_, otherSellerToken := s.user("seller")
+	_, bareAdminToken := s.user("admin")
+	_, readerAdminToken := s.user("admin", auth.PermissionOrdersRead, auth.PermissionSubscriptionsRead, auth.PermissionUsersRead)
+	_, superAdminToken := s.user("admin", auth.PermissionAll)
+
+	subscription := fmt.Sprintf("/subscriptions/%d", f.subscription.ID)
+	order := fmt.Sprintf("/orders/%d", f.order.ID)
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   interface{}
+		want   int
+	}{
+		// RequireSelf
+		{"own subscriptions", http.MethodGet, fmt.Sprintf("/consumers/%d/subscriptions", f.consumer.ID), f.consumerToken, nil, http.StatusOK},
+		{"someone else's subscriptions", http.MethodGet, fmt.Sprintf("/consumers/%d/subscriptions", f.consumer.ID), strangerToken, nil, http.StatusForbidden},
+		{"admin without subscriptions:read", http.MethodGet, fmt.Sprintf("/consumers/%d/subscriptions", f.consumer.ID), bareAdminToken, nil, http.StatusForbidden},
+		{"admin with subscriptions:read", http.MethodGet, fmt.Sprintf("/consumers/%d/subscriptions", f.consumer.ID), readerAdminToken, nil, http.StatusOK},
+		{"admin without users:write updates", http.MethodPut, fmt.Sprintf("/users/%d", f.consumer.ID), readerAdminToken, map[string]string{"name": "x"}, http.StatusForbidden},
+		{"invalid id", http.MethodGet, "/consumers/abc/subscriptions", f.consumerToken, nil, http.StatusBadRequest},
+
+		// RequireBasketOwner
+		{"seller's basket orders", http.MethodGet, fmt.Sprintf("/baskets/%d/orders", f.basket.ID), f.sellerToken, nil, http.StatusOK},
+		{"other seller's basket orders", http.MethodGet, fmt.Sprintf("/baskets/%d/orders", f.basket.ID), otherSellerToken, nil, http.StatusForbidden},
+		{"missing basket", http.MethodGet, "/baskets/999/orders", f.sellerToken, nil, http.StatusNotFound},
+		{"admin without orders:read", http.MethodGet, fmt.Sprintf("/baskets/%d/orders", f.basket.ID), bareAdminToken, nil, http.StatusForbidden},
+		{"admin with orders:read", http.MethodGet, fmt.Sprintf("/baskets/%d/orders", f.basket.ID), readerAdminToken, nil, http.StatusOK},
+
+		// RequireSubscriptionAccess
+		{"subscriber's orders", http.MethodGet, subscription + "/orders", f.consumerToken, nil, http.StatusOK},
+		{"seller's subscription orders", http.MethodGet, subscription + "/orders", f.sellerToken, nil, http.StatusOK},
+		{"stranger's subscription orders", http.MethodGet, subscription + "/orders", strangerToken, nil, http.StatusForbidden},
+		{"missing subscription", http.MethodGet, "/subscriptions/999/orders", f.consumerToken, nil, http.StatusNotFound},
+		{"seller's history", http.MethodGet, subscription + "/history", f.sellerToken, nil, http.StatusOK},
+		{"stranger's history", http.MethodGet, subscription + "/history", strangerToken, nil, http.StatusForbidden},
+		{"admin with subscriptions:read history", http.MethodGet, subscription + "/history", readerAdminToken, nil, http.StatusOK},
+
+		// RequireSubscriber has no admin override
+		{"seller pauses", http.MethodPost, subscription + "/pause", f.sellerToken, nil, http.StatusForbidden},
+		{"admin pauses", http.MethodPost, subscription + "/pause", superAdminToken, nil, http.StatusForbidden},
+		{"missing subscription pause", http.MethodPost, "/subscriptions/999/pause", f.consumerToken, nil, http.StatusNotFound},
+
+		// RequireOrderAccess and RequireOrderSeller
+		{"subscriber's order", http.MethodGet, order, f.consumerToken, nil, http.StatusOK},
+		{"seller's order", http.MethodGet, order, f.sellerToken, nil, http.StatusOK},
+		{"stranger's order", http.MethodGet, order, strangerToken, nil, http.StatusForbidden},
+		{"admin without orders:read order", http.MethodGet, order, bareAdminToken, nil, http.StatusForbidden},
+		{"admin with orders:read order", http.MethodGet, order, readerAdminToken, nil, http.StatusOK},
+		{"subscriber ships", http.MethodPut, order + "/status", f.consumerToken, map[string]string{"status": "shipped"}, http.StatusForbidden},
+		{"admin with orders:read ships", http.MethodPut, order + "/status", readerAdminToken, map[string]string{"status": "shipped"}, http.StatusForbidden},
+
+		// Roles
+		{"consumer creates basket", http.MethodPost, "/baskets", f.consumerToken, map[string]string{"name": "x", "description": "x"}, http.StatusForbidden},
+		{"other seller creates order", http.MethodPost, "/orders", otherSellerToken, map[string]uint{"subscription_id": f.subscription.ID}, http.StatusForbidden},
+		{"admin without orders:write creates order", http.MethodPost, "/orders", readerAdminToken, map[string]uint{"subscription_id": f.subscription.ID}, http.StatusForbidden},
+		{"admin lists seller webhooks", http.MethodGet, fmt.Sprintf("/sellers/%d/webhooks", f.seller.ID), superAdminToken, nil, http.StatusForbidden},
+		{"seller lists own webhooks", http.MethodGet, fmt.Sprintf("/sellers/%d/webhooks", f.seller.ID), f.sellerToken, nil, http.StatusOK},
+		{"consumer on admin routes", http.MethodGet, "/admin/users", f.consumerToken, nil, http.StatusForbidden},
+		{"admin without permission", http.MethodGet, "/admin/users", bareAdminToken, nil, http.StatusForbidden},
+		{"admin with permission", http.MethodGet, "/admin/users", readerAdminToken, nil, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if w := s.do(tt.method, tt.path, tt.token, tt.body); w.Code != tt.want {
+			t.Errorf("%s: %s %s = %d %s, want %d", tt.name, tt.method, tt.path, w.Code, w.Body.String(), tt.want)
+		}
+	}
+
+	// Admins holding orders:write act for any seller
+	s.expect(http.StatusOK, http.MethodPut, order+"/status", superAdminToken, map[string]string{"status": "shipped"}, nil)
+}
+
+func TestSubscriptionLifecycleConflicts(t *testing.T) {
+	s := newTestServer(t)
+	f := s.newFixture()
+	path := fmt.Sprintf("/subscriptions/%d", f.subscription.ID)
+
+	steps := []struct {
+		action string
+		body   interface{}
+		want   int
+	}{
+		{"resume", nil, http.StatusConflict}, // Not paused
+		{"pause", map[string]string{"until": "2000-01-01"}, http.StatusBadRequest},
+		{"skip-next", nil, http.StatusOK},
+		{"pause", nil, http.StatusOK},
+		{"pause", nil, http.StatusConflict},
+		{"skip-next", nil, http.StatusConflict}, // No delivery while paused
+		{"resume", nil, http.StatusOK},
+		{"resume", nil, http.StatusConflict},
+		{"cancel", map[string]string{"reason": "Moving abroad"}, http.StatusOK},
+		{"cancel", map[string]string{"reason": "Twice"}, http.StatusConflict},
+		{"pause", nil, http.StatusConflict},
+		{"resume", nil, http.StatusConflict},
+		{"skip-next", nil, http.StatusConflict},
+	}
+	for i, step := range steps {
+		if w := s.do(http.MethodPost, path+"/"+step.action, f.consumerToken, step.body); w.Code != step.want {
+			t.Fatalf("step %d: %s = %d %s, want %d", i+1, step.action, w.Code, w.Body.String(), step.want)
+		}
+	}
+
+	var history []models.SubscriptionEvent
+	s.expect(http.StatusOK, http.MethodGet, path+"/history", f.consumerToken, nil, &history)
+	var actions []string
+	for _, event := range history {
+		actions = append(actions, event.Action)
+	}
+	want := []string{
+		models.SubscriptionEventCreated,
+		models.SubscriptionEventSkipped,
+		models.SubscriptionEventPaused,
+		models.SubscriptionEventResumed,
+		models.SubscriptionEventCancelled,
+	}
+	if !slices.Equal(actions, want) {
+		t.Errorf("history = %v, want %v", actions, want)
+	}
+}
+
+func TestOrderStatusConflicts(t *testing.T) {
+	s := newTestServer(t)
+	f := s.newFixture()
+	path := fmt.Sprintf("/orders/%d/status", f.order.ID)
+
+	steps := []struct {
+		status models.OrderStatus
+		want   int
+	}{
+		{models.OrderDelivered, http.StatusConflict}, // Not shipped yet
+		{"lost", http.StatusBadRequest},
+		{models.OrderShipped, http.StatusOK},
+		{models.OrderShipped, http.StatusOK}, // Repeating the status only updates tracking
+		{models.OrderCancelled, http.StatusConflict},
+		{models.OrderDelivered, http.StatusOK},
+		{models.OrderReturned, http.StatusOK},
+		{models.OrderShipped, http.StatusConflict}, // Returned is final
+	}
+	for i, step := range steps {
+		body := map[string]interface{}{"status": step.status}
+		if w := s.do(http.MethodPut, path, f.sellerToken, body); w.Code != step.want {
+			t.Fatalf("step %d: %s = %d %s, want %d", i+1, step.status, w.Code, w.Body.String(), step.want)
+		}
+	}
+}
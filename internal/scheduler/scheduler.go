@@ -0,0 +1,160 @@
+// Package scheduler generates the recurring orders of active subscriptions
+// and applies time-based lifecycle changes: trials ending and pauses expiring.
+//
+// Every instance may run a scheduler. Due subscriptions are locked with
+// SELECT ... FOR UPDATE SKIP LOCKED and orders carry a unique
+// (subscription_id, scheduled_for) key, so each delivery is created exactly
+// once no matter how many instances are running.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/config"
+	"github.com/alexandreffaria/hoby-loop/internal/lifecycle"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// Scheduler periodically turns due subscriptions into orders
+type Scheduler struct {
+	Subscriptions repository.SubscriptionRepository
+	Interval      time.Duration
+	BatchSize     int
+	MaxCatchUp    int
+	Now           func() time.Time
+	// OrderJobs builds the background jobs queued with each generated order
+	OrderJobs repository.JobBuilder[models.Order]
+	// ResumeJobs builds the background jobs queued when a pause ends
+	ResumeJobs repository.JobBuilder[models.Subscription]
+}
+
+// New creates a Scheduler from the given configuration
+func New(subscriptions repository.SubscriptionRepository, cfg config.SchedulerConfig) *Scheduler {
+	return &Scheduler{
+		Subscriptions: subscriptions,
+		Interval:      cfg.Interval,
+		BatchSize:     cfg.BatchSize,
+		MaxCatchUp:    cfg.MaxCatchUp,
+		Now:           time.Now,
+	}
+}
+
+// Start runs the scheduler in the background until ctx is cancelled.
+// The first run happens immediately so deliveries missed while the
+// service was down are caught up on startup.
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := s.RunOnce(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("scheduler: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce applies lapsed trials and pauses, then processes every subscription
+// that is due and returns how many were processed
+func (s *Scheduler) RunOnce(ctx context.Context) (int, error) {
+	now := s.Now()
+	if err := s.applyLapsed(ctx, now); err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for {
+		processed, err := s.Subscriptions.ProcessDue(ctx, now, s.BatchSize, s.plan(now), s.OrderJobs)
+		total += processed
+		if err != nil {
+			return total, err
+		}
+		if processed < s.BatchSize {
+			return total, nil
+		}
+	}
+}
+
+// applyLapsed ends finished trials and resumes subscriptions whose pause ended
+func (s *Scheduler) applyLapsed(ctx context.Context, now time.Time) error {
+	lapsed, err := s.Subscriptions.ListLapsed(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	for i := range lapsed {
+		subscription := &lapsed[i]
+		from := subscription.Status
+
+		var event models.SubscriptionEvent
+		var jobs []models.OutboxJob
+		if from == models.SubscriptionTrialing {
+			event, err = lifecycle.EndTrial(subscription, now)
+		} else {
+			event, err = lifecycle.Resume(subscription, lifecycle.SystemActor, now)
+			if err == nil {
+				jobs, err = s.ResumeJobs.Build(*subscription)
+			}
+		}
+		if err != nil {
+			log.Printf("scheduler: subscription %d: %v", subscription.ID, err)
+			continue
+		}
+
+		// Another instance or a user got there first
+		err = s.Subscriptions.Transition(ctx, subscription, from, &event, jobs)
+		if err != nil && !errors.Is(err, repository.ErrConflict) {
+			return err
+		}
+	}
+	return nil
+}
+
+// plan returns the DeliveryPlanner for a run at now. It generates one order
+// per delivery missed up to now, at most MaxCatchUp of the most recent ones.
+func (s *Scheduler) plan(now time.Time) repository.DeliveryPlanner {
+	return func(subscription models.Subscription) ([]models.Order, time.Time) {
+		anchor := subscription.CreatedAt
+
+		var due []time.Time
+		next := *subscription.NextDeliveryAt
+		for !next.After(now) {
+			due = append(due, next)
+
+			var err error
+			next, err = lifecycle.NextDelivery(subscription.Frequency, anchor, next)
+			if err != nil {
+				// Park subscriptions we cannot schedule instead of retrying every run
+				log.Printf("scheduler: subscription %d: %v", subscription.ID, err)
+				return nil, now.Add(24 * time.Hour)
+			}
+		}
+
+		if skipped := len(due) - s.MaxCatchUp; skipped > 0 {
+			log.Printf("scheduler: subscription %d: skipping %d missed deliveries", subscription.ID, skipped)
+			due = due[skipped:]
+		}
+
+		orders := make([]models.Order, 0, len(due))
+		for i := range due {
+			scheduledFor := due[i]
+			orders = append(orders, models.Order{
+				SubscriptionID: subscription.ID,
+				Status:         models.OrderPreparing,
+				ScheduledFor:   &scheduledFor,
+			})
+		}
+		return orders, next
+	}
+}
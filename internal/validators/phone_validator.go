@@ -0,0 +1,39 @@
+package validators
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ValidatePhone validates a phone number usable for WhatsApp and SMS.
+// Brazilian numbers may omit the +55 country code: (11) 98765-4321.
+func ValidatePhone(phone string) bool {
+	digits := FormatPhone(phone)
+	if digits == "" {
+		return false
+	}
+	digits = strings.TrimPrefix(digits, "+")
+
+	// Brazilian numbers: country code, two digit area code and 8 or 9 digits
+	if strings.HasPrefix(digits, "55") {
+		return len(digits) == 12 || len(digits) == 13
+	}
+
+	// E.164 allows up to 15 digits
+	return len(digits) >= 8 && len(digits) <= 15
+}
+
+// FormatPhone returns the phone number in E.164 format (+5511987654321)
+func FormatPhone(phone string) string {
+	international := strings.HasPrefix(strings.TrimSpace(phone), "+")
+	digits := regexp.MustCompile(`\D`).ReplaceAllString(phone, "")
+	if digits == "" {
+		return ""
+	}
+
+	// Local Brazilian number without the country code
+	if !international && (len(digits) == 10 || len(digits) == 11) {
+		digits = "55" + digits
+	}
+	return "+" + digits
+}
@@ -0,0 +1,217 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/internal/outbox"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// maxLoggedResponse caps the response body kept in the delivery log
+const maxLoggedResponse = 1024
+
+// ErrNonPublicAddress is returned when an endpoint resolves to an address
+// of a private network, so that sellers cannot reach internal services
+var ErrNonPublicAddress = errors.New("endpoint address is not public")
+
+// nonPublicPrefixes are special-purpose ranges not covered by the netip
+// predicates that are private in practice or reach IPv4 hosts indirectly
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "This network", 0.x.x.x reaches local hosts
+	netip.MustParsePrefix("100.64.0.0/10"),  // Carrier-grade NAT shared address space
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // Benchmarking
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64, embeds any IPv4 address
+	netip.MustParsePrefix("64:ff9b:1::/48"), // Local-use NAT64
+}
+
+// PublicAddress reports whether ip may receive webhooks: loopback, private,
+// link-local, multicast, unspecified and other special-purpose addresses may not
+func PublicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Sender posts signed events to endpoints and logs every attempt
+type Sender struct {
+	Webhooks repository.WebhookRepository
+	Client   *http.Client
+	Now      func() time.Time
+}
+
+// NewSender creates a Sender whose requests time out after timeout. It only
+// connects to public addresses, checked when dialing so that DNS answers
+// changing after the endpoint was registered cannot get around it, and does
+// not follow redirects.
+func NewSender(webhooks repository.WebhookRepository, timeout time.Duration) *Sender {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !PublicAddress(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrNonPublicAddress, address)
+			}
+			return nil
+		},
+	}
+	transport := &http.Transport{
+		Proxy:               nil, // A proxy would be dialed instead of the endpoint
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	return &Sender{
+		Webhooks: webhooks,
+		Client: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		Now: time.Now,
+	}
+}
+
+// Deliver posts event to endpoint once and records the attempt. Responses
+// other than 2xx are returned as errors.
+func (s *Sender) Deliver(ctx context.Context, endpoint models.WebhookEndpoint, event Event, attempt int) (models.WebhookDelivery, error) {
+	delivery := models.WebhookDelivery{
+		EndpointID: endpoint.ID,
+		EventID:    event.ID,
+		Event:      event.Type,
+		Attempt:    attempt,
+	}
+
+	started := s.Now()
+	sendErr := s.post(ctx, endpoint, event, &delivery)
+	delivery.DurationMS = s.Now().Sub(started).Milliseconds()
+	delivery.Success = sendErr == nil
+	if sendErr != nil {
+		delivery.Error = sendErr.Error()
+	}
+
+	if err := s.Webhooks.RecordDelivery(ctx, &delivery); err != nil {
+		log.Printf("webhooks: recording delivery of %s to endpoint %d: %v", event.ID, endpoint.ID, err)
+	}
+	return delivery, sendErr
+}
+
+// post sends the signed request and fills in the response of delivery
+func (s *Sender) post(ctx context.Context, endpoint models.WebhookEndpoint, event Event, delivery *models.WebhookDelivery) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "HobyLoop-Webhooks/1.0")
+	req.Header.Set(EventHeader, event.Type)
+	req.Header.Set(DeliveryHeader, event.ID)
+	req.Header.Set(SignatureHeader, Sign(endpoint.Secret, s.Now(), body))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, maxLoggedResponse))
+	delivery.StatusCode = resp.StatusCode
+	delivery.ResponseBody = string(response)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return nil
+}
+
+// DispatchHandler fans KindDispatch jobs out into one KindDeliver job per
+// active endpoint of the seller subscribed to the event
+func DispatchHandler(subscriptions repository.SubscriptionRepository, webhooks repository.WebhookRepository, jobs repository.JobRepository) outbox.Handler {
+	return func(ctx context.Context, job *models.OutboxJob) error {
+		var payload dispatchPayload
+		if err := outbox.Decode(job, &payload); err != nil {
+			return err
+		}
+
+		subscription, err := subscriptions.FindByID(ctx, payload.SubscriptionID)
+		if err != nil {
+			if errors.Is(err, repository.ErrNotFound) {
+				return outbox.Permanent(fmt.Errorf("subscription %d not found", payload.SubscriptionID))
+			}
+			return err
+		}
+
+		endpoints, err := webhooks.ListEndpoints(ctx, subscription.Basket.UserID)
+		if err != nil {
+			return err
+		}
+		for _, endpoint := range endpoints {
+			if !endpoint.Subscribes(payload.Event.Type) {
+				continue
+			}
+			deliver, err := outbox.NewJob(KindDeliver, deliverPayload{EndpointID: endpoint.ID, Event: payload.Event})
+			if err != nil {
+				return err
+			}
+			// A retried dispatch may enqueue a delivery twice; receivers
+			// discard duplicates by event ID
+			if err := jobs.Enqueue(ctx, &deliver); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// DeliverHandler runs KindDeliver jobs. Deliveries to endpoints that were
+// removed or disabled in the meantime are dropped.
+func DeliverHandler(sender *Sender) outbox.Handler {
+	return func(ctx context.Context, job *models.OutboxJob) error {
+		var payload deliverPayload
+		if err := outbox.Decode(job, &payload); err != nil {
+			return err
+		}
+
+		endpoint, err := sender.Webhooks.FindEndpoint(ctx, payload.EndpointID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !endpoint.Subscribes(payload.Event.Type) {
+			return nil
+		}
+
+		_, err = sender.Deliver(ctx, *endpoint, payload.Event, job.Attempts)
+		return err
+	}
+}
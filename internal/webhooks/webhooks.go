@@ -0,0 +1,193 @@
+// Package webhooks delivers subscription and order events to the HTTP
+// endpoints sellers register, so their own systems do not have to poll.
+//
+// Events are queued through the outbox in the same transaction as the change.
+// A dispatch job fans an event out to every matching endpoint of the seller,
+// with one delivery job per endpoint so each one retries on its own schedule.
+// Every request is signed with the endpoint's secret and logged.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexandreffaria/hoby-loop/internal/outbox"
+	"github.com/alexandreffaria/hoby-loop/models"
+)
+
+// Event types sellers can subscribe to
+const (
+	EventSubscriptionCreated   = "subscription.created"
+	EventSubscriptionCancelled = "subscription.cancelled"
+	EventOrderCreated          = "order.created"
+	EventOrderStatusChanged    = "order.status_changed"
+
+	// EventTest is only sent by the "send test event" endpoint
+	EventTest = "webhook.test"
+)
+
+// Events lists every event type an endpoint can subscribe to
+var Events = []string{
+	EventSubscriptionCreated,
+	EventSubscriptionCancelled,
+	EventOrderCreated,
+	EventOrderStatusChanged,
+}
+
+// Request headers sent with every delivery
+const (
+	SignatureHeader = "X-Hoby-Signature"
+	EventHeader     = "X-Hoby-Event"
+	DeliveryHeader  = "X-Hoby-Delivery"
+)
+
+// ErrInvalidSignature is returned by Verify when a signature does not match
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Event is the JSON body posted to endpoints. ID is the same on every retry,
+// so receivers can discard duplicates.
+type Event struct {
+	ID        string         `json:"id"`
+	Type      string         `json:"type"`
+	CreatedAt time.Time      `json:"created_at"`
+	Data      models.RawJSON `json:"data"`
+}
+
+// NewEvent creates an event with a fresh ID and data encoded as JSON
+func NewEvent(eventType string, data interface{}) (Event, error) {
+	id, err := randomHex(16)
+	if err != nil {
+		return Event{}, err
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, fmt.Errorf("encoding %s data: %w", eventType, err)
+	}
+	return Event{ID: "evt_" + id, Type: eventType, CreatedAt: time.Now().UTC(), Data: encoded}, nil
+}
+
+// NewSecret generates an endpoint signing secret
+func NewSecret() (string, error) {
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + secret, nil
+}
+
+// Sign returns the signature header of body: the timestamp and the
+// hex HMAC-SHA256 of "<timestamp>.<body>", as "t=1700000000,v1=5257a8..."
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + unix + ",v1=" + signature(secret, unix, body)
+}
+
+// Verify checks a signature header produced by Sign and rejects timestamps
+// further than tolerance from now, which protects receivers from replays
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var unix, expected string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			unix = value
+		case "v1":
+			expected = value
+		}
+	}
+
+	seconds, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil || expected == "" {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+	if !hmac.Equal([]byte(signature(secret, unix, body)), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// signature computes the hex HMAC-SHA256 of "<unix>.<body>"
+func signature(secret, unix string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unix))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// randomHex returns n random bytes encoded as hex
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Outbox job kinds
+const (
+	KindDispatch = "webhook.dispatch"
+	KindDeliver  = "webhook.deliver"
+)
+
+// dispatchPayload is the payload of KindDispatch jobs. The seller is found
+// through the subscription when the job runs.
+type dispatchPayload struct {
+	SubscriptionID uint  `json:"subscription_id"`
+	Event          Event `json:"event"`
+}
+
+// deliverPayload is the payload of KindDeliver jobs
+type deliverPayload struct {
+	EndpointID uint  `json:"endpoint_id"`
+	Event      Event `json:"event"`
+}
+
+// NewDispatchJob creates the job sending an event about a subscription, or
+// one of its orders, to the webhooks of the subscription's seller
+func NewDispatchJob(subscriptionID uint, eventType string, data interface{}) (models.OutboxJob, error) {
+	event, err := NewEvent(eventType, data)
+	if err != nil {
+		return models.OutboxJob{}, err
+	}
+	return outbox.NewJob(KindDispatch, dispatchPayload{SubscriptionID: subscriptionID, Event: event})
+}
+
+// SubscriptionJob creates the dispatch job of a subscription event
+func SubscriptionJob(eventType string, subscription models.Subscription) (models.OutboxJob, error) {
+	return NewDispatchJob(subscription.ID, eventType, map[string]interface{}{
+		"subscription": subscription,
+	})
+}
+
+// OrderJob creates the dispatch job of an order event. previous is the status
+// before the change and is omitted when empty.
+func OrderJob(eventType string, order models.Order, previous models.OrderStatus) (models.OutboxJob, error) {
+	order.Subscription = models.Subscription{}
+	data := map[string]interface{}{"order": order}
+	if previous != "" {
+		data["previous_status"] = previous
+	}
+	return NewDispatchJob(order.SubscriptionID, eventType, data)
+}
+
+// OrderCreatedJobs builds the order.created dispatch job of a new order, for
+// use as a repository.JobBuilder
+func OrderCreatedJobs(order models.Order) ([]models.OutboxJob, error) {
+	job, err := OrderJob(EventOrderCreated, order, "")
+	if err != nil {
+		return nil, err
+	}
+	return []models.OutboxJob{job}, nil
+}
@@ -1,22 +1,88 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
 
+	"github.com/alexandreffaria/hoby-loop/config"
+	"github.com/alexandreffaria/hoby-loop/internal/boleto"
+	"github.com/alexandreffaria/hoby-loop/internal/controllers"
 	"github.com/alexandreffaria/hoby-loop/internal/database"
+	"github.com/alexandreffaria/hoby-loop/internal/invoices"
+	"github.com/alexandreffaria/hoby-loop/internal/notify"
+	"github.com/alexandreffaria/hoby-loop/internal/outbox"
+	"github.com/alexandreffaria/hoby-loop/internal/payments"
+	"github.com/alexandreffaria/hoby-loop/internal/pix"
+	"github.com/alexandreffaria/hoby-loop/internal/repository"
 	"github.com/alexandreffaria/hoby-loop/internal/routes"
+	"github.com/alexandreffaria/hoby-loop/internal/scheduler"
+	"github.com/alexandreffaria/hoby-loop/internal/webhooks"
 )
 
 func main() {
+	// Load configuration from defaults, config file, environment and flags
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+
 	// Initialize database connection
 	database.Initialize()
 
-	// Setup router with all routes
-	r := routes.SetupRouter()
+	repos := repository.NewGormRepositories(database.DB)
+
+	// Charge orders through the configured payment provider
+	provider, err := payments.NewProvider(cfg.Payments)
+	if err != nil {
+		log.Fatal("Failed to set up payments: ", err)
+	}
+	billing := payments.NewService(provider, pix.NewMerchant(cfg.Pix), boleto.NewIssuer(cfg.Boleto), repos)
+	invoicing := invoices.NewService(repos)
+
+	// Generate recurring orders in the background, each one notified,
+	// invoiced and charged once created
+	if cfg.Scheduler.Enabled {
+		sched := scheduler.New(repos.Subscriptions, cfg.Scheduler)
+		sched.OrderJobs = controllers.OrderCreatedJobs
+		if billing.Enabled() {
+			sched.OrderJobs = repository.JoinJobs(controllers.OrderCreatedJobs, payments.ChargeJobs)
+			sched.ResumeJobs = payments.DunningRetryJobs
+		}
+		sched.Start(context.Background())
+		log.Printf("⏰ Recurring order scheduler running every %s", cfg.Scheduler.Interval)
+	}
+
+	// Deliver notifications on every configured channel
+	notifier, err := notify.NewServiceFromConfig(cfg.Notifications, repos.Users, repos.Notifications)
+	if err != nil {
+		log.Fatal("Failed to set up notifications: ", err)
+	}
+
+	// Sign and send seller webhooks
+	sender := webhooks.NewSender(repos.Webhooks, cfg.Webhooks.Timeout)
+
+	// Run queued background jobs
+	if cfg.Outbox.Enabled {
+		worker := outbox.New(repos.Jobs, cfg.Outbox)
+		worker.Handle(outbox.KindOrderNotification, outbox.OrderNotificationHandler(repos.Subscriptions, notifier))
+		worker.Handle(outbox.KindPaymentReminder, outbox.PaymentReminderHandler(repos.Subscriptions, notifier))
+		worker.Handle(webhooks.KindDispatch, webhooks.DispatchHandler(repos.Subscriptions, repos.Webhooks, repos.Jobs))
+		worker.Handle(webhooks.KindDeliver, webhooks.DeliverHandler(sender))
+		worker.Handle(payments.KindCharge, payments.ChargeHandler(billing))
+		worker.Handle(payments.KindBoletoOverdue, payments.BoletoOverdueHandler(billing))
+		worker.Handle(payments.KindDunningRetry, payments.DunningRetryHandler(billing))
+		worker.Handle(invoices.KindIssue, invoices.IssueHandler(invoicing))
+		worker.Start(context.Background())
+		log.Printf("📬 Background job workers running (%d)", cfg.Outbox.Workers)
+	}
+
+	// Setup router with all routes backed by the database
+	r := routes.SetupRouter(repos, notifier, sender, billing, invoicing)
 
 	// Start the server
-	log.Println("🚀 Server starting on http://localhost:8080")
-	if err := r.Run(":8080"); err != nil {
+	log.Printf("🚀 Server starting on http://localhost:%s", cfg.Server.Port)
+	if err := r.Run(":" + cfg.Server.Port); err != nil {
 		log.Fatal("Failed to start server: ", err)
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// BoletoStatus is the state of a boleto
+type BoletoStatus string
+
+// Boleto states. Overdue boletos may still be paid late; a new boleto is
+// issued when the order is charged again.
+const (
+	BoletoOpen    BoletoStatus = "open"
+	BoletoPaid    BoletoStatus = "paid"
+	BoletoOverdue BoletoStatus = "overdue"
+)
+
+// BoletoStatuses lists every boleto state
+var BoletoStatuses = []BoletoStatus{BoletoOpen, BoletoPaid, BoletoOverdue}
+
+// IsValid reports whether s is a known boleto state
+func (s BoletoStatus) IsValid() bool {
+	for _, status := range BoletoStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Boleto is a bank slip issued for an order, paid through the Payment it
+// belongs to. The payer is recorded as issued so the boleto can be printed
+// again unchanged.
+type Boleto struct {
+	ID            uint         `json:"id" gorm:"primarykey"`
+	OrderID       uint         `json:"order_id" gorm:"index"`
+	PaymentID     uint         `json:"payment_id" gorm:"index"`
+	BankCode      string       `json:"bank_code"`
+	OurNumber     string       `json:"our_number" gorm:"uniqueIndex"` // Nosso número, the boleto's ID at the bank
+	Barcode       string       `json:"barcode"`
+	DigitableLine string       `json:"digitable_line"`
+	Amount        Money        `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
+	DueDate       time.Time    `json:"due_date" gorm:"type:date"`
+	PayerName     string       `json:"payer_name"`
+	PayerDocument string       `json:"payer_document"` // CNPJ
+	PayerAddress  string       `json:"payer_address,omitempty"`
+	Status        BoletoStatus `json:"status" gorm:"index"`
+	PaidAt        *time.Time   `json:"paid_at,omitempty"`
+	PaidAmount    Money        `json:"paid_amount" gorm:"embedded;embeddedPrefix:paid_amount_"` // Zero until paid, may include late fees
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// IdempotencyKey stores the response to a request sent with an
+// Idempotency-Key header, so retries of the request get the same response
+// instead of repeating it. Keys are scoped to the user sending them. A key
+// without a status code belongs to a request that is still being processed.
+type IdempotencyKey struct {
+	ID          uint   `gorm:"primarykey"`
+	UserID      uint   `gorm:"uniqueIndex:idx_idempotency_keys_user_key"`
+	Key         string `gorm:"uniqueIndex:idx_idempotency_keys_user_key"`
+	Method      string
+	Path        string
+	Fingerprint string // SHA-256 of the method, path and body of the request
+	StatusCode  int
+	ContentType string
+	Response    []byte
+	ExpiresAt   time.Time `gorm:"index"`
+	CreatedAt   time.Time
+}
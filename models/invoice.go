@@ -0,0 +1,105 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// InvoiceItemKind is the kind of a line of an invoice
+type InvoiceItemKind string
+
+// Invoice line kinds. Discounts have negative amounts; taxes are the
+// approximate taxes already included in the price, shown for information
+// and not added to the total.
+const (
+	InvoiceItemBasket   InvoiceItemKind = "basket"
+	InvoiceItemShipping InvoiceItemKind = "shipping"
+	InvoiceItemDiscount InvoiceItemKind = "discount"
+	InvoiceItemTax      InvoiceItemKind = "tax"
+)
+
+// InvoiceItemKinds lists every invoice line kind
+var InvoiceItemKinds = []InvoiceItemKind{InvoiceItemBasket, InvoiceItemShipping, InvoiceItemDiscount, InvoiceItemTax}
+
+// IsValid reports whether k is a known invoice line kind
+func (k InvoiceItemKind) IsValid() bool {
+	for _, kind := range InvoiceItemKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// InvoiceStatus is the payment state of an invoice, derived from the
+// payments of its order when it is read
+type InvoiceStatus string
+
+// Invoice states. Invoices of trial deliveries have nothing to pay.
+const (
+	InvoiceOpen     InvoiceStatus = "open"
+	InvoicePaid     InvoiceStatus = "paid"
+	InvoiceRefunded InvoiceStatus = "refunded"
+	InvoiceNoCharge InvoiceStatus = "no_charge"
+)
+
+// InvoiceStatuses lists every invoice state
+var InvoiceStatuses = []InvoiceStatus{InvoiceOpen, InvoicePaid, InvoiceRefunded, InvoiceNoCharge}
+
+// Invoice is the billing statement of one order, the delivery of one
+// subscription cycle. Sellers number their invoices sequentially. Seller and
+// consumer details are recorded as issued so the invoice can be rendered
+// again unchanged.
+type Invoice struct {
+	ID             uint      `json:"id" gorm:"primarykey"`
+	SellerID       uint      `json:"seller_id" gorm:"uniqueIndex:idx_invoices_seller_sequence,priority:1"`
+	Sequence       int       `json:"sequence" gorm:"uniqueIndex:idx_invoices_seller_sequence,priority:2"` // Per seller, starting at 1
+	Number         string    `json:"number"`                                                              // Sequence as printed, e.g. 000042
+	ConsumerID     uint      `json:"consumer_id" gorm:"index"`
+	SubscriptionID uint      `json:"subscription_id" gorm:"index"`
+	OrderID        uint      `json:"order_id" gorm:"uniqueIndex"`
+	PeriodStart    time.Time `json:"period_start"` // Subscription cycle billed, up to the next delivery
+	PeriodEnd      time.Time `json:"period_end"`
+	IssuedAt       time.Time `json:"issued_at"`
+	Locale         string    `json:"locale"` // Language the invoice is rendered in
+
+	// Totals. Total = Subtotal - Discount is what the order is charged; Tax
+	// is included in it.
+	Subtotal Money `json:"subtotal" gorm:"embedded;embeddedPrefix:subtotal_"`
+	Discount Money `json:"discount" gorm:"embedded;embeddedPrefix:discount_"`
+	Tax      Money `json:"tax" gorm:"embedded;embeddedPrefix:tax_"`
+	Total    Money `json:"total" gorm:"embedded;embeddedPrefix:total_"`
+
+	SellerName       string `json:"seller_name"`
+	SellerDocument   string `json:"seller_document,omitempty"` // CNPJ
+	SellerAddress    string `json:"seller_address,omitempty"`
+	ConsumerName     string `json:"consumer_name"`
+	ConsumerDocument string `json:"consumer_document,omitempty"` // CNPJ, or CPF
+	ConsumerEmail    string `json:"consumer_email"`
+	ConsumerAddress  string `json:"consumer_address,omitempty"`
+
+	Items []InvoiceItem `json:"items" gorm:"foreignKey:InvoiceID"`
+
+	// Payment state, filled in when the invoice is read
+	Status InvoiceStatus `json:"status" gorm:"-"`
+	PaidAt *time.Time    `json:"paid_at,omitempty" gorm:"-"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// InvoiceItem is one line of an invoice
+type InvoiceItem struct {
+	ID          uint            `json:"id" gorm:"primarykey"`
+	InvoiceID   uint            `json:"invoice_id" gorm:"index"`
+	Kind        InvoiceItemKind `json:"kind"`
+	Description string          `json:"description"`
+	Quantity    int             `json:"quantity"`
+	UnitPrice   Money           `json:"unit_price" gorm:"embedded;embeddedPrefix:unit_price_"`
+	Amount      Money           `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
+}
+
+// InvoiceNumber formats the sequence of an invoice as printed on it
+func InvoiceNumber(sequence int) string {
+	return fmt.Sprintf("%06d", sequence)
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 	"gorm.io/gorm"
 )
@@ -14,13 +15,20 @@ type User struct {
 	Name          string `json:"name"`
 	
 	// Business identification fields with validation
-	CNPJ          string `json:"cnpj,omitempty" gorm:"unique;index"`    // Only for sellers
+	CNPJ          string `json:"cnpj,omitempty" gorm:"unique;index"`    // Sellers, and consumers paying with boletos
 	CPF           string `json:"cpf,omitempty" gorm:"unique;index"`     // Only for consumers
 	
 	// Admin-specific fields
 	IsActive      bool   `json:"is_active" gorm:"default:true"` // For disabling admin accounts
 	Permissions   string `json:"permissions,omitempty"`         // JSON string of admin permissions
 	
+	// Contact for WhatsApp and SMS notifications, E.164 format
+	Phone string `json:"phone,omitempty"`
+	// Language of notifications (pt-BR or en), empty uses the configured default
+	Locale string `json:"locale,omitempty"`
+	// Customer ID at the payment provider, created with the first payment method
+	PaymentCustomerID string `json:"-"`
+
 	// Address fields
 	AddressStreet string `json:"address_street"`
 	AddressNumber string `json:"address_number"`
@@ -29,24 +37,97 @@ type User struct {
 	AddressZip    string `json:"address_zip"`
 }
 
+// PostalAddress returns the user's address on one line, e.g.
+// "Rua A, 10 - São Paulo/SP - CEP 01000-000"
+func (u User) PostalAddress() string {
+	var parts []string
+	if street := strings.Trim(u.AddressStreet+", "+u.AddressNumber, ", "); street != "" {
+		parts = append(parts, street)
+	}
+	if city := strings.Trim(u.AddressCity+"/"+u.AddressState, "/"); city != "" {
+		parts = append(parts, city)
+	}
+	if u.AddressZip != "" {
+		parts = append(parts, "CEP "+u.AddressZip)
+	}
+	return strings.Join(parts, " - ")
+}
+
 // Basket represents a product that sellers can offer
 type Basket struct {
 	gorm.Model
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	UserID      uint    `json:"seller_id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Price       Money  `json:"price" gorm:"embedded;embeddedPrefix:price_"` // Stored in centavos
+	UserID      uint   `json:"seller_id"`
 }
 
+// Subscription delivery frequencies
+const (
+	FrequencyWeekly   = "weekly"
+	FrequencyBiweekly = "biweekly"
+	FrequencyMonthly  = "monthly"
+)
+
+// Frequencies lists every subscription delivery frequency
+var Frequencies = []string{FrequencyWeekly, FrequencyBiweekly, FrequencyMonthly}
+
 // Subscription represents a recurring purchase of a basket by a consumer
 type Subscription struct {
 	gorm.Model
-	UserID    uint   `json:"user_id"`
-	User      User   `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	BasketID  uint   `json:"basket_id"`
-	Basket    Basket `json:"basket,omitempty" gorm:"foreignKey:BasketID"`
-	Frequency string `json:"frequency"`
-	Status    string `json:"status"`
+	UserID         uint       `json:"user_id"`
+	User           User       `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	BasketID       uint       `json:"basket_id"`
+	Basket         Basket     `json:"basket,omitempty" gorm:"foreignKey:BasketID"`
+	Frequency      string             `json:"frequency"`
+	Status         SubscriptionStatus `json:"status" gorm:"index"`
+	NextDeliveryAt *time.Time         `json:"next_delivery_at,omitempty" gorm:"index"` // Next order the scheduler will generate
+
+	// Lifecycle details
+	TrialEndsAt        *time.Time `json:"trial_ends_at,omitempty"`
+	PausedUntil        *time.Time `json:"paused_until,omitempty"` // Resumed automatically at this time, nil pauses indefinitely
+	CancelledAt        *time.Time `json:"cancelled_at,omitempty"`
+	CancellationReason string     `json:"cancellation_reason,omitempty"`
+
+	// Dunning of a failed recurring charge, set while the subscription is past due
+	PastDueAt       *time.Time `json:"past_due_at,omitempty"`      // When the first charge attempt failed
+	DunningAttempts int        `json:"dunning_attempts,omitempty"` // Failed charge attempts so far, the first one included
+	NextRetryAt     *time.Time `json:"next_retry_at,omitempty"`    // Next automatic retry, nil once retries are exhausted
+	DunningOrderID  uint       `json:"dunning_order_id,omitempty"` // Order whose charge failed
+
+	// Payment method charged for each order, the token is only known to the payment provider
+	PaymentMethod string `json:"payment_method,omitempty"`
+	PaymentToken  string `json:"-"`
+	CardBrand     string `json:"card_brand,omitempty"`
+	CardLast4     string `json:"card_last4,omitempty"`
+}
+
+// Subscription event actions
+const (
+	SubscriptionEventCreated     = "created"
+	SubscriptionEventPaused      = "paused"
+	SubscriptionEventResumed     = "resumed"
+	SubscriptionEventCancelled   = "cancelled"
+	SubscriptionEventSkipped     = "skipped_delivery"
+	SubscriptionEventTrialEnded  = "trial_ended"
+	SubscriptionEventPastDue     = "past_due"
+	SubscriptionEventRetryFailed = "payment_retry_failed"
+	SubscriptionEventRecovered   = "payment_recovered"
+)
+
+// SubscriptionEvent records a change to a subscription: who made it, what
+// changed and when. ActorID is 0 for changes made by the system.
+type SubscriptionEvent struct {
+	ID             uint               `json:"id" gorm:"primarykey"`
+	SubscriptionID uint               `json:"subscription_id" gorm:"index"`
+	ActorID        uint               `json:"actor_id,omitempty"`
+	Action         string             `json:"action"`
+	FromStatus     SubscriptionStatus `json:"from_status"`
+	ToStatus       SubscriptionStatus `json:"to_status"`
+	Reason         string             `json:"reason,omitempty"`
+	EffectiveUntil *time.Time         `json:"effective_until,omitempty"` // Pause end date
+	SkippedFor     *time.Time         `json:"skipped_for,omitempty"`     // Skipped delivery date
+	CreatedAt      time.Time          `json:"created_at"`
 }
 
 // Order represents a delivery of a subscription
@@ -54,8 +135,101 @@ type Order struct {
 	gorm.Model
 	SubscriptionID uint         `json:"subscription_id" gorm:"index"`
 	Subscription   Subscription `json:"subscription,omitempty" gorm:"foreignKey:SubscriptionID"`
-	Status         string       `json:"status" gorm:"default:'preparing'"` // "preparing", "shipped", "delivered"
+	Status         OrderStatus  `json:"status" gorm:"default:'preparing'"` // See order_status.go
 	TrackingCode   string       `json:"tracking_code,omitempty"`
+	ScheduledFor   *time.Time   `json:"scheduled_for,omitempty"` // Delivery date of orders generated by the scheduler
 	ShippedAt      *time.Time   `json:"shipped_at,omitempty"`
 	DeliveredAt    *time.Time   `json:"delivered_at,omitempty"`
-}
\ No newline at end of file
+}
+// Order event types
+const (
+	OrderEventCreated         = "created"
+	OrderEventStatusChanged   = "status_changed"
+	OrderEventTrackingUpdated = "tracking_updated"
+	OrderEventNote            = "note"
+	OrderEventPaid            = "paid"            // A card charge, Pix transfer or boleto paid the order
+	OrderEventPaymentOverdue  = "payment_overdue" // A boleto of the order expired unpaid
+)
+
+// OrderEvent records a change to an order for its delivery timeline.
+// ActorID is 0 for changes made by the system.
+type OrderEvent struct {
+	ID           uint        `json:"id" gorm:"primarykey"`
+	OrderID      uint        `json:"order_id" gorm:"index"`
+	ActorID      uint        `json:"actor_id,omitempty"`
+	Type         string      `json:"type"`
+	FromStatus   OrderStatus `json:"from_status,omitempty"`
+	ToStatus     OrderStatus `json:"to_status,omitempty"`
+	TrackingCode string      `json:"tracking_code,omitempty"`
+	Note         string      `json:"note,omitempty"`
+	CreatedAt    time.Time   `json:"created_at"`
+}
+
+// Supported notification languages
+const (
+	LocalePortuguese = "pt-BR"
+	LocaleEnglish    = "en"
+)
+
+// Locales lists every supported notification language
+var Locales = []string{LocalePortuguese, LocaleEnglish}
+
+// Notification channels
+const (
+	ChannelEmail    = "email"
+	ChannelWebhook  = "webhook"
+	ChannelWhatsApp = "whatsapp"
+	ChannelSMS      = "sms"
+	ChannelLog      = "log"
+)
+
+// NotificationChannels lists every notification channel
+var NotificationChannels = []string{ChannelEmail, ChannelWebhook, ChannelWhatsApp, ChannelSMS, ChannelLog}
+
+// NotificationPreference enables or disables a channel for a user. Users
+// without stored preferences receive the configured default channels.
+type NotificationPreference struct {
+	ID        uint      `json:"-" gorm:"primarykey"`
+	UserID    uint      `json:"user_id" gorm:"uniqueIndex:idx_notification_preferences_user_channel"`
+	Channel   string    `json:"channel" gorm:"uniqueIndex:idx_notification_preferences_user_channel"`
+	Enabled   bool      `json:"enabled"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Notification delivery results
+const (
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+	DeliverySkipped = "skipped" // Channel not configured or user has no address for it
+)
+
+// NotificationDelivery records the result of sending one notification on one channel
+type NotificationDelivery struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	UserID    uint      `json:"user_id" gorm:"index"`
+	Channel   string    `json:"channel"`
+	Event     string    `json:"event"`
+	Subject   string    `json:"subject"`
+	Status    string    `json:"status" gorm:"index"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RefreshToken represents a long-lived token used to obtain new access tokens.
+// Only the SHA-256 hash of the token is stored.
+type RefreshToken struct {
+	gorm.Model
+	UserID     uint       `json:"user_id" gorm:"index"`
+	TokenHash  string     `json:"-" gorm:"uniqueIndex"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	ReplacedBy uint       `json:"replaced_by,omitempty"` // ID of the token issued on rotation
+}
+
+// RevokedToken records an access token invalidated before its expiry
+type RevokedToken struct {
+	ID        uint      `gorm:"primarykey"`
+	TokenID   string    `gorm:"uniqueIndex"` // The "jti" claim of the access token
+	ExpiresAt time.Time `gorm:"index"`
+	CreatedAt time.Time
+}
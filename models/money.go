@@ -0,0 +1,364 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is used when an amount is given without a currency
+const DefaultCurrency = "BRL"
+
+// ErrCurrencyMismatch is returned when combining amounts in different currencies
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// ErrInvalidMoney is returned when an amount cannot be parsed
+var ErrInvalidMoney = errors.New("invalid money amount")
+
+// ErrMoneyOverflow is returned when arithmetic leaves the int64 range of minor units
+var ErrMoneyOverflow = errors.New("money amount out of range")
+
+// decimalPattern is the accepted format of ParseMoney, a plain decimal number
+var decimalPattern = regexp.MustCompile(`^-?\d+(\.\d+)?$`)
+
+// minorUnits is the number of decimal places of each supported currency
+var minorUnits = map[string]int{
+	"BRL": 2,
+	"USD": 2,
+	"EUR": 2,
+}
+
+// Money is an amount in the minor unit of its currency (centavos for BRL).
+// It is stored in two columns, <prefix>amount and <prefix>currency.
+type Money struct {
+	Amount   int64  `gorm:"column:amount"`
+	Currency string `gorm:"column:currency;size:3"`
+}
+
+// NewMoney creates an amount from minor units
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+// BRL creates an amount in centavos
+func BRL(centavos int64) Money {
+	return NewMoney(centavos, "BRL")
+}
+
+// ParseMoney parses a decimal string in major units ("1234.56") into Money.
+// Extra decimal places are rounded half to even. Fractions ("1/3"),
+// exponents ("1e3") and other bases ("0x10") are rejected.
+func ParseMoney(value string, currency string) (Money, error) {
+	currency = strings.ToUpper(currency)
+	digits, ok := minorUnits[currency]
+	if !ok {
+		return Money{}, fmt.Errorf("%w: unsupported currency %q", ErrInvalidMoney, currency)
+	}
+
+	value = strings.TrimSpace(value)
+	if !decimalPattern.MatchString(value) {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidMoney, value)
+	}
+	rat, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidMoney, value)
+	}
+
+	scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil))
+	rat.Mul(rat, scale)
+
+	amount, err := roundHalfEven(rat.Num(), rat.Denom())
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// Add returns m + other
+func (m Money) Add(other Money) (Money, error) {
+	if err := m.sameCurrency(other); err != nil {
+		return Money{}, err
+	}
+	sum := m.Amount + other.Amount
+	if (other.Amount > 0 && sum < m.Amount) || (other.Amount < 0 && sum > m.Amount) {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+// Sub returns m - other
+func (m Money) Sub(other Money) (Money, error) {
+	if err := m.sameCurrency(other); err != nil {
+		return Money{}, err
+	}
+	difference := m.Amount - other.Amount
+	if (other.Amount > 0 && difference > m.Amount) || (other.Amount < 0 && difference < m.Amount) {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: difference, Currency: m.Currency}, nil
+}
+
+// Mul returns m multiplied by a whole quantity
+func (m Money) Mul(quantity int64) (Money, error) {
+	product := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(quantity))
+	if !product.IsInt64() {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: product.Int64(), Currency: m.Currency}, nil
+}
+
+// Allocate splits m into parts proportional to ratios without losing minor
+// units. Each part is rounded toward zero and the remainder is handed out
+// one minor unit at a time from the first part with a non-zero ratio, so
+// R$ 0,05 allocated 1:1 gives R$ 0,03 and R$ 0,02.
+func (m Money) Allocate(ratios ...int64) ([]Money, error) {
+	var total int64
+	for _, ratio := range ratios {
+		if ratio < 0 {
+			return nil, errors.New("money: negative allocation ratio")
+		}
+		if ratio > math.MaxInt64-total {
+			return nil, ErrMoneyOverflow
+		}
+		total += ratio
+	}
+	if total == 0 {
+		return nil, errors.New("money: allocation ratios must not all be zero")
+	}
+
+	parts := make([]Money, len(ratios))
+	remainder := m.Amount
+	for i, ratio := range ratios {
+		share := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(ratio))
+		share.Quo(share, big.NewInt(total))
+		parts[i] = Money{Amount: share.Int64(), Currency: m.Currency}
+		remainder -= parts[i].Amount
+	}
+
+	step := int64(1)
+	if remainder < 0 {
+		step = -1
+	}
+	for i := 0; remainder != 0; i++ {
+		if ratios[i] == 0 {
+			continue
+		}
+		parts[i].Amount += step
+		remainder -= step
+	}
+	return parts, nil
+}
+
+// Split divides m into n parts as even as possible, the first parts taking
+// the remainder
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, errors.New("money: split into fewer than one part")
+	}
+	ratios := make([]int64, n)
+	for i := range ratios {
+		ratios[i] = 1
+	}
+	return m.Allocate(ratios...)
+}
+
+// Scale returns m * numerator / denominator rounded half to even,
+// e.g. Scale(15, 100) for a 15% discount
+func (m Money) Scale(numerator, denominator int64) (Money, error) {
+	if denominator == 0 {
+		return Money{}, errors.New("money: division by zero")
+	}
+	num := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(numerator))
+	amount, err := roundHalfEven(num, big.NewInt(denominator))
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: amount, Currency: m.Currency}, nil
+}
+
+// Negate returns -m
+func (m Money) Negate() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsPositive reports whether the amount is greater than zero
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+// Cmp compares two amounts in the same currency, returning -1, 0 or 1
+func (m Money) Cmp(other Money) (int, error) {
+	if err := m.sameCurrency(other); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// Decimal returns the amount in major units without a currency symbol ("1234.56")
+func (m Money) Decimal() string {
+	digits := m.digits()
+	s, negative := strings.CutPrefix(strconv.FormatInt(m.Amount, 10), "-")
+	if digits > 0 {
+		for len(s) <= digits {
+			s = "0" + s
+		}
+		s = s[:len(s)-digits] + "." + s[len(s)-digits:]
+	}
+	if negative {
+		s = "-" + s
+	}
+	return s
+}
+
+// Format renders the amount for display. BRL uses the Brazilian
+// convention (R$ 1.234,56); other currencies use the ISO code (USD 1,234.56).
+func (m Money) Format() string {
+	decimal := strings.TrimPrefix(m.Decimal(), "-")
+	whole, fraction, _ := strings.Cut(decimal, ".")
+
+	thousands, separator, symbol := ",", ".", m.Currency+" "
+	if m.Currency == "BRL" {
+		thousands, separator, symbol = ".", ",", "R$ "
+	}
+
+	var grouped strings.Builder
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteString(thousands)
+		}
+		grouped.WriteRune(r)
+	}
+
+	s := symbol + grouped.String()
+	if fraction != "" {
+		s += separator + fraction
+	}
+	if m.Amount < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+// String implements fmt.Stringer
+func (m Money) String() string {
+	return m.Format()
+}
+
+// moneyJSON is the wire format of Money
+type moneyJSON struct {
+	Amount    int64  `json:"amount"`
+	Currency  string `json:"currency"`
+	Formatted string `json:"formatted,omitempty"`
+}
+
+// MarshalJSON encodes the amount in minor units together with its currency
+// and a display string: {"amount": 123456, "currency": "BRL", "formatted": "R$ 1.234,56"}
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.Amount, Currency: m.Currency, Formatted: m.Format()})
+}
+
+// UnmarshalJSON accepts an integer in minor units (123456), a decimal string
+// in major units ("1234.56") or the object produced by MarshalJSON.
+// Amounts without a currency default to DefaultCurrency.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	trimmed := strings.TrimSpace(string(data))
+
+	switch {
+	case trimmed == "null":
+		return nil
+
+	case strings.HasPrefix(trimmed, "{"):
+		var wire moneyJSON
+		if err := json.Unmarshal(data, &wire); err != nil {
+			return err
+		}
+		if wire.Currency == "" {
+			wire.Currency = DefaultCurrency
+		}
+		if _, ok := minorUnits[strings.ToUpper(wire.Currency)]; !ok {
+			return fmt.Errorf("%w: unsupported currency %q", ErrInvalidMoney, wire.Currency)
+		}
+		*m = NewMoney(wire.Amount, wire.Currency)
+		return nil
+
+	case strings.HasPrefix(trimmed, `"`):
+		var decimal string
+		if err := json.Unmarshal(data, &decimal); err != nil {
+			return err
+		}
+		parsed, err := ParseMoney(decimal, DefaultCurrency)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+
+	default:
+		amount, err := strconv.ParseInt(trimmed, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: numbers must be integer minor units, got %s", ErrInvalidMoney, trimmed)
+		}
+		*m = NewMoney(amount, DefaultCurrency)
+		return nil
+	}
+}
+
+// digits returns the number of minor unit digits of the currency
+func (m Money) digits() int {
+	if digits, ok := minorUnits[m.Currency]; ok {
+		return digits
+	}
+	return 2
+}
+
+// sameCurrency returns ErrCurrencyMismatch if the currencies differ
+func (m Money) sameCurrency(other Money) error {
+	if m.Currency != other.Currency {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	return nil
+}
+
+// roundHalfEven divides num by denom rounding ties to the nearest even integer
+func roundHalfEven(num, denom *big.Int) (int64, error) {
+	if denom.Sign() < 0 {
+		num = new(big.Int).Neg(num)
+		denom = new(big.Int).Neg(denom)
+	}
+
+	quotient, remainder := new(big.Int).QuoRem(num, denom, new(big.Int))
+
+	// Compare twice the remainder with the divisor to find ties
+	twice := new(big.Int).Abs(remainder)
+	twice.Mul(twice, big.NewInt(2))
+	switch twice.Cmp(denom) {
+	case 1:
+		quotient.Add(quotient, big.NewInt(int64(num.Sign())))
+	case 0:
+		if quotient.Bit(0) == 1 {
+			quotient.Add(quotient, big.NewInt(int64(num.Sign())))
+		}
+	}
+
+	if !quotient.IsInt64() {
+		return 0, ErrMoneyOverflow
+	}
+	return quotient.Int64(), nil
+}
@@ -0,0 +1,272 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		value    string
+		currency string
+		want     int64
+	}{
+		{"1234.56", "BRL", 123456},
+		{"0", "BRL", 0},
+		{"7", "brl", 700},
+		{" 0.1 ", "USD", 10},
+		{"-12.30", "EUR", -1230},
+		{"0.125", "BRL", 12},   // Tie rounds down to even
+		{"0.135", "BRL", 14},   // Tie rounds up to even
+		{"-0.125", "BRL", -12}, // Negative ties round to even too
+		{"-0.135", "BRL", -14},
+		{"0.1251", "BRL", 13},
+		{"-0.1249", "BRL", -12},
+		{"-0.005", "BRL", 0},
+		{"92233720368547758.07", "BRL", math.MaxInt64},
+		{"-92233720368547758.08", "BRL", math.MinInt64},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.value, tt.currency)
+		if err != nil {
+			t.Errorf("ParseMoney(%q, %s): %v", tt.value, tt.currency, err)
+			continue
+		}
+		if got.Amount != tt.want {
+			t.Errorf("ParseMoney(%q, %s) = %d, want %d", tt.value, tt.currency, got.Amount, tt.want)
+		}
+	}
+}
+
+func TestParseMoneyRejects(t *testing.T) {
+	tests := []struct {
+		value    string
+		currency string
+		err      error
+	}{
+		{"1e3", "BRL", ErrInvalidMoney},
+		{"0x10", "BRL", ErrInvalidMoney},
+		{"1/3", "BRL", ErrInvalidMoney},
+		{"", "BRL", ErrInvalidMoney},
+		{"1.", "BRL", ErrInvalidMoney},
+		{".5", "BRL", ErrInvalidMoney},
+		{"+1", "BRL", ErrInvalidMoney},
+		{"1,50", "BRL", ErrInvalidMoney},
+		{"--1", "BRL", ErrInvalidMoney},
+		{"NaN", "BRL", ErrInvalidMoney},
+		{"Inf", "BRL", ErrInvalidMoney},
+		{"1.00", "XYZ", ErrInvalidMoney},
+		{"92233720368547758.08", "BRL", ErrMoneyOverflow},
+		{"-92233720368547758.09", "BRL", ErrMoneyOverflow},
+	}
+	for _, tt := range tests {
+		if _, err := ParseMoney(tt.value, tt.currency); !errors.Is(err, tt.err) {
+			t.Errorf("ParseMoney(%q, %s) error = %v, want %v", tt.value, tt.currency, err, tt.err)
+		}
+	}
+}
+
+func TestMoneyArithmeticOverflow(t *testing.T) {
+	maxBRL, minBRL := BRL(math.MaxInt64), BRL(math.MinInt64)
+	tests := []struct {
+		name     string
+		op       func() (Money, error)
+		want     int64 // Ignored when overflow is set
+		overflow bool
+	}{
+		{"add max", func() (Money, error) { return maxBRL.Add(BRL(0)) }, math.MaxInt64, false},
+		{"add past max", func() (Money, error) { return maxBRL.Add(BRL(1)) }, 0, true},
+		{"add past min", func() (Money, error) { return minBRL.Add(BRL(-1)) }, 0, true},
+		{"add opposite ends", func() (Money, error) { return maxBRL.Add(minBRL) }, -1, false},
+		{"sub to min", func() (Money, error) { return BRL(-1).Sub(maxBRL) }, math.MinInt64, false},
+		{"sub past min", func() (Money, error) { return minBRL.Sub(BRL(1)) }, 0, true},
+		{"sub past max", func() (Money, error) { return maxBRL.Sub(BRL(-1)) }, 0, true},
+		{"sub min from zero", func() (Money, error) { return BRL(0).Sub(minBRL) }, 0, true},
+		{"mul max by one", func() (Money, error) { return maxBRL.Mul(1) }, math.MaxInt64, false},
+		{"mul max by two", func() (Money, error) { return maxBRL.Mul(2) }, 0, true},
+		{"mul min by minus one", func() (Money, error) { return minBRL.Mul(-1) }, 0, true},
+		{"mul by minus one", func() (Money, error) { return maxBRL.Mul(-1) }, -math.MaxInt64, false},
+		{"scale max down and up", func() (Money, error) { return maxBRL.Scale(2, 2) }, math.MaxInt64, false},
+		{"scale max up", func() (Money, error) { return maxBRL.Scale(3, 2) }, 0, true},
+		{"scale min negated", func() (Money, error) { return minBRL.Scale(-1, 1) }, 0, true},
+		{"scale min by a negative denominator", func() (Money, error) { return minBRL.Scale(1, -1) }, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := tt.op()
+		switch {
+		case tt.overflow && !errors.Is(err, ErrMoneyOverflow):
+			t.Errorf("%s: error = %v, want ErrMoneyOverflow", tt.name, err)
+		case !tt.overflow && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case !tt.overflow && got != BRL(tt.want):
+			t.Errorf("%s = %v, want %d", tt.name, got.Amount, tt.want)
+		}
+	}
+}
+
+func TestMoneyCurrencyMismatch(t *testing.T) {
+	usd := NewMoney(100, "usd")
+	if _, err := BRL(100).Add(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Add error = %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := BRL(100).Sub(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Sub error = %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := BRL(100).Cmp(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Cmp error = %v, want ErrCurrencyMismatch", err)
+	}
+}
+
+func TestMoneyScale(t *testing.T) {
+	tests := []struct {
+		amount                 int64
+		numerator, denominator int64
+		want                   int64
+	}{
+		{12990, 15, 100, 1948}, // 1948.5, tie to even
+		{12970, 15, 100, 1946}, // 1945.5, tie to even
+		{-12990, 15, 100, -1948},
+		{1, 1, 2, 0},
+		{3, 1, 2, 2},
+		{3, 1, -2, -2}, // Negative denominator
+		{-3, -1, -2, -2},
+		{1000, 1, 3, 333},
+		{2000, 1, 3, 667},
+	}
+	for _, tt := range tests {
+		got, err := BRL(tt.amount).Scale(tt.numerator, tt.denominator)
+		if err != nil || got.Amount != tt.want {
+			t.Errorf("BRL(%d).Scale(%d, %d) = %d, %v, want %d", tt.amount, tt.numerator, tt.denominator, got.Amount, err, tt.want)
+		}
+	}
+
+	if _, err := BRL(1).Scale(1, 0); err == nil {
+		t.Error("Scale(1, 0) did not fail")
+	}
+}
+
+func TestMoneyAllocate(t *testing.T) {
+	tests := []struct {
+		amount int64
+		ratios []int64
+		want   []int64
+	}{
+		{5, []int64{1, 1}, []int64{3, 2}},
+		{100, []int64{1, 1, 1}, []int64{34, 33, 33}},
+		{-5, []int64{1, 1}, []int64{-3, -2}},
+		{-100, []int64{1, 1, 1}, []int64{-34, -33, -33}},
+		{5, []int64{0, 1, 1}, []int64{0, 3, 2}}, // The remainder skips zero ratios
+		{-5, []int64{0, 0, 1, 1}, []int64{0, 0, -3, -2}},
+		{7, []int64{1, 0}, []int64{7, 0}},
+		{0, []int64{1, 2}, []int64{0, 0}},
+		{1000, []int64{70, 20, 10}, []int64{700, 200, 100}},
+		{1, []int64{1, 1, 1}, []int64{1, 0, 0}},
+		{math.MaxInt64, []int64{1, 1}, []int64{math.MaxInt64/2 + 1, math.MaxInt64 / 2}},
+		{math.MinInt64, []int64{1, 1}, []int64{math.MinInt64 / 2, math.MinInt64 / 2}},
+		{math.MaxInt64, []int64{math.MaxInt64 - 1, 1}, []int64{math.MaxInt64 - 1, 1}},
+	}
+	for _, tt := range tests {
+		parts, err := BRL(tt.amount).Allocate(tt.ratios...)
+		if err != nil {
+			t.Errorf("BRL(%d).Allocate(%v): %v", tt.amount, tt.ratios, err)
+			continue
+		}
+		got := make([]int64, len(parts))
+		for i, part := range parts {
+			got[i] = part.Amount
+			if part.Currency != "BRL" {
+				t.Errorf("BRL(%d).Allocate(%v) part %d in %s", tt.amount, tt.ratios, i, part.Currency)
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("BRL(%d).Allocate(%v) = %v, want %v", tt.amount, tt.ratios, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyAllocateRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		ratios   []int64
+		overflow bool
+	}{
+		{"no ratios", nil, false},
+		{"all zero", []int64{0, 0}, false},
+		{"negative", []int64{1, -1}, false},
+		{"ratios overflow", []int64{math.MaxInt64, 1}, true},
+	}
+	for _, tt := range tests {
+		_, err := BRL(100).Allocate(tt.ratios...)
+		if err == nil {
+			t.Errorf("%s: Allocate(%v) did not fail", tt.name, tt.ratios)
+		}
+		if tt.overflow != errors.Is(err, ErrMoneyOverflow) {
+			t.Errorf("%s: Allocate(%v) error = %v", tt.name, tt.ratios, err)
+		}
+	}
+}
+
+func TestMoneySplit(t *testing.T) {
+	parts, err := BRL(-10).Split(3)
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	if want := []Money{BRL(-4), BRL(-3), BRL(-3)}; !slices.Equal(parts, want) {
+		t.Errorf("Split = %v, want %v", parts, want)
+	}
+	if _, err := BRL(10).Split(0); err == nil {
+		t.Error("Split(0) did not fail")
+	}
+}
+
+func TestMoneyFormat(t *testing.T) {
+	tests := []struct {
+		money   Money
+		decimal string
+		format  string
+	}{
+		{BRL(123456), "1234.56", "R$ 1.234,56"},
+		{BRL(-5), "-0.05", "-R$ 0,05"},
+		{BRL(0), "0.00", "R$ 0,00"},
+		{BRL(100000000), "1000000.00", "R$ 1.000.000,00"},
+		{NewMoney(123456, "usd"), "1234.56", "USD 1,234.56"},
+		{BRL(math.MinInt64), "-92233720368547758.08", "-R$ 92.233.720.368.547.758,08"},
+	}
+	for _, tt := range tests {
+		if got := tt.money.Decimal(); got != tt.decimal {
+			t.Errorf("Decimal(%d) = %s, want %s", tt.money.Amount, got, tt.decimal)
+		}
+		if got := tt.money.Format(); got != tt.format {
+			t.Errorf("Format(%d) = %s, want %s", tt.money.Amount, got, tt.format)
+		}
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Money
+	}{
+		{`12990`, BRL(12990)},
+		{`"129.90"`, BRL(12990)},
+		{`"0.125"`, BRL(12)},
+		{`{"amount": 500, "currency": "usd"}`, NewMoney(500, "USD")},
+		{`{"amount": 500}`, BRL(500)},
+	}
+	for _, tt := range tests {
+		var got Money
+		if err := json.Unmarshal([]byte(tt.input), &got); err != nil || got != tt.want {
+			t.Errorf("Unmarshal(%s) = %+v, %v, want %+v", tt.input, got, err, tt.want)
+		}
+	}
+
+	for _, input := range []string{`129.9`, `1e3`, `"1e3"`, `"0x10"`, `{"amount": 1, "currency": "XYZ"}`} {
+		var got Money
+		if err := json.Unmarshal([]byte(input), &got); !errors.Is(err, ErrInvalidMoney) {
+			t.Errorf("Unmarshal(%s) error = %v, want ErrInvalidMoney", input, err)
+		}
+	}
+}
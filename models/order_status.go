@@ -0,0 +1,79 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// OrderStatus is the fulfilment state of an order
+type OrderStatus string
+
+// Order states
+const (
+	OrderPreparing      OrderStatus = "preparing"       // Being assembled by the seller
+	OrderShipped        OrderStatus = "shipped"         // Handed to the carrier
+	OrderDelivered      OrderStatus = "delivered"       // Received by the consumer
+	OrderFailedDelivery OrderStatus = "failed_delivery" // The carrier could not deliver, may be reshipped
+	OrderReturned       OrderStatus = "returned"        // Sent back to the seller, terminal
+	OrderCancelled      OrderStatus = "cancelled"       // Cancelled before shipping, terminal
+)
+
+// ErrInvalidOrderTransition is returned when an order cannot move to the requested status
+var ErrInvalidOrderTransition = errors.New("invalid order status transition")
+
+// orderTransitions lists the statuses each status may move to
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderPreparing:      {OrderShipped, OrderCancelled},
+	OrderShipped:        {OrderDelivered, OrderFailedDelivery, OrderReturned},
+	OrderFailedDelivery: {OrderShipped, OrderReturned, OrderCancelled},
+	OrderDelivered:      {OrderReturned},
+	OrderReturned:       {},
+	OrderCancelled:      {},
+}
+
+// OrderStatuses lists every order status
+var OrderStatuses = []OrderStatus{
+	OrderPreparing, OrderShipped, OrderDelivered, OrderFailedDelivery, OrderReturned, OrderCancelled,
+}
+
+// IsValid reports whether s is a known status
+func (s OrderStatus) IsValid() bool {
+	_, ok := orderTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether an order may move from s to next
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal reports whether no further transitions are possible
+func (s OrderStatus) IsFinal() bool {
+	return len(orderTransitions[s]) == 0
+}
+
+// TransitionTo moves the order to next, recording the shipping and delivery
+// times. Every status change must go through here.
+func (o *Order) TransitionTo(next OrderStatus, now time.Time) error {
+	if !next.IsValid() {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidOrderTransition, next)
+	}
+	if !o.Status.CanTransitionTo(next) {
+		return fmt.Errorf("%w: cannot go from %s to %s", ErrInvalidOrderTransition, o.Status, next)
+	}
+
+	o.Status = next
+	switch next {
+	case OrderShipped:
+		o.ShippedAt = &now
+	case OrderDelivered:
+		o.DeliveredAt = &now
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"time"
+)
+
+// JobStatus is the state of an outbox job
+type JobStatus string
+
+// Outbox job states. Failed jobs go back to pending with a later run_at until
+// they run out of attempts and become dead.
+const (
+	JobPending JobStatus = "pending"
+	JobRunning JobStatus = "running"
+	JobDone    JobStatus = "done"
+	JobDead    JobStatus = "dead"
+)
+
+// JobStatuses lists every job state
+var JobStatuses = []JobStatus{JobPending, JobRunning, JobDone, JobDead}
+
+// IsValid reports whether s is a known job state
+func (s JobStatus) IsValid() bool {
+	for _, status := range JobStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// OutboxJob is background work recorded in the same transaction as the change
+// that caused it, so it survives crashes and restarts
+type OutboxJob struct {
+	ID          uint       `json:"id" gorm:"primarykey"`
+	Kind        string     `json:"kind" gorm:"index"`
+	Payload     RawJSON    `json:"payload" gorm:"type:jsonb"`
+	Status      JobStatus  `json:"status" gorm:"index:idx_outbox_jobs_status_run_at,priority:1"`
+	Attempts    int        `json:"attempts"`
+	RunAt       time.Time  `json:"run_at" gorm:"index:idx_outbox_jobs_status_run_at,priority:2"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"` // Lease of the worker running the job
+	LastError   string     `json:"last_error,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// RawJSON is a JSON document stored as-is in a jsonb column
+type RawJSON []byte
+
+// Value implements driver.Valuer
+func (j RawJSON) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return "null", nil
+	}
+	return string(j), nil
+}
+
+// Scan implements sql.Scanner
+func (j *RawJSON) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*j = nil
+	case []byte:
+		*j = append(RawJSON(nil), v...)
+	case string:
+		*j = RawJSON(v)
+	default:
+		return errors.New("RawJSON: unsupported column type")
+	}
+	return nil
+}
+
+// MarshalJSON embeds the document unchanged
+func (j RawJSON) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+// UnmarshalJSON stores a copy of the document
+func (j *RawJSON) UnmarshalJSON(data []byte) error {
+	*j = append(RawJSON(nil), data...)
+	return nil
+}
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// PaymentStatus is the state of a charge attempt
+type PaymentStatus string
+
+// Payment states. Pending payments are waiting for the provider's answer,
+// either because the call failed and will be retried or because the
+// provider confirms asynchronously.
+const (
+	PaymentPending   PaymentStatus = "pending"
+	PaymentSucceeded PaymentStatus = "succeeded"
+	PaymentFailed    PaymentStatus = "failed"
+	PaymentRefunded  PaymentStatus = "refunded"
+)
+
+// PaymentStatuses lists every payment state
+var PaymentStatuses = []PaymentStatus{PaymentPending, PaymentSucceeded, PaymentFailed, PaymentRefunded}
+
+// IsValid reports whether s is a known payment state
+func (s PaymentStatus) IsValid() bool {
+	for _, status := range PaymentStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Payment methods
+const (
+	PaymentMethodCard   = "card"
+	PaymentMethodPix    = "pix"
+	PaymentMethodBoleto = "boleto"
+)
+
+// Payment records one attempt to charge the subscriber for an order. A
+// declined order gets a new payment for every retry.
+type Payment struct {
+	ID             uint          `json:"id" gorm:"primarykey"`
+	OrderID        uint          `json:"order_id" gorm:"index"`
+	SubscriptionID uint          `json:"subscription_id" gorm:"index"`
+	Provider       string        `json:"provider" gorm:"index:idx_payments_provider_charge_id,priority:1"`
+	Method         string        `json:"method,omitempty"`
+	ChargeID       string        `json:"charge_id,omitempty" gorm:"index:idx_payments_provider_charge_id,priority:2"` // ID of the charge at the provider, the txid for Pix, the nosso número for boletos
+	Amount         Money         `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
+	Status         PaymentStatus `json:"status" gorm:"index"`
+	Attempt        int           `json:"attempt"`
+	FailureCode    string        `json:"failure_code,omitempty"` // e.g. card_declined, insufficient_funds
+	FailureMessage string        `json:"failure_message,omitempty"`
+	PaidAt         *time.Time    `json:"paid_at,omitempty"`
+	RefundedAt     *time.Time    `json:"refunded_at,omitempty"`
+	EndToEndID     string        `json:"end_to_end_id,omitempty"` // ID of the Pix transfer that paid it
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
@@ -0,0 +1,51 @@
+package models
+
+// SubscriptionStatus is the lifecycle state of a subscription
+type SubscriptionStatus string
+
+// Subscription states
+const (
+	SubscriptionTrialing  SubscriptionStatus = "trialing"  // Receiving deliveries before the first charge
+	SubscriptionActive    SubscriptionStatus = "active"    // Receiving deliveries
+	SubscriptionPaused    SubscriptionStatus = "paused"    // No deliveries until resumed or paused_until
+	SubscriptionPastDue   SubscriptionStatus = "past_due"  // A charge failed, deliveries are on hold
+	SubscriptionCancelled SubscriptionStatus = "cancelled" // Terminal
+)
+
+// subscriptionTransitions lists the states each state may move to
+var subscriptionTransitions = map[SubscriptionStatus][]SubscriptionStatus{
+	SubscriptionTrialing:  {SubscriptionActive, SubscriptionPaused, SubscriptionCancelled},
+	SubscriptionActive:    {SubscriptionPaused, SubscriptionPastDue, SubscriptionCancelled},
+	SubscriptionPaused:    {SubscriptionTrialing, SubscriptionActive, SubscriptionPastDue, SubscriptionCancelled},
+	SubscriptionPastDue:   {SubscriptionActive, SubscriptionPaused, SubscriptionCancelled},
+	SubscriptionCancelled: {},
+}
+
+// SubscriptionStatuses lists every subscription state
+var SubscriptionStatuses = []SubscriptionStatus{
+	SubscriptionTrialing, SubscriptionActive, SubscriptionPaused, SubscriptionPastDue, SubscriptionCancelled,
+}
+
+// IsValid reports whether s is a known state
+func (s SubscriptionStatus) IsValid() bool {
+	_, ok := subscriptionTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether a subscription may move from s to next
+func (s SubscriptionStatus) CanTransitionTo(next SubscriptionStatus) bool {
+	for _, allowed := range subscriptionTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// ReceivesDeliveries reports whether the scheduler generates orders in this state
+func (s SubscriptionStatus) ReceivesDeliveries() bool {
+	return s == SubscriptionTrialing || s == SubscriptionActive
+}
+
+// DeliveringStatuses are the states in which orders are generated
+var DeliveringStatuses = []SubscriptionStatus{SubscriptionTrialing, SubscriptionActive}
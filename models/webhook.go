@@ -0,0 +1,39 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// WebhookEndpoint is a seller URL receiving signed event notifications
+type WebhookEndpoint struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	UserID      uint      `json:"seller_id" gorm:"index"`
+	URL         string    `json:"url"`
+	Description string    `json:"description,omitempty"`
+	Events      []string  `json:"events" gorm:"serializer:json"` // Event types delivered to this endpoint
+	Secret      string    `json:"-"`                             // HMAC-SHA256 signing key, shown once on creation
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Subscribes reports whether the endpoint receives events of the given type
+func (e WebhookEndpoint) Subscribes(eventType string) bool {
+	return e.Active && slices.Contains(e.Events, eventType)
+}
+
+// WebhookDelivery records one attempt to deliver an event to an endpoint
+type WebhookDelivery struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	EndpointID   uint      `json:"endpoint_id" gorm:"index"`
+	EventID      string    `json:"event_id" gorm:"index"`
+	Event        string    `json:"event"`
+	Attempt      int       `json:"attempt"`
+	StatusCode   int       `json:"status_code,omitempty"`
+	Success      bool      `json:"success"`
+	Error        string    `json:"error,omitempty"`
+	ResponseBody string    `json:"-"` // Truncated, kept for operators and never returned to the seller
+	DurationMS   int64     `json:"duration_ms"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -40,7 +40,7 @@ def generate_mock_data():
                 "seller_id": seller_id,
                 "name": f"Kit {fake.word().capitalize()}",
                 "description": fake.sentence(nb_words=12),
-                "price": round(random.uniform(49.90, 199.90), 2)
+                "price": f"{random.uniform(49.90, 199.90):.2f}"
             })
 
     # --- 2. Consumers (IDs 10-50) ---
@@ -75,8 +75,8 @@ def generate_mock_data():
                 "id": subscription_id,
                 "user_id": consumer["id"],
                 "basket_id": basket["id"],
-                "frequency": fake.random_element(elements=("semanal", "quinzenal", "mensal")),
-                "status": fake.random_element(elements=("ativo", "pausado", "cancelado"))
+                "frequency": fake.random_element(elements=("weekly", "biweekly", "monthly")),
+                "status": fake.random_element(elements=("active", "paused", "cancelled"))
             })
             subscription_id += 1
 